@@ -0,0 +1,471 @@
+// cmd/analyzer/commands.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/internal/report"
+)
+
+var compareCmd = &command{
+	name:    "compare",
+	summary: "Compare two saved JSON results and write a comparison report",
+	usage:   "compare [flags] <before.json> <after.json>",
+	examples: []string{
+		"fn-analyzer compare performance-before_fixes-20250101-120000.json performance-after_fixes-20250102-120000.json",
+		"fn-analyzer compare --output ./comparisons before.json after.json",
+		"fn-analyzer compare results/before_fixes-20250101-120000 results/after_fixes-20250102-120000",
+	},
+}
+
+var checkSLACmd = &command{
+	name:    "check-sla",
+	summary: "Check a saved JSON result against a file of per-query p95 budgets",
+	usage:   "check-sla [flags] <result.json> <slas.json>",
+	examples: []string{
+		"fn-analyzer check-sla performance-nightly-20250101-120000.json slas.json",
+		"fn-analyzer check-sla --output ./sla-checks results/nightly-20250101-120000 slas.json",
+	},
+}
+
+var trendCmd = &command{
+	name:    "trend",
+	summary: "Report per-query latency across the most recent saved results",
+	usage:   "trend [flags]",
+	examples: []string{
+		"fn-analyzer trend",
+		"fn-analyzer trend --runs 20 --dir ./nightly-results",
+	},
+}
+
+var validateCmd = &command{
+	name:    "validate",
+	summary: "Validate the config and queries file without connecting to the database",
+	usage:   "validate [flags]",
+	examples: []string{
+		"fn-analyzer validate",
+		"fn-analyzer validate --queries 'queries/*.json'",
+	},
+}
+
+var captureCmd = &command{
+	name:    "capture",
+	summary: "Capture a snapshot of server status metrics to a JSON file",
+	usage:   "capture [flags]",
+	examples: []string{
+		"fn-analyzer capture",
+		"fn-analyzer capture --output ./snapshots --label pre_migration",
+	},
+}
+
+var testConnectionCmd = &command{
+	name:    "test-connection",
+	summary: "Test the database connection and print server statistics",
+	usage:   "test-connection [flags]",
+	examples: []string{
+		"fn-analyzer test-connection",
+		"fn-analyzer test-connection --config staging.json",
+	},
+}
+
+var versionCmd = &command{
+	name:    "version",
+	summary: "Print the analyzer version",
+	usage:   "version",
+}
+
+func init() {
+	compareCmd.run = runCompare
+	checkSLACmd.run = runCheckSLA
+	trendCmd.run = runTrend
+	validateCmd.run = runValidate
+	captureCmd.run = runCapture
+	testConnectionCmd.run = runTestConnection
+	versionCmd.run = runVersion
+}
+
+func runCompare(args []string) error {
+	fs, common := newFlagSet(compareCmd)
+	outputDir := fs.String("output", "", "Output directory for the comparison report (overrides config)")
+	thresholdReport, threshold := compareThresholdFlags(fs)
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	if *threshold < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --compare-threshold %g: must not be negative\n", *threshold)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+	applyCompareThreshold(cfg, *thresholdReport, *threshold)
+
+	before, err := report.LoadResult(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := report.LoadResult(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	comparison := report.BuildComparison(before, after)
+	if err := report.SaveComparison(comparison, cfg.OutputDir); err != nil {
+		return err
+	}
+	return saveChanges(cfg, comparison)
+}
+
+func runCheckSLA(args []string) error {
+	fs, common := newFlagSet(checkSLACmd)
+	outputDir := fs.String("output", "", "Output directory for the SLA check report (overrides config)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+
+	result, err := report.LoadResult(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	slas, err := report.LoadSLAs(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	check := report.BuildSLACheck(result, slas, fs.Arg(1))
+	if err := report.SaveSLACheck(check, result, cfg.OutputDir); err != nil {
+		return err
+	}
+
+	if check.Breached > 0 {
+		var breached []string
+		for _, qc := range check.Checks {
+			if !qc.Passed {
+				breached = append(breached, qc.Name)
+			}
+		}
+		return withExitCode(exitAssertion, fmt.Errorf("%d queries breached their SLA: %s",
+			len(breached), strings.Join(breached, ", ")))
+	}
+	return nil
+}
+
+// compareThresholdFlags defines the flags of the short "what changed"
+// comparison report, shared by compare and run.
+func compareThresholdFlags(fs *flag.FlagSet) (enabled *bool, threshold *float64) {
+	enabled = fs.Bool("compare-threshold-report", false, "With each comparison, also write a report listing only the queries whose average latency moved by more than --compare-threshold")
+	threshold = fs.Float64("compare-threshold", 0, "Percent change in average latency for --compare-threshold-report (overrides config; default 10)")
+	return enabled, threshold
+}
+
+func applyCompareThreshold(cfg *config.Config, thresholdReport bool, threshold float64) {
+	if thresholdReport {
+		cfg.CompareThresholdReport = true
+	}
+	if threshold > 0 {
+		cfg.CompareThresholdPercent = threshold
+	}
+}
+
+// saveChanges writes the short report of the queries that moved in
+// comparison, if configured.
+func saveChanges(cfg *config.Config, comparison model.ComparisonResult) error {
+	if !cfg.CompareThresholdReport {
+		return nil
+	}
+	return report.SaveChanges(comparison, cfg.CompareThresholdPercent, cfg.OutputDir)
+}
+
+func runTrend(args []string) error {
+	fs, common := newFlagSet(trendCmd)
+	runs := fs.Int("runs", 10, "Number of most recent results to include")
+	dir := fs.String("dir", "", "Directory to load results from (default: the output directory)")
+	outputDir := fs.String("output", "", "Output directory for the trend report (overrides config)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if *runs < 2 {
+		fmt.Fprintf(fs.Output(), "invalid --runs %d: a trend needs at least 2 runs\n", *runs)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = cfg.OutputDir
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+
+	results, err := report.LoadRecentResults(*dir, *runs)
+	if err != nil {
+		return err
+	}
+	if len(results) < 2 {
+		return fmt.Errorf("found %d results in %s; a trend needs at least 2", len(results), *dir)
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	return report.SaveTrend(results, cfg.OutputDir)
+}
+
+func runValidate(args []string) error {
+	fs, common := newFlagSet(validateCmd)
+	queriesFile := fs.String("queries", "", "Comma-separated queries files or glob patterns (overrides config)")
+	strictLint := fs.Bool("strict-lint", false, "Treat lint warnings as problems")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *queriesFile != "" {
+		cfg.QueriesFile = *queriesFile
+	}
+	if *strictLint {
+		cfg.StrictLint = true
+	}
+
+	queries, err := analyzer.LoadQueries(cfg.QueriesFile)
+	if err != nil {
+		return fmt.Errorf("error loading queries: %w", err)
+	}
+
+	var problems, lint []string
+	complexity := make(map[string]int)
+	classifier := analyzer.NewComplexityClassifier(cfg.ComplexityRules)
+	for i, q := range queries {
+		if q.Name == "" {
+			problems = append(problems, fmt.Sprintf("query #%d has no name", i+1))
+		}
+		if strings.TrimSpace(q.SQL) == "" {
+			problems = append(problems, fmt.Sprintf("query %q has no SQL", q.Name))
+		}
+		if q.Weight < 0 {
+			problems = append(problems, fmt.Sprintf("query %q has negative weight %d", q.Name, q.Weight))
+		}
+		complexity[classifier.Classify(q.SQL)]++
+		for _, w := range q.LintWarnings {
+			lint = append(lint, fmt.Sprintf("%s: %s", q.Name, w))
+		}
+	}
+	if cfg.StrictLint {
+		problems = append(problems, lint...)
+	}
+	checked := queries
+	if len(cfg.Schemas) > 0 {
+		if checked, err = analyzer.ExpandSchemas(queries, cfg.Schemas); err != nil {
+			problems = append(problems, err.Error())
+			checked = queries
+		}
+	}
+	if err := analyzer.CheckTableAccess(checked, cfg.AllowedTablesPattern, cfg.DeniedTablesPattern); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	fmt.Printf("Loaded %d queries from %s\n", len(queries), cfg.QueriesFile)
+
+	levels := make([]string, 0, len(complexity))
+	for level := range complexity {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	for _, level := range levels {
+		fmt.Printf("  %s: %d queries\n", level, complexity[level])
+	}
+
+	if len(lint) > 0 && !cfg.StrictLint {
+		fmt.Printf("\n%d lint warning(s):\n", len(lint))
+		for _, l := range lint {
+			fmt.Printf("  - %s\n", l)
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("\n%d problem(s) found:\n", len(problems))
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		return fmt.Errorf("validation failed with %d problem(s)", len(problems))
+	}
+
+	fmt.Println("\n✓ Configuration and queries are valid")
+	return nil
+}
+
+func runCapture(args []string) error {
+	fs, common := newFlagSet(captureCmd)
+	outputDir := fs.String("output", "", "Output directory (overrides config)")
+	label := fs.String("label", "", "Snapshot label (overrides config)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+	if *label != "" {
+		cfg.Label = *label
+	}
+
+	db, err := database.Connect(cfg.DSN, 1, connectRetry(cfg))
+	if err != nil {
+		return withExitCode(exitConnection, fmt.Errorf("error connecting to database: %w", err))
+	}
+	defer db.Close()
+
+	metrics, err := database.GetDetailedMetrics(db)
+	if err != nil {
+		return fmt.Errorf("error capturing metrics: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	snapshot := struct {
+		Timestamp time.Time          `json:"timestamp"`
+		Label     string             `json:"label"`
+		Metrics   database.DBMetrics `json:"metrics"`
+	}{
+		Timestamp: time.Now(),
+		Label:     cfg.Label,
+		Metrics:   metrics,
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling metrics: %w", err)
+	}
+
+	filename := filepath.Join(cfg.OutputDir, fmt.Sprintf("metrics-%s-%s.json",
+		cfg.Label, snapshot.Timestamp.Format("20060102-150405")))
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+
+	log.Printf("Metrics snapshot saved to %s", filename)
+	return nil
+}
+
+func runTestConnection(args []string) error {
+	fs, common := newFlagSet(testConnectionCmd)
+	retry := addRetryFlags(fs)
+	benchConnect := fs.Int("bench-connect", 0, "Also open N fresh connections sequentially and concurrently and report connect latency")
+	concurrency := fs.Int("concurrency", 0, "Parallel openers for the concurrent --bench-connect batch (overrides config)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+	if err := retry.validate(fs); err != nil {
+		return err
+	}
+	if *benchConnect < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --bench-connect %d: must not be negative\n", *benchConnect)
+		return errUsage
+	}
+	if *concurrency < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --concurrency %d: must not be negative\n", *concurrency)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	retry.apply(cfg)
+	if *benchConnect > 0 {
+		cfg.BenchConnect = *benchConnect
+	}
+	if *concurrency > 0 {
+		cfg.Concurrency = *concurrency
+	}
+
+	if err := database.TestConnection(cfg.DSN, connectRetry(cfg)); err != nil {
+		return withExitCode(exitConnection, fmt.Errorf("connection test failed: %w", err))
+	}
+
+	if cfg.BenchConnect > 0 {
+		log.Printf("Measuring connect latency over %d fresh connections...", cfg.BenchConnect)
+		bench, err := database.BenchmarkConnect(context.Background(), cfg.DSN, cfg.BenchConnect, cfg.Concurrency)
+		if err != nil {
+			return fmt.Errorf("error benchmarking connections: %w", err)
+		}
+		report.PrintConnectionBenchmark(bench)
+	}
+	return nil
+}
+
+func runVersion(args []string) error {
+	fs, _ := newFlagSet(versionCmd)
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	printVersion()
+	return nil
+}
+
+func printVersion() {
+	fmt.Printf("DB Analyzer v%s\n", Version)
+}
@@ -0,0 +1,40 @@
+// cmd/analyzer/exit.go
+package main
+
+import "errors"
+
+// Exit codes returned by the analyzer. Scripts can rely on these values.
+const (
+	exitOK          = 0 // Completed cleanly
+	exitToolError   = 1 // Tool, configuration or usage error
+	exitConnection  = 2 // Couldn't connect to the database
+	exitQueryErrors = 3 // The run completed but some query executions failed
+	exitAssertion   = 4 // An assertion or regression gate failed
+)
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode tags err with the exit code the process should return.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitToolError
+}
@@ -0,0 +1,154 @@
+// cmd/analyzer/exit_test.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// quietLogs discards log output for the test; --quiet redirects it too.
+func quietLogs(t *testing.T) {
+	t.Helper()
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+}
+
+func writeFixture(t *testing.T, dir, name string, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunExitCodes(t *testing.T) {
+	quietLogs(t)
+	dir := t.TempDir()
+
+	// Nothing listens on port 1, so connecting fails straight away.
+	unreachable := writeFixture(t, dir, "unreachable.json", map[string]any{
+		"dsn": "user:pass@tcp(127.0.0.1:1)/db?timeout=1s",
+	})
+	result := writeFixture(t, dir, "result.json", model.TestResult{SchemaVersion: model.SchemaVersion})
+	slas := writeFixture(t, dir, "slas.json", map[string]float64{"orders": 50})
+	output := filepath.Join(dir, "out")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"help", []string{"help"}, exitOK},
+		{"version", []string{"version"}, exitOK},
+		{"command help", []string{"run", "-h"}, exitOK},
+		{"unknown command", []string{"frobnicate"}, exitToolError},
+		{"unknown flag", []string{"run", "--no-such-flag"}, exitToolError},
+		{"invalid flag value", []string{"test-connection", "--connect-retries", "-1"}, exitToolError},
+		{"missing config", []string{"test-connection", "--config", filepath.Join(dir, "missing.json")}, exitToolError},
+		{"unreachable database", []string{"test-connection", "--config", unreachable}, exitConnection},
+		{"missing results file", []string{"check-sla", "--output", output, filepath.Join(dir, "missing.json"), slas}, exitToolError},
+		{"SLA breached", []string{"check-sla", "--output", output, result, slas}, exitAssertion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdout, stderr := os.Stdout, os.Stderr
+			os.Stdout, os.Stderr = devNull, devNull
+			defer func() {
+				os.Stdout, os.Stderr = stdout, stderr
+				devNull.Close()
+			}()
+
+			if got := run(tt.args); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	query := func(name string, errors int) model.QueryResult {
+		return model.QueryResult{Name: name, Errors: errors}
+	}
+
+	tests := []struct {
+		name   string
+		result model.TestResult
+		want   int
+	}{
+		{"clean", model.TestResult{QueryResults: []model.QueryResult{query("a", 0)}}, exitOK},
+		{"failed executions", model.TestResult{QueryResults: []model.QueryResult{query("a", 2)}}, exitQueryErrors},
+		{"within minSuccessRate", model.TestResult{QueryResults: []model.QueryResult{
+			{Name: "a", Errors: 2, MinSuccessRate: 0.9},
+		}}, exitOK},
+		{"SLA violated", model.TestResult{QueryResults: []model.QueryResult{
+			{Name: "a", Errors: 2, SLAViolations: []string{"p95 over 50ms"}},
+		}}, exitAssertion},
+		{"alert without failOnAlert", model.TestResult{
+			Alerts: []model.Alert{{Rule: "p95"}},
+		}, exitOK},
+		{"alert with failOnAlert", model.TestResult{
+			Config: config.Config{Alerts: config.Alerts{FailOnAlert: true}},
+			Alerts: []model.Alert{{Rule: "p95"}},
+		}, exitAssertion},
+		{"varying row counts", model.TestResult{
+			Config:       config.Config{FailOnNonDeterministic: true},
+			QueryResults: []model.QueryResult{{Name: "a", NonDeterministicRowCount: true}},
+		}, exitAssertion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(runOutcome(tt.result)); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"untagged", base, exitToolError},
+		{"usage", errUsage, exitToolError},
+		{"help", flag.ErrHelp, exitToolError},
+		{"tagged", withExitCode(exitConnection, base), exitConnection},
+		{"wrapped", errors.Join(errors.New("context"), withExitCode(exitAssertion, base)), exitAssertion},
+		{"tagging nil", withExitCode(exitQueryErrors, nil), exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+	if err := withExitCode(exitConnection, base); !errors.Is(err, base) || err.Error() != "boom" {
+		t.Errorf("withExitCode() = %v, want it to wrap %v", err, base)
+	}
+}
@@ -0,0 +1,122 @@
+// cmd/analyzer/explain.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/database"
+)
+
+var explainCmd = &command{
+	name:    "explain",
+	summary: "Inspect a single query: complexity, tables and EXPLAIN plan",
+	usage:   "explain [flags] (--query <name> | --sql <statement>)",
+	examples: []string{
+		"fn-analyzer explain --query consistency_AccountAlert_Business",
+		"fn-analyzer explain --sql \"SELECT * FROM users WHERE id = 'abc'\"",
+		"fn-analyzer explain --query consistency_AuditLog_User --analyze",
+	},
+}
+
+func init() {
+	explainCmd.run = runExplain
+}
+
+func runExplain(args []string) error {
+	fs, common := newFlagSet(explainCmd)
+	queriesFile := fs.String("queries", "", "Comma-separated queries files or glob patterns (overrides config)")
+	queryName := fs.String("query", "", "Name of the query to explain (resolved from the queries file)")
+	sqlText := fs.String("sql", "", "SQL statement to explain")
+	analyze := fs.Bool("analyze", false, "Also run EXPLAIN ANALYZE (executes the query; MySQL 8.0.18+)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if (*queryName == "") == (*sqlText == "") {
+		fmt.Fprintln(fs.Output(), "exactly one of --query or --sql is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *queriesFile != "" {
+		cfg.QueriesFile = *queriesFile
+	}
+
+	name := "(ad hoc)"
+	query := *sqlText
+	var params []any
+	if *queryName != "" {
+		queries, err := analyzer.LoadQueries(cfg.QueriesFile)
+		if err != nil {
+			return fmt.Errorf("error loading queries: %w", err)
+		}
+		query = ""
+		for _, q := range queries {
+			if q.Name == *queryName {
+				name = q.Name
+				query = q.SQL
+				params = q.ExplainParams
+				break
+			}
+		}
+		if query == "" {
+			return fmt.Errorf("query %q not found in %s", *queryName, cfg.QueriesFile)
+		}
+	}
+
+	fmt.Printf("Query:      %s\n", name)
+	fmt.Printf("SQL:        %s\n", strings.TrimSpace(query))
+	fmt.Printf("Type:       %s\n", analyzer.EstimateStatementType(query))
+	complexity := analyzer.NewComplexityClassifier(cfg.ComplexityRules)
+	score, _ := complexity.Score(query)
+	fmt.Printf("Complexity: %s (score %d)\n", complexity.Classify(query), score)
+	fmt.Printf("Tables:     %s\n", strings.Join(analyzer.AnalyzeTablesInQuery(query), ", "))
+
+	db, err := database.Connect(cfg.DSN, 1, connectRetry(cfg))
+	if err != nil {
+		return withExitCode(exitConnection, fmt.Errorf("error connecting to database: %w", err))
+	}
+	defer db.Close()
+
+	// The plans are bounded by the query timeout, so a stalled server or
+	// network doesn't hang the command.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	plan, err := analyzer.GenerateQueryExplain(ctx, db, query, params...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\nEXPLAIN:")
+	fmt.Println(prettyPlan(plan))
+
+	if *analyze {
+		analyzed, err := analyzer.GenerateQueryExplainAnalyze(ctx, db, query, params...)
+		if err != nil {
+			return err
+		}
+		fmt.Println("\nEXPLAIN ANALYZE:")
+		fmt.Println(analyzed)
+	}
+
+	return nil
+}
+
+// prettyPlan indents a JSON explain plan and returns tabular plans unchanged.
+func prettyPlan(plan string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(plan), "", "  "); err != nil {
+		return plan
+	}
+	return buf.String()
+}
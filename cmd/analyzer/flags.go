@@ -0,0 +1,155 @@
+// cmd/analyzer/flags.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+)
+
+// errUsage is returned when flag parsing fails; the flag package has already
+// reported the problem to the user.
+var errUsage = errors.New("usage error")
+
+// commonFlags are accepted by every subcommand.
+type commonFlags struct {
+	configFile string
+	verbose    bool
+	quiet      bool
+}
+
+func newFlagSet(cmd *command) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	common := &commonFlags{}
+	fs.StringVar(&common.configFile, "config", "config.json", "Path to config file")
+	fs.BoolVar(&common.verbose, "verbose", false, "Verbose output")
+	fs.BoolVar(&common.quiet, "quiet", false, "Suppress log output (run prints a single-line JSON summary)")
+
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage:\n  fn-analyzer %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+		if len(cmd.examples) > 0 {
+			fmt.Fprintln(out, "\nExamples:")
+			for _, ex := range cmd.examples {
+				fmt.Fprintf(out, "  %s\n", ex)
+			}
+		}
+	}
+
+	return fs, common
+}
+
+// parseFlags parses args into fs. A nil error with done set means help was
+// printed and the command should return immediately.
+func parseFlags(fs *flag.FlagSet, args []string) (done bool, err error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return true, nil
+		}
+		return true, errUsage
+	}
+	return false, nil
+}
+
+func (c *commonFlags) loadConfig() (*config.Config, error) {
+	if c.quiet {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg, err := config.LoadConfig(c.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if c.verbose {
+		cfg.Verbose = true
+	}
+	if c.quiet {
+		cfg.Quiet = true
+	}
+	return cfg, nil
+}
+
+// loadConfigOrDefault is used by commands that don't need a database
+// connection: a missing config file falls back to the defaults.
+func (c *commonFlags) loadConfigOrDefault() (*config.Config, error) {
+	cfg, err := c.loadConfig()
+	if errors.Is(err, config.ErrNotFound) {
+		cfg = config.Default()
+		cfg.Verbose = c.verbose
+		cfg.Quiet = c.quiet
+		return cfg, nil
+	}
+	return cfg, err
+}
+
+// retryFlags are accepted by commands that wait for the database to come up.
+type retryFlags struct {
+	attempts int
+	backoff  time.Duration
+	timeout  time.Duration
+}
+
+func addRetryFlags(fs *flag.FlagSet) *retryFlags {
+	r := &retryFlags{}
+	fs.IntVar(&r.attempts, "connect-retries", 0, "Attempts at the initial connection before giving up, for a database that is still starting (overrides config)")
+	fs.DurationVar(&r.backoff, "connect-backoff", 0, "Delay before the first connection retry, doubling each attempt (overrides config)")
+	fs.DurationVar(&r.timeout, "connect-timeout", 0, "Give up on each connection attempt after this long (overrides config; default 10s)")
+	return r
+}
+
+// validate reports invalid values and returns errUsage.
+func (r *retryFlags) validate(fs *flag.FlagSet) error {
+	if r.attempts < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --connect-retries %d: must not be negative\n", r.attempts)
+		return errUsage
+	}
+	if r.backoff < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --connect-backoff %v: must not be negative\n", r.backoff)
+		return errUsage
+	}
+	if r.timeout < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --connect-timeout %v: must not be negative\n", r.timeout)
+		return errUsage
+	}
+	return nil
+}
+
+func (r *retryFlags) apply(cfg *config.Config) {
+	if r.attempts > 0 {
+		cfg.ConnectRetry.MaxAttempts = r.attempts
+	}
+	if r.backoff > 0 {
+		cfg.ConnectRetry.BackoffSeconds = r.backoff.Seconds()
+	}
+	if r.timeout > 0 {
+		cfg.ConnectRetry.TimeoutSeconds = r.timeout.Seconds()
+	}
+}
+
+// connectRetry converts the configured retry policy for the database package.
+func connectRetry(cfg *config.Config) database.ConnectRetry {
+	return database.ConnectRetry{
+		MaxAttempts: cfg.ConnectRetry.MaxAttempts,
+		Backoff:     time.Duration(cfg.ConnectRetry.BackoffSeconds * float64(time.Second)),
+		Timeout:     time.Duration(cfg.ConnectRetry.TimeoutSeconds * float64(time.Second)),
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,109 @@
+// cmd/analyzer/init.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+var initCmd = &command{
+	name:    "init",
+	summary: "Create a config file and a sample queries file",
+	usage:   "init [flags]",
+	examples: []string{
+		"fn-analyzer init",
+		"fn-analyzer init --dsn 'user:pass@tcp(localhost:3306)/app' --queries app-queries.json",
+		"fn-analyzer init --force",
+	},
+}
+
+func init() {
+	initCmd.run = runInit
+}
+
+// sampleQueries only touch information_schema so they run against any MySQL
+// database and demonstrate each field of the queries file.
+var sampleQueries = []model.Query{
+	{
+		Name:        "sample_list_tables",
+		Description: "Lists the tables in the current schema with their estimated row counts",
+		SQL:         "SELECT table_name, table_rows FROM information_schema.tables WHERE table_schema = DATABASE() LIMIT 100",
+		Weight:      5,
+	},
+	{
+		Name:        "sample_columns_per_table",
+		Description: "Joins tables to columns and aggregates the column count per table",
+		SQL:         "SELECT t.table_name, COUNT(c.column_name) AS column_count FROM information_schema.tables t JOIN information_schema.columns c ON c.table_schema = t.table_schema AND c.table_name = t.table_name WHERE t.table_schema = DATABASE() GROUP BY t.table_name ORDER BY column_count DESC LIMIT 20",
+		Weight:      10,
+	},
+	{
+		Name:        "sample_indexed_columns",
+		Description: "Lists indexed columns in the current schema",
+		SQL:         "SELECT table_name, index_name, column_name FROM information_schema.statistics WHERE table_schema = DATABASE() LIMIT 100",
+		Weight:      3,
+	},
+}
+
+func runInit(args []string) error {
+	fs, common := newFlagSet(initCmd)
+	dsn := fs.String("dsn", "", "Database DSN to write into the config (prompted for when omitted on a terminal)")
+	queriesFile := fs.String("queries", "queries.json", "Path of the sample queries file to create")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if !*force {
+		for _, path := range []string{common.configFile, *queriesFile} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+			}
+		}
+	}
+
+	cfg := config.Default()
+	cfg.QueriesFile = *queriesFile
+
+	if *dsn != "" {
+		cfg.DSN = *dsn
+	} else if isTerminal(os.Stdin) {
+		fmt.Printf("Database DSN [%s]: ", cfg.DSN)
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			cfg.DSN = line
+		}
+	}
+
+	if err := config.Save(cfg, common.configFile); err != nil {
+		return err
+	}
+	fmt.Printf("Created config file at %s\n", common.configFile)
+
+	data, err := json.MarshalIndent(sampleQueries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding sample queries: %w", err)
+	}
+	if err := os.WriteFile(*queriesFile, data, 0644); err != nil {
+		return fmt.Errorf("error writing sample queries: %w", err)
+	}
+	fmt.Printf("Created sample queries file at %s\n", *queriesFile)
+
+	fmt.Println("\nNext steps:")
+	fmt.Printf("  fn-analyzer test-connection --config %s\n", common.configFile)
+	fmt.Printf("  fn-analyzer run --config %s\n", common.configFile)
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
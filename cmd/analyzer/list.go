@@ -0,0 +1,146 @@
+// cmd/analyzer/list.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+var listCmd = &command{
+	name:    "list",
+	summary: "List the queries in the suite with complexity and referenced tables",
+	usage:   "list [flags]",
+	examples: []string{
+		"fn-analyzer list",
+		"fn-analyzer list --sort complexity",
+		"fn-analyzer list --queries orders.json --format json | jq '.[].name'",
+	},
+}
+
+func init() {
+	listCmd.run = runList
+}
+
+type queryListing struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Weight        int      `json:"weight"`
+	Complexity    string   `json:"complexity"`
+	Score         int      `json:"complexityScore"`
+	Tables        []string `json:"tables"`
+	StatementType string   `json:"statementType"`
+}
+
+func runList(args []string) error {
+	fs, common := newFlagSet(listCmd)
+	queriesFile := fs.String("queries", "", "Comma-separated queries files or glob patterns (overrides config)")
+	format := fs.String("format", "table", "Output format: table or json")
+	only := fs.String("only", "", "Comma-separated name patterns (glob, or /regex/) of queries to list")
+	skip := fs.String("skip", "", "Comma-separated name patterns (glob, or /regex/) of queries to hide")
+	sortBy := fs.String("sort", "", "Sort by weight, complexity or name (default: file order)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(fs.Output(), "invalid --format %q: must be table or json\n", *format)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if *queriesFile != "" {
+		cfg.QueriesFile = *queriesFile
+	}
+
+	queries, err := analyzer.LoadQueries(cfg.QueriesFile)
+	if err != nil {
+		return fmt.Errorf("error loading queries: %w", err)
+	}
+
+	queries, err = analyzer.FilterQueriesByName(queries, splitList(*only), splitList(*skip))
+	if err != nil {
+		return err
+	}
+
+	listings := buildListings(queries, analyzer.NewComplexityClassifier(cfg.ComplexityRules))
+
+	switch *sortBy {
+	case "":
+	case "weight":
+		sort.SliceStable(listings, func(i, j int) bool {
+			return listings[i].Weight > listings[j].Weight
+		})
+	case "complexity":
+		sort.SliceStable(listings, func(i, j int) bool {
+			return analyzer.ComplexityRank(listings[i].Complexity) > analyzer.ComplexityRank(listings[j].Complexity)
+		})
+	case "name":
+		sort.SliceStable(listings, func(i, j int) bool {
+			return listings[i].Name < listings[j].Name
+		})
+	default:
+		fmt.Fprintf(fs.Output(), "invalid --sort %q: must be weight, complexity or name\n", *sortBy)
+		return errUsage
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+
+	return printListings(listings)
+}
+
+// maxListedDescription keeps the table readable with long descriptions.
+const maxListedDescription = 40
+
+func printListings(listings []queryListing) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION\tWEIGHT\tCOMPLEXITY\tSCORE\tTYPE\tTABLES")
+	for _, l := range listings {
+		description := []rune(l.Description)
+		if len(description) > maxListedDescription {
+			description = append(description[:maxListedDescription-3], []rune("...")...)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%s\n",
+			l.Name, string(description), l.Weight, l.Complexity, l.Score, l.StatementType, strings.Join(l.Tables, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d queries\n", len(listings))
+	return nil
+}
+
+func buildListings(queries []model.Query, complexity analyzer.ComplexityClassifier) []queryListing {
+	listings := make([]queryListing, 0, len(queries))
+	for _, q := range queries {
+		tables := analyzer.AnalyzeTablesInQuery(q.SQL)
+		if tables == nil {
+			tables = []string{}
+		}
+		score, _ := complexity.Score(q.SQL)
+		listings = append(listings, queryListing{
+			Name:          q.Name,
+			Description:   q.Description,
+			Weight:        q.Weight,
+			Complexity:    complexity.Classify(q.SQL),
+			Score:         score,
+			Tables:        tables,
+			StatementType: analyzer.EstimateStatementType(q.SQL),
+		})
+	}
+	return listings
+}
@@ -2,103 +2,110 @@
 package main
 
 import (
-	"flag"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"time"
-
-	"github.com/0xsj/fn-analyzer/internal/analyzer"
-	"github.com/0xsj/fn-analyzer/internal/config"
-	"github.com/0xsj/fn-analyzer/internal/database"
+	"strings"
 )
 
 var (
 	Version = "1.0.0"
 )
 
-func main() {
-	start := time.Now()
-
-	configFile := flag.String("config", "config.json", "Path to config file")
-	queriesFile := flag.String("queries", "", "Path to queries file (overrides config)")
-	outputDir := flag.String("output", "", "Output directory (overrides config)")
-	label := flag.String("label", "", "Test run label (overrides config)")
-	verbose := flag.Bool("verbose", false, "Verbose output")
-	testConnection := flag.Bool("test-connection", false, "Test database connection only")
-	versionFlag := flag.Bool("version", false, "Print version and exit")
-	flag.Parse()
-
-	if *versionFlag {
-		fmt.Printf("DB Analyzer v%s\n", Version)
-		return
-	}
+type command struct {
+	name     string
+	summary  string
+	usage    string
+	examples []string
+	run      func(args []string) error
+}
 
-	cfg, err := config.LoadConfig(*configFile)
-	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+var commands []*command
+
+func init() {
+	commands = []*command{
+		initCmd,
+		runCmd,
+		compareCmd,
+		checkSLACmd,
+		trendCmd,
+		replayCmd,
+		validateCmd,
+		listCmd,
+		explainCmd,
+		captureCmd,
+		serveCmd,
+		workerCmd,
+		testConnectionCmd,
+		versionCmd,
 	}
+}
 
-	if *queriesFile != "" {
-		cfg.QueriesFile = *queriesFile
-	}
-	if *outputDir != "" {
-		cfg.OutputDir = *outputDir
-	}
-	if *label != "" {
-		cfg.Label = *label
-	}
-	if *verbose {
-		cfg.Verbose = true
-	}
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	if *testConnection {
-		if err := database.TestConnection(cfg.DSN); err != nil {
-			log.Fatalf("Connection test failed: %v", err)
+// run dispatches args to a subcommand and returns the process exit code.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "-help") {
+			printUsage()
+			return exitOK
 		}
-		return
+		if len(args) > 0 {
+			log.Println("Warning: running without a subcommand is deprecated; use 'fn-analyzer run'")
+		}
+		return finish(runCmd.run(args))
 	}
 
-	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
-		log.Fatalf("Error creating output directory: %v", err)
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				cmd.run([]string{"-h"})
+				return exitOK
+			}
+		}
+		printUsage()
+		return exitOK
 	}
 
-	queries, err := analyzer.LoadQueries(cfg.QueriesFile)
-	if err != nil {
-		log.Fatalf("Error loading queries: %v", err)
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+		printUsage()
+		return exitToolError
 	}
 
-	log.Printf("Loaded %d queries from %s", len(queries), cfg.QueriesFile)
-
-	db, err := database.Connect(cfg.DSN, cfg.Concurrency)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
-	defer db.Close()
-
-	if err := analyzer.WarmupConnectionPool(db, cfg.WarmupIterations); err != nil {
-		log.Fatalf("Error during warmup: %v", err)
-	}
+	return finish(cmd.run(args[1:]))
+}
 
-	connInfo, err := database.GetConnectionInfo(db)
-	if err != nil {
-		log.Printf("Warning: couldn't get complete connection info: %v", err)
+// finish reports err (if any) and maps it to an exit code.
+func finish(err error) int {
+	if err != nil && !errors.Is(err, errUsage) {
+		log.Printf("Error: %v", err)
 	}
+	return exitCode(err)
+}
 
-	log.Printf("Starting performance test with %d queries, %d iterations each, concurrency %d",
-		len(queries), cfg.Iterations, cfg.Concurrency)
-
-	a := analyzer.NewAnalyzer(db, queries, *cfg)
-
-	results, err := a.Run()
-	if err != nil {
-		log.Fatalf("Error during test: %v", err)
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
 	}
+	return nil
+}
 
-	err = analyzer.GenerateReports(results, connInfo, *cfg, time.Since(start))
-	if err != nil {
-		log.Fatalf("Error generating reports: %v", err)
+func printUsage() {
+	out := os.Stderr
+	fmt.Fprintf(out, "FN Analyzer v%s - performance testing tool for databases\n\n", Version)
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  fn-analyzer <command> [flags] [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.summary)
 	}
-
-	log.Printf("Test completed in %v", time.Since(start))
+	fmt.Fprintln(out, "\nRun 'fn-analyzer <command> -h' for command flags and examples.")
+	fmt.Fprintln(out, "Running without a command is a deprecated alias for 'run'.")
 }
@@ -0,0 +1,72 @@
+// cmd/analyzer/profile.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profileFlags profile the analyzer process itself, to tell client-side
+// overhead apart from database latency at high concurrency.
+type profileFlags struct {
+	cpu string
+	mem string
+}
+
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	p := &profileFlags{}
+	fs.StringVar(&p.cpu, "cpuprofile", "", "Write a CPU profile of the analyzer to this file")
+	fs.StringVar(&p.mem, "memprofile", "", "Write a heap profile of the analyzer to this file when the run ends")
+	return p
+}
+
+// start begins CPU profiling if requested. The returned stop function ends
+// it and writes the heap profile; it only logs failures, so a profile problem
+// never changes the outcome of the run.
+func (p *profileFlags) start() (stop func(), err error) {
+	var cpuFile *os.File
+	if p.cpu != "" {
+		cpuFile, err = os.Create(p.cpu)
+		if err != nil {
+			return nil, fmt.Errorf("error creating CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %w", err)
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				log.Printf("Warning: error writing CPU profile: %v", err)
+			} else {
+				log.Printf("CPU profile written to %s", p.cpu)
+			}
+		}
+		if p.mem != "" {
+			p.writeHeapProfile()
+		}
+	}, nil
+}
+
+func (p *profileFlags) writeHeapProfile() {
+	f, err := os.Create(p.mem)
+	if err != nil {
+		log.Printf("Warning: error creating memory profile: %v", err)
+		return
+	}
+	defer f.Close()
+
+	runtime.GC() // Up-to-date statistics for live objects
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		log.Printf("Warning: error writing memory profile: %v", err)
+		return
+	}
+	log.Printf("Memory profile written to %s", p.mem)
+}
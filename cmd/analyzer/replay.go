@@ -0,0 +1,85 @@
+// cmd/analyzer/replay.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/report"
+)
+
+var replayCmd = &command{
+	name:    "replay",
+	summary: "Re-derive statistics and reports from a saved JSON result without a database",
+	usage:   "replay [flags] <result.json>",
+	examples: []string{
+		"fn-analyzer replay performance-baseline-20250101-120000.json",
+		"fn-analyzer replay --format md --output ./reanalyzed old-run.json.gz",
+	},
+}
+
+func init() {
+	replayCmd.run = runReplay
+}
+
+func runReplay(args []string) error {
+	fs, common := newFlagSet(replayCmd)
+	outputDir := fs.String("output", "", "Output directory for the regenerated reports (overrides config)")
+	label := fs.String("label", "", "Label for the regenerated reports (default: the original label)")
+	formats := fs.String("format", "", "Comma-separated report formats (default: the formats of the original run)")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if err := report.ValidateFormats(splitList(*formats)); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid --format: %v\n", err)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+
+	saved, err := report.LoadResult(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	result, err := analyzer.Reanalyze(saved)
+	if err != nil {
+		return fmt.Errorf("error replaying %s: %w", fs.Arg(0), err)
+	}
+
+	if *label != "" {
+		result.Label = *label
+	}
+	if *formats != "" {
+		result.Config.Formats = splitList(*formats)
+	}
+
+	log.Printf("Replayed %d queries, %d executions from %s",
+		len(result.QueryResults), result.Summary.TotalExecutions, fs.Arg(0))
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	if err := report.WriteReports(result, cfg.OutputDir, result.Config.Formats); err != nil {
+		return err
+	}
+
+	if !cfg.Quiet && !cfg.NoSummary {
+		report.PrintSummary(result)
+	}
+	return nil
+}
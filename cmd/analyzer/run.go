@@ -0,0 +1,1206 @@
+// cmd/analyzer/run.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/environment"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/internal/report"
+	"github.com/0xsj/fn-analyzer/internal/telemetry"
+)
+
+var runCmd = &command{
+	name:    "run",
+	summary: "Run the performance test suite and write reports",
+	usage:   "run [flags]",
+	examples: []string{
+		"fn-analyzer run --config config.json",
+		"fn-analyzer run --queries orders.json,users.json --label before_fixes",
+		"fn-analyzer run --output ./results --verbose",
+	},
+}
+
+func init() {
+	runCmd.run = runRun
+}
+
+func runRun(args []string) error {
+	start := time.Now()
+
+	fs, common := newFlagSet(runCmd)
+	queriesFile := fs.String("queries", "", "Comma-separated queries files or glob patterns (overrides config)")
+	outputDir := fs.String("output", "", "Output directory (overrides config)")
+	label := fs.String("label", "", "Test run label (overrides config)")
+	labelFromGit := fs.Bool("label-from-git", false, "Derive the label as <branch>-<short-sha> when --label isn't given")
+	gitSHA := fs.String("git-sha", "", "Revision of the deployed code under test, recorded in the report (default $FN_GIT_SHA)")
+	only := fs.String("only", "", "Comma-separated name patterns (glob, or /regex/) of queries to run")
+	skip := fs.String("skip", "", "Comma-separated name patterns (glob, or /regex/) of queries to skip")
+	statements := fs.String("statements", "", "Run only reads or writes (overrides config)")
+	failNonDeterministic := fs.Bool("fail-on-nondeterministic", false, "Fail the run if any query's row count varies between iterations")
+	strictLint := fs.Bool("strict-lint", false, "Refuse to run if any query has lint warnings (SELECT *, ORDER BY without LIMIT, UPDATE/DELETE without WHERE)")
+	coverage := fs.Float64("weight-coverage", 0, "Run only the highest-weight queries covering this percent of total weight (e.g. 90)")
+	validateOutput := fs.Bool("validate-output", false, "Validate the JSON report against the documented schema before writing")
+	beforeDSN := fs.String("before-dsn", "", "Run the suite against this DSN and then --after-dsn, and compare the two (overrides config)")
+	afterDSN := fs.String("after-dsn", "", "Second target for --before-dsn (overrides config)")
+	schemas := fs.String("schemas", "", "Comma-separated schemas to run each query containing {{schema}} in (overrides config)")
+	workers := fs.String("workers", "", "Comma-separated worker addresses (host:port) to split the run across; see 'worker' (overrides config)")
+	workerExecutions := fs.Bool("worker-executions", false, "Have --workers return every execution rather than per-query aggregates, for per-connection stats, replay and significance tests")
+	baselineDir := fs.String("compare-baseline-dir", "", "Compare against the newest earlier performance-*.json in this directory")
+	thresholdReport, threshold := compareThresholdFlags(fs)
+	tagQueries := fs.Bool("tag-queries", false, "Prefix each executed statement with a /* fn-analyzer run=... query=... iter=... */ comment")
+	order := fs.String("order", "", "Execution order across queries: round-robin, sequential or shuffled (overrides config)")
+	seed := fs.Uint64("seed", 0, "Seed for the run's random choices, such as the shuffled order, to repeat an earlier run's (overrides config)")
+	connMode := fs.String("connection-mode", "", "Connection mode: pool or dedicated (one pinned connection per worker) (overrides config)")
+	sampleRows := fs.Int("capture-sample-rows", 0, "Store the first N result rows of each query's first iteration in the JSON report")
+	columnTypes := fs.Bool("capture-column-types", false, "Store the result column names and database types of each query's first iteration")
+	maxRows := fs.Int64("max-rows", 0, "Cancel an execution once it returns more than N rows and record it as failed (overrides config)")
+	formats := fs.String("format", "", "Comma-separated report formats: json, csv, html, md, junit (default json,csv)")
+	compress := fs.Bool("compress", false, "Write JSON and CSV reports gzipped")
+	streamCSV := fs.Bool("stream-csv", false, "Append each query's CSV row to a partial report as soon as it finishes, so a crash keeps finished queries")
+	noSummary := fs.Bool("no-summary", false, "Don't print the console summary; only write report files")
+	topN := fs.Int("summary-top", 0, "Number of queries in the summary's ranked lists (default 5)")
+	durationUnit := fs.String("duration-unit", "", "Unit for durations in the summary and CSV/HTML/Markdown reports: ms, us, ns or auto (overrides config)")
+	explainPlans := fs.Bool("explain-plans", false, "EXPLAIN each query after the run and report plan warnings")
+	explainPlanFiles := fs.Bool("explain-plan-files", false, "With --explain-plans, write each plan to explain-<query>.json in the output directory instead of the report")
+	measureLocks := fs.Bool("measure-locks", false, "Run one query at a time and record the server's row lock waits while each ran")
+	profileSlowest := fs.Bool("profile-slowest", false, "Re-run the slowest query with SHOW PROFILE after the run and report where its time went")
+	isolation := fs.String("isolation", "", "Session isolation level: READ-UNCOMMITTED, READ-COMMITTED, REPEATABLE-READ or SERIALIZABLE (overrides config)")
+	txMode := fs.String("tx-mode", "", "Wrap each execution in a transaction: none, commit or rollback (overrides config)")
+	rollbackWrites := fs.Bool("rollback-writes", false, "Run INSERT, UPDATE and DELETE queries in a transaction that is rolled back, keeping the data unchanged")
+	freshConn := fs.Bool("fresh-conn", false, "Open a new connection for every execution to measure connect cost")
+	measureCold := fs.Bool("measure-cold", false, "Report each query's first execution as its cold latency, separate from the warm statistics")
+	benchConnect := fs.Int("bench-connect", 0, "Before the run, open N fresh connections sequentially and concurrently and report connect latency (overrides config)")
+	connFraction := fs.Float64("max-connections-fraction", 0, "Share of the server's max_connections the run may open, e.g. 0.5 (overrides config)")
+	onConnLimit := fs.String("on-connection-limit", "", "When the pool would exceed --max-connections-fraction: clamp or error (overrides config)")
+	alerts := fs.String("alerts", "", "Comma-separated alert rules checked against server metrics during the run, e.g. \"bufferPoolHitRate < 95\" (overrides config)")
+	failOnAlert := fs.Bool("fail-on-alert", false, "Fail the run if any alert rule held during it")
+	guardrails := fs.String("guardrails", "", "Comma-separated rules that pause dispatch while the server is over them, e.g. \"threadsRunning > 150 resume < 100\" (overrides config)")
+	stepSummary := fs.String("step-summary", "", "Append a Markdown summary of the run to this file (default $GITHUB_STEP_SUMMARY when set)")
+	stepSummaryRows := fs.Int("step-summary-rows", 0, "Rows shown per table in the step summary before pointing to the full report (overrides config)")
+	statsdAddr := fs.String("statsd", "", "Send execution timings and error rates to the statsd agent at host:port (overrides config)")
+	dirPerRun := fs.Bool("output-to-single-dir-per-run", false, "Write all of the run's files to a new <output>/<label>-<timestamp>/ directory")
+	minVersion := fs.String("min-server-version", "", "Refuse to run against a server older than this, e.g. 8.0 or \"MariaDB 10.6\" (overrides config)")
+	healthCheck := fs.Duration("health-check", 0, "Ping idle pooled connections this often during the run (e.g. 30s) and close bad ones (overrides config)")
+	soak := fs.Duration("soak", 0, "Repeat the suite back to back for this long (e.g. 6h), writing a snapshot report periodically (overrides config)")
+	soakSnapshot := fs.Duration("soak-snapshot", 0, "Time between soak snapshot reports, e.g. 10m (overrides config)")
+	retry := addRetryFlags(fs)
+	profile := addProfileFlags(fs)
+	list := fs.Bool("list", false, "Print the selected queries with complexity and tables, then exit without connecting (same as 'list')")
+	testConnection := fs.Bool("test-connection", false, "Test database connection only (deprecated: use 'test-connection')")
+	versionFlag := fs.Bool("version", false, "Print version and exit (deprecated: use 'version')")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	if *versionFlag {
+		printVersion()
+		return nil
+	}
+
+	if *order != "" && !slices.Contains(analyzer.ExecutionOrders, *order) {
+		fmt.Fprintf(fs.Output(), "invalid --order %q: must be %s\n", *order, strings.Join(analyzer.ExecutionOrders, ", "))
+		return errUsage
+	}
+
+	if *statements != "" && *statements != "reads" && *statements != "writes" {
+		fmt.Fprintf(fs.Output(), "invalid --statements %q: must be reads or writes\n", *statements)
+		return errUsage
+	}
+
+	if err := retry.validate(fs); err != nil {
+		return err
+	}
+
+	if *benchConnect < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --bench-connect %d: must not be negative\n", *benchConnect)
+		return errUsage
+	}
+
+	if *connFraction < 0 || *connFraction > 1 {
+		fmt.Fprintf(fs.Output(), "invalid --max-connections-fraction %g: must be between 0 and 1\n", *connFraction)
+		return errUsage
+	}
+	if *onConnLimit != "" && *onConnLimit != config.OnConnectionLimitClamp && *onConnLimit != config.OnConnectionLimitError {
+		fmt.Fprintf(fs.Output(), "invalid --on-connection-limit %q: must be %s or %s\n", *onConnLimit, config.OnConnectionLimitClamp, config.OnConnectionLimitError)
+		return errUsage
+	}
+
+	if *healthCheck < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --health-check %s: must not be negative\n", *healthCheck)
+		return errUsage
+	}
+
+	if *soak < 0 || *soakSnapshot < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --soak or --soak-snapshot: must not be negative\n")
+		return errUsage
+	}
+
+	if *threshold < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --compare-threshold %g: must not be negative\n", *threshold)
+		return errUsage
+	}
+
+	if *stepSummaryRows < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --step-summary-rows %d: must not be negative\n", *stepSummaryRows)
+		return errUsage
+	}
+
+	var statsdHost string
+	var statsdPort int
+	if *statsdAddr != "" {
+		host, port, err := net.SplitHostPort(*statsdAddr)
+		if err == nil {
+			statsdPort, err = strconv.Atoi(port)
+		}
+		if err != nil || host == "" || statsdPort <= 0 || statsdPort > 65535 {
+			fmt.Fprintf(fs.Output(), "invalid --statsd %q: must be host:port\n", *statsdAddr)
+			return errUsage
+		}
+		statsdHost = host
+	}
+
+	if _, err := database.ParseAlertRules(splitList(*alerts)); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid --alerts: %v\n", err)
+		return errUsage
+	}
+	if *minVersion != "" {
+		if _, err := database.ParseMinServerVersion(*minVersion); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid --min-server-version: %v\n", err)
+			return errUsage
+		}
+	}
+	if _, err := database.ParseGuardrailRules(splitList(*guardrails)); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid --guardrails: %v\n", err)
+		return errUsage
+	}
+
+	if *maxRows < 0 {
+		fmt.Fprintf(fs.Output(), "invalid --max-rows %d: must not be negative\n", *maxRows)
+		return errUsage
+	}
+
+	if *txMode != "" && !slices.Contains(analyzer.TransactionModes, *txMode) {
+		fmt.Fprintf(fs.Output(), "invalid --tx-mode %q: must be %s\n", *txMode, strings.Join(analyzer.TransactionModes, ", "))
+		return errUsage
+	}
+	if *isolation != "" {
+		if _, err := database.NormalizeIsolationLevel(*isolation); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid --isolation: %v\n", err)
+			return errUsage
+		}
+	}
+
+	if *connMode != "" && !slices.Contains(analyzer.ConnectionModes, *connMode) {
+		fmt.Fprintf(fs.Output(), "invalid --connection-mode %q: must be %s\n", *connMode, strings.Join(analyzer.ConnectionModes, ", "))
+		return errUsage
+	}
+
+	if err := report.ValidateFormats(splitList(*formats)); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid --format: %v\n", err)
+		return errUsage
+	}
+
+	if err := report.ValidateDurationUnit(*durationUnit); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid --duration-unit: %v\n", err)
+		return errUsage
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if *queriesFile != "" {
+		cfg.QueriesFile = *queriesFile
+	}
+	if *outputDir != "" {
+		cfg.OutputDir = *outputDir
+	}
+	if *labelFromGit {
+		cfg.LabelFromGit = true
+	}
+	if *gitSHA != "" {
+		cfg.GitSHA = *gitSHA
+	}
+	if *label != "" {
+		cfg.Label = *label
+	} else if cfg.LabelFromGit {
+		if git, err := environment.DetectGit("."); err != nil {
+			log.Printf("Warning: couldn't derive label from git, using %q: %v", cfg.Label, err)
+		} else {
+			cfg.Label = git.Label()
+			log.Printf("Using label %q from git", cfg.Label)
+		}
+	}
+	if *only != "" {
+		cfg.Only = splitList(*only)
+	}
+	if *skip != "" {
+		cfg.Skip = splitList(*skip)
+	}
+	if *statements != "" {
+		cfg.Statements = *statements
+	}
+	if *strictLint {
+		cfg.StrictLint = true
+	}
+	if *failNonDeterministic {
+		cfg.FailOnNonDeterministic = true
+	}
+	if *coverage > 0 {
+		cfg.WeightCoverage = *coverage
+	}
+	if *validateOutput {
+		cfg.ValidateOutput = true
+	}
+	if *baselineDir != "" {
+		cfg.CompareBaselineDir = *baselineDir
+	}
+	applyCompareThreshold(cfg, *thresholdReport, *threshold)
+	if *schemas != "" {
+		cfg.Schemas = splitList(*schemas)
+	}
+	if *beforeDSN != "" {
+		cfg.BeforeDSN = *beforeDSN
+	}
+	if *afterDSN != "" {
+		cfg.AfterDSN = *afterDSN
+	}
+	if (cfg.BeforeDSN == "") != (cfg.AfterDSN == "") {
+		fmt.Fprintf(fs.Output(), "--before-dsn and --after-dsn must be given together\n")
+		return errUsage
+	}
+	if *workers != "" {
+		cfg.Workers = splitList(*workers)
+	}
+	if *workerExecutions {
+		cfg.WorkerExecutions = true
+	}
+	if len(cfg.Workers) > 0 && cfg.BeforeDSN != "" {
+		fmt.Fprintf(fs.Output(), "--workers can't be combined with --before-dsn: workers connect with their own DSN\n")
+		return errUsage
+	}
+	if len(cfg.Workers) > 0 && os.Getenv(analyzer.WorkerTokenEnv) == "" {
+		fmt.Fprintf(fs.Output(), "--workers needs the workers' shared token in %s\n", analyzer.WorkerTokenEnv)
+		return errUsage
+	}
+	if *tagQueries {
+		cfg.TagQueries = true
+	}
+	if *freshConn {
+		cfg.FreshConnPerQuery = true
+	}
+	if *connFraction > 0 {
+		cfg.ConnectionLimit.MaxFraction = *connFraction
+	}
+	if *onConnLimit != "" {
+		cfg.ConnectionLimit.OnExceed = *onConnLimit
+	}
+	if *measureCold {
+		cfg.MeasureCold = true
+	}
+	if *alerts != "" {
+		cfg.Alerts.Rules = splitList(*alerts)
+	}
+	if *guardrails != "" {
+		cfg.Guardrails.Rules = splitList(*guardrails)
+	}
+	if *dirPerRun {
+		cfg.DirPerRun = true
+	}
+	if *stepSummary != "" {
+		cfg.StepSummary.Path = *stepSummary
+	} else if cfg.StepSummary.Path == "" {
+		cfg.StepSummary.Path = os.Getenv("GITHUB_STEP_SUMMARY")
+	}
+	if *stepSummaryRows > 0 {
+		cfg.StepSummary.MaxRows = *stepSummaryRows
+	}
+	if statsdHost != "" {
+		cfg.StatsD.Host = statsdHost
+		cfg.StatsD.Port = statsdPort
+	}
+	if *failOnAlert {
+		cfg.Alerts.FailOnAlert = true
+	}
+	if *minVersion != "" {
+		cfg.MinServerVersion = *minVersion
+	}
+	if *healthCheck > 0 {
+		cfg.HealthCheckSeconds = healthCheck.Seconds()
+	}
+	if *soak > 0 {
+		cfg.Soak.DurationSeconds = soak.Seconds()
+	}
+	if *soakSnapshot > 0 {
+		cfg.Soak.SnapshotSeconds = soakSnapshot.Seconds()
+	}
+	if len(cfg.Workers) > 0 && cfg.Soak.Enabled() {
+		fmt.Fprintf(fs.Output(), "--workers can't be combined with --soak\n")
+		return errUsage
+	}
+	if *benchConnect > 0 {
+		cfg.BenchConnect = *benchConnect
+	}
+	if *order != "" {
+		cfg.ExecutionOrder = *order
+	}
+	if *seed != 0 {
+		cfg.Seed = *seed
+	}
+	if *connMode != "" {
+		cfg.ConnectionMode = *connMode
+	}
+	if *isolation != "" {
+		cfg.IsolationLevel = *isolation
+	}
+	if *txMode != "" {
+		cfg.TransactionMode = *txMode
+	}
+	if *rollbackWrites {
+		cfg.RollbackWrites = true
+	}
+	if *noSummary {
+		cfg.NoSummary = true
+	}
+	if *topN > 0 {
+		cfg.SummaryTopN = *topN
+	}
+	if *durationUnit != "" {
+		cfg.DurationUnit = *durationUnit
+	}
+	if *explainPlans {
+		cfg.CollectExplainPlans = true
+	}
+	if *explainPlanFiles {
+		cfg.ExplainPlanFiles = true
+	}
+	if *profileSlowest {
+		cfg.ProfileSlowest = true
+	}
+	if *measureLocks {
+		cfg.MeasureLocks = true
+	}
+	if *compress {
+		cfg.CompressReports = true
+	}
+	if *streamCSV {
+		cfg.StreamCSV = true
+	}
+	if *formats != "" {
+		cfg.Formats = splitList(*formats)
+	}
+	if *sampleRows > 0 {
+		cfg.CaptureSampleRows = *sampleRows
+	}
+	if *columnTypes {
+		cfg.CaptureColumnTypes = true
+	}
+	if *maxRows > 0 {
+		cfg.MaxRows = *maxRows
+	}
+	retry.apply(cfg)
+
+	if *list {
+		queries, err := analyzer.LoadQueries(cfg.QueriesFile)
+		if err != nil {
+			return fmt.Errorf("error loading queries: %w", err)
+		}
+		queries, err = analyzer.FilterQueriesByName(queries, cfg.Only, cfg.Skip)
+		if err != nil {
+			return err
+		}
+		return printListings(buildListings(queries, analyzer.NewComplexityClassifier(cfg.ComplexityRules)))
+	}
+
+	if *testConnection {
+		if err := database.TestConnection(cfg.DSN, connectRetry(cfg)); err != nil {
+			return withExitCode(exitConnection, fmt.Errorf("connection test failed: %w", err))
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stopProfile, err := profile.start()
+	if err != nil {
+		return err
+	}
+	var result model.TestResult
+	if cfg.BeforeDSN != "" {
+		result, err = executeDualRun(ctx, cfg)
+	} else {
+		result, err = executeRun(ctx, cfg, start, nil)
+	}
+	stopProfile()
+	if err == nil {
+		err = runOutcome(result)
+	}
+
+	if cfg.Quiet {
+		printQuietSummary(cfg.Label, result, err)
+	}
+
+	return err
+}
+
+// executeRun loads the suite, runs it against the database and writes the
+// reports. observe, if non-nil, is called with the analyzer before the run
+// starts so callers can track progress. Cancelling ctx aborts the run without
+// writing reports.
+func executeRun(ctx context.Context, cfg *config.Config, start time.Time, observe func(*analyzer.Analyzer)) (model.TestResult, error) {
+	var result model.TestResult
+
+	if err := report.ValidateFormats(cfg.Formats); err != nil {
+		return result, err
+	}
+
+	if err := report.ValidateDurationUnit(cfg.DurationUnit); err != nil {
+		return result, fmt.Errorf("invalid durationUnit: %w", err)
+	}
+
+	if err := report.ValidateCSVColumns(cfg.CSVColumns); err != nil {
+		return result, fmt.Errorf("invalid csvColumns: %w", err)
+	}
+
+	if err := report.ValidateCSVDelimiter(cfg.CSVDelimiter); err != nil {
+		return result, fmt.Errorf("invalid csvDelimiter: %w", err)
+	}
+
+	if cfg.TransactionMode != "" && !slices.Contains(analyzer.TransactionModes, cfg.TransactionMode) {
+		return result, fmt.Errorf("invalid transactionMode %q: must be %s", cfg.TransactionMode, strings.Join(analyzer.TransactionModes, ", "))
+	}
+
+	// The session DSN carries the isolation level to every connection the
+	// run opens; the report keeps the configured DSN.
+	sessionDSN, err := database.WithIsolationLevel(cfg.DSN, cfg.IsolationLevel)
+	if err != nil {
+		return result, fmt.Errorf("invalid isolationLevel: %w", err)
+	}
+
+	if cfg.DirPerRun {
+		dir, err := report.CreateRunDir(cfg.OutputDir, cfg.Label, start)
+		if err != nil {
+			return result, fmt.Errorf("error creating run directory: %w", err)
+		}
+		// The recorded config names the directory the files are in.
+		cfg.OutputDir = dir
+		cfg.DirPerRun = false
+		log.Printf("Writing the run's files to %s", dir)
+	} else if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return result, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	pickSeed(cfg)
+	runCfg := *cfg
+	runCfg.DSN = sessionDSN
+
+	alertRules, err := database.ParseAlertRules(cfg.Alerts.Rules)
+	if err != nil {
+		return result, fmt.Errorf("invalid alerts: %w", err)
+	}
+	guardrailRules, err := database.ParseGuardrailRules(cfg.Guardrails.Rules)
+	if err != nil {
+		return result, fmt.Errorf("invalid guardrails: %w", err)
+	}
+
+	queries, err := analyzer.LoadQueries(cfg.QueriesFile)
+	if err != nil {
+		return result, fmt.Errorf("error loading queries: %w", err)
+	}
+
+	log.Printf("Loaded %d queries from %s", len(queries), cfg.QueriesFile)
+
+	if err := analyzer.CheckTableAccess(queries, cfg.AllowedTablesPattern, cfg.DeniedTablesPattern); err != nil {
+		return result, err
+	}
+
+	if cfg.StrictLint {
+		if err := analyzer.CheckLint(queries); err != nil {
+			return result, err
+		}
+	}
+
+	if err := analyzer.ValidateSampleMask(cfg.SampleMaskColumns); err != nil {
+		return result, err
+	}
+
+	if len(cfg.Only) > 0 || len(cfg.Skip) > 0 {
+		queries, err = analyzer.FilterQueriesByName(queries, cfg.Only, cfg.Skip)
+		if err != nil {
+			return result, err
+		}
+		if len(queries) == 0 {
+			return result, fmt.Errorf("no queries left after applying --only/--skip filters")
+		}
+		log.Printf("Selected %d queries after name filtering", len(queries))
+	}
+
+	if cfg.Statements != "" {
+		queries, err = analyzer.CreateTestQueries(queries, cfg.Statements, 0)
+		if err != nil {
+			return result, err
+		}
+		log.Printf("Selected %d %s", len(queries), cfg.Statements)
+	}
+
+	if cfg.WeightCoverage > 0 {
+		queries, err = analyzer.SelectByWeightCoverage(queries, cfg.WeightCoverage)
+		if err != nil {
+			return result, err
+		}
+		log.Printf("Selected %d queries covering %.0f%% of total weight", len(queries), cfg.WeightCoverage)
+	}
+
+	if err := analyzer.CheckMultiStatements(queries, runCfg.DSN); err != nil {
+		return result, err
+	}
+	if cfg.RollbackWrites {
+		if names := analyzer.IrreversibleWrites(queries); len(names) > 0 {
+			log.Printf("Warning: rollbackWrites can't undo %s; only INSERT, UPDATE and DELETE are rolled back, other writes keep their effects",
+				strings.Join(names, ", "))
+		}
+	}
+
+	db, err := database.Connect(runCfg.DSN, cfg.Concurrency, connectRetry(cfg))
+	if err != nil {
+		return result, withExitCode(exitConnection, fmt.Errorf("error connecting to database: %w", err))
+	}
+	defer db.Close()
+
+	if cfg.MinServerVersion != "" {
+		if err := checkServerVersion(db, cfg.MinServerVersion); err != nil {
+			return result, err
+		}
+	}
+
+	poolLimit, err := limitPool(db, cfg)
+	if err != nil {
+		return result, err
+	}
+
+	if len(cfg.Schemas) > 0 || cfg.SchemaQuery != "" {
+		schemas := slices.Clone(cfg.Schemas)
+		if cfg.SchemaQuery != "" {
+			listed, err := database.ListSchemas(db, cfg.SchemaQuery)
+			if err != nil {
+				return result, fmt.Errorf("error running schemaQuery: %w", err)
+			}
+			for _, schema := range listed {
+				if !slices.Contains(schemas, schema) {
+					schemas = append(schemas, schema)
+				}
+			}
+		}
+		if len(schemas) == 0 {
+			return result, fmt.Errorf("schemaQuery returned no schemas")
+		}
+		queries, err = analyzer.ExpandSchemas(queries, schemas)
+		if err != nil {
+			return result, err
+		}
+		log.Printf("Fanned out to %d queries across %d schemas", len(queries), len(schemas))
+
+		// The templates were only checked against the denied pattern.
+		if err := analyzer.CheckTableAccess(queries, cfg.AllowedTablesPattern, cfg.DeniedTablesPattern); err != nil {
+			return result, err
+		}
+	}
+
+	// Registered first so a setup that fails halfway is cleaned up too.
+	if len(cfg.TeardownScripts) > 0 {
+		defer runTeardown(db, cfg)
+	}
+
+	var setup *database.ScriptRun
+	if len(cfg.SetupScripts) > 0 {
+		log.Printf("Running %d setup scripts...", len(cfg.SetupScripts))
+		run, err := database.RunScripts(ctx, db, cfg.SetupScripts, scriptTimeout(cfg))
+		if err != nil {
+			return result, fmt.Errorf("setup failed after %d statements, nothing was measured: %w", run.Statements, err)
+		}
+		log.Printf("Setup ran %d statements in %s", run.Statements, run.Duration.Round(time.Millisecond))
+		setup = &run
+	}
+
+	var connBench *database.ConnectionBenchmark
+	if cfg.BenchConnect > 0 {
+		log.Printf("Measuring connect latency over %d fresh connections...", cfg.BenchConnect)
+		bench, err := database.BenchmarkConnect(ctx, runCfg.DSN, cfg.BenchConnect, cfg.Concurrency)
+		if err != nil {
+			log.Printf("Warning: couldn't benchmark connections: %v", err)
+		} else {
+			connBench = &bench
+		}
+	}
+
+	if err := analyzer.WarmupConnectionPool(db, cfg.WarmupIterations); err != nil {
+		return result, fmt.Errorf("error during warmup: %w", err)
+	}
+
+	connInfo, err := database.GetConnectionInfo(db)
+	if err != nil {
+		log.Printf("Warning: couldn't get complete connection info: %v", err)
+	}
+	connInfo.PoolLimit = poolLimit
+
+	log.Printf("Starting performance test with %d queries, %d iterations each, concurrency %d",
+		len(queries), cfg.Iterations, cfg.Concurrency)
+
+	tracer, stopTracing, err := telemetry.StartTracing(ctx, Version)
+	if err != nil {
+		return result, err
+	}
+	defer stopTracing()
+
+	statsd, err := telemetry.StartStatsD(cfg.StatsD)
+	if err != nil {
+		return result, err
+	}
+
+	a := analyzer.NewAnalyzer(db, queries, runCfg)
+	a.TraceExecutions(tracer)
+	a.AuthenticateWorkers(os.Getenv(analyzer.WorkerTokenEnv))
+	if statsd != nil {
+		a.OnExecution(statsd.Execution)
+	}
+	if cfg.StreamCSV {
+		stream, err := report.OpenCSVStream(*cfg, start)
+		if err != nil {
+			return result, err
+		}
+		defer stream.Close()
+		a.OnQueryComplete(func(q model.QueryResult) {
+			if err := stream.Write(q); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		})
+	}
+	if observe != nil {
+		observe(a)
+	}
+
+	poolWaitBefore := db.Stats().WaitDuration
+	countersBefore, countersErr := database.GetWorkloadCounters(db)
+	if countersErr != nil {
+		log.Printf("Warning: couldn't read workload counters: %v", countersErr)
+	}
+	serverBefore, serverErr := database.GetServerCounters(db)
+	if serverErr != nil {
+		log.Printf("Warning: couldn't read server counters: %v", serverErr)
+	}
+
+	if cfg.Soak.Enabled() {
+		a.OnSnapshot(func(results []model.QueryResult, soak model.Soak) {
+			writeSoakSnapshot(cfg, results, soak, connInfo)
+		})
+	}
+
+	var monitor *analyzer.AlertMonitor
+	stopMonitor := func() {}
+	if len(alertRules) > 0 {
+		if monitor, stopMonitor, err = startAlertMonitor(ctx, &runCfg, alertRules); err != nil {
+			return result, err
+		}
+		defer stopMonitor()
+	}
+
+	var guard *analyzer.Guard
+	stopGuard := func() {}
+	if len(guardrailRules) > 0 && len(cfg.Workers) > 0 {
+		log.Printf("Warning: guardrails are ignored in distributed runs")
+	} else if len(guardrailRules) > 0 {
+		if guard, stopGuard, err = startGuard(ctx, &runCfg, guardrailRules); err != nil {
+			return result, err
+		}
+		defer stopGuard()
+		a.GuardDispatch(guard)
+	}
+
+	var health *database.HealthChecker
+	stopHealth := func() {}
+	if cfg.HealthCheckSeconds > 0 && len(cfg.Workers) == 0 && analyzer.EffectiveConnectionMode(*cfg) == analyzer.ConnModePool {
+		interval := time.Duration(cfg.HealthCheckSeconds * float64(time.Second))
+		healthCtx, cancel := context.WithCancel(ctx)
+		health = database.StartHealthChecker(healthCtx, db, interval)
+		stopHealth = func() {
+			cancel()
+			health.Wait()
+		}
+		defer stopHealth()
+		log.Printf("Checking idle connections every %s", interval)
+	}
+
+	results, err := a.RunContext(ctx)
+	stopMonitor()
+	stopGuard()
+	stopHealth()
+	var emission *model.StatsDEmission
+	if statsd != nil {
+		e := statsd.Close()
+		emission = &e
+	}
+	if err != nil {
+		return result, fmt.Errorf("error during test: %w", err)
+	}
+
+	connInfo.ConnectionMode = analyzer.EffectiveConnectionMode(*cfg)
+	connInfo.Reconnects = a.Reconnects()
+	if health != nil {
+		connInfo.HealthChecks = health.Checked()
+		connInfo.HealthCheckEvictions = health.Evicted()
+	}
+	if connInfo.ConnectionMode == analyzer.ConnModePool {
+		connInfo.PoolWait = db.Stats().WaitDuration - poolWaitBefore
+	}
+	if countersErr == nil {
+		if countersAfter, err := database.GetWorkloadCounters(db); err != nil {
+			log.Printf("Warning: couldn't read workload counters: %v", err)
+		} else {
+			workload := countersAfter.Sub(countersBefore)
+			connInfo.Workload = &workload
+		}
+	}
+
+	run := model.TestResult{
+		Config:         redactConfig(*cfg),
+		TotalDuration:  time.Since(start) - setupDuration(setup),
+		ConnectionInfo: connInfo,
+		Environment: model.Environment{
+			DSNHost:       database.DSNHost(cfg.DSN),
+			ServerVersion: connInfo.Version,
+		},
+		Metadata:            runMetadata(cfg),
+		ConnectionBenchmark: connBench,
+		Heatmap:             a.Heatmap(),
+		Workers:             a.Workers(),
+		Setup:               setup,
+		StatsD:              emission,
+	}
+	if analyzer.UsesSeed(*cfg) {
+		run.Seed = cfg.Seed
+	}
+	run.Soak = a.Soak()
+	if monitor != nil {
+		run.MetricsHistory = monitor.History()
+		run.Alerts = monitor.Alerts()
+	}
+	if guard != nil {
+		guardrails := guard.Summary()
+		run.Guardrails = &guardrails
+	}
+	if serverErr == nil {
+		if serverAfter, err := database.GetServerCounters(db); err != nil {
+			log.Printf("Warning: couldn't read server counters: %v", err)
+		} else {
+			delta := serverAfter.Sub(serverBefore)
+			run.ServerDelta = &delta
+		}
+	}
+	if run.Environment.ServerVariables, err = database.GetServerVariables(db); err != nil {
+		log.Printf("Warning: couldn't read server variables: %v", err)
+	}
+
+	result, err = analyzer.GenerateReports(results, run)
+	if err != nil {
+		return result, fmt.Errorf("error generating reports: %w", err)
+	}
+
+	var comparison *model.ComparisonResult
+	if cfg.CompareBaselineDir != "" {
+		comparison = compareWithBaseline(cfg, result, start)
+	}
+	writeStepSummary(cfg, result, comparison)
+
+	log.Printf("Test completed in %v", time.Since(start))
+	return result, nil
+}
+
+// executeDualRun runs the suite against cfg.BeforeDSN and then cfg.AfterDSN,
+// writing both sets of reports plus a comparison, and returns the after run.
+func executeDualRun(ctx context.Context, cfg *config.Config) (model.TestResult, error) {
+	targets := []struct{ side, dsn string }{
+		{"before", cfg.BeforeDSN},
+		{"after", cfg.AfterDSN},
+	}
+
+	// Both targets get the same random choices, or the comparison measures
+	// them along with the change.
+	pickSeed(cfg)
+
+	// One directory holds both targets' reports and their comparison.
+	if cfg.DirPerRun {
+		dir, err := report.CreateRunDir(cfg.OutputDir, cfg.Label, time.Now())
+		if err != nil {
+			return model.TestResult{}, fmt.Errorf("error creating run directory: %w", err)
+		}
+		cfg.OutputDir = dir
+		cfg.DirPerRun = false
+		log.Printf("Writing the run's files to %s", dir)
+	}
+
+	var results []model.TestResult
+	for _, target := range targets {
+		targetCfg := *cfg
+		targetCfg.DSN = target.dsn
+		targetCfg.Label = cfg.Label + "-" + target.side
+		targetCfg.BeforeDSN, targetCfg.AfterDSN = "", ""
+		targetCfg.CompareBaselineDir = ""
+		targetCfg.StepSummary.Path = ""
+
+		log.Printf("Running %s target (%s)", target.side, database.DSNHost(target.dsn))
+		result, err := executeRun(ctx, &targetCfg, time.Now(), nil)
+		if err != nil {
+			return result, fmt.Errorf("%s run: %w", target.side, err)
+		}
+		results = append(results, result)
+	}
+
+	comparison := report.BuildComparison(results[0], results[1])
+	comparison.Ordering = model.OrderingSequential
+	if err := report.SaveComparison(comparison, cfg.OutputDir); err != nil {
+		return results[1], fmt.Errorf("error saving comparison: %w", err)
+	}
+	if err := saveChanges(cfg, comparison); err != nil {
+		return results[1], fmt.Errorf("error saving changes: %w", err)
+	}
+	writeStepSummary(cfg, results[1], &comparison)
+
+	log.Printf("After vs before: average query time improved %.1f%%", comparison.ImprovementSummary.AvgTimeImprovement)
+	return results[1], nil
+}
+
+// limitPool keeps the run within cfg.ConnectionLimit's share of the server's
+// max_connections. Over the limit, the pool is clamped with a warning or the
+// run refused, as configured. Dedicated and fresh connections can't be
+// clamped: every worker needs its own, so too many workers is always an
+// error. A server whose limit can't be read isn't checked.
+func limitPool(db *sql.DB, cfg *config.Config) (*database.PoolLimit, error) {
+	limit := cfg.ConnectionLimit
+	if limit.MaxFraction <= 0 {
+		return nil, nil
+	}
+
+	serverMax, err := database.ServerMaxConnections(db)
+	if err != nil {
+		log.Printf("Warning: couldn't read max_connections, connection limit not checked: %v", err)
+		return nil, nil
+	}
+
+	pool := &database.PoolLimit{
+		ServerMaxConnections: serverMax,
+		Allowed:              max(int(float64(serverMax)*limit.MaxFraction), 1),
+		Requested:            database.MaxOpenConns(cfg.Concurrency),
+	}
+	pool.MaxOpenConns = pool.Requested
+	if pool.Requested <= pool.Allowed {
+		return pool, nil
+	}
+
+	problem := fmt.Sprintf("a pool of up to %d connections exceeds %.0f%% of the server's max_connections (%d allowed of %d)",
+		pool.Requested, limit.MaxFraction*100, pool.Allowed, serverMax)
+	if limit.OnExceed == config.OnConnectionLimitError {
+		return nil, fmt.Errorf("%s; lower concurrency or raise connectionLimit.maxFraction", problem)
+	}
+	if mode := analyzer.EffectiveConnectionMode(*cfg); mode != analyzer.ConnModePool && cfg.Concurrency > pool.Allowed {
+		return nil, fmt.Errorf("%s, and %s connections can't be shared by %d workers; lower concurrency", problem, mode, cfg.Concurrency)
+	}
+
+	database.ClampPool(db, pool.Allowed, cfg.Concurrency)
+	pool.MaxOpenConns = pool.Allowed
+	pool.Clamped = true
+	log.Printf("Warning: %s; clamping the pool to %d connections", problem, pool.Allowed)
+	if cfg.Concurrency > pool.Allowed {
+		log.Printf("Warning: %d workers share %d connections; waits show up as connection acquire time", cfg.Concurrency, pool.Allowed)
+	}
+	return pool, nil
+}
+
+// runMetadata describes this analyzer build and host for the report.
+func runMetadata(cfg *config.Config) model.RunMetadata {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	gitSHA := cfg.GitSHA
+	if gitSHA == "" {
+		gitSHA = os.Getenv("FN_GIT_SHA")
+	}
+
+	return model.RunMetadata{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Hostname:  hostname,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+		GitSHA:    gitSHA,
+	}
+}
+
+// runTeardown runs the teardown scripts once the run is over, whether or not
+// it or its setup succeeded. By then the reports are written, so a failure is only logged.
+// It gets a context of its own: an interrupted run still cleans up.
+func runTeardown(db *sql.DB, cfg *config.Config) {
+	log.Printf("Running %d teardown scripts...", len(cfg.TeardownScripts))
+	run, err := database.RunScripts(context.Background(), db, cfg.TeardownScripts, scriptTimeout(cfg))
+	if err != nil {
+		log.Printf("Warning: teardown failed after %d statements: %v", run.Statements, err)
+		return
+	}
+	log.Printf("Teardown ran %d statements in %s (not part of the test duration)", run.Statements, run.Duration.Round(time.Millisecond))
+}
+
+func scriptTimeout(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.ScriptTimeoutSeconds * float64(time.Second))
+}
+
+func setupDuration(setup *database.ScriptRun) time.Duration {
+	if setup == nil {
+		return 0
+	}
+	return setup.Duration
+}
+
+// writeSoakSnapshot writes one snapshot of a soak as a report of its own,
+// labelled with its sequence number. A snapshot that can't be written is
+// logged and the soak goes on.
+func writeSoakSnapshot(cfg *config.Config, results []model.QueryResult, soak model.Soak, connInfo database.ConnectionInfo) {
+	snapCfg := *cfg
+	snapCfg.Label = fmt.Sprintf("%s-snapshot-%03d", cfg.Label, soak.Sequence)
+	snapCfg.NoSummary = true
+
+	run := model.TestResult{
+		Config:         redactConfig(snapCfg),
+		TotalDuration:  soak.WindowEnd.Sub(soak.WindowStart),
+		ConnectionInfo: connInfo,
+		Environment: model.Environment{
+			DSNHost:       database.DSNHost(cfg.DSN),
+			ServerVersion: connInfo.Version,
+		},
+		Metadata: runMetadata(cfg),
+		Soak:     &soak,
+	}
+	if analyzer.UsesSeed(*cfg) {
+		run.Seed = cfg.Seed
+	}
+	if _, err := analyzer.GenerateReports(results, run); err != nil {
+		log.Printf("Warning: couldn't write soak snapshot %d: %v", soak.Sequence, err)
+	}
+}
+
+// redactConfig returns cfg with the passwords in its DSNs masked, as it is
+// recorded in reports and served by serve.
+func redactConfig(cfg config.Config) config.Config {
+	cfg.DSN = database.RedactDSN(cfg.DSN)
+	cfg.BeforeDSN = database.RedactDSN(cfg.BeforeDSN)
+	cfg.AfterDSN = database.RedactDSN(cfg.AfterDSN)
+	return cfg
+}
+
+// pickSeed gives a run that makes random choices a seed if it has none, and
+// logs it so the run can be repeated.
+func pickSeed(cfg *config.Config) {
+	if !analyzer.UsesSeed(*cfg) || cfg.Seed != 0 {
+		return
+	}
+	cfg.Seed = analyzer.NewSeed()
+	log.Printf("Using random seed %d (pass --seed %d to repeat this run's order)", cfg.Seed, cfg.Seed)
+}
+
+// checkServerVersion fails unless the server is at least minVersion, so a
+// suite that needs newer SQL fails once up front instead of with an error
+// per query.
+func checkServerVersion(db *sql.DB, minVersion string) error {
+	min, err := database.ParseMinServerVersion(minVersion)
+	if err != nil {
+		return fmt.Errorf("invalid minServerVersion: %w", err)
+	}
+	info, err := database.GetConnectionInfo(db)
+	if info.Version == "" {
+		return fmt.Errorf("error reading the server version for minServerVersion: %w", err)
+	}
+	version, err := database.ParseServerVersion(info.Version)
+	if err != nil {
+		return fmt.Errorf("error checking minServerVersion: %w", err)
+	}
+	if version.MariaDB != min.MariaDB {
+		return fmt.Errorf("server version %s is %s, but minServerVersion %s is for %s",
+			info.Version, version.Flavor(), minVersion, min.Flavor())
+	}
+	if !version.AtLeast(min) {
+		return fmt.Errorf("server version %s (%s) is below minServerVersion %s; the suite needs a newer server",
+			info.Version, version, minVersion)
+	}
+	log.Printf("Server version %s meets minServerVersion %s", info.Version, minVersion)
+	return nil
+}
+
+// startAlertMonitor samples server metrics every cfg.Alerts.IntervalSeconds
+// on a connection of its own, so sampling neither waits for nor takes a slot
+// in the run's pool, and checks each sample against rules.
+func startAlertMonitor(ctx context.Context, cfg *config.Config, rules []database.AlertRule) (*analyzer.AlertMonitor, func(), error) {
+	db, err := database.Connect(cfg.DSN, 1, connectRetry(cfg))
+	if err != nil {
+		return nil, nil, withExitCode(exitConnection, fmt.Errorf("error connecting for alert monitoring: %w", err))
+	}
+	db.SetMaxOpenConns(1)
+
+	monitor := analyzer.NewAlertMonitor(rules)
+	ctx, cancel := context.WithCancel(ctx)
+	interval := time.Duration(cfg.Alerts.IntervalSeconds * float64(time.Second))
+	database.RunMetricsCollector(ctx, db, interval, monitor.Observe)
+
+	log.Printf("Checking %d alert rules every %s", len(rules), interval)
+	return monitor, func() {
+		cancel()
+		db.Close()
+	}, nil
+}
+
+// startGuard samples server metrics every cfg.Guardrails.IntervalSeconds on
+// a connection of its own, like startAlertMonitor, so it can still sample a
+// server busy enough to trip a rule, and feeds each sample to a guard.
+func startGuard(ctx context.Context, cfg *config.Config, rules []database.GuardrailRule) (*analyzer.Guard, func(), error) {
+	db, err := database.Connect(cfg.DSN, 1, connectRetry(cfg))
+	if err != nil {
+		return nil, nil, withExitCode(exitConnection, fmt.Errorf("error connecting for guardrails: %w", err))
+	}
+	db.SetMaxOpenConns(1)
+
+	guard := analyzer.NewGuard(rules)
+	ctx, cancel := context.WithCancel(ctx)
+	interval := time.Duration(cfg.Guardrails.IntervalSeconds * float64(time.Second))
+	database.RunMetricsCollector(ctx, db, interval, guard.Observe)
+
+	log.Printf("Checking %d guardrails every %s", len(rules), interval)
+	return guard, func() {
+		cancel()
+		db.Close()
+	}, nil
+}
+
+// runOutcome turns a completed run into its exit status. SLA violations fail
+// the run as an assertion. Failed executions fail it as query errors, except
+// on queries with a minSuccessRate they stayed within.
+func runOutcome(result model.TestResult) error {
+	var breached, unstable []string
+	var failed int
+	for _, q := range result.QueryResults {
+		if len(q.SLAViolations) > 0 {
+			breached = append(breached, q.Name)
+		}
+		if q.NonDeterministicRowCount {
+			unstable = append(unstable, q.Name)
+		}
+		if q.MinSuccessRate == 0 {
+			failed += q.Errors
+		}
+	}
+
+	if len(breached) > 0 {
+		return withExitCode(exitAssertion, fmt.Errorf("%d queries violated their SLA: %s",
+			len(breached), strings.Join(breached, ", ")))
+	}
+	if result.Config.Alerts.FailOnAlert && len(result.Alerts) > 0 {
+		rules := make([]string, len(result.Alerts))
+		for i, alert := range result.Alerts {
+			rules[i] = alert.Rule
+		}
+		slices.Sort(rules)
+		return withExitCode(exitAssertion, fmt.Errorf("%d alerts fired: %s",
+			len(result.Alerts), strings.Join(slices.Compact(rules), ", ")))
+	}
+	if result.Config.FailOnNonDeterministic && len(unstable) > 0 {
+		return withExitCode(exitAssertion, fmt.Errorf("%d queries returned varying row counts: %s",
+			len(unstable), strings.Join(unstable, ", ")))
+	}
+	if failed > 0 {
+		return withExitCode(exitQueryErrors, fmt.Errorf("%d of %d query executions failed",
+			failed, result.Summary.TotalExecutions))
+	}
+	return nil
+}
+
+// compareWithBaseline compares result with the most recent run in the
+// configured baseline directory that finished before this one started at
+// start, returning the comparison. A missing baseline is not an error; it
+// returns nil.
+func compareWithBaseline(cfg *config.Config, result model.TestResult, start time.Time) *model.ComparisonResult {
+	path, err := report.FindLatestResult(cfg.CompareBaselineDir, start)
+	if err != nil {
+		log.Printf("Skipping baseline comparison: %v", err)
+		return nil
+	}
+
+	baseline, err := report.LoadResult(path)
+	if err != nil {
+		log.Printf("Skipping baseline comparison: %v", err)
+		return nil
+	}
+
+	comparison := report.BuildComparison(baseline, result)
+	if err := report.SaveComparison(comparison, cfg.OutputDir); err != nil {
+		log.Printf("Warning: couldn't save baseline comparison: %v", err)
+	}
+	if err := saveChanges(cfg, comparison); err != nil {
+		log.Printf("Warning: couldn't save baseline changes: %v", err)
+	}
+
+	log.Printf("Compared with %s (%s): average query time improved %.1f%%",
+		path, baseline.Label, comparison.ImprovementSummary.AvgTimeImprovement)
+	return &comparison
+}
+
+// writeStepSummary appends the run's summary, with comparison if there is
+// one, to the configured step summary file. Failing to is only logged: the
+// reports are already written.
+func writeStepSummary(cfg *config.Config, result model.TestResult, comparison *model.ComparisonResult) {
+	if cfg.StepSummary.Path == "" {
+		return
+	}
+	if err := report.AppendStepSummary(cfg.StepSummary.Path, result, comparison, runOutcome(result), cfg.StepSummary.MaxRows); err != nil {
+		log.Printf("Warning: couldn't write step summary: %v", err)
+	}
+}
+
+// printQuietSummary writes the single-line JSON summary printed in --quiet
+// mode.
+func printQuietSummary(label string, result model.TestResult, err error) {
+	line := struct {
+		ExitCode int                  `json:"exitCode"`
+		Label    string               `json:"label"`
+		Error    string               `json:"error,omitempty"`
+		Summary  *model.ResultSummary `json:"summary,omitempty"`
+	}{
+		ExitCode: exitCode(err),
+		Label:    label,
+	}
+
+	if err != nil {
+		line.Error = err.Error()
+	}
+	if !result.Timestamp.IsZero() {
+		line.Summary = &result.Summary
+	}
+
+	data, _ := json.Marshal(line)
+	fmt.Println(string(data))
+}
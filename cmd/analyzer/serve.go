@@ -0,0 +1,282 @@
+// cmd/analyzer/serve.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+var serveCmd = &command{
+	name:    "serve",
+	summary: "Run as an HTTP service that triggers runs and serves results",
+	usage:   "serve [flags]",
+	examples: []string{
+		"fn-analyzer serve",
+		"fn-analyzer serve --listen :8080",
+		"curl -X POST localhost:8080/runs -d '{\"label\":\"ci\",\"iterations\":20,\"only\":[\"orders_*\"]}'",
+		"curl localhost:8080/runs/1",
+		"curl localhost:8080/runs/1/result",
+		"curl -X DELETE localhost:8080/runs/1",
+	},
+}
+
+func init() {
+	serveCmd.run = runServe
+}
+
+const (
+	runStatusRunning   = "running"
+	runStatusCompleted = "completed"
+	runStatusFailed    = "failed"
+	runStatusCancelled = "cancelled"
+)
+
+// runRequest is the body accepted by POST /runs. Zero values keep the
+// server's config.
+type runRequest struct {
+	Label       string   `json:"label"` // Becomes part of report paths, so no separators or ".."
+	Iterations  int      `json:"iterations"`
+	Concurrency int      `json:"concurrency"`
+	QueriesFile string   `json:"queriesFile"` // Only the server's own queries file is accepted
+	Only        []string `json:"only"`
+	Skip        []string `json:"skip"`
+}
+
+type serverRun struct {
+	ID         string     `json:"id"`
+	Label      string     `json:"label"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Completed  int        `json:"completedExecutions"`
+	Total      int        `json:"totalExecutions"`
+
+	cancel   context.CancelFunc
+	analyzer *analyzer.Analyzer
+	result   *model.TestResult
+}
+
+type server struct {
+	cfg *config.Config
+
+	mu      sync.Mutex
+	runs    map[string]*serverRun
+	nextID  int
+	current *serverRun
+}
+
+func runServe(args []string) error {
+	fs, common := newFlagSet(serveCmd)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on; the API has no authentication, so widen it with care")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	s := &server{cfg: cfg, runs: make(map[string]*serverRun)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.handleCreateRun)
+	mux.HandleFunc("GET /runs/{id}", s.handleGetRun)
+	mux.HandleFunc("GET /runs/{id}/result", s.handleGetResult)
+	mux.HandleFunc("DELETE /runs/{id}", s.handleCancelRun)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+
+	log.Printf("Listening on %s", *listen)
+	return http.ListenAndServe(*listen, mux)
+}
+
+func (s *server) handleCreateRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	if strings.ContainsAny(req.Label, `/\`) || strings.Contains(req.Label, "..") {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid label %q: path separators and \"..\" aren't allowed", req.Label))
+		return
+	}
+	if req.QueriesFile != "" && filepath.Clean(req.QueriesFile) != filepath.Clean(s.cfg.QueriesFile) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid queriesFile %q: only the server's %s can be run", req.QueriesFile, s.cfg.QueriesFile))
+		return
+	}
+
+	cfg := *s.cfg
+	if req.Label != "" {
+		cfg.Label = req.Label
+	}
+	if req.Iterations > 0 {
+		cfg.Iterations = req.Iterations
+	}
+	if req.Concurrency > 0 {
+		cfg.Concurrency = req.Concurrency
+	}
+	if len(req.Only) > 0 {
+		cfg.Only = req.Only
+	}
+	if len(req.Skip) > 0 {
+		cfg.Skip = req.Skip
+	}
+
+	s.mu.Lock()
+	if s.current != nil {
+		id := s.current.ID
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("run %s is already in progress", id))
+		return
+	}
+
+	s.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &serverRun{
+		ID:        strconv.Itoa(s.nextID),
+		Label:     cfg.Label,
+		Status:    runStatusRunning,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+	s.runs[run.ID] = run
+	s.current = run
+	s.mu.Unlock()
+
+	go s.execute(ctx, run, &cfg)
+
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
+
+func (s *server) execute(ctx context.Context, run *serverRun, cfg *config.Config) {
+	defer run.cancel()
+
+	result, err := executeRun(ctx, cfg, run.StartedAt, func(a *analyzer.Analyzer) {
+		s.mu.Lock()
+		run.analyzer = a
+		s.mu.Unlock()
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if run.analyzer != nil {
+		run.Completed, run.Total = run.analyzer.Progress()
+		run.analyzer = nil
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	s.current = nil
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		run.Status = runStatusCancelled
+	case err != nil:
+		run.Status = runStatusFailed
+		run.Error = err.Error()
+	default:
+		run.Status = runStatusCompleted
+		run.result = &result
+	}
+
+	log.Printf("Run %s %s", run.ID, run.Status)
+}
+
+// snapshot returns a copy of run with up-to-date progress.
+func (s *server) snapshot(run *serverRun) serverRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if run.analyzer != nil {
+		run.Completed, run.Total = run.analyzer.Progress()
+	}
+	return *run
+}
+
+func (s *server) lookup(w http.ResponseWriter, r *http.Request) *serverRun {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("run %s not found", r.PathValue("id")))
+		return nil
+	}
+	return run
+}
+
+func (s *server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.lookup(w, r); run != nil {
+		writeJSON(w, http.StatusOK, s.snapshot(run))
+	}
+}
+
+func (s *server) handleGetResult(w http.ResponseWriter, r *http.Request) {
+	run := s.lookup(w, r)
+	if run == nil {
+		return
+	}
+
+	snap := s.snapshot(run)
+	if snap.result == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("run %s has no result (status %s)", run.ID, snap.Status))
+		return
+	}
+	writeJSON(w, http.StatusOK, snap.result)
+}
+
+func (s *server) handleCancelRun(w http.ResponseWriter, r *http.Request) {
+	run := s.lookup(w, r)
+	if run == nil {
+		return
+	}
+
+	run.cancel()
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	probe, err := database.Probe(ctx, s.cfg.DSN)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":     "ok",
+		"version":    probe.Version,
+		"pingTimeMs": float64(probe.PingTime.Microseconds()) / 1000,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
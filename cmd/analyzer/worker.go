@@ -0,0 +1,271 @@
+// cmd/analyzer/worker.go
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/0xsj/fn-analyzer/internal/analyzer"
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/internal/telemetry"
+)
+
+var workerCmd = &command{
+	name:    "worker",
+	summary: "Generate load for a distributed run started elsewhere with --workers",
+	usage:   "worker [flags]",
+	examples: []string{
+		"FN_WORKER_TOKEN=secret fn-analyzer worker --listen :9000",
+		"FN_WORKER_TOKEN=secret fn-analyzer run --workers loadgen1:9000,loadgen2:9000",
+	},
+}
+
+func init() {
+	workerCmd.run = runWorker
+}
+
+// workerShard is the shard a worker is running or last ran.
+type workerShard struct {
+	status   analyzer.ShardStatus
+	cancel   context.CancelFunc
+	analyzer *analyzer.Analyzer
+	result   analyzer.ShardResult
+}
+
+// worker runs one shard at a time for a coordinator. Only the latest shard is
+// kept, so a long-lived worker doesn't accumulate executions.
+type worker struct {
+	cfg *config.Config
+
+	mu     sync.Mutex
+	shard  *workerShard
+	nextID int
+}
+
+func runWorker(args []string) error {
+	fs, common := newFlagSet(workerCmd)
+	listen := fs.String("listen", ":9000", "Address to listen on")
+	if done, err := parseFlags(fs, args); done {
+		return err
+	}
+
+	// The worker runs whatever SQL it is sent against its database, so it
+	// only answers a coordinator that knows the token.
+	token := os.Getenv(analyzer.WorkerTokenEnv)
+	if token == "" {
+		return fmt.Errorf("worker needs a shared token: set %s here and on the coordinator", analyzer.WorkerTokenEnv)
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	w := &worker{cfg: cfg}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shards", w.handleCreateShard)
+	mux.HandleFunc("GET /shards/{id}", w.handleGetShard)
+	mux.HandleFunc("GET /shards/{id}/result", w.handleGetResult)
+	mux.HandleFunc("DELETE /shards/{id}", w.handleCancelShard)
+
+	log.Printf("Worker listening on %s", *listen)
+	return http.ListenAndServe(*listen, requireToken(token, mux))
+}
+
+// requireToken rejects any request that doesn't carry token as its bearer
+// token.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeError(rw, http.StatusUnauthorized, fmt.Errorf("missing or wrong worker token"))
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (w *worker) handleCreateShard(rw http.ResponseWriter, r *http.Request) {
+	var req analyzer.Shard
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid shard: %w", err))
+		return
+	}
+	if len(req.Queries) == 0 || req.Config.Iterations <= 0 || req.Config.Concurrency <= 0 {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid shard: needs queries, iterations and concurrency"))
+		return
+	}
+	// The worker's own patterns apply, whatever the coordinator allows.
+	if err := analyzer.CheckTableAccess(req.Queries, w.cfg.AllowedTablesPattern, w.cfg.DeniedTablesPattern); err != nil {
+		writeError(rw, http.StatusForbidden, fmt.Errorf("shard refused: %w", err))
+		return
+	}
+
+	w.mu.Lock()
+	if w.shard != nil && w.shard.status.Status == analyzer.ShardRunning {
+		id := w.shard.status.ID
+		w.mu.Unlock()
+		writeError(rw, http.StatusConflict, fmt.Errorf("shard %s is already in progress", id))
+		return
+	}
+
+	w.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	shard := &workerShard{
+		status: analyzer.ShardStatus{
+			ID:     strconv.Itoa(w.nextID),
+			Status: analyzer.ShardRunning,
+			Total:  len(req.Queries) * req.Config.Iterations,
+		},
+		cancel: cancel,
+	}
+	w.shard = shard
+	w.mu.Unlock()
+
+	log.Printf("Shard %s: %d queries, %d iterations, concurrency %d",
+		shard.status.ID, len(req.Queries), req.Config.Iterations, req.Config.Concurrency)
+	go w.execute(ctx, shard, req)
+
+	writeJSON(rw, http.StatusAccepted, w.snapshot(shard))
+}
+
+func (w *worker) execute(ctx context.Context, shard *workerShard, req analyzer.Shard) {
+	defer shard.cancel()
+
+	results, err := w.runShard(ctx, shard, req)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var heatmap *model.Heatmap
+	if shard.analyzer != nil {
+		shard.status.Completed, _ = shard.analyzer.Progress()
+		heatmap = shard.analyzer.Heatmap()
+		shard.analyzer = nil
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		shard.status.Status = analyzer.ShardCancelled
+	case err != nil:
+		shard.status.Status = analyzer.ShardFailed
+		shard.status.Error = err.Error()
+	default:
+		shard.status.Status = analyzer.ShardCompleted
+		shard.result = analyzer.NewShardResult(results, heatmap, req.Config.WorkerExecutions)
+	}
+
+	log.Printf("Shard %s %s", shard.status.ID, shard.status.Status)
+}
+
+// runShard runs the coordinator's queries with the coordinator's settings
+// against this worker's own DSN. Explain plans and profiling are left to the
+// coordinator.
+func (w *worker) runShard(ctx context.Context, shard *workerShard, req analyzer.Shard) ([]model.QueryResult, error) {
+	cfg := req.Config
+	cfg.Workers = nil
+	cfg.CollectExplainPlans = false
+	cfg.ProfileSlowest = false
+	cfg.MeasureLocks = false
+
+	dsn, err := database.WithIsolationLevel(w.cfg.DSN, cfg.IsolationLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid isolationLevel: %w", err)
+	}
+	cfg.DSN = dsn
+
+	db, err := database.Connect(cfg.DSN, cfg.Concurrency, connectRetry(w.cfg))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := limitPool(db, &cfg); err != nil {
+		return nil, err
+	}
+
+	if err := analyzer.WarmupConnectionPool(db, cfg.WarmupIterations); err != nil {
+		return nil, fmt.Errorf("error during warmup: %w", err)
+	}
+
+	tracer, stopTracing, err := telemetry.StartTracing(ctx, Version)
+	if err != nil {
+		return nil, err
+	}
+	defer stopTracing()
+
+	a := analyzer.NewAnalyzer(db, req.Queries, cfg)
+	a.TraceExecutions(tracer)
+	w.mu.Lock()
+	shard.analyzer = a
+	w.mu.Unlock()
+
+	return a.RunContext(ctx)
+}
+
+// snapshot returns the shard's status with up-to-date progress.
+func (w *worker) snapshot(shard *workerShard) analyzer.ShardStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if shard.analyzer != nil {
+		shard.status.Completed, _ = shard.analyzer.Progress()
+	}
+	return shard.status
+}
+
+func (w *worker) lookup(rw http.ResponseWriter, r *http.Request) *workerShard {
+	w.mu.Lock()
+	shard := w.shard
+	w.mu.Unlock()
+
+	if shard == nil || shard.status.ID != r.PathValue("id") {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("shard %s not found", r.PathValue("id")))
+		return nil
+	}
+	return shard
+}
+
+func (w *worker) handleGetShard(rw http.ResponseWriter, r *http.Request) {
+	if shard := w.lookup(rw, r); shard != nil {
+		writeJSON(rw, http.StatusOK, w.snapshot(shard))
+	}
+}
+
+func (w *worker) handleGetResult(rw http.ResponseWriter, r *http.Request) {
+	shard := w.lookup(rw, r)
+	if shard == nil {
+		return
+	}
+
+	w.mu.Lock()
+	status, result := shard.status, shard.result
+	w.mu.Unlock()
+
+	if status.Status != analyzer.ShardCompleted {
+		writeError(rw, http.StatusConflict, fmt.Errorf("shard %s has no result (status %s)", status.ID, status.Status))
+		return
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+func (w *worker) handleCancelShard(rw http.ResponseWriter, r *http.Request) {
+	shard := w.lookup(rw, r)
+	if shard == nil {
+		return
+	}
+
+	shard.cancel()
+	writeJSON(rw, http.StatusAccepted, w.snapshot(shard))
+}
@@ -0,0 +1,93 @@
+// internal/analyzer/alerts.go
+package analyzer
+
+import (
+	"log"
+	"sync"
+
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// AlertMonitor evaluates alert rules against the server metrics sampled
+// during a run and records each episode in which a rule held. It is safe to
+// feed from a collector goroutine while the run reads it.
+type AlertMonitor struct {
+	rules []database.AlertRule
+
+	mu      sync.Mutex
+	history []database.DBMetrics
+	alerts  []model.Alert
+	firing  map[int]int // Rule index to its open episode in alerts
+}
+
+func NewAlertMonitor(rules []database.AlertRule) *AlertMonitor {
+	return &AlertMonitor{rules: rules, firing: make(map[int]int)}
+}
+
+// Observe records sample and opens, extends or resolves an alert for every
+// rule.
+func (m *AlertMonitor) Observe(sample database.DBMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.history = append(m.history, sample)
+
+	for i, rule := range m.rules {
+		value, holds := rule.Check(sample)
+		open, isFiring := m.firing[i]
+
+		switch {
+		case holds && !isFiring:
+			m.firing[i] = len(m.alerts)
+			m.alerts = append(m.alerts, model.Alert{
+				Rule:       rule.Expr,
+				Metric:     rule.Metric,
+				Threshold:  rule.Threshold,
+				Value:      value,
+				WorstValue: value,
+				FiredAt:    sample.Timestamp,
+				Samples:    1,
+			})
+			log.Printf("Warning: alert fired: %s (%s = %.4g)", rule.Expr, rule.Metric, value)
+		case holds:
+			alert := &m.alerts[open]
+			alert.Samples++
+			if worse(rule.Op, value, alert.WorstValue) {
+				alert.WorstValue = value
+			}
+		case isFiring:
+			resolved := sample.Timestamp
+			m.alerts[open].ResolvedAt = &resolved
+			delete(m.firing, i)
+			log.Printf("Alert resolved: %s (%s = %.4g)", rule.Expr, rule.Metric, value)
+		}
+	}
+}
+
+// worse reports whether value is further past a threshold compared with op
+// than current is.
+func worse(op string, value, current float64) bool {
+	switch op {
+	case "<", "<=":
+		return value < current
+	case ">", ">=":
+		return value > current
+	default:
+		return false
+	}
+}
+
+// Alerts returns the episodes recorded so far, in the order they fired.
+func (m *AlertMonitor) Alerts() []model.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]model.Alert(nil), m.alerts...)
+}
+
+// History returns every sample observed so far.
+func (m *AlertMonitor) History() []database.DBMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]database.DBMetrics(nil), m.history...)
+}
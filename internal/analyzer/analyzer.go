@@ -8,30 +8,38 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"slices"
-
 	"github.com/0xsj/fn-analyzer/internal/config"
-	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/environment"
 	"github.com/0xsj/fn-analyzer/internal/model"
 	"github.com/0xsj/fn-analyzer/internal/report"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
 )
 
 type Analyzer struct {
 	db          *sql.DB
+	executor    *QueryExecutor
 	queries     []model.Query
 	config      config.Config
 	concurrency int
 	iterations  int
 	timeout     time.Duration
 	verbose     bool
+	workers     []model.WorkerRun // Filled in by a distributed run
+	soak        *model.Soak       // Filled in by a soak run
+	onSnapshot  func([]model.QueryResult, model.Soak)
+	workerToken string // Presented to the workers of a distributed run
 }
 
 func NewAnalyzer(db *sql.DB, queries []model.Query, cfg config.Config) *Analyzer {
 	return &Analyzer{
 		db:          db,
+		executor:    NewQueryExecutor(db, cfg),
 		queries:     queries,
 		config:      cfg,
 		concurrency: cfg.Concurrency,
@@ -41,20 +49,90 @@ func NewAnalyzer(db *sql.DB, queries []model.Query, cfg config.Config) *Analyzer
 	}
 }
 
+// LoadQueries loads queries from a comma-separated list of files or glob
+// patterns and concatenates them in order. A query name defined in more than
+// one file is an error.
 func LoadQueries(path string) ([]model.Query, error) {
-	data, err := os.ReadFile(path)
+	files, err := resolveQueryFiles(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading queries file: %w", err)
+		return nil, err
 	}
 
 	var queries []model.Query
-	if err := json.Unmarshal(data, &queries); err != nil {
-		return nil, fmt.Errorf("error parsing queries file: %w", err)
+	sources := make(map[string]string)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading queries file %s: %w", file, err)
+		}
+
+		var fileQueries []model.Query
+		if err := json.Unmarshal(data, &fileQueries); err != nil {
+			return nil, fmt.Errorf("error parsing queries file %s: %w", file, err)
+		}
+
+		for _, q := range fileQueries {
+			if prev, ok := sources[q.Name]; ok && prev != file {
+				return nil, fmt.Errorf("duplicate query name %q in %s (already defined in %s)", q.Name, file, prev)
+			}
+			sources[q.Name] = file
+			if q.SLOMs < 0 || q.SLOPercentile < 0 || q.SLOPercentile >= 100 {
+				return nil, fmt.Errorf("invalid SLO for query %q in %s: sloMs must not be negative and sloPercentile must be below 100", q.Name, file)
+			}
+			if q.SLOPercentile > 0 && q.SLOMs == 0 {
+				return nil, fmt.Errorf("query %q in %s sets sloPercentile without sloMs", q.Name, file)
+			}
+			if q.CaptureSampleRows < 0 {
+				return nil, fmt.Errorf("invalid captureSampleRows for query %q in %s: must not be negative", q.Name, file)
+			}
+			q.LintWarnings = LintQuery(q.SQL)
+			queries = append(queries, q)
+		}
 	}
 
 	return queries, nil
 }
 
+func resolveQueryFiles(spec string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		matches := []string{part}
+		if strings.ContainsAny(part, "*?[") {
+			var err error
+			matches, err = filepath.Glob(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid queries pattern %q: %w", part, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no queries files match %q", part)
+			}
+			sort.Strings(matches)
+		}
+
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			files = append(files, m)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no queries file specified")
+	}
+
+	return files, nil
+}
+
 func WarmupConnectionPool(db *sql.DB, iterations int) error {
 	log.Printf("Warming up connection pool with %d iterations...", iterations)
 
@@ -81,176 +159,190 @@ func WarmupConnectionPool(db *sql.DB, iterations int) error {
 }
 
 func (a *Analyzer) Run() ([]model.QueryResult, error) {
-	var results []model.QueryResult
-	resultsMutex := sync.Mutex{}
-	semaphore := make(chan struct{}, a.concurrency)
-
-	for _, query := range a.queries {
-		result := model.QueryResult{
-			Name:            query.Name,
-			Description:     query.Description,
-			SQL:             query.SQL,
-			MinDuration:     time.Hour,
-			Weight:          query.Weight,
-			QueryComplexity: AnalyzeQueryComplexity(query.SQL),
-			Executions:      make([]model.QueryExecution, 0, a.iterations),
-		}
-
-		var durations []time.Duration
-		var wg sync.WaitGroup
-		resultMutex := sync.Mutex{}
-
-		log.Printf("Testing query: %s", query.Name)
-
-		for i := range a.iterations {
-			wg.Add(1)
-			semaphore <- struct{}{}
-
-			go func(iteration int) {
-				defer wg.Done()
-				defer func() { <-semaphore }()
-
-				queryResult := a.executeQuery(query.SQL)
-
-				resultMutex.Lock()
-				defer resultMutex.Unlock()
-
-				if len(result.Executions) == 0 {
-					result.FirstExecutedAt = queryResult.startTime
-				}
+	return a.RunContext(context.Background())
+}
 
-				result.LastExecutedAt = queryResult.startTime
+// Reconnects returns how many dedicated connections had to be replaced.
+func (a *Analyzer) Reconnects() int {
+	return a.executor.Reconnects()
+}
 
-				execution := model.QueryExecution{
-					SQL:       query.SQL,
-					StartTime: queryResult.startTime,
-					Duration:  queryResult.duration,
-					RowCount:  queryResult.rowCount,
-				}
+// Heatmap returns the run's executions by time window and latency bucket, or
+// nil before the run.
+func (a *Analyzer) Heatmap() *model.Heatmap {
+	if a.executor.heatmap == nil {
+		return nil
+	}
+	return a.executor.heatmap.heatmap()
+}
 
-				if queryResult.err != nil {
-					execution.ErrorMessage = queryResult.err.Error()
-					result.Errors++
-					if len(result.ErrorDetails) < 10 {
-						result.ErrorDetails = append(result.ErrorDetails, queryResult.err.Error())
-					}
+// Workers returns each worker's share of a distributed run, or nil for a run
+// executed in this process.
+func (a *Analyzer) Workers() []model.WorkerRun {
+	return a.workers
+}
 
-					result.Executions = append(result.Executions, execution)
-					return
-				}
+// OnQueryComplete registers fn to receive each query's result as soon as its
+// last iteration finishes, before the rest of the run is done. fn is called
+// from worker goroutines and must be safe for concurrent use. Register it
+// before the run starts.
+func (a *Analyzer) OnQueryComplete(fn func(model.QueryResult)) {
+	a.executor.onQueryDone = fn
+}
 
-				result.SuccessfulExecutions++
-				result.TotalDuration += queryResult.duration
-				result.RowsAffected += queryResult.rowCount
-				durations = append(durations, queryResult.duration)
+// OnExecution registers fn to receive each measured execution as it
+// finishes, with its query's name and complexity. fn is called from the
+// worker that ran it, between executions, so it must be quick and safe for
+// concurrent use. Register it before the run starts.
+func (a *Analyzer) OnExecution(fn func(query, complexity string, execution model.QueryExecution)) {
+	a.executor.onExecution = fn
+}
 
-				result.Executions = append(result.Executions, execution)
+// Progress reports how many executions have completed out of the total the
+// run will perform.
+func (a *Analyzer) Progress() (completed, total int) {
+	return int(a.executor.completed.Load()), len(a.queries) * a.iterations
+}
 
-				if queryResult.duration < result.MinDuration {
-					result.MinDuration = queryResult.duration
-				}
-				if queryResult.duration > result.MaxDuration {
-					result.MaxDuration = queryResult.duration
-				}
+// RunContext runs the suite until it completes or ctx is cancelled. On
+// cancellation in-flight queries are aborted and the results gathered so far
+// are returned along with the context error.
+func (a *Analyzer) RunContext(ctx context.Context) ([]model.QueryResult, error) {
+	var results []model.QueryResult
+	var err error
 
-				if a.verbose && (iteration == 0 || (iteration+1)%10 == 0) {
-					log.Printf("Query %s iteration %d: %v, %d rows",
-						query.Name, iteration+1, queryResult.duration, queryResult.rowCount)
-				}
-			}(i)
-		}
+	ctx, endSpan := a.startRunSpan(ctx)
+	defer func() { endSpan(err) }()
 
-		wg.Wait()
+	if a.config.MeasureLocks && (len(a.config.Workers) > 0 || a.config.Soak.Enabled()) {
+		log.Printf("Warning: measureLocks is ignored in distributed and soak runs")
+	}
 
-		if result.SuccessfulExecutions > 0 {
-			result.AvgDuration = result.TotalDuration / time.Duration(result.SuccessfulExecutions)
+	if len(a.config.Workers) > 0 {
+		results, err = a.runDistributed(ctx)
+	} else if a.config.Soak.Enabled() {
+		results, err = a.runSoak(ctx)
+	} else if a.config.MeasureLocks {
+		results, err = a.runMeasuringLocks(ctx)
+	} else {
+		order := a.config.ExecutionOrder
+		if order == "" {
+			order = OrderRoundRobin
 		}
+		log.Printf("Dispatching %d executions in %s order across %d workers",
+			len(a.queries)*a.iterations, order, a.concurrency)
 
-		if len(durations) > 0 {
-			slices.Sort(durations)
-			idx95 := int(float64(len(durations)) * 0.95)
-			if idx95 >= len(durations) {
-				idx95 = len(durations) - 1
-			}
-			result.Percentile95 = durations[idx95]
-		}
+		results, err = a.executor.ExecuteBatchContext(ctx, a.queries, a.iterations)
+	}
 
-		resultsMutex.Lock()
-		results = append(results, result)
-		resultsMutex.Unlock()
+	if err == nil && a.config.CollectExplainPlans {
+		a.collectExplainPlans(results)
+	}
+	if err == nil && a.config.ProfileSlowest {
+		a.profileSlowest(ctx, results)
+	}
 
+	for _, result := range results {
 		avgMs := float64(result.AvgDuration.Microseconds()) / 1000
 		p95Ms := float64(result.Percentile95.Microseconds()) / 1000
 
-		log.Printf("  Results: %.2f ms avg, %.2f ms p95, %d rows, %s complexity",
-			avgMs, p95Ms, result.RowsAffected, result.QueryComplexity)
+		log.Printf("%s: %.2f ms avg, %.2f ms p95, %.1f qps, %d rows, %s complexity",
+			result.Name, avgMs, p95Ms, result.AchievedQPS, result.RowsAffected, result.QueryComplexity)
 	}
 
-	return results, nil
+	return results, err
 }
 
-type queryResult struct {
-	duration  time.Duration
-	rowCount  int64
-	err       error
-	startTime time.Time
+// collectExplainPlans fetches the EXPLAIN plan of every query, with its
+// explainParams bound, and records the warnings found in it. With
+// ExplainPlanFiles, JSON plans are written to their own files and the report
+// keeps only the file's path. results are in the order of a.queries.
+func (a *Analyzer) collectExplainPlans(results []model.QueryResult) {
+	for i := range results {
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.Timeout)
+		plan, err := GenerateQueryExplain(ctx, a.db, results[i].SQL, a.queries[i].ExplainParams...)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: couldn't explain %s: %v", results[i].Name, err)
+			continue
+		}
+		results[i].PlanWarnings = PlanWarnings(plan)
+		results[i].EstimatedCost, results[i].EstimatedRows = PlanEstimates(plan)
+
+		if a.config.ExplainPlanFiles && strings.HasPrefix(plan, "{") {
+			name := ExplainPlanFile(results[i].Name)
+			if err := os.WriteFile(filepath.Join(a.config.OutputDir, name), []byte(plan), 0644); err != nil {
+				log.Printf("Warning: couldn't write the explain plan of %s, keeping it in the report: %v", results[i].Name, err)
+			} else {
+				plan = name
+			}
+		}
+		results[i].ExplainPlan = plan
+	}
 }
 
-func (a *Analyzer) executeQuery(sql string) queryResult {
-	result := queryResult{
-		startTime: time.Now(),
+// GenerateReports completes the test result and writes the configured
+// reports. run carries what the caller recorded around the run: config,
+// duration, connection info, environment, metadata and server counters.
+// Everything derived from results, and the git state of the working
+// directory, is filled in here.
+func GenerateReports(results []model.QueryResult, run model.TestResult) (model.TestResult, error) {
+	cfg := run.Config
+
+	testResult := run
+	testResult.SchemaVersion = model.SchemaVersion
+	testResult.Timestamp = time.Now()
+	testResult.Label = cfg.Label
+	testResult.QueryResults = results
+	attributeTotalTime(results)
+	testResult.Summary = calculateSummary(results, utils.PercentileMethod(cfg.PercentileMethod))
+	testResult.TableBreakdown = tableBreakdown(results)
+	testResult.SchemaSpread = schemaSpread(results)
+	testResult.CostLatency = costLatency(results)
+	testResult.Connections, testResult.Summary.ConnectionLatencySpread = connectionBreakdown(results)
+	testResult.Summary.UnevenConnections = testResult.Summary.ConnectionLatencySpread >= unevenConnectionSpread
+	testResult.TimingScheme = model.TimingExcludesAcquire
+
+	if git, err := environment.DetectGit("."); err == nil {
+		testResult.Environment.GitCommit = git.Commit
+		testResult.Environment.GitBranch = git.Branch
+		testResult.Environment.GitDirty = git.Dirty
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
-	defer cancel()
-
-	rows, err := a.db.QueryContext(ctx, sql)
-	result.duration = time.Since(result.startTime)
-
-	if err != nil {
-		result.err = err
-		return result
+	if cfg.ValidateOutput {
+		if err := report.ValidateResult(testResult); err != nil {
+			return testResult, fmt.Errorf("output validation failed: %w", err)
+		}
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		result.rowCount++
+	if err := report.WriteReports(testResult, cfg.OutputDir, cfg.Formats); err != nil {
+		return testResult, err
 	}
 
-	if err = rows.Err(); err != nil {
-		result.err = err
+	if !cfg.Quiet && !cfg.NoSummary {
+		report.PrintSummary(testResult)
 	}
 
-	return result
+	return testResult, nil
 }
 
-func GenerateReports(results []model.QueryResult, connInfo database.ConnectionInfo, cfg config.Config, duration time.Duration) error {
-	summary := calculateSummary(results)
-
-	testResult := model.TestResult{
-		Timestamp:      time.Now(),
-		Label:          cfg.Label,
-		Config:         cfg,
-		TotalDuration:  duration,
-		QueryResults:   results,
-		ConnectionInfo: connInfo,
-		Summary:        summary,
+// attributeTotalTime sets each result's share of the time spent in
+// successful executions across all of results, which says which query the
+// run spent most of its time in, however fast each call.
+func attributeTotalTime(results []model.QueryResult) {
+	var total time.Duration
+	for _, result := range results {
+		total += result.TotalDuration
 	}
-
-	if err := report.SaveJSON(testResult, cfg.OutputDir); err != nil {
-		return fmt.Errorf("error saving JSON report: %w", err)
+	if total == 0 {
+		return
 	}
-
-	if err := report.SaveCSV(testResult, cfg.OutputDir); err != nil {
-		return fmt.Errorf("error saving CSV report: %w", err)
+	for i := range results {
+		results[i].PercentOfTotalTime = float64(results[i].TotalDuration) / float64(total) * 100
 	}
-
-	report.PrintSummary(testResult)
-
-	return nil
 }
 
-func calculateSummary(results []model.QueryResult) model.ResultSummary {
+func calculateSummary(results []model.QueryResult, method utils.PercentileMethod) model.ResultSummary {
 	summary := model.ResultSummary{
 		TotalQueries:        len(results),
 		QueriesByComplexity: make(map[string]int),
@@ -258,17 +350,32 @@ func calculateSummary(results []model.QueryResult) model.ResultSummary {
 
 	var totalDuration time.Duration
 	var maxDuration time.Duration
+	var totalConnect, totalClose time.Duration
+	var freshConnQueries int
+	var totalTxOverhead time.Duration
+	var txQueries int
+	var windowStart, windowEnd time.Time
+	var harnessOverhead time.Duration
+	var durations, acquireWaits []time.Duration
+	var latency, acquire utils.Histogram
+	errorsByType := make(map[string]int)
 
 	for _, result := range results {
-		summary.TotalExecutions += len(result.Executions)
+		executed := result.SuccessfulExecutions + result.Errors
+		summary.TotalExecutions += executed
 		summary.SuccessfulExecutions += result.SuccessfulExecutions
 		summary.FailedExecutions += result.Errors
 		summary.TotalRowsReturned += result.RowsAffected
 
-		if result.Errors == 0 {
+		switch {
+		case result.Errors == 0:
 			summary.SuccessfulQueries++
-		} else {
+		case result.FullyFailed():
+			summary.FailedQueries++
+			summary.FullyFailedQueries++
+		default:
 			summary.FailedQueries++
+			summary.PartiallyFailedQueries++
 		}
 
 		totalDuration += result.AvgDuration
@@ -277,8 +384,110 @@ func calculateSummary(results []model.QueryResult) model.ResultSummary {
 		}
 
 		summary.QueriesByComplexity[result.QueryComplexity]++
+		summary.LintWarnings += len(result.LintWarnings)
+		if result.NonDeterministicRowCount {
+			summary.NonDeterministicQueries++
+		}
+		if result.TimeoutCensored {
+			summary.TimeoutCensoredQueries++
+		}
+		if result.PlanFlipped {
+			summary.PlanFlippedQueries++
+		}
+		if slo := result.SLO; slo != nil {
+			summary.SLOQueries++
+			if slo.Executions > 0 && !slo.Met {
+				summary.SLOMissedQueries++
+			}
+		}
+
+		for _, exec := range result.Executions {
+			if exec.ErrorMessage == "" {
+				durations = append(durations, exec.Duration)
+				if exec.AcquireDuration > 0 {
+					acquireWaits = append(acquireWaits, exec.AcquireDuration)
+				}
+			}
+		}
+		if d := result.Distributions; d != nil {
+			latency.Merge(d.Latency)
+			acquire.Merge(d.Acquire)
+		}
+		for kind, n := range result.ErrorsByType {
+			errorsByType[kind] += n
+		}
+		harnessOverhead += result.AvgHarnessOverhead * time.Duration(executed)
+
+		start, end := executionWindow(result.Executions)
+		if start.IsZero() {
+			// Without executions, the window runs from the first start to
+			// the last.
+			start, end = result.FirstExecutedAt, result.LastExecutedAt
+		}
+		if !start.IsZero() && (windowStart.IsZero() || start.Before(windowStart)) {
+			windowStart = start
+		}
+		if end.After(windowEnd) {
+			windowEnd = end
+		}
+
+		if result.AvgTxOverhead > 0 {
+			totalTxOverhead += result.AvgTxOverhead
+			txQueries++
+		}
+
+		if result.AvgFreshConnOverall > 0 {
+			totalConnect += result.AvgConnectDuration
+			totalClose += result.AvgCloseDuration
+			freshConnQueries++
+		}
+	}
+
+	if window := windowEnd.Sub(windowStart); window > 0 {
+		summary.AchievedQPS = float64(summary.SuccessfulExecutions) / window.Seconds()
+	}
+
+	// A run keeps either every result's executions or, distributed without
+	// workerExecutions, every result's histograms.
+	if len(durations) > 0 || latency.Count > 0 {
+		stats := latency.Stats(method)
+		if len(durations) > 0 {
+			stats = utils.CalculateStatsInPlace(durations, method)
+		}
+		summary.MedianDurationMs = float64(stats.Median.Microseconds()) / 1000
+		summary.StdDevDurationMs = float64(stats.StdDev.Microseconds()) / 1000
+		summary.P95DurationMs = float64(stats.P95.Microseconds()) / 1000
+		summary.P99DurationMs = float64(stats.P99.Microseconds()) / 1000
+	}
+	if len(errorsByType) > 0 {
+		summary.ErrorsByType = errorsByType
+	}
+
+	if summary.TotalExecutions > 0 {
+		summary.HarnessOverheadUs = float64(harnessOverhead.Nanoseconds()) / float64(summary.TotalExecutions) / 1000
+	}
+
+	if txQueries > 0 {
+		summary.AvgTxOverheadMs = float64((totalTxOverhead / time.Duration(txQueries)).Microseconds()) / 1000
+	}
+
+	if len(acquireWaits) > 0 || acquire.Count > 0 {
+		stats := acquire.Stats(method)
+		if len(acquireWaits) > 0 {
+			stats = utils.CalculateStatsInPlace(acquireWaits, method)
+		}
+		summary.AvgAcquireMs = float64(stats.Mean.Microseconds()) / 1000
+		summary.P95AcquireMs = float64(stats.P95.Microseconds()) / 1000
+	}
+
+	if freshConnQueries > 0 {
+		summary.AvgConnectMs = float64((totalConnect / time.Duration(freshConnQueries)).Microseconds()) / 1000
+		summary.AvgCloseMs = float64((totalClose / time.Duration(freshConnQueries)).Microseconds()) / 1000
 	}
 
+	complexityVsLatency(&summary, results)
+	summary.ByStatementType = summarizeByStatementType(results, method)
+
 	if summary.TotalQueries > 0 {
 		avgDuration := totalDuration / time.Duration(summary.TotalQueries)
 		summary.AvgDurationMs = float64(avgDuration.Microseconds()) / 1000
@@ -287,3 +496,116 @@ func calculateSummary(results []model.QueryResult) model.ResultSummary {
 
 	return summary
 }
+
+// summarizeByStatementType aggregates executions per statement type so read
+// and write latency can be told apart.
+func summarizeByStatementType(results []model.QueryResult, method utils.PercentileMethod) map[string]model.StatementTypeSummary {
+	byType := make(map[string]model.StatementTypeSummary)
+	durations := make(map[string][]time.Duration)
+	histograms := make(map[string]*utils.Histogram)
+	successful := make(map[string]int)
+	total := make(map[string]time.Duration)
+	failed := make(map[string]int)
+
+	for _, result := range results {
+		kind := result.StatementType
+		if kind == "" {
+			kind = StatementOther
+		}
+		s := byType[kind]
+		s.Queries++
+		s.Executions += result.SuccessfulExecutions + result.Errors
+		byType[kind] = s
+
+		successful[kind] += result.SuccessfulExecutions
+		total[kind] += result.TotalDuration
+		failed[kind] += result.Errors
+		for _, exec := range result.Executions {
+			if exec.ErrorMessage == "" {
+				durations[kind] = append(durations[kind], exec.Duration)
+			}
+		}
+		if d := result.Distributions; d != nil {
+			if histograms[kind] == nil {
+				histograms[kind] = &utils.Histogram{}
+			}
+			histograms[kind].Merge(d.Latency)
+		}
+	}
+
+	for kind, s := range byType {
+		if n := successful[kind]; n > 0 {
+			s.AvgDurationMs = float64((total[kind] / time.Duration(n)).Microseconds()) / 1000
+		}
+		if d := durations[kind]; len(d) > 0 {
+			s.P95DurationMs = float64(utils.CalculatePercentileWith(d, 95, method).Microseconds()) / 1000
+		} else if h := histograms[kind]; h != nil && h.Count > 0 {
+			s.P95DurationMs = float64(h.Stats(method).P95.Microseconds()) / 1000
+		}
+		if s.Executions > 0 {
+			s.ErrorRate = float64(failed[kind]) / float64(s.Executions)
+		}
+		byType[kind] = s
+	}
+
+	return byType
+}
+
+// complexityVsLatency records on summary how well complexity predicts
+// latency across the queries that completed at least once: the Pearson and
+// Spearman correlation of complexity score with average latency, and the
+// average latency of each complexity level. It also lists the queries that
+// score at or below the median but whose average latency is in the slowest
+// quarter, since those are the ones the complexity label doesn't explain.
+// Outliers need at least four measured queries to be meaningful.
+func complexityVsLatency(summary *model.ResultSummary, results []model.QueryResult) {
+	var measured []model.QueryResult
+	totals := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for _, result := range results {
+		if result.SuccessfulExecutions > 0 {
+			measured = append(measured, result)
+			totals[result.QueryComplexity] += result.AvgDuration
+			counts[result.QueryComplexity]++
+		}
+	}
+	if len(measured) == 0 {
+		return
+	}
+
+	summary.AvgDurationMsByComplexity = make(map[string]float64, len(totals))
+	for level, total := range totals {
+		summary.AvgDurationMsByComplexity[level] = float64((total / time.Duration(counts[level])).Microseconds()) / 1000
+	}
+
+	if len(measured) < 2 {
+		return
+	}
+
+	scores := make([]float64, len(measured))
+	latencies := make([]float64, len(measured))
+	for i, result := range measured {
+		scores[i] = float64(result.ComplexityScore)
+		latencies[i] = float64(result.AvgDuration)
+	}
+	summary.ComplexityLatencyQueries = len(measured)
+	summary.ComplexityLatencyCorrelation = utils.PearsonCorrelation(scores, latencies)
+	summary.ComplexityLatencySpearman = utils.SpearmanCorrelation(scores, latencies)
+	if len(measured) < 4 {
+		return
+	}
+
+	sortedScores := append([]float64(nil), scores...)
+	sort.Float64s(sortedScores)
+	medianScore := sortedScores[(len(sortedScores)-1)/2]
+
+	sortedLatencies := append([]float64(nil), latencies...)
+	sort.Float64s(sortedLatencies)
+	slowLatency := sortedLatencies[len(sortedLatencies)*3/4]
+
+	for i, result := range measured {
+		if scores[i] <= medianScore && latencies[i] >= slowLatency {
+			summary.SimpleButSlow = append(summary.SimpleButSlow, result.Name)
+		}
+	}
+}
@@ -4,79 +4,370 @@ package analyzer
 import (
 	"regexp"
 	"strings"
-)
-
-func AnalyzeQueryComplexity(sql string) string {
-	sql = strings.ToLower(sql)
-
-	joinCount := strings.Count(sql, "join")
-
-	hasAggregation := strings.Contains(sql, "group by") ||
-		strings.Contains(sql, "count(") ||
-		strings.Contains(sql, "sum(") ||
-		strings.Contains(sql, "avg(") ||
-		strings.Contains(sql, "max(") ||
-		strings.Contains(sql, "min(")
 
-	hasSubquery := strings.Count(sql, "select") > 1
+	"github.com/0xsj/fn-analyzer/internal/config"
+)
 
-	hasOrdering := strings.Contains(sql, "order by")
+// queryFeatures counts the constructs that make a statement expensive to
+// plan and execute.
+type queryFeatures struct {
+	joins           int
+	subqueries      int
+	aggregations    int
+	windowFunctions int
+	conditions      int // AND/OR connectives
+	ctes            int
+	unions          int
+	ordering        bool
+	having          bool
+}
 
-	hasWindowFunc := strings.Contains(sql, "over (") ||
-		strings.Contains(sql, "over(") ||
-		strings.Contains(sql, "rank()") ||
-		strings.Contains(sql, "row_number()")
+// ComplexityClassifier assigns complexity levels under a set of rules.
+type ComplexityClassifier struct {
+	rules config.ComplexityRules
+}
 
-	conditionComplexity := strings.Count(sql, " and ") + strings.Count(sql, " or ")
+// inspect returns the features of sql, from its parse tree when the rules
+// use the parser and it understands sql, and from keyword heuristics
+// otherwise.
+func (c ComplexityClassifier) inspect(sql string) queryFeatures {
+	if c.rules.Analyzer != config.ComplexityAnalyzerHeuristic {
+		if stmt, ok := parseStatement(sql); ok {
+			return parsedFeatures(stmt)
+		}
+	}
+	return heuristicFeatures(sql)
+}
 
-	hasHaving := strings.Contains(sql, "having ")
+// NewComplexityClassifier returns a classifier using rules, or the default
+// rules if rules is unset.
+func NewComplexityClassifier(rules config.ComplexityRules) ComplexityClassifier {
+	return ComplexityClassifier{rules: rules.OrDefault()}
+}
 
-	hasUnion := strings.Contains(sql, "union ")
+// AnalyzeQueryComplexity classifies sql under the default rules.
+func AnalyzeQueryComplexity(sql string) string {
+	return NewComplexityClassifier(config.DefaultComplexityRules()).Classify(sql)
+}
 
-	hasCTE := strings.Contains(sql, "with ") && (strings.Contains(sql, " as (") || strings.Contains(sql, " as("))
+// Classify returns the complexity level of sql: low, low-medium, medium or
+// high.
+func (c ComplexityClassifier) Classify(sql string) string {
+	f := c.inspect(sql)
+	hasAggregation := f.aggregations > 0
+	hasSubquery := f.subqueries > 0
 
-	if (joinCount > 2 && (hasAggregation || hasSubquery)) ||
-		hasWindowFunc ||
-		hasUnion ||
-		(hasAggregation && hasHaving) ||
-		hasCTE ||
-		conditionComplexity > 5 {
+	if (f.joins > c.rules.HighJoins && (hasAggregation || hasSubquery)) ||
+		f.windowFunctions > 0 ||
+		f.unions > 0 ||
+		(hasAggregation && f.having) ||
+		f.ctes > 0 ||
+		f.conditions > c.rules.HighConditions {
 		return "high"
-	} else if (joinCount > 0 && (hasAggregation || hasSubquery)) ||
-		(conditionComplexity > 2) ||
-		(joinCount > 1) {
+	} else if (f.joins > 0 && (hasAggregation || hasSubquery)) ||
+		(f.conditions > c.rules.MediumConditions) ||
+		(f.joins > c.rules.MediumJoins) {
 		return "medium"
-	} else if joinCount > 0 || hasAggregation || hasSubquery || hasOrdering {
+	} else if f.joins > 0 || hasAggregation || hasSubquery || f.ordering {
 		return "low-medium"
 	} else {
 		return "low"
 	}
 }
 
+// complexityWeights are the points each occurrence of a construct adds to a
+// query's complexity score.
+var complexityWeights = map[string]int{
+	"joins":           3,
+	"subqueries":      4,
+	"aggregations":    2,
+	"windowFunctions": 5,
+	"conditions":      1,
+	"ctes":            4,
+	"unions":          4,
+}
+
+// ScoreQueryComplexity scores sql under the default rules.
+func ScoreQueryComplexity(sql string) (int, map[string]int) {
+	return NewComplexityClassifier(config.DefaultComplexityRules()).Score(sql)
+}
+
+// Score returns a numeric complexity score for sql together with the points
+// contributed by each construct. It is finer grained than the level from
+// Classify, so queries in the same bucket can still be ranked against each
+// other.
+func (c ComplexityClassifier) Score(sql string) (int, map[string]int) {
+	f := c.inspect(sql)
+	counts := map[string]int{
+		"joins":           f.joins,
+		"subqueries":      f.subqueries,
+		"aggregations":    f.aggregations,
+		"windowFunctions": f.windowFunctions,
+		"conditions":      f.conditions,
+		"ctes":            f.ctes,
+		"unions":          f.unions,
+	}
+
+	score := 0
+	components := make(map[string]int)
+	for name, count := range counts {
+		if count == 0 {
+			continue
+		}
+		points := count * complexityWeights[name]
+		components[name] = points
+		score += points
+	}
+
+	return score, components
+}
+
+var (
+	joinRegex        = regexp.MustCompile(`\bjoin\b`)
+	selectRegex      = regexp.MustCompile(`\bselect\b`)
+	aggregationRegex = regexp.MustCompile(`\b(count|sum|avg|min|max|group_concat)\s*\(|\bgroup\s+by\b|\bhaving\b`)
+	windowRegex      = regexp.MustCompile(`\bover\s*\(`)
+	conditionRegex   = regexp.MustCompile(`\b(and|or)\b`)
+	cteRegex         = regexp.MustCompile("(?:\\bwith(?:\\s+recursive)?|,)\\s*`?([a-z0-9_]+)`?\\s*(?:\\([^)]*\\)\\s*)?as\\s*\\(")
+	unionRegex       = regexp.MustCompile(`\bunion\b`)
+	tableRegex       = regexp.MustCompile("\\b(from|join|into|update)\\s+((?:`[^`]+`|[a-z0-9_$]+)(?:\\s*\\.\\s*(?:`[^`]+`|[a-z0-9_$]+))?)")
+	// tableListRegex continues a FROM or UPDATE table list past a comma,
+	// skipping the previous table's alias.
+	tableListRegex = regexp.MustCompile("^(?:\\s+(?:as\\s+)?(`[^`]+`|[a-z0-9_$]+))?\\s*,\\s*((?:`[^`]+`|[a-z0-9_$]+)(?:\\s*\\.\\s*(?:`[^`]+`|[a-z0-9_$]+))?)")
+)
+
+// clauseKeywords can follow a table name but are not aliases, so a comma
+// after them doesn't continue the table list.
+var clauseKeywords = map[string]bool{
+	"where": true, "on": true, "using": true, "join": true, "inner": true,
+	"left": true, "right": true, "cross": true, "natural": true,
+	"straight_join": true, "group": true, "order": true, "having": true,
+	"limit": true, "union": true, "set": true, "window": true, "for": true,
+	"lock": true, "partition": true, "use": true, "force": true, "ignore": true,
+}
+
+// heuristicFeatures is the fallback for statements the parser rejects, such
+// as CTEs and window functions. Keywords are only counted outside string
+// literals and comments.
+func heuristicFeatures(sql string) queryFeatures {
+	sql = strings.ToLower(blankLiterals(sql))
+
+	f := queryFeatures{
+		joins:           len(joinRegex.FindAllStringIndex(sql, -1)),
+		subqueries:      max(len(selectRegex.FindAllStringIndex(sql, -1))-1, 0),
+		aggregations:    len(aggregationRegex.FindAllStringIndex(sql, -1)),
+		windowFunctions: len(windowRegex.FindAllStringIndex(sql, -1)),
+		conditions:      len(conditionRegex.FindAllStringIndex(sql, -1)),
+		unions:          len(unionRegex.FindAllStringIndex(sql, -1)),
+		ordering:        strings.Contains(sql, "order by"),
+		having:          strings.Contains(sql, "having "),
+	}
+	f.ctes = len(cteNames(sql))
+
+	return f
+}
+
+// blankLiterals returns sql with the contents of string literals, and whole
+// comments, replaced by spaces, so words inside them aren't taken for
+// keywords. Quotes and newlines are kept so the statement keeps its shape. An
+// unterminated literal or comment blanks the rest of sql.
+func blankLiterals(sql string) string {
+	out := []byte(sql)
+	blank := func(from, to int) {
+		for k := from; k < to; k++ {
+			if out[k] != '\n' {
+				out[k] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"':
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == '\\' {
+					j++
+				} else if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j++ // A doubled quote is an escaped quote
+						continue
+					}
+					break
+				}
+			}
+			blank(i+1, min(j, len(sql)))
+			i = j
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := len(sql)
+			if j := strings.Index(sql[i+2:], "*/"); j >= 0 {
+				end = i + 2 + j + 2
+			}
+			blank(i, end)
+			i = end - 1
+		case c == '#' || strings.HasPrefix(sql[i:], "--") && (i+2 == len(sql) || strings.IndexByte(" \t\r\n", sql[i+2]) >= 0):
+			end := len(sql)
+			if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+				end = i + j
+			}
+			blank(i, end)
+			i = end
+		}
+	}
+	return string(out)
+}
+
+// cteNames returns the names defined by a leading WITH clause in the
+// lower-cased sql.
+func cteNames(sql string) map[string]bool {
+	if !strings.HasPrefix(strings.TrimLeft(sql, " \t\r\n("), "with") {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, match := range cteRegex.FindAllStringSubmatch(sql, -1) {
+		names[match[1]] = true
+	}
+	return names
+}
+
+// AnalyzeTablesInQuery returns the tables sql reads or writes, in order of
+// first appearance and schema-qualified where the query qualifies them.
+// Aliases, derived tables and CTE names are not reported.
 func AnalyzeTablesInQuery(sql string) []string {
-	sql = strings.ToLower(sql)
+	if stmt, ok := parseStatement(sql); ok {
+		return parsedTables(stmt)
+	}
 
-	tableRegex := regexp.MustCompile(`from\s+([a-z0-9_]+)|join\s+([a-z0-9_]+)`)
-	matches := tableRegex.FindAllStringSubmatch(sql, -1)
+	sql = strings.ToLower(sql)
+	ctes := cteNames(sql)
 
 	var tables []string
 	seen := make(map[string]bool)
 
-	for _, match := range matches {
-		var tableName string
-		if match[1] != "" {
-			tableName = match[1]
-		} else {
-			tableName = match[2]
+	add := func(name string) {
+		tableName := strings.NewReplacer("`", "", " ", "", "\t", "", "\n", "", "\r", "").Replace(name)
+		if tableName == "" || seen[tableName] || ctes[tableName] {
+			return
 		}
+		seen[tableName] = true
+		tables = append(tables, tableName)
+	}
 
-		if tableName == "" || seen[tableName] {
+	for _, match := range tableRegex.FindAllStringSubmatchIndex(sql, -1) {
+		add(sql[match[4]:match[5]])
+		if keyword := sql[match[2]:match[3]]; keyword != "from" && keyword != "update" {
 			continue
 		}
 
-		seen[tableName] = true
-		tables = append(tables, tableName)
+		// Comma joins: FROM a x, b AS y, c
+		rest := sql[match[1]:]
+		for {
+			next := tableListRegex.FindStringSubmatchIndex(rest)
+			if next == nil || next[2] >= 0 && clauseKeywords[rest[next[2]:next[3]]] {
+				break
+			}
+			add(rest[next[4]:next[5]])
+			rest = rest[next[1]:]
+		}
 	}
 
 	return tables
 }
+
+// ComplexityRank orders the complexity levels returned by
+// AnalyzeQueryComplexity from simplest to most complex.
+func ComplexityRank(level string) int {
+	switch level {
+	case "low":
+		return 0
+	case "low-medium":
+		return 1
+	case "medium":
+		return 2
+	case "high":
+		return 3
+	default:
+		return -1
+	}
+}
+
+// Statement kinds returned by ClassifyStatement.
+const (
+	StatementSelect = "select"
+	StatementInsert = "insert" // Includes REPLACE
+	StatementUpdate = "update"
+	StatementDelete = "delete"
+	StatementOther  = "other"
+)
+
+// StatementKinds lists the statement kinds in report order.
+var StatementKinds = []string{StatementSelect, StatementInsert, StatementUpdate, StatementDelete, StatementOther}
+
+// ClassifyStatement returns the kind of statement sql is. The parse tree is
+// used when available; otherwise the first keyword outside parentheses after
+// any WITH clause decides, so WITH x AS (...) DELETE ... is a delete.
+func ClassifyStatement(sql string) string {
+	if stmt, ok := parseStatement(sql); ok {
+		return parsedStatementKind(stmt)
+	}
+
+	sql = strings.ToLower(sql)
+	var topLevel strings.Builder
+	depth := 0
+	for _, r := range sql {
+		switch {
+		case r == '(':
+			depth++
+			topLevel.WriteRune(' ')
+		case r == ')':
+			depth = max(depth-1, 0)
+			topLevel.WriteRune(' ')
+		case depth == 0:
+			topLevel.WriteRune(r)
+		}
+	}
+
+	fields := strings.Fields(topLevel.String())
+	if len(fields) > 0 && fields[0] != "with" {
+		fields = fields[:1]
+	}
+	for _, field := range fields {
+		switch field {
+		case "select":
+			return StatementSelect
+		case "insert", "replace":
+			return StatementInsert
+		case "update":
+			return StatementUpdate
+		case "delete":
+			return StatementDelete
+		}
+	}
+
+	return StatementOther
+}
+
+// EstimateStatementType reports whether a statement reads or writes data.
+// Statements other than SELECT, INSERT, UPDATE and DELETE are writes if they
+// lead with a DDL or CALL keyword.
+func EstimateStatementType(sql string) string {
+	switch ClassifyStatement(sql) {
+	case StatementSelect:
+		return "read"
+	case StatementInsert, StatementUpdate, StatementDelete:
+		return "write"
+	}
+
+	fields := strings.Fields(strings.ToLower(strings.TrimLeft(sql, " \t\r\n(")))
+	if len(fields) == 0 {
+		return "read"
+	}
+
+	switch fields[0] {
+	case "create", "alter", "drop", "truncate", "call":
+		return "write"
+	}
+
+	return "read"
+}
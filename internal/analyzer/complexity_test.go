@@ -0,0 +1,109 @@
+// internal/analyzer/complexity_test.go
+package analyzer
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+)
+
+func TestAnalyzeTablesInQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		sql    string
+		parsed bool // Whether the parser handles sql, rather than the fallback
+		want   []string
+	}{
+		{"quoted and qualified with alias", "SELECT o.id FROM `db`.`orders` o WHERE o.total > 10", true, []string{"db.orders"}},
+		{"alias with AS", "SELECT o.id FROM orders AS o", true, []string{"orders"}},
+		{"comma join", "SELECT * FROM a, b WHERE a.id = b.a_id", true, []string{"a", "b"}},
+		{"comma join with aliases", "SELECT * FROM orders o, `db`.`customers` AS c WHERE c.id = o.customer_id", true, []string{"orders", "db.customers"}},
+		{"subquery in FROM", "SELECT x.n FROM (SELECT COUNT(*) AS n FROM orders) AS x JOIN customers c ON c.id = x.n", true, []string{"orders", "customers"}},
+		{"subquery in WHERE", "SELECT * FROM Orders WHERE id IN (SELECT order_id FROM Shop.Items)", true, []string{"orders", "shop.items"}},
+		{"repeated table", "SELECT * FROM orders a JOIN orders b ON a.parent_id = b.id", true, []string{"orders"}},
+		{"column named like a keyword", "SELECT joined_at, updated_from FROM users", true, []string{"users"}},
+		{"insert select", "INSERT INTO audit (id) SELECT id FROM orders", true, []string{"audit", "orders"}},
+		{"multi-table update", "UPDATE orders o JOIN customers c ON c.id = o.customer_id SET o.flag = 1", true, []string{"orders", "customers"}},
+		{"delete", "DELETE FROM sessions WHERE expires_at < NOW()", true, []string{"sessions"}},
+		{"no tables", "SELECT 1", true, []string{}},
+
+		// The parser doesn't know CTEs or window functions.
+		{"CTE names not reported", "WITH recent AS (SELECT * FROM orders WHERE created_at > NOW()) SELECT * FROM recent JOIN customers ON customers.id = recent.customer_id", false, []string{"orders", "customers"}},
+		{"several CTEs in a comma join", "WITH a AS (SELECT 1 FROM t1), `b` AS (SELECT 2 FROM db2.t2) SELECT * FROM a, b", false, []string{"t1", "db2.t2"}},
+		{"recursive CTE with columns", "WITH RECURSIVE tree (id) AS (SELECT id FROM nodes UNION ALL SELECT n.id FROM nodes n JOIN tree ON n.parent_id = tree.id) SELECT * FROM tree", false, []string{"nodes"}},
+		{"fallback comma join", "WITH r AS (SELECT 1) SELECT * FROM orders o, `db`.`customers` AS c, r", false, []string{"orders", "db.customers"}},
+		{"fallback subquery in FROM", "WITH r AS (SELECT 1) SELECT * FROM (SELECT id FROM orders) x JOIN r", false, []string{"orders"}},
+		{"fallback clause after table", "WITH r AS (SELECT 1) SELECT * FROM orders WHERE id IN (1, 2) ORDER BY a, b", false, []string{"orders"}},
+		{"window function", "SELECT id, ROW_NUMBER() OVER (ORDER BY id) FROM `events` e LEFT JOIN db.users u ON u.id = e.user_id", false, []string{"events", "db.users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := parseStatement(tt.sql); ok != tt.parsed {
+				t.Fatalf("parseStatement() ok = %v, want %v", ok, tt.parsed)
+			}
+			got := AnalyzeTablesInQuery(tt.sql)
+			if len(got) != len(tt.want) || !slices.Equal(got, tt.want) {
+				t.Errorf("AnalyzeTablesInQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func classifierUsing(analyzer string) ComplexityClassifier {
+	rules := config.DefaultComplexityRules()
+	rules.Analyzer = analyzer
+	return NewComplexityClassifier(rules)
+}
+
+// Words that merely contain a keyword, and keywords inside literals and
+// comments, don't count toward complexity under either analyzer.
+func TestComplexityIgnoresNonKeywords(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+	}{
+		{"column containing join", "SELECT adjoining, joined_at FROM plots"},
+		{"table containing join", "SELECT * FROM rejoin_requests"},
+		{"keywords in a string", "SELECT * FROM notes WHERE body = 'a JOIN b AND c OR d GROUP BY e'"},
+		{"keywords in a double-quoted string", `SELECT * FROM notes WHERE body = "left join (select count(*))"`},
+		{"escaped quote in a string", `SELECT * FROM notes WHERE body = 'it''s a join \' or select'`},
+		{"block comment", "SELECT * /* JOIN other ON x AND y */ FROM notes"},
+		{"line comment", "SELECT * FROM notes -- JOIN other AND (SELECT 1)\n"},
+		{"hash comment", "SELECT * FROM notes # UNION SELECT * FROM other\n"},
+	}
+
+	for _, tt := range tests {
+		for _, analyzer := range []string{config.ComplexityAnalyzerParser, config.ComplexityAnalyzerHeuristic} {
+			t.Run(tt.name+"/"+analyzer, func(t *testing.T) {
+				c := classifierUsing(analyzer)
+				if level := c.Classify(tt.sql); level != "low" {
+					t.Errorf("Classify() = %s, want low", level)
+				}
+				if score, components := c.Score(tt.sql); score != 0 {
+					t.Errorf("Score() = %d %v, want 0", score, components)
+				}
+			})
+		}
+	}
+}
+
+// Real constructs are still counted, so the cases above aren't passing by
+// ignoring everything.
+func TestComplexityCountsKeywords(t *testing.T) {
+	sql := "SELECT c.name, COUNT(*) FROM orders o JOIN customers c ON c.id = o.customer_id " +
+		"WHERE o.note <> 'join' AND o.total > 10 GROUP BY c.name"
+
+	for _, analyzer := range []string{config.ComplexityAnalyzerParser, config.ComplexityAnalyzerHeuristic} {
+		t.Run(analyzer, func(t *testing.T) {
+			c := classifierUsing(analyzer)
+			if level := c.Classify(sql); level != "medium" {
+				t.Errorf("Classify() = %s, want medium", level)
+			}
+			if _, components := c.Score(sql); components["joins"] != 3 || components["conditions"] != 1 {
+				t.Errorf("Score() components = %v, want one join and one condition", components)
+			}
+		})
+	}
+}
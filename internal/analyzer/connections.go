@@ -0,0 +1,104 @@
+// internal/analyzer/connections.go
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+const (
+	// minConnectionExecutions is the fewest successful executions a
+	// connection needs to count toward the spread across connections.
+	minConnectionExecutions = 20
+
+	// unevenConnectionSpread is how much slower than the typical connection
+	// the slowest may be before the run is flagged.
+	unevenConnectionSpread = 1.5
+)
+
+// connectionBreakdown groups executions by the connection they ran on in
+// dedicated mode, or by worker otherwise, slowest first. Connections run
+// different mixes of queries, so they are compared by relative latency: each
+// execution's duration over its query's median. It also returns the slowest
+// connection's relative latency over the median of the others', 0 when fewer
+// than two connections have enough executions to tell.
+func connectionBreakdown(results []model.QueryResult) ([]model.ConnectionStats, float64) {
+	type accumulator struct {
+		stats     model.ConnectionStats
+		durations []time.Duration
+		relative  float64
+	}
+	type connKey struct {
+		worker int
+		conn   int64
+	}
+	byConn := make(map[connKey]*accumulator)
+
+	for _, result := range results {
+		for _, exec := range result.Executions {
+			if exec.Worker == 0 {
+				continue
+			}
+			key := connKey{exec.Worker, exec.ConnectionID}
+			acc, ok := byConn[key]
+			if !ok {
+				acc = &accumulator{stats: model.ConnectionStats{Worker: exec.Worker, ConnectionID: exec.ConnectionID}}
+				byConn[key] = acc
+			}
+			acc.stats.Executions++
+			if exec.Error != nil || exec.ErrorMessage != "" {
+				acc.stats.Errors++
+				continue
+			}
+			acc.durations = append(acc.durations, exec.Duration)
+			if result.MedianDuration > 0 {
+				acc.relative += float64(exec.Duration) / float64(result.MedianDuration)
+			}
+		}
+	}
+	if len(byConn) < 2 {
+		return nil, 0
+	}
+
+	breakdown := make([]model.ConnectionStats, 0, len(byConn))
+	var typical []float64
+	for _, acc := range byConn {
+		if n := len(acc.durations); n > 0 {
+			stats := utils.CalculateStats(acc.durations)
+			acc.stats.AvgMs = float64(stats.Mean.Microseconds()) / 1000
+			acc.stats.P95Ms = float64(stats.P95.Microseconds()) / 1000
+			acc.stats.RelativeLatency = acc.relative / float64(n)
+			if n >= minConnectionExecutions {
+				typical = append(typical, acc.stats.RelativeLatency)
+			}
+		}
+		breakdown = append(breakdown, acc.stats)
+	}
+
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].RelativeLatency != breakdown[j].RelativeLatency {
+			return breakdown[i].RelativeLatency > breakdown[j].RelativeLatency
+		}
+		if breakdown[i].Worker != breakdown[j].Worker {
+			return breakdown[i].Worker < breakdown[j].Worker
+		}
+		return breakdown[i].ConnectionID < breakdown[j].ConnectionID
+	})
+
+	if len(typical) < 2 {
+		return breakdown, 0
+	}
+	sort.Float64s(typical)
+	slowest, rest := typical[len(typical)-1], typical[:len(typical)-1]
+	median := rest[len(rest)/2]
+	if len(rest)%2 == 0 {
+		median = (rest[len(rest)/2-1] + rest[len(rest)/2]) / 2
+	}
+	if median <= 0 {
+		return breakdown, 0
+	}
+	return breakdown, slowest / median
+}
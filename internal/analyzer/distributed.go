@@ -0,0 +1,580 @@
+// internal/analyzer/distributed.go
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+// Shard is the part of a distributed run one worker performs: every query,
+// for the worker's share of the iterations and concurrency.
+type Shard struct {
+	Config  config.Config `json:"config"` // The coordinator's config without its DSN; workers connect with their own
+	Queries []model.Query `json:"queries"`
+}
+
+// WorkerTokenEnv names the environment variable holding the token a worker
+// and its coordinator share. Every request to a worker must carry it.
+const WorkerTokenEnv = "FN_WORKER_TOKEN"
+
+// AuthenticateWorkers makes a distributed run present token to its workers.
+// Set it before the run starts.
+func (a *Analyzer) AuthenticateWorkers(token string) {
+	a.workerToken = token
+}
+
+// Shard states reported by a worker.
+const (
+	ShardRunning   = "running"
+	ShardCompleted = "completed"
+	ShardFailed    = "failed"
+	ShardCancelled = "cancelled"
+)
+
+// ShardStatus is a worker's view of the shard it is running. The coordinator
+// polls it for progress and as a heartbeat.
+type ShardStatus struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Completed int    `json:"completedExecutions"`
+	Total     int    `json:"totalExecutions"`
+}
+
+// ShardResult is what a worker returns for a completed shard: one result per
+// query, in the shard's order. Unless the run sets workerExecutions, the
+// results carry no executions, and their latency distributions come as
+// histograms in Distributions instead.
+type ShardResult struct {
+	QueryResults  []model.QueryResult   `json:"queryResults"`
+	Distributions []model.Distributions `json:"distributions,omitempty"` // One per query, when the results have no executions
+	Heatmap       *model.Heatmap        `json:"heatmap,omitempty"`       // Counted from the worker's own start
+}
+
+// NewShardResult returns what a worker sends back for a completed shard:
+// results with every execution, or by default with histograms in their
+// place, which stay small however long the shard ran.
+func NewShardResult(results []model.QueryResult, heatmap *model.Heatmap, executions bool) ShardResult {
+	if executions {
+		return ShardResult{QueryResults: results, Heatmap: heatmap}
+	}
+
+	shard := ShardResult{
+		QueryResults:  make([]model.QueryResult, len(results)),
+		Distributions: make([]model.Distributions, len(results)),
+		Heatmap:       heatmap,
+	}
+	for i, result := range results {
+		shard.Distributions[i] = distributionsOf(result.Executions)
+		result.Executions = nil
+		shard.QueryResults[i] = result
+	}
+	return shard
+}
+
+// distributionsOf returns the histograms of the durations finalizeResult
+// reads a result's statistics from.
+func distributionsOf(executions []model.QueryExecution) model.Distributions {
+	d := model.Distributions{Latency: &utils.Histogram{}, Censored: &utils.Histogram{}, Acquire: &utils.Histogram{}}
+	for _, exec := range executions {
+		if exec.Error == nil || exec.TimedOut {
+			d.Censored.Add(exec.Duration)
+		}
+		if exec.Error != nil {
+			continue
+		}
+		d.Latency.Add(exec.Duration)
+		if exec.AcquireDuration > 0 {
+			d.Acquire.Add(exec.AcquireDuration)
+		}
+	}
+	return d
+}
+
+const (
+	shardPollInterval   = time.Second
+	shardMaxMissedPolls = 5 // Consecutive failed polls before a worker is given up on
+	shardRequestTimeout = 10 * time.Second
+	shardResultTimeout  = 5 * time.Minute // With workerExecutions, results carry every execution and can be large
+)
+
+// runDistributed splits the run's iterations and concurrency across the
+// configured workers, waits for them and merges what they return into one
+// result per query. A worker that fails or stops responding is dropped with a
+// warning; the run fails only if none of them completes.
+//
+// Workers return per-query aggregates: counts, totals, extremes and latency
+// histograms, which add up across workers. Counts, min/max, means and
+// standard deviations are exact over every execution and percentiles are
+// within the histograms' resolution. With workerExecutions, workers return
+// every execution instead, merged exactly as the in-process workers' are.
+//
+// Durations are measured on each worker's monotonic clock and don't depend
+// on the workers' clocks agreeing, and neither does anything else that
+// combines workers: each counts its heatmap from its own start, throughput is
+// computed per worker and summed, and the cold execution is chosen by
+// duration. Clock skew shows up only in first/last executed timestamps.
+func (a *Analyzer) runDistributed(ctx context.Context) ([]model.QueryResult, error) {
+	addresses := a.config.Workers
+	if len(addresses) > a.iterations {
+		log.Printf("Warning: %d iterations can't be split across %d workers; using the first %d",
+			a.iterations, len(addresses), a.iterations)
+		addresses = addresses[:a.iterations]
+	}
+	iterations := splitShares(a.iterations, len(addresses))
+	concurrency := splitShares(max(a.concurrency, 1), len(addresses))
+
+	shardCfg := a.config
+	shardCfg.DSN = ""
+	shardCfg.Workers = nil
+	// Every worker opens its own pool against the same server.
+	shardCfg.ConnectionLimit.MaxFraction /= float64(len(addresses))
+
+	start := time.Now()
+	a.workers = make([]model.WorkerRun, len(addresses))
+	shards := make([]*ShardResult, len(addresses))
+	var wg sync.WaitGroup
+
+	for i, address := range addresses {
+		worker := &a.workers[i]
+		*worker = model.WorkerRun{
+			Address:     address,
+			Iterations:  iterations[i],
+			Concurrency: max(concurrency[i], 1),
+		}
+
+		cfg := shardCfg
+		cfg.Iterations = worker.Iterations
+		cfg.Concurrency = worker.Concurrency
+		if UsesSeed(cfg) {
+			// Each worker gets its own order, still fixed by the run's seed.
+			cfg.Seed = a.executor.rng.Uint64()
+		}
+		shard := Shard{Config: cfg, Queries: a.queries}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			client := &shardClient{base: workerURL(address), token: a.workerToken}
+			var reported int
+			result, err := client.run(ctx, shard, func(completed int) {
+				a.executor.completed.Add(int64(completed - reported))
+				reported = completed
+			})
+			if err == nil && len(result.QueryResults) != len(a.queries) {
+				err = fmt.Errorf("returned %d query results for %d queries", len(result.QueryResults), len(a.queries))
+			}
+			if err == nil && !cfg.WorkerExecutions && len(result.Distributions) != len(a.queries) {
+				err = fmt.Errorf("returned %d distributions for %d queries", len(result.Distributions), len(a.queries))
+			}
+			if err != nil {
+				worker.Error = err.Error()
+				if ctx.Err() == nil {
+					log.Printf("Warning: worker %s failed, its %d iterations are missing from the run: %v",
+						address, worker.Iterations, err)
+				}
+				return
+			}
+			shards[i] = result
+		}()
+	}
+
+	log.Printf("Dispatched %d iterations of %d queries across %d workers",
+		a.iterations, len(a.queries), len(addresses))
+	wg.Wait()
+
+	results := a.mergeShards(shards, start)
+
+	if err := ctx.Err(); err != nil {
+		return results, fmt.Errorf("run aborted: %w", err)
+	}
+
+	var failed []string
+	for _, worker := range a.workers {
+		if worker.Error != "" {
+			failed = append(failed, worker.Address)
+		}
+	}
+	if len(failed) == len(a.workers) {
+		return results, fmt.Errorf("all %d workers failed", len(failed))
+	}
+	if len(failed) > 0 {
+		log.Printf("Warning: run degraded, %d of %d workers failed: %s",
+			len(failed), len(a.workers), strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// mergeShards folds the per-query results of every worker that completed its
+// shard into one result per query, and adds up the workers' heatmaps.
+func (a *Analyzer) mergeShards(shards []*ShardResult, start time.Time) []model.QueryResult {
+	qe := a.executor
+	results := make([]model.QueryResult, len(a.queries))
+	shares := NormalizeWeights(a.queries)
+
+	// Each worker counts its heatmap from its own start, close to the
+	// coordinator's, so windows line up without comparing clocks.
+	qe.heatmap = newHeatmapCounter(qe.heatmapCfg, start)
+	for _, shard := range shards {
+		if shard != nil && shard.Heatmap != nil && len(shard.Heatmap.BucketsMs) == len(qe.heatmap.bounds) {
+			qe.heatmap.mergeCounts(shard.Heatmap.Counts)
+		}
+	}
+
+	// Each worker process numbers its own workers from 1; renumber them
+	// across the run so they stay apart.
+	firstWorker := make([]int, len(shards))
+	for w := 1; w < len(shards); w++ {
+		firstWorker[w] = firstWorker[w-1] + a.workers[w-1].Concurrency
+	}
+
+	// Workers split off their own cold executions; one is chosen below.
+	opts := qe.finalizeOptions()
+	opts.measureCold = false
+
+	for i, query := range a.queries {
+		results[i] = qe.newQueryResult(query, shares[i], a.iterations)
+
+		var parts []model.QueryResult
+		var overhead time.Duration
+		var ran int
+		var qps float64
+		var flipped bool
+		for w, shard := range shards {
+			if shard == nil {
+				continue
+			}
+			q := shard.QueryResults[i]
+			if shard.Distributions != nil {
+				q.Distributions = &shard.Distributions[i]
+			}
+			for j := range q.Executions {
+				q.Executions[j] = restoreError(q.Executions[j])
+				if q.Executions[j].Worker > 0 {
+					q.Executions[j].Worker += firstWorker[w]
+				}
+			}
+
+			// Every worker met the query cold once, but only the first to
+			// reach the server found it cold, and start times can't tell
+			// which that was across hosts. The slowest is the best
+			// measure of the cold cost; none of them counts as warm.
+			executed := q.SuccessfulExecutions + q.Errors
+			if cold := q.ColdExecution; cold != nil {
+				executed++
+				if results[i].ColdExecution == nil || cold.Duration > results[i].ColdDuration {
+					c := *cold
+					if c.Worker > 0 {
+						c.Worker += firstWorker[w]
+					}
+					results[i].ColdExecution = &c
+					results[i].ColdDuration = c.Duration
+				}
+			}
+			overhead += q.AvgHarnessOverhead * time.Duration(executed)
+			ran += executed
+			qps += q.AchievedQPS
+			a.workers[w].Executions += executed
+
+			// Workers fingerprint the same server's plan at slightly
+			// different times; a flip seen by any of them counts.
+			if results[i].PlanFingerprints == nil {
+				results[i].PlanFingerprints = q.PlanFingerprints
+			}
+			if results[i].SampleRows == nil {
+				results[i].SampleRows = q.SampleRows
+			}
+			flipped = flipped || q.PlanFlipped
+			parts = append(parts, q)
+		}
+
+		if a.config.WorkerExecutions {
+			var executions []model.QueryExecution
+			for _, q := range parts {
+				executions = append(executions, q.Executions...)
+			}
+			mergeExecutions(&results[i], executions, 0, opts)
+		} else {
+			mergeAggregates(&results[i], parts, opts)
+		}
+		if ran > 0 {
+			results[i].AvgHarnessOverhead = overhead / time.Duration(ran)
+		}
+		results[i].AchievedQPS = qps
+		results[i].PlanFlipped = flipped
+
+		if qe.onQueryDone != nil {
+			qe.onQueryDone(results[i])
+		}
+	}
+	return results
+}
+
+// mergeAggregates folds the aggregates workers returned for one query into
+// result and derives its statistics as finalizeResult does from executions:
+// counts and totals add up, extremes take the min or max, averages are
+// weighted by the executions behind them and the histograms merge.
+func mergeAggregates(result *model.QueryResult, parts []model.QueryResult, opts finalizeOptions) {
+	dist := model.Distributions{Latency: &utils.Histogram{}, Censored: &utils.Histogram{}, Acquire: &utils.Histogram{}}
+	var txOverhead, connect, closeCost, overall time.Duration
+	var txCount, freshCount int
+
+	for _, q := range parts {
+		if !q.FirstExecutedAt.IsZero() && (result.FirstExecutedAt.IsZero() || q.FirstExecutedAt.Before(result.FirstExecutedAt)) {
+			result.FirstExecutedAt = q.FirstExecutedAt
+		}
+		if q.LastExecutedAt.After(result.LastExecutedAt) {
+			result.LastExecutedAt = q.LastExecutedAt
+		}
+		if result.ColumnTypes == nil {
+			result.ColumnTypes = q.ColumnTypes
+		}
+
+		result.Errors += q.Errors
+		for kind, n := range q.ErrorsByType {
+			if result.ErrorsByType == nil {
+				result.ErrorsByType = make(map[string]int)
+			}
+			result.ErrorsByType[kind] += n
+		}
+		for _, detail := range q.ErrorDetails {
+			if len(result.ErrorDetails) < 10 {
+				result.ErrorDetails = append(result.ErrorDetails, detail)
+			}
+		}
+		result.Timeouts += q.Timeouts
+		if result.SLO != nil && q.SLO != nil {
+			result.SLO.Within += q.SLO.Within
+			result.SLO.Executions += q.SLO.Executions
+		}
+		if q.Distributions != nil {
+			dist.Latency.Merge(q.Distributions.Latency)
+			dist.Censored.Merge(q.Distributions.Censored)
+			dist.Acquire.Merge(q.Distributions.Acquire)
+		}
+
+		if q.SuccessfulExecutions == 0 {
+			continue
+		}
+		if result.SuccessfulExecutions == 0 || q.MinRowCount < result.MinRowCount {
+			result.MinRowCount = q.MinRowCount
+		}
+		if result.SuccessfulExecutions == 0 || q.MaxRowCount > result.MaxRowCount {
+			result.MaxRowCount = q.MaxRowCount
+		}
+		result.SuccessfulExecutions += q.SuccessfulExecutions
+		result.TotalDuration += q.TotalDuration
+		result.RowsAffected += q.RowsAffected
+		result.MinDuration = min(result.MinDuration, q.MinDuration)
+		result.MaxDuration = max(result.MaxDuration, q.MaxDuration)
+
+		n := time.Duration(q.SuccessfulExecutions)
+		if q.AvgTxOverhead > 0 {
+			txOverhead += q.AvgTxOverhead * n
+			txCount += q.SuccessfulExecutions
+		}
+		if q.AvgFreshConnOverall > 0 {
+			connect += q.AvgConnectDuration * n
+			closeCost += q.AvgCloseDuration * n
+			overall += q.AvgFreshConnOverall * n
+			freshCount += q.SuccessfulExecutions
+		}
+	}
+	result.Distributions = &dist
+
+	if txCount > 0 {
+		result.AvgTxOverhead = txOverhead / time.Duration(txCount)
+	}
+	if freshCount > 0 {
+		result.AvgConnectDuration = connect / time.Duration(freshCount)
+		result.AvgCloseDuration = closeCost / time.Duration(freshCount)
+		result.AvgFreshConnOverall = overall / time.Duration(freshCount)
+	}
+	if dist.Acquire.Count > 0 {
+		stats := dist.Acquire.Stats(opts.percentiles)
+		result.AvgAcquireDuration = stats.Mean
+		result.P95AcquireDuration = stats.P95
+		result.MaxAcquireDuration = stats.Max
+	}
+
+	total := result.SuccessfulExecutions + result.Errors
+	if total > 0 {
+		result.SuccessRate = float64(result.SuccessfulExecutions) / float64(total)
+	}
+	checkSLA(result)
+	if result.SLO != nil {
+		judgeSLO(result.SLO)
+	}
+	if result.Timeouts > 0 {
+		result.TimeoutRate = float64(result.Timeouts) / float64(total)
+		result.TimeoutCensored = result.TimeoutRate > timeoutCensorRate
+		stats := dist.Censored.Stats(opts.percentiles)
+		result.CensoredPercentile95 = stats.P95
+		result.CensoredPercentile99 = stats.P99
+	}
+
+	if result.SuccessfulExecutions == 0 {
+		return
+	}
+
+	result.ZeroRows = result.RowsAffected == 0 && EstimateStatementType(result.SQL) == "read"
+	result.NonDeterministicRowCount = result.MinRowCount != result.MaxRowCount
+	result.AvgDuration = result.TotalDuration / time.Duration(result.SuccessfulExecutions)
+	recordLatencyStats(result, dist.Latency.Stats(opts.percentiles), opts)
+}
+
+// splitShares divides total into n shares that differ by at most one.
+func splitShares(total, n int) []int {
+	shares := make([]int, n)
+	for i := range shares {
+		shares[i] = total / n
+		if i < total%n {
+			shares[i]++
+		}
+	}
+	return shares
+}
+
+// workerURL turns a worker address such as loadgen1:9000 into the base URL
+// of its API.
+func workerURL(address string) string {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return strings.TrimRight(address, "/")
+}
+
+// workerError is an error response from a worker, as opposed to failing to
+// reach it.
+type workerError struct {
+	status  int
+	message string
+}
+
+func (e *workerError) Error() string {
+	return fmt.Sprintf("worker responded %d: %s", e.status, e.message)
+}
+
+// shardClient drives one shard on a worker through its HTTP API.
+type shardClient struct {
+	base  string
+	token string // Sent as a bearer token
+}
+
+// run starts shard on the worker and polls it until it finishes, passing
+// progress the worker's completed executions. Transient poll failures are
+// retried; after shardMaxMissedPolls in a row the worker is considered dead.
+// Cancelling ctx cancels the shard on the worker.
+func (c *shardClient) run(ctx context.Context, shard Shard, progress func(completed int)) (*ShardResult, error) {
+	var status ShardStatus
+	if err := c.do(ctx, http.MethodPost, "/shards", shard, &status, shardRequestTimeout); err != nil {
+		return nil, fmt.Errorf("error starting shard: %w", err)
+	}
+
+	ticker := time.NewTicker(shardPollInterval)
+	defer ticker.Stop()
+
+	missed := 0
+	for status.Status == ShardRunning {
+		select {
+		case <-ctx.Done():
+			c.cancel(status.ID)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		var polled ShardStatus
+		if err := c.do(ctx, http.MethodGet, "/shards/"+status.ID, nil, &polled, shardRequestTimeout); err != nil {
+			var werr *workerError
+			if errors.As(err, &werr) {
+				return nil, err
+			}
+			if missed++; missed >= shardMaxMissedPolls && ctx.Err() == nil {
+				return nil, fmt.Errorf("worker stopped responding: %w", err)
+			}
+			continue
+		}
+		missed = 0
+		status = polled
+		progress(status.Completed)
+	}
+
+	if status.Status != ShardCompleted {
+		return nil, fmt.Errorf("shard %s: %s", status.Status, status.Error)
+	}
+
+	timeout := shardRequestTimeout
+	if shard.Config.WorkerExecutions {
+		timeout = shardResultTimeout
+	}
+	var result ShardResult
+	if err := c.do(ctx, http.MethodGet, "/shards/"+status.ID+"/result", nil, &result, timeout); err != nil {
+		return nil, fmt.Errorf("error fetching shard result: %w", err)
+	}
+	return &result, nil
+}
+
+// cancel asks the worker to stop the shard. It is best effort: the
+// coordinator is already giving up on it.
+func (c *shardClient) cancel(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), shardRequestTimeout)
+	defer cancel()
+	if err := c.do(ctx, http.MethodDelete, "/shards/"+id, nil, &ShardStatus{}, shardRequestTimeout); err != nil {
+		log.Printf("Warning: couldn't cancel shard %s on %s: %v", id, c.base, err)
+	}
+}
+
+func (c *shardClient) do(ctx context.Context, method, path string, body, out any, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&msg)
+		return &workerError{status: resp.StatusCode, message: msg.Error}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// internal/analyzer/distributed_test.go
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// workerExecutions returns a worker's executions of one query: a cold first
+// one, then n warm ones from start on the worker's own clock, every tenth of
+// them failed.
+func workerExecutions(start time.Time, cold time.Duration, n int, spread time.Duration) []model.QueryExecution {
+	executions := []model.QueryExecution{{StartTime: start, Duration: cold, Worker: 1}}
+	for i := 1; i <= n; i++ {
+		exec := model.QueryExecution{
+			StartTime: start.Add(time.Duration(i) * time.Millisecond),
+			Duration:  time.Millisecond + time.Duration(i*i%97)*spread,
+			RowCount:  3,
+			Worker:    1 + i%2,
+		}
+		if i%10 == 0 {
+			exec.Error = errors.New("deadlock found")
+			exec.ErrorMessage = exec.Error.Error()
+		}
+		executions = append(executions, exec)
+	}
+	return executions
+}
+
+// shardOf returns what a worker that ran executions sends the coordinator,
+// after the trip through JSON.
+func shardOf(t *testing.T, executions []model.QueryExecution, withExecutions bool) *ShardResult {
+	t.Helper()
+	result := model.QueryResult{Name: "orders", SQL: "SELECT id FROM orders", MinDuration: time.Hour}
+	mergeExecutions(&result, executions, 0, finalizeOptions{measureCold: true})
+
+	data, err := json.Marshal(NewShardResult([]model.QueryResult{result}, nil, withExecutions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shard ShardResult
+	if err := json.Unmarshal(data, &shard); err != nil {
+		t.Fatal(err)
+	}
+	return &shard
+}
+
+func TestMergeShards(t *testing.T) {
+	now := time.Now()
+	// The second worker's clock runs an hour behind, so its cold execution
+	// looks like the run's first although the first worker's met the
+	// colder server.
+	first := workerExecutions(now, 80*time.Millisecond, 400, 100*time.Microsecond)
+	second := workerExecutions(now.Add(-time.Hour), 30*time.Millisecond, 250, 170*time.Microsecond)
+
+	// Every warm execution merged in one place is what the run measured.
+	var want model.QueryResult
+	want.MinDuration = time.Hour
+	mergeExecutions(&want, append(append([]model.QueryExecution(nil), first[1:]...), second[1:]...), 0, finalizeOptions{})
+
+	for _, withExecutions := range []bool{false, true} {
+		cfg := config.Config{Iterations: 652, WorkerExecutions: withExecutions}
+		a := NewAnalyzer(nil, []model.Query{{Name: "orders", SQL: "SELECT id FROM orders"}}, cfg)
+		a.workers = []model.WorkerRun{{Concurrency: 2}, {Concurrency: 2}}
+
+		got := a.mergeShards([]*ShardResult{
+			shardOf(t, first, withExecutions),
+			shardOf(t, second, withExecutions),
+		}, now)[0]
+
+		if got.SuccessfulExecutions != want.SuccessfulExecutions || got.Errors != want.Errors ||
+			got.TotalDuration != want.TotalDuration || got.RowsAffected != want.RowsAffected {
+			t.Errorf("workerExecutions=%v: successes/errors/total/rows = %d/%d/%v/%d, want %d/%d/%v/%d", withExecutions,
+				got.SuccessfulExecutions, got.Errors, got.TotalDuration, got.RowsAffected,
+				want.SuccessfulExecutions, want.Errors, want.TotalDuration, want.RowsAffected)
+		}
+		if got.MinDuration != want.MinDuration || got.MaxDuration != want.MaxDuration || got.AvgDuration != want.AvgDuration {
+			t.Errorf("workerExecutions=%v: min/max/avg = %v/%v/%v, want %v/%v/%v", withExecutions,
+				got.MinDuration, got.MaxDuration, got.AvgDuration, want.MinDuration, want.MaxDuration, want.AvgDuration)
+		}
+		if !reflect.DeepEqual(got.ErrorsByType, want.ErrorsByType) || got.SuccessRate != want.SuccessRate {
+			t.Errorf("workerExecutions=%v: errors by type %v and success rate %v, want %v and %v", withExecutions,
+				got.ErrorsByType, got.SuccessRate, want.ErrorsByType, want.SuccessRate)
+		}
+		if diff := got.StdDevDuration - want.StdDevDuration; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("workerExecutions=%v: stddev = %v, want %v", withExecutions, got.StdDevDuration, want.StdDevDuration)
+		}
+		for _, p := range []struct {
+			name      string
+			got, want time.Duration
+		}{
+			{"median", got.MedianDuration, want.MedianDuration},
+			{"p95", got.Percentile95, want.Percentile95},
+			{"p99", got.Percentile99, want.Percentile99},
+		} {
+			if diff := float64(p.got-p.want) / float64(p.want); diff > 0.005 || diff < -0.005 {
+				t.Errorf("workerExecutions=%v: %s = %v, want %v", withExecutions, p.name, p.got, p.want)
+			}
+		}
+
+		if got.ColdDuration != 80*time.Millisecond {
+			t.Errorf("workerExecutions=%v: cold duration = %v, want the slowest worker's 80ms", withExecutions, got.ColdDuration)
+		}
+		if a.workers[0].Executions != len(first) || a.workers[1].Executions != len(second) {
+			t.Errorf("workerExecutions=%v: worker executions = %d and %d, want %d and %d", withExecutions,
+				a.workers[0].Executions, a.workers[1].Executions, len(first), len(second))
+		}
+		if withExecutions != (len(got.Executions) > 0) {
+			t.Errorf("workerExecutions=%v: merged result has %d executions", withExecutions, len(got.Executions))
+		}
+
+		summary := calculateSummary([]model.QueryResult{got}, "")
+		if summary.TotalExecutions != want.SuccessfulExecutions+want.Errors || summary.P95DurationMs == 0 {
+			t.Errorf("workerExecutions=%v: summary counts %d executions with p95 %vms, want %d and the merged p95", withExecutions,
+				summary.TotalExecutions, summary.P95DurationMs, want.SuccessfulExecutions+want.Errors)
+		}
+	}
+}
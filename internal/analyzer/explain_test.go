@@ -0,0 +1,116 @@
+// internal/analyzer/explain_test.go
+package analyzer
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+func TestGenerateQueryExplainJSON(t *testing.T) {
+	fake := &fakeDB{respond: func(query string) fakeResult {
+		return fakeResult{columns: []string{"EXPLAIN"}, rows: [][]driver.Value{{`{"query_block": {}}`}}}
+	}}
+
+	got, err := GenerateQueryExplain(t.Context(), fake.open(t), "SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"query_block": {}}` {
+		t.Errorf("GenerateQueryExplain() = %q, want the JSON plan", got)
+	}
+	if executed := fake.queries(); len(executed) != 1 || executed[0] != "EXPLAIN FORMAT=JSON SELECT * FROM orders" {
+		t.Errorf("executed %q, want only the JSON EXPLAIN", executed)
+	}
+}
+
+// Servers without FORMAT=JSON get the tabular plan, one line per row with
+// the same number of cells whatever the values hold.
+func TestGenerateQueryExplainTabularFallback(t *testing.T) {
+	fake := &fakeDB{respond: func(query string) fakeResult {
+		if strings.HasPrefix(query, "EXPLAIN FORMAT=JSON") {
+			return fakeResult{err: errors.New("You have an error in your SQL syntax")}
+		}
+		return fakeResult{
+			columns: []string{"id", "table", "key", "Extra"},
+			rows: [][]driver.Value{
+				{int64(1), "orders", nil, "Using where"},
+				{int64(2), []byte("a|b"), "line\nbreak", `C:\tmp` + "\r\n"},
+			},
+		}
+	}}
+
+	got, err := GenerateQueryExplain(t.Context(), fake.open(t), "SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id | table | key | Extra\n" +
+		"--- | --- | --- | ---\n" +
+		"1 | orders | NULL | Using where\n" +
+		`2 | a\|b | line\nbreak | C:\\tmp\n` + "\n"
+	if got != want {
+		t.Errorf("GenerateQueryExplain() =\n%s\nwant\n%s", got, want)
+	}
+	if executed := fake.queries(); len(executed) != 2 || executed[1] != "EXPLAIN SELECT * FROM orders" {
+		t.Errorf("executed %q, want the JSON EXPLAIN then the tabular one", executed)
+	}
+}
+
+func TestGenerateQueryExplainRefuses(t *testing.T) {
+	fake := &fakeDB{}
+	db := fake.open(t)
+
+	if got, err := GenerateQueryExplain(t.Context(), db, "UPDATE orders SET total = 0"); err != nil || !strings.Contains(got, "not available") {
+		t.Errorf("GenerateQueryExplain(UPDATE) = %q, %v, want a not-available note", got, err)
+	}
+	if _, err := GenerateQueryExplain(t.Context(), db, "SELECT * FROM orders WHERE id = ?"); !errors.Is(err, errUnboundPlaceholders) {
+		t.Errorf("GenerateQueryExplain(placeholder) = %v, want errUnboundPlaceholders", err)
+	}
+	// A '?' in a literal isn't a placeholder.
+	if _, err := GenerateQueryExplain(t.Context(), db, "SELECT * FROM orders WHERE note = '?'"); err != nil {
+		t.Errorf("GenerateQueryExplain(literal) = %v", err)
+	}
+	for _, q := range fake.queries() {
+		if !strings.Contains(q, "note = '?'") {
+			t.Errorf("executed %q, want only the query without placeholders explained", q)
+		}
+	}
+}
+
+// A query's explainParams are bound to its placeholders, with whole JSON
+// numbers as integers.
+func TestGenerateQueryExplainBindsParams(t *testing.T) {
+	fake := &fakeDB{respond: func(query string) fakeResult {
+		return fakeResult{columns: []string{"EXPLAIN"}, rows: [][]driver.Value{{`{"query_block": {}}`}}}
+	}}
+	db := fake.open(t)
+
+	var q model.Query
+	if err := json.Unmarshal([]byte(`{"sql": "SELECT * FROM orders WHERE customer_id = ? AND total > ? AND status = ?",
+		"explainParams": [42, 9.5, "open"]}`), &q); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GenerateQueryExplain(t.Context(), db, q.SQL, q.ExplainParams...); err != nil {
+		t.Fatalf("GenerateQueryExplain() = %v", err)
+	}
+	if _, err := PlanFingerprint(t.Context(), db, q.SQL, q.ExplainParams...); err == nil {
+		t.Error("PlanFingerprint() of a plan without table columns succeeded")
+	}
+
+	want := []driver.Value{int64(42), 9.5, "open"}
+	for i, args := range fake.boundArgs() {
+		if !reflect.DeepEqual(args, want) {
+			t.Errorf("%q bound %v, want %v", fake.queries()[i], args, want)
+		}
+	}
+	if n := len(fake.queries()); n != 2 {
+		t.Errorf("executed %d statements, want the JSON EXPLAIN and the fingerprint's", n)
+	}
+}
@@ -0,0 +1,113 @@
+// internal/analyzer/fakedb_test.go
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+// fakeResult is what a fakeDB returns for a statement.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+// fakeDB is an in-memory database/sql driver for tests. Every statement is
+// answered by respond, or with an empty result without it, and logged in the
+// order it was run along with the values bound to it.
+type fakeDB struct {
+	respond func(query string) fakeResult
+
+	mu   sync.Mutex
+	log  []string
+	args [][]driver.Value
+}
+
+// open returns a *sql.DB backed by f, closed when the test ends.
+func (f *fakeDB) open(tb interface{ Cleanup(func()) }) *sql.DB {
+	db := sql.OpenDB(f)
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// queries returns the statements run so far, in order.
+func (f *fakeDB) queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+// boundArgs returns the values bound to each statement of queries.
+func (f *fakeDB) boundArgs() [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]driver.Value(nil), f.args...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDB is opened with sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	var args []driver.Value
+	for _, arg := range named {
+		args = append(args, arg.Value)
+	}
+
+	c.db.mu.Lock()
+	c.db.log = append(c.db.log, query)
+	c.db.args = append(c.db.args, args)
+	c.db.mu.Unlock()
+
+	var result fakeResult
+	if c.db.respond != nil {
+		result = c.db.respond(query)
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB doesn't prepare statements")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,116 @@
+// internal/analyzer/guardrails.go
+package analyzer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// Guard pauses a run's dispatch while a guardrail rule has tripped on the
+// server metrics sampled during it. Executions already running finish
+// normally; workers just get no new ones until every tripped rule has
+// cleared. It is safe to feed from a collector goroutine while the run waits
+// on it.
+type Guard struct {
+	rules []database.GuardrailRule
+
+	mu      sync.Mutex
+	tripped map[int]bool  // Rules holding dispatch paused
+	resume  chan struct{} // Closed while dispatch isn't paused
+	pauses  []model.GuardrailPause
+}
+
+func NewGuard(rules []database.GuardrailRule) *Guard {
+	resume := make(chan struct{})
+	close(resume)
+	return &Guard{rules: rules, tripped: make(map[int]bool), resume: resume}
+}
+
+// GuardDispatch makes the run wait on guard before dispatching each
+// execution. Set it before the run starts.
+func (a *Analyzer) GuardDispatch(guard *Guard) {
+	a.executor.guard = guard
+}
+
+// Observe trips and clears rules on sample, pausing dispatch when the first
+// rule trips and resuming it when the last one clears.
+func (g *Guard) Observe(sample database.DBMetrics) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	paused := len(g.tripped) > 0
+	for i, rule := range g.rules {
+		if g.tripped[i] {
+			if value, ok := rule.Clears(sample); ok {
+				delete(g.tripped, i)
+				log.Printf("Guardrail cleared: %s (%s = %.4g)", rule.Expr, rule.Pause.Metric, value)
+			}
+		} else if value, ok := rule.Trips(sample); ok {
+			g.tripped[i] = true
+			log.Printf("Warning: guardrail tripped: %s (%s = %.4g)", rule.Expr, rule.Pause.Metric, value)
+			if !paused && len(g.tripped) == 1 {
+				g.pauses = append(g.pauses, model.GuardrailPause{
+					Rule:     rule.Expr,
+					Metric:   rule.Pause.Metric,
+					Value:    value,
+					PausedAt: time.Now(),
+				})
+			}
+		}
+	}
+
+	switch {
+	case !paused && len(g.tripped) > 0:
+		g.resume = make(chan struct{})
+		log.Printf("Warning: pausing dispatch until the server recovers")
+	case paused && len(g.tripped) == 0:
+		pause := &g.pauses[len(g.pauses)-1]
+		resumed := time.Now()
+		pause.ResumedAt = &resumed
+		pause.Duration = resumed.Sub(pause.PausedAt)
+		close(g.resume)
+		log.Printf("Resuming dispatch after %s", g.pauses[len(g.pauses)-1].Duration.Round(time.Millisecond))
+	}
+}
+
+// wait blocks while dispatch is paused, returning early with ctx's error. It
+// returns when the pause it waited out ended, or the zero time if it didn't
+// wait.
+func (g *Guard) wait(ctx context.Context) (time.Time, error) {
+	g.mu.Lock()
+	resume := g.resume
+	g.mu.Unlock()
+
+	select {
+	case <-resume:
+		return time.Time{}, nil
+	default:
+	}
+	select {
+	case <-resume:
+		return time.Now(), nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
+
+// Summary returns every pause so far and the time dispatch spent paused. A
+// pause still open counts up to now.
+func (g *Guard) Summary() model.Guardrails {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	summary := model.Guardrails{Rules: len(g.rules), Pauses: append([]model.GuardrailPause(nil), g.pauses...)}
+	for i, pause := range summary.Pauses {
+		if pause.ResumedAt == nil {
+			summary.Pauses[i].Duration = time.Since(pause.PausedAt)
+		}
+		summary.PausedTime += summary.Pauses[i].Duration
+	}
+	return summary
+}
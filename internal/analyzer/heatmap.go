@@ -0,0 +1,76 @@
+// internal/analyzer/heatmap.go
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// heatmapCounter bins successful executions by start time and latency as
+// they complete, so the heatmap doesn't depend on executions being kept.
+type heatmapCounter struct {
+	start  time.Time
+	window time.Duration
+	bounds []time.Duration
+	counts [][]int
+}
+
+func newHeatmapCounter(cfg config.Heatmap, start time.Time) *heatmapCounter {
+	bounds := make([]time.Duration, len(cfg.BucketsMs))
+	for i, ms := range cfg.BucketsMs {
+		bounds[i] = time.Duration(ms * float64(time.Millisecond))
+	}
+	return &heatmapCounter{
+		start:  start,
+		window: max(time.Duration(cfg.WindowSeconds*float64(time.Second)), time.Millisecond),
+		bounds: bounds,
+	}
+}
+
+func (h *heatmapCounter) add(execution model.QueryExecution) {
+	if execution.Error != nil {
+		return
+	}
+	w := max(int(execution.StartTime.Sub(h.start)/h.window), 0)
+	h.grow(w + 1)
+	b := sort.Search(len(h.bounds), func(i int) bool { return execution.Duration <= h.bounds[i] })
+	h.counts[w][b]++
+}
+
+func (h *heatmapCounter) grow(windows int) {
+	for len(h.counts) < windows {
+		h.counts = append(h.counts, make([]int, len(h.bounds)+1))
+	}
+}
+
+// merge adds other's counts, which must use the same grid, into h.
+func (h *heatmapCounter) merge(other *heatmapCounter) {
+	h.mergeCounts(other.counts)
+}
+
+// mergeCounts adds counts, per window and bucket of the same grid as h, into
+// h.
+func (h *heatmapCounter) mergeCounts(counts [][]int) {
+	h.grow(len(counts))
+	for w, row := range counts {
+		for b, n := range row {
+			h.counts[w][b] += n
+		}
+	}
+}
+
+func (h *heatmapCounter) heatmap() *model.Heatmap {
+	bucketsMs := make([]float64, len(h.bounds))
+	for i, b := range h.bounds {
+		bucketsMs[i] = float64(b) / float64(time.Millisecond)
+	}
+	return &model.Heatmap{
+		Start:         h.start,
+		WindowSeconds: h.window.Seconds(),
+		BucketsMs:     bucketsMs,
+		Counts:        h.counts,
+	}
+}
@@ -0,0 +1,223 @@
+// internal/analyzer/lint.go
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/xwb1989/sqlparser"
+)
+
+// Lint warnings reported by LintQuery.
+const (
+	lintSelectStar    = "SELECT * fetches every column; list the columns the application uses"
+	lintOrderNoLimit  = "ORDER BY without LIMIT sorts the entire result"
+	lintUpdateNoWhere = "UPDATE without WHERE changes every row"
+	lintDeleteNoWhere = "DELETE without WHERE removes every row"
+)
+
+var (
+	selectStarRegex = regexp.MustCompile("\\bselect\\s+(?:distinct\\s+)?(?:`?[a-z0-9_]+`?\\.)?\\*")
+	whereRegex      = regexp.MustCompile(`\bwhere\b`)
+	limitRegex      = regexp.MustCompile(`\blimit\b`)
+	orderByRegex    = regexp.MustCompile(`\border\s+by\b`)
+)
+
+// LintQuery returns warnings about anti-patterns in sql: SELECT *, ORDER BY
+// without LIMIT, and UPDATE or DELETE without WHERE.
+func LintQuery(sql string) []string {
+	if stmt, ok := parseStatement(sql); ok {
+		return lintParsed(stmt)
+	}
+
+	lower := strings.ToLower(sql)
+	var warnings []string
+
+	switch ClassifyStatement(sql) {
+	case StatementSelect:
+		if selectStarRegex.MatchString(lower) {
+			warnings = append(warnings, lintSelectStar)
+		}
+		if orderByRegex.MatchString(lower) && !limitRegex.MatchString(lower) {
+			warnings = append(warnings, lintOrderNoLimit)
+		}
+	case StatementUpdate:
+		if !whereRegex.MatchString(lower) {
+			warnings = append(warnings, lintUpdateNoWhere)
+		}
+	case StatementDelete:
+		if !whereRegex.MatchString(lower) {
+			warnings = append(warnings, lintDeleteNoWhere)
+		}
+	}
+
+	return warnings
+}
+
+func lintParsed(stmt sqlparser.Statement) []string {
+	var warnings []string
+
+	switch n := stmt.(type) {
+	case *sqlparser.Select:
+		if len(n.OrderBy) > 0 && n.Limit == nil {
+			warnings = append(warnings, lintOrderNoLimit)
+		}
+	case *sqlparser.Union:
+		if len(n.OrderBy) > 0 && n.Limit == nil {
+			warnings = append(warnings, lintOrderNoLimit)
+		}
+	case *sqlparser.Update:
+		if n.Where == nil {
+			warnings = append(warnings, lintUpdateNoWhere)
+		}
+	case *sqlparser.Delete:
+		if n.Where == nil {
+			warnings = append(warnings, lintDeleteNoWhere)
+		}
+	}
+
+	// Only select lists count; COUNT(*) is a StarExpr too
+	star := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if sel, ok := node.(*sqlparser.Select); ok {
+			for _, expr := range sel.SelectExprs {
+				if _, ok := expr.(*sqlparser.StarExpr); ok {
+					star = true
+				}
+			}
+		}
+		return !star, nil
+	}, stmt)
+	if star {
+		warnings = append([]string{lintSelectStar}, warnings...)
+	}
+
+	return warnings
+}
+
+// CheckMultiStatements returns an error naming every query that holds more
+// than one statement when dsn doesn't enable multiStatements, since each of
+// their executions would fail with a syntax error. CALL needs no flag: the
+// driver always accepts the several result sets a procedure can return.
+func CheckMultiStatements(queries []model.Query, dsn string) error {
+	if database.MultiStatementsEnabled(dsn) {
+		return nil
+	}
+	var failing []string
+	for _, q := range queries {
+		if n := len(database.SplitStatements(q.SQL)); n > 1 {
+			failing = append(failing, fmt.Sprintf("%s (%d statements)", q.Name, n))
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d queries hold several statements; add multiStatements=true to the DSN to run them:\n  %s",
+		len(failing), strings.Join(failing, "\n  "))
+}
+
+// CheckLint returns an error naming every query with lint warnings, for runs
+// that treat warnings as fatal.
+func CheckLint(queries []model.Query) error {
+	var failing []string
+	for _, q := range queries {
+		for _, w := range q.LintWarnings {
+			failing = append(failing, fmt.Sprintf("%s: %s", q.Name, w))
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d lint warning(s) with strict lint enabled:\n  %s", len(failing), strings.Join(failing, "\n  "))
+}
+
+// templateSchema stands in for SchemaPlaceholder while a query that hasn't
+// been fanned out yet is parsed for its tables.
+const templateSchema = "fn_template_schema"
+
+// CheckTableAccess returns an error naming every query that touches a table
+// not matching allowed, when set, or matching denied. Patterns are globs
+// (*_pii) unless wrapped in slashes, in which case they are regular
+// expressions, and match table names regardless of case. A denied pattern
+// also catches a schema-qualified name by its table part, so app.users_pii
+// can't slip past *_pii; an allowed pattern must match the name as the query
+// writes it.
+//
+// With either pattern set, a statement whose tables can't be resolved, such
+// as a CALL, is refused too. In a query not yet fanned out over its schemas,
+// tables qualified by SchemaPlaceholder are only checked against denied;
+// check the fanned-out queries again for allowed.
+func CheckTableAccess(queries []model.Query, allowed, denied string) error {
+	if allowed == "" && denied == "" {
+		return nil
+	}
+	allow, err := compileTablePattern(allowed)
+	if err != nil {
+		return fmt.Errorf("invalid allowedTablesPattern: %w", err)
+	}
+	deny, err := compileTablePattern(denied)
+	if err != nil {
+		return fmt.Errorf("invalid deniedTablesPattern: %w", err)
+	}
+
+	var failing []string
+	for _, q := range queries {
+		sql := strings.ReplaceAll(q.SQL, SchemaPlaceholder, templateSchema)
+		for _, stmt := range database.SplitStatements(sql) {
+			if ClassifyStatement(stmt.SQL) == StatementOther {
+				failing = append(failing, fmt.Sprintf("%s: can't tell which tables its %s statement touches",
+					q.Name, strings.ToUpper(strings.Fields(stmt.SQL)[0])))
+				continue
+			}
+			for _, table := range AnalyzeTablesInQuery(stmt.SQL) {
+				table = strings.ToLower(table)
+				schema, bare, qualified := strings.Cut(table, ".")
+				if !qualified {
+					bare = table
+				}
+				switch {
+				case deny != nil && (deny(table) || deny(bare)):
+					failing = append(failing, fmt.Sprintf("%s touches %s, denied by deniedTablesPattern %q",
+						q.Name, displayTable(table), denied))
+				case allow != nil && schema != templateSchema && !allow(table):
+					failing = append(failing, fmt.Sprintf("%s touches %s, which allowedTablesPattern %q doesn't match",
+						q.Name, table, allowed))
+				}
+			}
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d table access violation(s):\n  %s", len(failing), strings.Join(failing, "\n  "))
+}
+
+// compileTablePattern compiles one table pattern to match lower-cased table
+// names, or returns nil for none.
+func compileTablePattern(pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if _, err := compileNamePatterns([]string{pattern}); err != nil {
+		return nil, err
+	}
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		pattern = "/(?i)" + pattern[1:]
+	} else {
+		pattern = strings.ToLower(pattern)
+	}
+	matchers, err := compileNamePatterns([]string{pattern})
+	if err != nil {
+		return nil, err
+	}
+	return matchers[0], nil
+}
+
+// displayTable names table the way its query writes it, putting
+// SchemaPlaceholder back.
+func displayTable(table string) string {
+	return strings.Replace(table, templateSchema+".", SchemaPlaceholder+".", 1)
+}
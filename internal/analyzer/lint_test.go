@@ -0,0 +1,73 @@
+// internal/analyzer/lint_test.go
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+func TestCheckTableAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		allowed string
+		denied  string
+		want    string // Substring of the error, or "" for none
+	}{
+		{"no patterns", "CALL dump_users_pii()", "", "", ""},
+		{"denied", "SELECT id FROM users_pii", "", "*_pii", "denied by deniedTablesPattern"},
+		{"denied pattern upper case", "SELECT id FROM users_pii", "", "*_PII", "touches users_pii"},
+		{"denied table upper case", "SELECT id FROM Users_PII", "", "*_pii", "touches users_pii"},
+		{"denied regexp upper case", "SELECT id FROM users_pii", "", "/_PII$/", "touches users_pii"},
+		{"denied qualified", "SELECT id FROM app.users_pii", "", "*_pii", "touches app.users_pii"},
+		{"denied in subquery", "SELECT id FROM orders WHERE user_id IN (SELECT id FROM users_pii)", "", "*_pii", "touches users_pii"},
+		{"denied in derived table", "SELECT * FROM (SELECT id FROM users_pii) u", "", "*_pii", "touches users_pii"},
+		{"denied in join", "SELECT o.id FROM orders o JOIN users_pii u ON u.id = o.user_id", "", "*_pii", "touches users_pii"},
+		{"denied in template", "SELECT id FROM {{schema}}.users_pii", "", "*_pii", "touches {{schema}}.users_pii"},
+		{"denied in second statement", "SELECT 1; SELECT id FROM users_pii", "", "*_pii", "touches users_pii"},
+		{"not denied", "SELECT id FROM orders", "", "*_pii", ""},
+		{"call refused", "CALL dump_users_pii()", "", "*_pii", "its CALL statement"},
+		{"call after select refused", "SELECT id FROM orders; CALL dump_users_pii()", "", "*_pii", "its CALL statement"},
+		{"allowed", "SELECT id FROM app.orders", "app.*", "", ""},
+		{"allowed upper case", "SELECT id FROM APP.Orders", "app.*", "", ""},
+		{"not allowed", "SELECT id FROM orders", "app.*", "", "allowedTablesPattern \"app.*\" doesn't match"},
+		{"not allowed in subquery", "SELECT id FROM app.orders WHERE id IN (SELECT order_id FROM audit.log)", "app.*", "", "touches audit.log"},
+		{"template deferred for allowed", "SELECT id FROM {{schema}}.orders", "app.*", "", ""},
+		{"fanned out not allowed", "SELECT id FROM tenant1.orders", "app.*", "", "touches tenant1.orders"},
+		{"deny wins over allow", "SELECT id FROM app.users_pii", "app.*", "*_pii", "denied by deniedTablesPattern"},
+		{"invalid allowed", "SELECT 1", "/[/", "", "invalid allowedTablesPattern"},
+		{"invalid denied", "SELECT 1", "", "[", "invalid deniedTablesPattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := []model.Query{{Name: "q", SQL: tt.sql}}
+			err := CheckTableAccess(queries, tt.allowed, tt.denied)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("CheckTableAccess() = %v, want nil", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("CheckTableAccess() = nil, want error containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("CheckTableAccess() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTableAccessFannedOut(t *testing.T) {
+	queries, err := ExpandSchemas([]model.Query{{Name: "q", SQL: "SELECT id FROM {{schema}}.orders"}}, []string{"app", "tenant1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CheckTableAccess(queries, "app.*", "")
+	if err == nil {
+		t.Fatal("CheckTableAccess() = nil, want an error for tenant1")
+	}
+	if !strings.Contains(err.Error(), "q@tenant1 touches tenant1.orders") || strings.Contains(err.Error(), "q@app") {
+		t.Errorf("CheckTableAccess() = %v, want only q@tenant1 named", err)
+	}
+}
@@ -0,0 +1,75 @@
+// internal/analyzer/locks.go
+package analyzer
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// runMeasuringLocks runs the suite one query at a time, each query's
+// iterations as a batch of their own, and attributes the change in the
+// server's row lock counters over each batch to that query. The counters are
+// global, so waits of other sessions running at the same time are counted
+// too. Queries no longer overlap each other, so contention between them
+// disappears from the run.
+func (a *Analyzer) runMeasuringLocks(ctx context.Context) ([]model.QueryResult, error) {
+	qe := a.executor
+	start := time.Now()
+	shares := NormalizeWeights(a.queries)
+
+	log.Printf("Measuring row lock waits: running %d queries one at a time, %d executions each across %d workers",
+		len(a.queries), a.iterations, a.concurrency)
+
+	results := make([]model.QueryResult, len(a.queries))
+	var err error
+	for i, query := range a.queries {
+		if err != nil {
+			// Cancelled: the remaining queries never ran.
+			results[i] = qe.newQueryResult(query, shares[i], a.iterations)
+			mergeExecutions(&results[i], nil, 0, qe.finalizeOptions())
+			continue
+		}
+
+		before, lockErr := database.GetLockCounters(a.db)
+		if lockErr != nil {
+			log.Printf("Warning: couldn't read row lock counters before %s: %v", query.Name, lockErr)
+		}
+
+		var batch []model.QueryResult
+		batch, err = qe.ExecuteBatchContext(ctx, a.queries[i:i+1], a.iterations)
+		if len(batch) == 0 {
+			return nil, err
+		}
+		results[i] = batch[0]
+		results[i].WeightShare = shares[i]
+
+		if lockErr == nil {
+			after, lockErr := database.GetLockCounters(a.db)
+			if lockErr != nil {
+				log.Printf("Warning: couldn't read row lock counters after %s: %v", query.Name, lockErr)
+				continue
+			}
+			delta := after.Sub(before)
+			results[i].LockWaits = delta.RowLockWaits
+			results[i].LockWaitTimeMs = delta.RowLockTimeMs
+		}
+	}
+
+	// Each batch counted its heatmap from its own start; count the whole
+	// run's executions from the run's.
+	qe.heatmap = newHeatmapCounter(qe.heatmapCfg, start)
+	for _, result := range results {
+		for _, execution := range result.Executions {
+			qe.heatmap.add(execution)
+		}
+		if result.ColdExecution != nil {
+			qe.heatmap.add(*result.ColdExecution)
+		}
+	}
+
+	return results, err
+}
@@ -0,0 +1,255 @@
+// internal/analyzer/plan.go
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+var (
+	jsonFullScanRegex  = regexp.MustCompile(`"table_name":\s*"([^"]+)",\s*"access_type":\s*"ALL"`)
+	jsonFilesortRegex  = regexp.MustCompile(`"using_filesort":\s*true`)
+	jsonTemporaryRegex = regexp.MustCompile(`"using_temporary_table":\s*true`)
+)
+
+// ExplainPlanFile is the name of the file, relative to the output directory,
+// that explainPlanFiles writes the plan of the named query to.
+func ExplainPlanFile(queryName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, queryName)
+	return "explain-" + name + ".json"
+}
+
+// IsExplainPlanFile reports whether a QueryResult's ExplainPlan is the path
+// of a file written by explainPlanFiles rather than the plan itself.
+func IsExplainPlanFile(plan string) bool {
+	return strings.HasPrefix(plan, "explain-") && strings.HasSuffix(plan, ".json")
+}
+
+// PlanFingerprint returns the shape of query's plan from a tabular EXPLAIN:
+// each table in join order with its access type and chosen index, e.g.
+// "o(ref,idx_customer) > c(eq_ref,PRIMARY)". It leaves out the row estimates,
+// which drift with every statistics update, so two fingerprints differ only
+// when the optimizer chose a different plan. params are bound to the query's
+// placeholders as GenerateQueryExplain binds them.
+func PlanFingerprint(ctx context.Context, db queryer, query string, params ...any) (string, error) {
+	args, err := explainArgs(query, params)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", fmt.Errorf("error explaining query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	tableCol, typeCol, keyCol := slices.Index(columns, "table"), slices.Index(columns, "type"), slices.Index(columns, "key")
+	if tableCol < 0 || typeCol < 0 || keyCol < 0 {
+		return "", fmt.Errorf("EXPLAIN returned no table, type and key columns")
+	}
+
+	values := make([]sql.NullString, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	var steps []string
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+		steps = append(steps, fmt.Sprintf("%s(%s,%s)",
+			orNull(values[tableCol]), orNull(values[typeCol]), orNull(values[keyCol])))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(steps, " > "), nil
+}
+
+func orNull(s sql.NullString) string {
+	if !s.Valid {
+		return "NULL"
+	}
+	return s.String
+}
+
+// PlanWarnings lists the expensive operations found in an EXPLAIN plan as
+// returned by GenerateQueryExplain, in either its JSON or tabular form: full
+// table scans, filesorts and temporary tables.
+func PlanWarnings(plan string) []string {
+	if strings.HasPrefix(strings.TrimSpace(plan), "{") {
+		return jsonPlanWarnings(plan)
+	}
+	return tabularPlanWarnings(plan)
+}
+
+func jsonPlanWarnings(plan string) []string {
+	var warnings []string
+	for _, m := range jsonFullScanRegex.FindAllStringSubmatch(plan, -1) {
+		warnings = append(warnings, fmt.Sprintf("full table scan on %s", m[1]))
+	}
+	if jsonFilesortRegex.MatchString(plan) {
+		warnings = append(warnings, "uses filesort")
+	}
+	if jsonTemporaryRegex.MatchString(plan) {
+		warnings = append(warnings, "uses a temporary table")
+	}
+	return warnings
+}
+
+func tabularPlanWarnings(plan string) []string {
+	lines := strings.Split(strings.TrimSpace(plan), "\n")
+	if len(lines) < 3 {
+		return nil
+	}
+
+	header := strings.Split(lines[0], " | ")
+	tableCol, typeCol, extraCol := -1, -1, -1
+	for i, col := range header {
+		switch col {
+		case "table":
+			tableCol = i
+		case "type":
+			typeCol = i
+		case "Extra":
+			extraCol = i
+		}
+	}
+
+	var warnings []string
+	var filesort, temporary bool
+	for _, line := range lines[2:] {
+		cells := strings.Split(line, " | ")
+		if typeCol >= 0 && typeCol < len(cells) && cells[typeCol] == "ALL" {
+			table := "?"
+			if tableCol >= 0 && tableCol < len(cells) {
+				table = cells[tableCol]
+			}
+			warnings = append(warnings, fmt.Sprintf("full table scan on %s", table))
+		}
+		if extraCol >= 0 && extraCol < len(cells) {
+			filesort = filesort || strings.Contains(cells[extraCol], "Using filesort")
+			temporary = temporary || strings.Contains(cells[extraCol], "Using temporary")
+		}
+	}
+
+	if filesort {
+		warnings = append(warnings, "uses filesort")
+	}
+	if temporary {
+		warnings = append(warnings, "uses a temporary table")
+	}
+	return warnings
+}
+
+// PlanEstimates reads the optimizer's estimates from a JSON EXPLAIN plan:
+// the query cost, and the rows produced by the last table in the join order,
+// before grouping and LIMIT. Either is nil when the plan doesn't carry it,
+// e.g. tabular plans from older servers or UNIONs.
+func PlanEstimates(plan string) (cost *float64, rows *int64) {
+	var root struct {
+		QueryBlock map[string]any `json:"query_block"`
+	}
+	if err := json.Unmarshal([]byte(plan), &root); err != nil || root.QueryBlock == nil {
+		return nil, nil
+	}
+
+	if info, ok := root.QueryBlock["cost_info"].(map[string]any); ok {
+		if c, ok := planNumber(info["query_cost"]); ok {
+			cost = &c
+		}
+	}
+	if r, ok := planRows(root.QueryBlock); ok {
+		n := int64(r)
+		rows = &n
+	}
+	return cost, rows
+}
+
+// planRows descends through the operations wrapping a query block's join to
+// the last table joined.
+func planRows(node map[string]any) (float64, bool) {
+	if table, ok := node["table"].(map[string]any); ok {
+		return planNumber(table["rows_produced_per_join"])
+	}
+	if loop, ok := node["nested_loop"].([]any); ok && len(loop) > 0 {
+		if last, ok := loop[len(loop)-1].(map[string]any); ok {
+			return planRows(last)
+		}
+	}
+	for _, key := range []string{"ordering_operation", "grouping_operation", "duplicates_removal", "windowing"} {
+		if child, ok := node[key].(map[string]any); ok {
+			return planRows(child)
+		}
+	}
+	return 0, false
+}
+
+// planNumber reads a numeric plan field, which MySQL writes as a string
+// ("12.50") in cost_info and as a number elsewhere.
+func planNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// costLatency pairs each query's optimizer cost with its measured latency.
+// RelativeSpeed compares a query's milliseconds per unit of cost with the
+// suite median, so a query far slower or faster than its cost predicts
+// stands out regardless of the server's absolute speed.
+func costLatency(results []model.QueryResult) []model.CostLatency {
+	var points []model.CostLatency
+	var ratios []float64
+	for _, r := range results {
+		if r.EstimatedCost == nil || *r.EstimatedCost <= 0 || r.SuccessfulExecutions == 0 {
+			continue
+		}
+		avgMs := float64(r.AvgDuration.Microseconds()) / 1000
+		points = append(points, model.CostLatency{
+			Query:         r.Name,
+			EstimatedCost: *r.EstimatedCost,
+			EstimatedRows: r.EstimatedRows,
+			AvgMs:         avgMs,
+			AvgRows:       float64(r.RowsAffected) / float64(r.SuccessfulExecutions),
+		})
+		ratios = append(ratios, avgMs / *r.EstimatedCost)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(ratios)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	for i := range points {
+		if median > 0 {
+			points[i].RelativeSpeed = ratios[i] / median
+		}
+	}
+	return points
+}
@@ -0,0 +1,133 @@
+// internal/analyzer/profile.go
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// ProfileQuery runs query once more on its own connection with session
+// profiling enabled and returns the time spent in each execution stage, in
+// the order the stages first ran. Stages that ran more than once are summed.
+// It needs a server that still supports SHOW PROFILE.
+func ProfileQuery(ctx context.Context, db *sql.DB, query string) ([]model.ProfileStage, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET profiling = 1"); err != nil {
+		return nil, fmt.Errorf("error enabling profiling: %w", err)
+	}
+	// The connection goes back to the pool, so don't leave profiling on
+	defer conn.ExecContext(context.Background(), "SET profiling = 0")
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+
+	var queryID int
+	profiles, err := conn.QueryContext(ctx, "SHOW PROFILES")
+	if err != nil {
+		return nil, fmt.Errorf("error listing profiles: %w", err)
+	}
+	for profiles.Next() {
+		var id int
+		var duration, text sql.NullString
+		if err := profiles.Scan(&id, &duration, &text); err != nil {
+			profiles.Close()
+			return nil, fmt.Errorf("error reading profiles: %w", err)
+		}
+		queryID = id
+	}
+	profiles.Close()
+	if queryID == 0 {
+		return nil, fmt.Errorf("no profile recorded; is profiling supported by this server?")
+	}
+
+	stageRows, err := conn.QueryContext(ctx, fmt.Sprintf("SHOW PROFILE FOR QUERY %d", queryID))
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile: %w", err)
+	}
+	defer stageRows.Close()
+
+	var stages []model.ProfileStage
+	index := make(map[string]int)
+	var total time.Duration
+	for stageRows.Next() {
+		var status, seconds string
+		if err := stageRows.Scan(&status, &seconds); err != nil {
+			return nil, fmt.Errorf("error reading profile: %w", err)
+		}
+		secs, err := strconv.ParseFloat(seconds, 64)
+		if err != nil {
+			continue
+		}
+		duration := time.Duration(secs * float64(time.Second))
+		total += duration
+
+		if i, ok := index[status]; ok {
+			stages[i].Duration += duration
+			continue
+		}
+		index[status] = len(stages)
+		stages = append(stages, model.ProfileStage{Stage: status, Duration: duration})
+	}
+	if err := stageRows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading profile: %w", err)
+	}
+
+	if total > 0 {
+		for i := range stages {
+			stages[i].Percent = float64(stages[i].Duration) / float64(total) * 100
+		}
+	}
+
+	return stages, nil
+}
+
+// profileSlowest profiles the query with the highest average latency and
+// stores the stage breakdown on its result.
+func (a *Analyzer) profileSlowest(ctx context.Context, results []model.QueryResult) {
+	slowest := -1
+	for i, result := range results {
+		if result.SuccessfulExecutions == 0 {
+			continue
+		}
+		if slowest < 0 || result.AvgDuration > results[slowest].AvgDuration {
+			slowest = i
+		}
+	}
+	if slowest < 0 {
+		return
+	}
+
+	// The profiling run isn't in a transaction; repeating a write the run
+	// rolled back would keep its effects.
+	if results[slowest].RolledBack && results[slowest].StatementType != StatementSelect {
+		log.Printf("Warning: not profiling slowest query %s: it writes, and its executions were rolled back", results[slowest].Name)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	log.Printf("Profiling slowest query %s", results[slowest].Name)
+	profile, err := ProfileQuery(ctx, a.db, results[slowest].SQL)
+	if err != nil {
+		log.Printf("Warning: couldn't profile %s: %v", results[slowest].Name, err)
+		return
+	}
+	results[slowest].Profile = profile
+}
@@ -5,61 +5,483 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand/v2"
 	"os"
+	"path"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
 	"github.com/0xsj/fn-analyzer/internal/model"
 	"github.com/0xsj/fn-analyzer/pkg/utils"
 )
 
 type QueryExecutor struct {
 	db          *sql.DB
+	dsn         string
 	timeout     time.Duration
 	verbose     bool
 	concurrency int
-	semaphore   chan struct{}
-	mutex       sync.Mutex
+	freshConn   bool
+	measureCold bool
+	tagQueries  bool
+	label       string
+	order       string
+	rng         *rand.Rand   // Every random choice of the run, seeded from Config.Seed
+	tracer      trace.Tracer // Nil unless executions are traced
+	guard       *Guard       // Nil unless dispatch pauses on guardrails
+	connMode    string
+	sampleRows  int
+	sampleCap   int                 // Longest sample value kept, in bytes
+	sampleMask  []func(string) bool // Columns whose sample values are masked
+	columnTypes bool
+	complexity  ComplexityClassifier
+	maxRows     int64
+	txMode      string
+	rollback    bool // Roll back INSERT, UPDATE and DELETE whatever txMode says
+	completed   atomic.Int64
+	reconnects  atomic.Int64
+	onQueryDone func(model.QueryResult)
+	onExecution func(query, complexity string, execution model.QueryExecution)
+	heatmapCfg  config.Heatmap
+	minSamples  config.PercentileMinSamples
+	percentiles utils.PercentileMethod
+	heatmap     *heatmapCounter // Filled in by ExecuteBatchContext
+	heatmapFrom time.Time       // When set, batches count the heatmap from here rather than their own start
+}
+
+// queryer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
+// session is satisfied by *sql.DB and *sql.Conn.
+type session interface {
+	queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Transaction modes control whether each execution is wrapped in a
+// transaction.
+const (
+	TxModeNone     = "none"     // Run the statement on its own (autocommit)
+	TxModeCommit   = "commit"   // BEGIN, statement, COMMIT
+	TxModeRollback = "rollback" // BEGIN, statement, ROLLBACK
+)
+
+// TransactionModes lists the accepted transaction modes, default first.
+var TransactionModes = []string{TxModeNone, TxModeCommit, TxModeRollback}
+
 func NewQueryExecutor(db *sql.DB, cfg config.Config) *QueryExecutor {
+	// The patterns were checked by ValidateSampleMask before the run.
+	sampleMask, err := compileNamePatterns(cfg.SampleMaskColumns)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	return &QueryExecutor{
 		db:          db,
+		dsn:         cfg.DSN,
 		timeout:     cfg.Timeout,
 		verbose:     cfg.Verbose,
 		concurrency: cfg.Concurrency,
-		semaphore:   make(chan struct{}, cfg.Concurrency),
+		freshConn:   cfg.FreshConnPerQuery,
+		measureCold: cfg.MeasureCold,
+		tagQueries:  cfg.TagQueries,
+		label:       cfg.Label,
+		order:       cfg.ExecutionOrder,
+		rng:         rand.New(rand.NewPCG(cfg.Seed, 0)),
+		connMode:    EffectiveConnectionMode(cfg),
+		sampleRows:  cfg.CaptureSampleRows,
+		sampleCap:   cfg.SampleCellMaxBytes,
+		sampleMask:  sampleMask,
+		columnTypes: cfg.CaptureColumnTypes,
+		complexity:  NewComplexityClassifier(cfg.ComplexityRules),
+		maxRows:     cfg.MaxRows,
+		txMode:      cfg.TransactionMode,
+		rollback:    cfg.RollbackWrites,
+		heatmapCfg:  cfg.Heatmap,
+		minSamples:  cfg.PercentileMinSamples,
+		percentiles: utils.PercentileMethod(cfg.PercentileMethod),
+	}
+}
+
+// ExecuteQuery runs query once, bounded by ctx and the configured timeout.
+// When tagging is enabled and ctx carries an ExecutionTag, the statement is
+// prefixed with a correlation comment. The connection is taken from the pool
+// before the statement starts, so time spent waiting for it is recorded as
+// AcquireDuration rather than counted as query time.
+func (qe *QueryExecutor) ExecuteQuery(ctx context.Context, query string) model.QueryExecution {
+	if qe.freshConn {
+		return qe.executeOnFreshConnection(ctx, query)
+	}
+
+	execution := model.QueryExecution{
+		StartTime: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	conn, err := qe.db.Conn(ctx)
+	execution.AcquireDuration = time.Since(execution.StartTime)
+	if err != nil {
+		execution.Error = fmt.Errorf("error acquiring connection: %w", err)
+		execution.ErrorMessage = execution.Error.Error()
+		return execution
+	}
+	defer conn.Close()
+
+	qe.runStatement(ctx, conn, query, &execution)
+	qe.recordTimeout(ctx, &execution)
+	return execution
+}
+
+// recordTimeout marks an execution that failed because its timeout expired.
+// Its duration becomes the timeout: the query would have taken at least that
+// long, and the moment the error surfaced would understate it.
+func (qe *QueryExecutor) recordTimeout(ctx context.Context, execution *model.QueryExecution) {
+	if execution.Error != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		execution.TimedOut = true
+		execution.Duration = qe.timeout
+	}
+}
+
+// ErrRowCapExceeded is recorded on executions that were cancelled because the
+// query returned more rows than the configured MaxRows.
+var ErrRowCapExceeded = errors.New("row cap exceeded")
+
+// statement returns the SQL actually sent to the server for query.
+func (qe *QueryExecutor) statement(ctx context.Context, query string) string {
+	if !qe.tagQueries {
+		return query
+	}
+	if tag, ok := executionTagFrom(ctx); ok {
+		return tag.Comment() + " " + query
+	}
+	return query
+}
+
+// rollbackKey marks the context of an execution to run in a transaction
+// that is rolled back.
+type rollbackKey struct{}
+
+// rollsBack reports whether executions of sql run in a transaction that is
+// rolled back: always in the rollback transaction mode, and for INSERT,
+// UPDATE and DELETE with rollbackWrites. Other writes, such as DDL, commit
+// implicitly and can't be rolled back.
+func (qe *QueryExecutor) rollsBack(sql string) bool {
+	if qe.txMode == TxModeRollback {
+		return true
+	}
+	if !qe.rollback {
+		return false
+	}
+	switch ClassifyStatement(sql) {
+	case StatementInsert, StatementUpdate, StatementDelete:
+		return true
+	}
+	return false
+}
+
+// IrreversibleWrites returns the names of the queries that write but that
+// rollbackWrites can't roll back, such as DDL or CALL.
+func IrreversibleWrites(queries []model.Query) []string {
+	var names []string
+	for _, q := range queries {
+		switch ClassifyStatement(q.SQL) {
+		case StatementInsert, StatementUpdate, StatementDelete:
+			continue
+		}
+		if EstimateStatementType(q.SQL) == "write" {
+			names = append(names, q.Name)
+		}
+	}
+	return names
+}
+
+// sampleRowsKey carries a query's own captureSampleRows in the context of
+// its executions.
+type sampleRowsKey struct{}
+
+// sampleLimit returns how many result rows to capture for the execution in
+// ctx: its query's own captureSampleRows if it sets one, or the config's.
+// Only the first iteration of each query is sampled.
+func (qe *QueryExecutor) sampleLimit(ctx context.Context) int {
+	if !firstIteration(ctx) {
+		return 0
 	}
+	if n, ok := ctx.Value(sampleRowsKey{}).(int); ok {
+		return n
+	}
+	return qe.sampleRows
+}
+
+// captureColumnTypes reports whether to record the result columns of the
+// execution in ctx. Like sample rows, only the first iteration is captured.
+func (qe *QueryExecutor) captureColumnTypes(ctx context.Context) bool {
+	return qe.columnTypes && firstIteration(ctx)
 }
 
-func (qe *QueryExecutor) ExecuteQuery(query string) model.QueryExecution {
+func firstIteration(ctx context.Context) bool {
+	tag, ok := executionTagFrom(ctx)
+	return ok && tag.Iteration == 1
+}
+
+// executeOnFreshConnection opens a brand-new connection, runs the query on it
+// and closes it again, recording the connect and close costs separately from
+// the query duration.
+func (qe *QueryExecutor) executeOnFreshConnection(ctx context.Context, query string) model.QueryExecution {
 	execution := model.QueryExecution{
 		StartTime: time.Now(),
-		SQL:       query,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), qe.timeout)
+	ctx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	db, err := database.OpenSingle(ctx, qe.dsn)
+	execution.ConnectDuration = time.Since(execution.StartTime)
+	if err != nil {
+		execution.Error = err
+		execution.ErrorMessage = err.Error()
+		return execution
+	}
+
+	qe.runStatement(ctx, db, query, &execution)
+	qe.recordTimeout(ctx, &execution)
+
+	closeStart := time.Now()
+	db.Close()
+	execution.CloseDuration = time.Since(closeStart)
+
+	return execution
+}
+
+// runStatement runs query on db, inside a transaction when a transaction mode
+// is configured. The BEGIN and COMMIT/ROLLBACK time is recorded as TxOverhead,
+// separate from the statement's duration.
+func (qe *QueryExecutor) runStatement(ctx context.Context, db session, query string, execution *model.QueryExecution) {
+	statement := qe.statement(ctx, query)
+	capture := captureOptions{
+		sampleRows:  qe.sampleLimit(ctx),
+		sampleCap:   qe.sampleCap,
+		sampleMask:  qe.sampleMask,
+		columnTypes: qe.captureColumnTypes(ctx),
+	}
+
+	txMode := qe.txMode
+	if ctx.Value(rollbackKey{}) != nil {
+		txMode = TxModeRollback
+	}
+	if txMode == "" || txMode == TxModeNone {
+		runQuery(ctx, db, statement, capture, qe.maxRows, execution)
+		return
+	}
+
+	begin := time.Now()
+	tx, err := db.BeginTx(ctx, nil)
+	execution.TxOverhead = time.Since(begin)
+	if err != nil {
+		execution.Error = err
+		execution.ErrorMessage = err.Error()
+		return
+	}
+
+	runQuery(ctx, tx, statement, capture, qe.maxRows, execution)
+
+	end := time.Now()
+	if txMode == TxModeCommit && execution.Error == nil {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	execution.TxOverhead += time.Since(end)
+
+	if err != nil && execution.Error == nil {
+		execution.Error = err
+		execution.ErrorMessage = err.Error()
+	}
+}
+
+// executeDedicated runs query on a worker's pinned connection. If the query
+// fails and the connection no longer answers a ping, it is replaced so the
+// next execution starts on a healthy connection.
+func (qe *QueryExecutor) executeDedicated(ctx context.Context, conn *dedicatedConn, query string) model.QueryExecution {
+	execution := model.QueryExecution{
+		StartTime:    time.Now(),
+		ConnectionID: conn.id,
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	qe.runStatement(queryCtx, conn.conn, query, &execution)
+	qe.recordTimeout(queryCtx, &execution)
+
+	if execution.Error != nil && ctx.Err() == nil {
+		qe.ensureConnAlive(ctx, conn)
+	}
+	return execution
+}
+
+func (qe *QueryExecutor) ensureConnAlive(ctx context.Context, conn *dedicatedConn) {
+	pingCtx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	if err := conn.conn.PingContext(pingCtx); err == nil {
+		return
+	}
+
+	conn.conn.Close()
+	fresh, err := qe.openDedicated(ctx)
+	if err != nil {
+		log.Printf("Warning: couldn't replace dead connection: %v", err)
+		return
+	}
+
+	*conn = fresh
+	qe.reconnects.Add(1)
+	if qe.verbose {
+		log.Printf("Replaced dead dedicated connection")
+	}
+}
+
+// dedicatedConn is a connection pinned to one worker, with its server-side
+// CONNECTION_ID() so executions can be traced to it.
+type dedicatedConn struct {
+	conn *sql.Conn
+	id   int64 // 0 if the server didn't say
+}
+
+// openDedicated takes a connection out of the pool for one worker.
+func (qe *QueryExecutor) openDedicated(ctx context.Context) (dedicatedConn, error) {
+	conn, err := qe.db.Conn(ctx)
+	if err != nil {
+		return dedicatedConn{}, err
+	}
+	dc := dedicatedConn{conn: conn}
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&dc.id); err != nil {
+		log.Printf("Warning: couldn't read the connection id of a dedicated connection: %v", err)
+	}
+	return dc, nil
+}
+
+// Reconnects returns how many dedicated connections were replaced.
+func (qe *QueryExecutor) Reconnects() int {
+	return int(qe.reconnects.Load())
+}
+
+// executionWindow returns the wall-clock span from the first execution start
+// to the last execution end.
+func executionWindow(executions []model.QueryExecution) (start, end time.Time) {
+	for _, exec := range executions {
+		finish := exec.StartTime.Add(exec.AcquireDuration + exec.ConnectDuration + exec.TxOverhead + exec.Duration + exec.CloseDuration)
+		if start.IsZero() || exec.StartTime.Before(start) {
+			start = exec.StartTime
+		}
+		if finish.After(end) {
+			end = finish
+		}
+	}
+	return start, end
+}
+
+// computeThroughput sets the achieved queries per second: successful
+// executions divided by the measured execution window.
+func computeThroughput(result *model.QueryResult) {
+	start, end := executionWindow(result.Executions)
+	if window := end.Sub(start); window > 0 {
+		result.AchievedQPS = float64(result.SuccessfulExecutions) / window.Seconds()
+	}
+}
+
+// captureOptions is what runQuery records about a result besides its row
+// count.
+type captureOptions struct {
+	sampleRows  int                 // Leading rows to store on the execution
+	sampleCap   int                 // Longest sample value kept, in bytes; 0 keeps them whole
+	sampleMask  []func(string) bool // Columns whose sample values are masked
+	columnTypes bool                // Store the result's column names and types
+}
+
+// runQuery executes query and counts the rows it returns across all its
+// result sets, as a CALL or a multi-statement query can return several. The
+// first sampleRows rows and the column types of the first result set are also
+// captured on the execution if requested; this happens after the duration has
+// been measured, so it doesn't affect timing. With maxRows > 0, a query that returns more rows is
+// cancelled and recorded as ErrRowCapExceeded.
+func runQuery(ctx context.Context, db queryer, query string, capture captureOptions, maxRows int64, execution *model.QueryExecution) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	start := time.Now()
-	rows, err := qe.db.QueryContext(ctx, query)
+	rows, err := db.QueryContext(ctx, query)
 	execution.Duration = time.Since(start)
 
 	if err != nil {
 		execution.Error = err
 		execution.ErrorMessage = err.Error()
-		return execution
+		return
 	}
 	defer rows.Close()
 
+	if capture.columnTypes {
+		execution.ColumnTypes = scanColumnTypes(rows)
+	}
+
+	sampleRows := capture.sampleRows
+	var columns []string
+	if sampleRows > 0 {
+		if columns, err = rows.Columns(); err != nil {
+			sampleRows = 0
+		}
+	}
+
 	var rowCount int64
-	for rows.Next() {
-		rowCount++
+	for resultSets := 1; ; resultSets++ {
+		for rows.Next() {
+			if maxRows > 0 && rowCount >= maxRows {
+				cancel()
+				rows.Close()
+				execution.RowCount = rowCount
+				execution.RowCapExceeded = true
+				execution.Error = fmt.Errorf("%w: cancelled after %d rows", ErrRowCapExceeded, rowCount)
+				execution.ErrorMessage = execution.Error.Error()
+				return
+			}
+			if rowCount < int64(sampleRows) {
+				if sample, err := scanSampleRow(rows, columns, capture); err == nil {
+					execution.SampleRows = append(execution.SampleRows, sample)
+				}
+			}
+			rowCount++
+		}
+		// The wait for each further result set is the server running the
+		// statements behind it, so it counts towards the duration the way
+		// the wait for the first one does.
+		waited := time.Now()
+		more := rows.NextResultSet()
+		execution.Duration += time.Since(waited)
+		if !more {
+			if resultSets > 1 {
+				execution.ResultSets = resultSets
+			}
+			break
+		}
+		// Later result sets have columns of their own.
+		sampleRows = 0
 	}
 	execution.RowCount = rowCount
 
@@ -67,115 +489,667 @@ func (qe *QueryExecutor) ExecuteQuery(query string) model.QueryExecution {
 		execution.Error = err
 		execution.ErrorMessage = err.Error()
 	}
+}
+
+// scanColumnTypes describes the columns of rows, or returns nil if the
+// driver can't.
+func scanColumnTypes(rows *sql.Rows) []model.ColumnType {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil
+	}
 
-	return execution
+	columns := make([]model.ColumnType, len(types))
+	for i, t := range types {
+		columns[i] = model.ColumnType{Name: t.Name(), DatabaseType: t.DatabaseTypeName()}
+		if precision, scale, ok := t.DecimalSize(); ok {
+			columns[i].Precision, columns[i].Scale = precision, scale
+		}
+		if nullable, ok := t.Nullable(); ok {
+			columns[i].Nullable = &nullable
+		}
+	}
+	return columns
+}
+
+// scanSampleRow reads the current row as strings, masking and cutting short
+// values as capture says.
+func scanSampleRow(rows *sql.Rows, columns []string, capture captureOptions) (model.SampleRow, error) {
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	row := make(model.SampleRow, len(columns))
+	for i, col := range columns {
+		var value string
+		switch v := values[i].(type) {
+		case nil:
+			value = "NULL"
+		case []byte:
+			value = string(v)
+		default:
+			value = fmt.Sprintf("%v", v)
+		}
+
+		switch {
+		case value != "NULL" && maskedColumn(capture.sampleMask, col):
+			value = maskedValue
+		case capture.sampleCap > 0 && len(value) > capture.sampleCap:
+			value = truncateSample(value, capture.sampleCap)
+		}
+		row[col] = value
+	}
+	return row, nil
+}
+
+// maskedValue replaces the sample values of masked columns.
+const maskedValue = "****"
+
+// maskedColumn reports whether column matches one of the mask patterns, as
+// named in the result or in lower case.
+func maskedColumn(mask []func(string) bool, column string) bool {
+	return matchesAny(mask, column) || matchesAny(mask, strings.ToLower(column))
+}
+
+// truncateSample cuts value to at most limit bytes, without splitting a
+// character, and notes its full length.
+func truncateSample(value string, limit int) string {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… (%d bytes)", value[:cut], len(value))
+}
+
+// summarizeTxOverhead averages the transaction BEGIN and COMMIT/ROLLBACK
+// cost of successful executions onto the result.
+func summarizeTxOverhead(result *model.QueryResult) {
+	var total time.Duration
+	var count int
+	for _, exec := range result.Executions {
+		if exec.Error == nil && exec.TxOverhead > 0 {
+			total += exec.TxOverhead
+			count++
+		}
+	}
+	if count > 0 {
+		result.AvgTxOverhead = total / time.Duration(count)
+	}
 }
 
+// summarizeAcquire records how long successful executions waited for a
+// pooled connection.
+func summarizeAcquire(result *model.QueryResult, method utils.PercentileMethod) {
+	var waits []time.Duration
+	for _, exec := range result.Executions {
+		if exec.Error == nil && exec.AcquireDuration > 0 {
+			waits = append(waits, exec.AcquireDuration)
+		}
+	}
+	if len(waits) == 0 {
+		return
+	}
+
+	stats := utils.CalculateStatsWith(waits, method)
+	result.AvgAcquireDuration = stats.Mean
+	result.P95AcquireDuration = stats.P95
+	result.MaxAcquireDuration = stats.Max
+}
+
+// summarizeConnectionCost averages the connect and close costs recorded by
+// fresh-connection executions onto the result.
+func summarizeConnectionCost(result *model.QueryResult) {
+	var connect, closeCost, overall time.Duration
+	var count int
+
+	for _, exec := range result.Executions {
+		if exec.Error != nil {
+			continue
+		}
+		connect += exec.ConnectDuration
+		closeCost += exec.CloseDuration
+		overall += exec.ConnectDuration + exec.Duration + exec.CloseDuration
+		count++
+	}
+
+	if count == 0 {
+		return
+	}
+
+	result.AvgConnectDuration = connect / time.Duration(count)
+	result.AvgCloseDuration = closeCost / time.Duration(count)
+	result.AvgFreshConnOverall = overall / time.Duration(count)
+}
+
+// ExecuteBatch runs every query for the given number of iterations and
+// returns one result per query, in the order of queries.
 func (qe *QueryExecutor) ExecuteBatch(queries []model.Query, iterations int) []model.QueryResult {
+	results, err := qe.ExecuteBatchContext(context.Background(), queries, iterations)
+	if err != nil {
+		log.Printf("Batch stopped early: %v", err)
+	}
+	return results
+}
+
+// ExecuteBatchContext feeds (query, iteration) tasks in the configured order
+// to a fixed pool of Concurrency workers, so every query makes progress
+// throughout the run instead of the first few hogging the pool. On
+// cancellation no further tasks are dispatched and the partial results are
+// returned with the context error.
+func (qe *QueryExecutor) ExecuteBatchContext(ctx context.Context, queries []model.Query, iterations int) ([]model.QueryResult, error) {
+	tasks, err := scheduleTasks(len(queries), iterations, qe.order, qe.rng)
+	if err != nil {
+		return nil, err
+	}
+
 	results := make([]model.QueryResult, len(queries))
-	var wg sync.WaitGroup
+	shares := NormalizeWeights(queries)
 
 	for i, query := range queries {
-		results[i] = model.QueryResult{
-			Name:            query.Name,
-			Description:     query.Description,
-			SQL:             query.SQL,
-			MinDuration:     time.Hour,
-			Weight:          query.Weight,
-			QueryComplexity: AnalyzeQueryComplexity(query.SQL),
-			Executions:      make([]model.QueryExecution, 0, iterations),
-		}
+		results[i] = qe.newQueryResult(query, shares[i], iterations)
 	}
 
-	for i, query := range queries {
-		wg.Add(1)
-		go func(idx int, q model.Query) {
-			defer wg.Done()
-			result := &results[idx]
+	// A fixed pool of concurrency workers pulls tasks from queue, rather than
+	// a goroutine per execution waiting on a semaphore, so the goroutine
+	// count stays at the concurrency level however long the run.
+	//
+	// Each worker keeps its executions and overhead to itself; a query's are
+	// merged by whichever worker finishes its last iteration, so the hot path
+	// takes no locks. The atomic countdown orders every worker's writes for
+	// that query before the merge.
+	workers := make([]workerState, max(qe.concurrency, 1))
+	remaining := make([]atomic.Int64, len(queries))
+	for i := range remaining {
+		remaining[i].Store(int64(iterations))
+	}
+	merged := make([]bool, len(queries))
+	// Queries with checkPlanStability are fingerprinted by the worker that
+	// starts them, before their first execution, and by the one that merges
+	// them, after their last.
+	firstPlans := make([]string, len(queries))
+	planStarted := make([]atomic.Bool, len(queries))
+	var complexities []string
+	if qe.tracer != nil || qe.onExecution != nil {
+		complexities = make([]string, len(queries))
+		for i := range results {
+			complexities[i] = results[i].QueryComplexity
+		}
+	}
+	rollback := make([]bool, len(queries))
+	for i := range results {
+		rollback[i] = results[i].RolledBack
+	}
+	start := time.Now()
+	heatmapFrom := start
+	if !qe.heatmapFrom.IsZero() {
+		heatmapFrom = qe.heatmapFrom
+	}
+	queue := make(chan task)
+	var wg sync.WaitGroup
 
-			if qe.verbose {
-				log.Printf("Testing query: %s", q.Name)
+	var conns []dedicatedConn
+	if qe.connMode == ConnModeDedicated {
+		conns = make([]dedicatedConn, len(workers))
+		for w := range conns {
+			if conns[w], err = qe.openDedicated(ctx); err != nil {
+				for _, c := range conns[:w] {
+					c.conn.Close()
+				}
+				return nil, fmt.Errorf("error opening dedicated connection %d of %d: %w", w+1, len(conns), err)
 			}
+		}
+		defer func() {
+			for _, c := range conns {
+				c.conn.Close()
+			}
+		}()
+	}
 
-			for iter := range iterations {
-				qe.semaphore <- struct{}{}
+	for w := range workers {
+		state := &workers[w]
+		state.executions = make([][]model.QueryExecution, len(queries))
+		state.overhead = make([]time.Duration, len(queries))
+		state.heatmap = newHeatmapCounter(qe.heatmapCfg, heatmapFrom)
+		if conns != nil {
+			state.conn = &conns[w]
+		}
 
-				execution := qe.ExecuteQuery(q.SQL)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := time.Now()
 
-				<-qe.semaphore
+			for t := range queue {
+				q := queries[t.query]
 
-				if len(result.Executions) == 0 {
-					result.FirstExecutedAt = execution.StartTime
+				// Time spent idle while dispatch was paused isn't overhead.
+				if t.released.After(last) {
+					last = t.released
 				}
 
-				result.LastExecutedAt = execution.StartTime
-
-				qe.mutex.Lock()
-
-				result.Executions = append(result.Executions, execution)
+				if q.CheckPlanStability && planStarted[t.query].CompareAndSwap(false, true) {
+					firstPlans[t.query] = qe.planFingerprint(ctx, q)
+					last = time.Now()
+				}
 
-				if execution.Error != nil {
-					result.Errors++
-					if len(result.ErrorDetails) < 10 {
-						result.ErrorDetails = append(result.ErrorDetails, execution.ErrorMessage)
-					}
+				execCtx := WithExecutionTag(ctx, ExecutionTag{Run: qe.label, Query: q.Name, Iteration: t.iteration + 1})
+				if q.CaptureSampleRows > 0 {
+					execCtx = context.WithValue(execCtx, sampleRowsKey{}, q.CaptureSampleRows)
+				}
+				if rollback[t.query] {
+					execCtx = context.WithValue(execCtx, rollbackKey{}, true)
+				}
+				var execution model.QueryExecution
+				if state.conn != nil {
+					execution = qe.executeDedicated(execCtx, state.conn, q.SQL)
 				} else {
-					result.SuccessfulExecutions++
-					result.TotalDuration += execution.Duration
-					result.RowsAffected += execution.RowCount
-
-					if execution.Duration < result.MinDuration {
-						result.MinDuration = execution.Duration
-					}
-					if execution.Duration > result.MaxDuration {
-						result.MaxDuration = execution.Duration
-					}
+					execution = qe.ExecuteQuery(execCtx, q.SQL)
+				}
+				execution.Worker = w + 1
+				state.executions[t.query] = append(state.executions[t.query], execution)
+				state.heatmap.add(execution)
+				if qe.tracer != nil {
+					qe.traceExecution(ctx, q, complexities[t.query], t.iteration, execution)
+				}
+				if qe.onExecution != nil {
+					qe.onExecution(q.Name, complexities[t.query], execution)
 				}
 
-				qe.mutex.Unlock()
+				qe.completed.Add(1)
 
-				if qe.verbose && (iter == 0 || (iter+1)%10 == 0) {
+				if qe.verbose && (t.iteration == 0 || (t.iteration+1)%10 == 0) {
 					if execution.Error != nil {
 						log.Printf("Query %s iteration %d: ERROR - %s",
-							q.Name, iter+1, execution.ErrorMessage)
+							q.Name, t.iteration+1, execution.ErrorMessage)
 					} else {
 						log.Printf("Query %s iteration %d: %v, %d rows",
-							q.Name, iter+1, execution.Duration, execution.RowCount)
+							q.Name, t.iteration+1, execution.Duration, execution.RowCount)
 					}
 				}
-			}
 
-			if result.SuccessfulExecutions > 0 {
-				result.AvgDuration = result.TotalDuration / time.Duration(result.SuccessfulExecutions)
+				now := time.Now()
+				measured := execution.AcquireDuration + execution.ConnectDuration + execution.TxOverhead + execution.Duration + execution.CloseDuration
+				state.overhead[t.query] += now.Sub(last) - measured
+				last = now
 
-				durations := make([]time.Duration, 0, result.SuccessfulExecutions)
-				for _, exec := range result.Executions {
-					if exec.Error == nil {
-						durations = append(durations, exec.Duration)
+				if remaining[t.query].Add(-1) == 0 {
+					mergeWorkers(&results[t.query], t.query, workers, qe.finalizeOptions())
+					if q.CheckPlanStability {
+						recordPlanFingerprints(&results[t.query], firstPlans[t.query], qe.planFingerprint(ctx, q))
 					}
+					merged[t.query] = true
+					if qe.onQueryDone != nil {
+						qe.onQueryDone(results[t.query])
+					}
+					last = time.Now()
 				}
+			}
+		}()
+	}
 
-				if len(durations) > 0 {
-					stats := utils.CalculateStats(durations)
-					result.Percentile95 = stats.P95
-					result.Percentile99 = stats.P99
-					result.StdDevDuration = stats.StdDev
-					result.MedianDuration = stats.Median
-				}
+	var released time.Time
+dispatch:
+	for _, t := range tasks {
+		if qe.guard != nil {
+			resumed, err := qe.guard.wait(ctx)
+			if err != nil {
+				break dispatch
+			}
+			if !resumed.IsZero() {
+				released = resumed
 			}
+			t.released = released
+		}
+		select {
+		case queue <- t:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	qe.heatmap = newHeatmapCounter(qe.heatmapCfg, heatmapFrom)
+	for _, state := range workers {
+		qe.heatmap.merge(state.heatmap)
+	}
+
+	// Queries cut short by cancellation were never merged by a worker.
+	for i := range results {
+		if !merged[i] {
+			mergeWorkers(&results[i], i, workers, qe.finalizeOptions())
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return results, fmt.Errorf("run aborted: %w", err)
+	}
+	return results, nil
+}
+
+// planFingerprint fingerprints q's plan, or returns "" with a warning when it
+// can't.
+func (qe *QueryExecutor) planFingerprint(ctx context.Context, q model.Query) string {
+	fingerprint, err := PlanFingerprint(ctx, qe.db, q.SQL, q.ExplainParams...)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: couldn't fingerprint the plan of %s: %v", q.Name, err)
+		}
+		return ""
+	}
+	return fingerprint
+}
+
+// recordPlanFingerprints records the plan fingerprints taken before the first
+// and after the last execution, and whether the plan flipped in between.
+// Nothing is recorded unless both were taken.
+func recordPlanFingerprints(result *model.QueryResult, first, last string) {
+	if first == "" || last == "" {
+		return
+	}
+	result.PlanFingerprints = []string{first, last}
+	result.PlanFlipped = first != last
+}
+
+// newQueryResult returns the empty result of query, ready for executions.
+func (qe *QueryExecutor) newQueryResult(query model.Query, share float64, iterations int) model.QueryResult {
+	score, components := qe.complexity.Score(query.SQL)
+	return model.QueryResult{
+		Name:                 query.Name,
+		Description:          query.Description,
+		SQL:                  query.SQL,
+		MinDuration:          time.Hour,
+		Weight:               query.Weight,
+		WeightShare:          share,
+		Schema:               query.Schema,
+		Template:             query.Template,
+		MinSuccessRate:       query.MinSuccessRate,
+		SLO:                  sloObjective(query.SLOMs, query.SLOPercentile),
+		QueryComplexity:      qe.complexity.Classify(query.SQL),
+		ComplexityScore:      score,
+		StatementType:        ClassifyStatement(query.SQL),
+		LintWarnings:         query.LintWarnings,
+		ComplexityComponents: components,
+		RolledBack:           qe.rollsBack(query.SQL),
+		Executions:           make([]model.QueryExecution, 0, iterations),
+	}
+}
+
+// mergeWorkers folds the executions and overhead every worker gathered for
+// query i into result and computes its statistics.
+func mergeWorkers(result *model.QueryResult, i int, workers []workerState, opts finalizeOptions) {
+	var executions []model.QueryExecution
+	var overhead time.Duration
+	for _, state := range workers {
+		executions = append(executions, state.executions[i]...)
+		overhead += state.overhead[i]
+	}
+	mergeExecutions(result, executions, overhead, opts)
+}
 
-			if qe.verbose {
-				avgMs := float64(result.AvgDuration.Microseconds()) / 1000
-				p95Ms := float64(result.Percentile95.Microseconds()) / 1000
+// mergeExecutions records executions, gathered from any number of workers, on
+// result in start order and computes its statistics. overhead is the total
+// harness overhead behind them.
+func mergeExecutions(result *model.QueryResult, executions []model.QueryExecution, overhead time.Duration, opts finalizeOptions) {
+	sort.SliceStable(executions, func(a, b int) bool {
+		return executions[a].StartTime.Before(executions[b].StartTime)
+	})
+	if len(executions) > 0 {
+		result.AvgHarnessOverhead = overhead / time.Duration(len(executions))
+	}
 
-				log.Printf("Results for %s: %.2f ms avg, %.2f ms p95, %d rows, %s complexity",
-					q.Name, avgMs, p95Ms, result.RowsAffected, result.QueryComplexity)
+	// Samples are kept once, on the result, rather than on the execution
+	// that captured them.
+	for i := range executions {
+		if executions[i].SampleRows != nil {
+			if result.SampleRows == nil {
+				result.SampleRows = executions[i].SampleRows
 			}
-		}(i, query)
+			executions[i].SampleRows = nil
+		}
 	}
 
-	wg.Wait()
-	return results
+	// The first execution finds plan, buffer pool and connection caches cold;
+	// when measured separately it is kept out of the warm statistics. A
+	// failed first execution says nothing about cold latency and is recorded
+	// as usual.
+	if opts.measureCold && len(executions) > 0 && executions[0].Error == nil {
+		cold := executions[0]
+		result.ColdExecution = &cold
+		result.ColdDuration = cold.Duration
+		executions = executions[1:]
+	}
+
+	for _, execution := range executions {
+		recordExecution(result, execution)
+	}
+
+	finalizeResult(result, opts)
+}
+
+// checkSLA records a violation for each SLA threshold the result misses.
+// Queries that never ran are not judged.
+func checkSLA(result *model.QueryResult) {
+	if result.SuccessfulExecutions+result.Errors == 0 {
+		return
+	}
+
+	if result.MinSuccessRate > 0 && result.SuccessRate < result.MinSuccessRate {
+		result.SLAViolations = append(result.SLAViolations,
+			fmt.Sprintf("success rate %.2f%% below minimum %.2f%%", result.SuccessRate*100, result.MinSuccessRate*100))
+	}
+}
+
+// defaultSLOPercentile applies to a query that declares sloMs alone.
+const defaultSLOPercentile = 99
+
+// sloObjective returns the SLO of a query with the given sloMs and
+// sloPercentile, ready for checkSLO, or nil if it declares none.
+func sloObjective(thresholdMs, percentile float64) *model.SLOResult {
+	if thresholdMs <= 0 {
+		return nil
+	}
+	if percentile == 0 {
+		percentile = defaultSLOPercentile
+	}
+	return &model.SLOResult{ThresholdMs: thresholdMs, Percentile: percentile}
+}
+
+// checkSLO measures the result's executions against its latency SLO, if it
+// has one. Failed executions are misses.
+func checkSLO(result *model.QueryResult) {
+	slo := result.SLO
+	if slo == nil {
+		return
+	}
+
+	threshold := time.Duration(slo.ThresholdMs * float64(time.Millisecond))
+	slo.Executions, slo.Within = len(result.Executions), 0
+	for _, execution := range result.Executions {
+		if execution.Error == nil && execution.Duration <= threshold {
+			slo.Within++
+		}
+	}
+	judgeSLO(slo)
+}
+
+// judgeSLO computes compliance and the verdict from the SLO's counts.
+func judgeSLO(slo *model.SLOResult) {
+	if slo.Executions == 0 {
+		return
+	}
+
+	slo.Compliance = float64(slo.Within) / float64(slo.Executions)
+	slo.ErrorBudgetBurn = (1 - slo.Compliance) / (1 - slo.Percentile/100)
+	slo.Met = float64(slo.Within)*100 >= slo.Percentile*float64(slo.Executions)
+}
+
+// workerState is what one worker of ExecuteBatchContext gathers, indexed by
+// query.
+type workerState struct {
+	executions [][]model.QueryExecution
+	overhead   []time.Duration // Wall time not spent inside the measured query
+	heatmap    *heatmapCounter
+	conn       *dedicatedConn // Pinned connection in dedicated mode
+}
+
+// recordExecution folds one execution into result. Executions may arrive out
+// of start order, so the first and last timestamps are tracked as min/max.
+func recordExecution(result *model.QueryResult, execution model.QueryExecution) {
+	if result.FirstExecutedAt.IsZero() || execution.StartTime.Before(result.FirstExecutedAt) {
+		result.FirstExecutedAt = execution.StartTime
+	}
+	if execution.StartTime.After(result.LastExecutedAt) {
+		result.LastExecutedAt = execution.StartTime
+	}
+
+	result.Executions = append(result.Executions, execution)
+	if result.ColumnTypes == nil {
+		result.ColumnTypes = execution.ColumnTypes
+	}
+
+	if execution.Error != nil {
+		result.Errors++
+		if len(result.ErrorDetails) < 10 {
+			result.ErrorDetails = append(result.ErrorDetails, execution.ErrorMessage)
+		}
+		if result.ErrorsByType == nil {
+			result.ErrorsByType = make(map[string]int)
+		}
+		result.ErrorsByType[classifyErrorMessage(execution.ErrorMessage)]++
+		return
+	}
+
+	result.SuccessfulExecutions++
+	result.TotalDuration += execution.Duration
+	result.RowsAffected += execution.RowCount
+
+	if execution.Duration < result.MinDuration {
+		result.MinDuration = execution.Duration
+	}
+	if execution.Duration > result.MaxDuration {
+		result.MaxDuration = execution.Duration
+	}
+}
+
+// finalizeOptions are the run settings finalizeResult depends on.
+type finalizeOptions struct {
+	freshConn   bool
+	measureCold bool
+	minSamples  config.PercentileMinSamples
+	percentiles utils.PercentileMethod
+}
+
+// finalizeOptions returns the executor's settings for finalizeResult.
+func (qe *QueryExecutor) finalizeOptions() finalizeOptions {
+	return finalizeOptions{freshConn: qe.freshConn, measureCold: qe.measureCold, minSamples: qe.minSamples, percentiles: qe.percentiles}
+}
+
+// finalizeResult computes the derived statistics once all executions of a
+// query have been recorded.
+func finalizeResult(result *model.QueryResult, opts finalizeOptions) {
+	if opts.freshConn {
+		summarizeConnectionCost(result)
+	}
+	computeThroughput(result)
+	summarizeTxOverhead(result)
+	summarizeAcquire(result, opts.percentiles)
+
+	if total := result.SuccessfulExecutions + result.Errors; total > 0 {
+		result.SuccessRate = float64(result.SuccessfulExecutions) / float64(total)
+	}
+	checkSLA(result)
+	checkSLO(result)
+	summarizeTimeouts(result, opts.percentiles)
+
+	if result.SuccessfulExecutions == 0 {
+		return
+	}
+
+	result.ZeroRows = result.RowsAffected == 0 && EstimateStatementType(result.SQL) == "read"
+	checkRowCountStability(result)
+
+	result.AvgDuration = result.TotalDuration / time.Duration(result.SuccessfulExecutions)
+
+	durations := make([]time.Duration, 0, result.SuccessfulExecutions)
+	for _, exec := range result.Executions {
+		if exec.Error == nil {
+			durations = append(durations, exec.Duration)
+		}
+	}
+
+	recordLatencyStats(result, utils.CalculateStatsInPlace(durations, opts.percentiles), opts)
+}
+
+// recordLatencyStats sets the result's latency distribution from stats of
+// its successful executions.
+func recordLatencyStats(result *model.QueryResult, stats utils.Stats, opts finalizeOptions) {
+	result.Percentile95 = stats.P95
+	result.Percentile99 = stats.P99
+	result.StdDevDuration = stats.StdDev
+	result.MedianDuration = stats.Median
+	result.P95InsufficientSamples = stats.Samples < opts.minSamples.P95
+	result.P99InsufficientSamples = stats.Samples < opts.minSamples.P99
+	if result.ColdDuration > 0 && result.MedianDuration > 0 {
+		result.ColdWarmRatio = float64(result.ColdDuration) / float64(result.MedianDuration)
+	}
+}
+
+// timeoutCensorRate is the timeout rate above which a query's latency
+// statistics are flagged: with more than 5% of executions timing out, the
+// true p95 lies at or beyond the timeout, and the p95 of the surviving
+// executions describes only the fast ones.
+const timeoutCensorRate = 0.05
+
+// summarizeTimeouts counts timed-out executions and computes percentiles that
+// include them at the timeout, alongside the regular ones computed from
+// successful executions only.
+func summarizeTimeouts(result *model.QueryResult, method utils.PercentileMethod) {
+	var durations []time.Duration
+	for _, exec := range result.Executions {
+		if exec.TimedOut {
+			result.Timeouts++
+		}
+		if exec.Error == nil || exec.TimedOut {
+			durations = append(durations, exec.Duration)
+		}
+	}
+	if result.Timeouts == 0 {
+		return
+	}
+
+	result.TimeoutRate = float64(result.Timeouts) / float64(len(result.Executions))
+	result.TimeoutCensored = result.TimeoutRate > timeoutCensorRate
+
+	stats := utils.CalculateStatsWith(durations, method)
+	result.CensoredPercentile95 = stats.P95
+	result.CensoredPercentile99 = stats.P99
+}
+
+// checkRowCountStability records the range of row counts across successful
+// executions and flags the result when they differ, which points at
+// concurrent writes or LIMIT over a non-deterministic order.
+func checkRowCountStability(result *model.QueryResult) {
+	first := true
+	for _, exec := range result.Executions {
+		if exec.Error != nil {
+			continue
+		}
+		if first || exec.RowCount < result.MinRowCount {
+			result.MinRowCount = exec.RowCount
+		}
+		if first || exec.RowCount > result.MaxRowCount {
+			result.MaxRowCount = exec.RowCount
+		}
+		first = false
+	}
+	result.NonDeterministicRowCount = result.MinRowCount != result.MaxRowCount
 }
 
 func CreateTestQueries(allQueries []model.Query, testType string, limit int) ([]model.Query, error) {
@@ -204,11 +1178,153 @@ func CreateTestQueries(allQueries []model.Query, testType string, limit int) ([]
 		}
 		return sortedQueries, nil
 
+	case "reads":
+		return filterQueriesByStatement(allQueries, false, limit)
+
+	case "writes":
+		return filterQueriesByStatement(allQueries, true, limit)
+
+	case "coverage":
+		// limit is the percentage of total weight to cover
+		return SelectByWeightCoverage(allQueries, float64(limit))
+
 	default:
 		return nil, fmt.Errorf("unknown test type: %s", testType)
 	}
 }
 
+// FilterQueriesByName keeps queries whose names match any of the only
+// patterns (all queries when only is empty) and none of the skip patterns.
+// Patterns are globs (orders_*) unless wrapped in slashes, in which case they
+// are regular expressions (/^orders_(list|get)$/).
+func FilterQueriesByName(queries []model.Query, only, skip []string) ([]model.Query, error) {
+	onlyMatchers, err := compileNamePatterns(only)
+	if err != nil {
+		return nil, err
+	}
+	skipMatchers, err := compileNamePatterns(skip)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []model.Query
+	for _, q := range queries {
+		if len(onlyMatchers) > 0 && !matchesAny(onlyMatchers, q.Name) {
+			continue
+		}
+		if matchesAny(skipMatchers, q.Name) {
+			continue
+		}
+		filtered = append(filtered, q)
+	}
+
+	return filtered, nil
+}
+
+// ValidateSampleMask reports a sampleMaskColumns pattern that doesn't
+// compile.
+func ValidateSampleMask(patterns []string) error {
+	if _, err := compileNamePatterns(patterns); err != nil {
+		return fmt.Errorf("invalid sampleMaskColumns: %w", err)
+	}
+	return nil
+}
+
+func compileNamePatterns(patterns []string) ([]func(string) bool, error) {
+	matchers := make([]func(string) bool, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+			}
+			matchers = append(matchers, re.MatchString)
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+		glob := pattern
+		matchers = append(matchers, func(name string) bool {
+			ok, _ := path.Match(glob, name)
+			return ok
+		})
+	}
+
+	return matchers, nil
+}
+
+func matchesAny(matchers []func(string) bool, name string) bool {
+	for _, match := range matchers {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeWeights interprets weights as relative frequencies within the
+// loaded set and returns each query's share of the total (summing to 1).
+// Negative weights count as zero; if every weight is zero the queries share
+// equally.
+func NormalizeWeights(queries []model.Query) []float64 {
+	shares := make([]float64, len(queries))
+	if len(queries) == 0 {
+		return shares
+	}
+
+	var total float64
+	for _, q := range queries {
+		if q.Weight > 0 {
+			total += float64(q.Weight)
+		}
+	}
+
+	for i, q := range queries {
+		switch {
+		case total == 0:
+			shares[i] = 1 / float64(len(queries))
+		case q.Weight > 0:
+			shares[i] = float64(q.Weight) / total
+		}
+	}
+
+	return shares
+}
+
+// SelectByWeightCoverage returns the highest-weight queries that together
+// account for at least percent of the total normalized weight, e.g. the
+// queries responsible for 90% of production query volume.
+func SelectByWeightCoverage(queries []model.Query, percent float64) ([]model.Query, error) {
+	if percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("weight coverage must be between 0 and 100, got %g", percent)
+	}
+
+	shares := NormalizeWeights(queries)
+	order := make([]int, len(queries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return shares[order[i]] > shares[order[j]]
+	})
+
+	target := percent / 100
+	var selected []model.Query
+	var covered float64
+	for _, idx := range order {
+		if covered >= target-1e-9 {
+			break
+		}
+		selected = append(selected, queries[idx])
+		covered += shares[idx]
+	}
+
+	return selected, nil
+}
+
 func filterQueriesByType(allQueries []model.Query, queryType string, limit int) ([]model.Query, error) {
 	var filtered []model.Query
 
@@ -229,6 +1345,33 @@ func filterQueriesByType(allQueries []model.Query, queryType string, limit int)
 	return filtered, nil
 }
 
+// filterQueriesByStatement keeps SELECTs (writes false) or INSERT, UPDATE and
+// DELETE statements (writes true). Other statements are in neither set.
+func filterQueriesByStatement(allQueries []model.Query, writes bool, limit int) ([]model.Query, error) {
+	var filtered []model.Query
+
+	for _, q := range allQueries {
+		kind := ClassifyStatement(q.SQL)
+		if kind == StatementOther || (kind == StatementSelect) == writes {
+			continue
+		}
+		filtered = append(filtered, q)
+	}
+
+	if len(filtered) == 0 {
+		if writes {
+			return nil, fmt.Errorf("no write queries found")
+		}
+		return nil, fmt.Errorf("no read queries found")
+	}
+
+	if limit > 0 && limit < len(filtered) {
+		return filtered[:limit], nil
+	}
+
+	return filtered, nil
+}
+
 func SaveTestQueries(queries []model.Query, outputPath string) error {
 	data, err := json.MarshalIndent(queries, "", "  ")
 	if err != nil {
@@ -259,7 +1402,9 @@ func ClassifyErrors(results []model.QueryResult) map[string]int {
 func classifyErrorMessage(errMsg string) string {
 	errMsg = strings.ToLower(errMsg)
 
-	if strings.Contains(errMsg, "deadlock") {
+	if strings.Contains(errMsg, "row cap exceeded") {
+		return "Row cap exceeded"
+	} else if strings.Contains(errMsg, "deadlock") {
 		return "Deadlock"
 	} else if strings.Contains(errMsg, "lock wait timeout") {
 		return "Lock timeout"
@@ -278,17 +1423,28 @@ func classifyErrorMessage(errMsg string) string {
 	}
 }
 
-func GenerateQueryExplain(db *sql.DB, query string) (string, error) {
+// GenerateQueryExplain returns the JSON EXPLAIN plan of a SELECT, or the
+// tabular one on servers without FORMAT=JSON. params are bound to the query's
+// ? placeholders, so the plan is the one the server picks for those values.
+// ctx bounds the round trips.
+func GenerateQueryExplain(ctx context.Context, db *sql.DB, query string, params ...any) (string, error) {
 	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(query)), "select") {
 		return "EXPLAIN not available for non-SELECT queries", nil
 	}
+	args, err := explainArgs(query, params)
+	if err != nil {
+		return "", err
+	}
 
 	explainQuery := "EXPLAIN FORMAT=JSON " + query
 	var explainResult string
 
-	err := db.QueryRow(explainQuery).Scan(&explainResult)
+	err = db.QueryRowContext(ctx, explainQuery, args...).Scan(&explainResult)
 	if err != nil {
-		rows, err := db.Query("EXPLAIN " + query)
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("error getting query explain plan: %w", ctx.Err())
+		}
+		rows, err := db.QueryContext(ctx, "EXPLAIN "+query, args...)
 		if err != nil {
 			return "", fmt.Errorf("error getting query explain plan: %w", err)
 		}
@@ -300,11 +1456,15 @@ func GenerateQueryExplain(db *sql.DB, query string) (string, error) {
 			return "", err
 		}
 
-		result.WriteString(strings.Join(columns, " | "))
-		result.WriteString("\n")
-		for range columns {
-			result.WriteString("--- | ")
+		header := make([]string, len(columns))
+		separator := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = formatExplainCell(col)
+			separator[i] = "---"
 		}
+		result.WriteString(strings.Join(header, " | "))
+		result.WriteString("\n")
+		result.WriteString(strings.Join(separator, " | "))
 		result.WriteString("\n")
 
 		values := make([]interface{}, len(columns))
@@ -313,30 +1473,121 @@ func GenerateQueryExplain(db *sql.DB, query string) (string, error) {
 			valuePtrs[i] = &values[i]
 		}
 
+		cells := make([]string, len(columns))
 		for rows.Next() {
 			if err := rows.Scan(valuePtrs...); err != nil {
 				return "", err
 			}
 
 			for i, val := range values {
-				var valStr string
-				b, ok := val.([]byte)
-				if ok {
-					valStr = string(b)
-				} else {
-					valStr = fmt.Sprintf("%v", val)
-				}
-
-				if i > 0 {
-					result.WriteString(" | ")
-				}
-				result.WriteString(valStr)
+				cells[i] = formatExplainCell(val)
 			}
+			result.WriteString(strings.Join(cells, " | "))
 			result.WriteString("\n")
 		}
 
+		if err := rows.Err(); err != nil {
+			return "", fmt.Errorf("error reading query explain plan: %w", err)
+		}
+
 		return result.String(), nil
 	}
 
 	return explainResult, nil
 }
+
+// errUnboundPlaceholders is returned for a query with ? placeholders and no
+// explainParams: the server can't plan an unbound placeholder, so there is no
+// representative plan to show.
+var errUnboundPlaceholders = errors.New("query has ? placeholders and no explainParams to explain it with; give it representative values to see its plan")
+
+// explainArgs returns params ready to bind to query's placeholders, or
+// errUnboundPlaceholders if it has placeholders and params is empty. Whole
+// numbers, which JSON decodes as float64, are bound as integers: compared
+// with a DOUBLE, an integer or string column can lose its index and the plan
+// wouldn't be the one the query gets.
+func explainArgs(query string, params []any) ([]any, error) {
+	if len(params) == 0 {
+		if HasPlaceholders(query) {
+			return nil, errUnboundPlaceholders
+		}
+		return nil, nil
+	}
+
+	args := make([]any, len(params))
+	for i, p := range params {
+		if f, ok := p.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			p = int64(f)
+		}
+		args[i] = p
+	}
+	return args, nil
+}
+
+// HasPlaceholders reports whether sql has ? placeholders outside string
+// literals and comments.
+func HasPlaceholders(sql string) bool {
+	return strings.ContainsRune(blankLiterals(sql), '?')
+}
+
+// formatExplainCell renders one value of the tabular EXPLAIN fallback. NULLs
+// print as NULL, and pipes and line breaks are escaped so each row stays on
+// one line with the expected number of cells.
+func formatExplainCell(val interface{}) string {
+	var s string
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	return explainCellEscaper.Replace(s)
+}
+
+var explainCellEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// GenerateQueryExplainAnalyze runs EXPLAIN ANALYZE (MySQL 8.0.18+), which
+// executes the query with params bound to its placeholders and reports actual
+// row counts and timings per plan step.
+func GenerateQueryExplainAnalyze(ctx context.Context, db *sql.DB, query string, params ...any) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(query)), "select") {
+		return "", fmt.Errorf("EXPLAIN ANALYZE is only supported for SELECT queries")
+	}
+	args, err := explainArgs(query, params)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := db.QueryContext(ctx, "EXPLAIN ANALYZE "+query, args...)
+	if err != nil {
+		return "", fmt.Errorf("error running EXPLAIN ANALYZE: %w", err)
+	}
+	defer rows.Close()
+
+	var result strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		result.WriteString(line)
+		result.WriteString("\n")
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
@@ -0,0 +1,147 @@
+// internal/analyzer/query_bench_test.go
+package analyzer
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// BenchmarkExecuteBatch measures the harness itself: the fake database
+// answers instantly, so ns/op is what the analyzer spends per execution
+// scheduling it, timing it and recording the result.
+func BenchmarkExecuteBatch(b *testing.B) {
+	fake := &fakeDB{respond: func(string) fakeResult {
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}
+	}}
+	db := fake.open(b)
+
+	queries := make([]model.Query, 8)
+	for i := range queries {
+		queries[i] = model.Query{Name: fmt.Sprintf("q%d", i), SQL: fmt.Sprintf("SELECT %d", i)}
+	}
+
+	for _, concurrency := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			db.SetMaxIdleConns(concurrency)
+			qe := NewQueryExecutor(db, config.Config{Timeout: time.Second, Concurrency: concurrency})
+			iterations := max(b.N/len(queries), 1)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			if _, err := qe.ExecuteBatchContext(b.Context(), queries, iterations); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// BenchmarkExecuteBatchDesigns compares the worker pool with the design it
+// replaced, a goroutine per execution gated by a channel semaphore with a
+// mutex per query, on the run behind the README's table: 50 queries x 400
+// iterations at concurrency 100. Run it on one CPU to compare with the table:
+//
+//	go test -run '^$' -bench ExecuteBatchDesigns -cpu 1 ./internal/analyzer
+//
+// Both designs compute per-query statistics. µs/exec is the analyzer's time
+// per execution and peak-goroutines the most goroutines seen at any
+// execution.
+func BenchmarkExecuteBatchDesigns(b *testing.B) {
+	const queryCount, iterations, concurrency = 50, 400, 100
+
+	fake := &fakeDB{respond: func(string) fakeResult {
+		return fakeResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}
+	}}
+	db := fake.open(b)
+	db.SetMaxIdleConns(concurrency)
+
+	queries := make([]model.Query, queryCount)
+	for i := range queries {
+		queries[i] = model.Query{Name: fmt.Sprintf("q%d", i), SQL: fmt.Sprintf("SELECT %d", i)}
+	}
+
+	designs := []struct {
+		name string
+		run  func(ctx context.Context, qe *QueryExecutor, sample func())
+	}{
+		{"pool", func(ctx context.Context, qe *QueryExecutor, sample func()) {
+			qe.onExecution = func(string, string, model.QueryExecution) { sample() }
+			if _, err := qe.ExecuteBatchContext(ctx, queries, iterations); err != nil {
+				b.Fatal(err)
+			}
+		}},
+		{"semaphore", func(ctx context.Context, qe *QueryExecutor, sample func()) {
+			executeBatchSemaphore(ctx, qe, queries, iterations, sample)
+		}},
+	}
+
+	for _, design := range designs {
+		b.Run(design.name, func(b *testing.B) {
+			var peak atomic.Int64
+			sample := func() {
+				n := int64(runtime.NumGoroutine())
+				for old := peak.Load(); n > old && !peak.CompareAndSwap(old, n); old = peak.Load() {
+				}
+			}
+
+			for b.Loop() {
+				qe := NewQueryExecutor(db, config.Config{Timeout: time.Second, Concurrency: concurrency})
+				design.run(b.Context(), qe, sample)
+			}
+
+			perExec := float64(b.Elapsed().Nanoseconds()) / float64(b.N*queryCount*iterations)
+			b.ReportMetric(perExec/1000, "µs/exec")
+			b.ReportMetric(float64(peak.Load()), "peak-goroutines")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
+
+// executeBatchSemaphore is the scheduler ExecuteBatchContext replaced, kept as
+// the benchmark's baseline: every execution gets a goroutine up front, which
+// waits on a semaphore of concurrency slots and records its execution under
+// its query's mutex.
+func executeBatchSemaphore(ctx context.Context, qe *QueryExecutor, queries []model.Query, iterations int, sample func()) []model.QueryResult {
+	results := make([]model.QueryResult, len(queries))
+	locks := make([]sync.Mutex, len(queries))
+	shares := NormalizeWeights(queries)
+	for i, query := range queries {
+		results[i] = qe.newQueryResult(query, shares[i], iterations)
+	}
+
+	semaphore := make(chan struct{}, max(qe.concurrency, 1))
+	var wg sync.WaitGroup
+	for iteration := range iterations {
+		for i, q := range queries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				execCtx := WithExecutionTag(ctx, ExecutionTag{Run: qe.label, Query: q.Name, Iteration: iteration + 1})
+				execution := qe.ExecuteQuery(execCtx, q.SQL)
+
+				locks[i].Lock()
+				recordExecution(&results[i], execution)
+				locks[i].Unlock()
+				qe.completed.Add(1)
+				sample()
+			}()
+		}
+	}
+	wg.Wait()
+
+	for i := range results {
+		finalizeResult(&results[i], qe.finalizeOptions())
+	}
+	return results
+}
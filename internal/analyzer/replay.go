@@ -0,0 +1,102 @@
+// internal/analyzer/replay.go
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+// Reanalyze re-derives every per-query statistic and the run summary of a
+// saved result from its stored executions, so analysis added since the run
+// can be applied to historical data. Percentiles are recomputed by linear
+// interpolation whatever method the run used. Queries whose executions
+// weren't kept in the report can't be re-derived and are an error.
+func Reanalyze(saved model.TestResult) (model.TestResult, error) {
+	result := saved
+	result.Config.PercentileMethod = config.PercentileLinear
+	result.QueryResults = make([]model.QueryResult, len(saved.QueryResults))
+	complexity := NewComplexityClassifier(saved.Config.ComplexityRules)
+
+	for i, q := range saved.QueryResults {
+		if len(q.Executions) == 0 && q.SuccessfulExecutions+q.Errors > 0 {
+			return saved, fmt.Errorf("query %s has no stored executions (was the report written with includeExecutions false?)", q.Name)
+		}
+		if q.ExecutionsTruncated > 0 {
+			return saved, fmt.Errorf("query %s is missing %d executions truncated by maxExecutionsInReport", q.Name, q.ExecutionsTruncated)
+		}
+
+		score, components := complexity.Score(q.SQL)
+		rebuilt := model.QueryResult{
+			Name:                 q.Name,
+			Description:          q.Description,
+			SQL:                  q.SQL,
+			MinDuration:          time.Hour,
+			Weight:               q.Weight,
+			WeightShare:          q.WeightShare,
+			Schema:               q.Schema,
+			Template:             q.Template,
+			MinSuccessRate:       q.MinSuccessRate,
+			QueryComplexity:      complexity.Classify(q.SQL),
+			ComplexityScore:      score,
+			StatementType:        ClassifyStatement(q.SQL),
+			LintWarnings:         LintQuery(q.SQL),
+			ComplexityComponents: components,
+			ExplainPlan:          q.ExplainPlan,
+			PlanWarnings:         PlanWarnings(q.ExplainPlan),
+			Profile:              q.Profile,
+			PlanFingerprints:     q.PlanFingerprints,
+			PlanFlipped:          q.PlanFlipped,
+			LockWaits:            q.LockWaits,
+			LockWaitTimeMs:       q.LockWaitTimeMs,
+			RolledBack:           q.RolledBack,
+			SampleRows:           q.SampleRows,
+			Executions:           make([]model.QueryExecution, 0, len(q.Executions)),
+		}
+		rebuilt.EstimatedCost, rebuilt.EstimatedRows = PlanEstimates(q.ExplainPlan)
+		if q.SLO != nil {
+			rebuilt.SLO = sloObjective(q.SLO.ThresholdMs, q.SLO.Percentile)
+		}
+		if IsExplainPlanFile(q.ExplainPlan) {
+			// The plan isn't in the report; keep what was derived from it.
+			rebuilt.PlanWarnings = q.PlanWarnings
+			rebuilt.EstimatedCost, rebuilt.EstimatedRows = q.EstimatedCost, q.EstimatedRows
+		}
+
+		executions := append([]model.QueryExecution(nil), q.Executions...)
+		if q.ColdExecution != nil {
+			executions = append(executions, *q.ColdExecution)
+		}
+		for i := range executions {
+			executions[i] = restoreError(executions[i])
+		}
+
+		mergeExecutions(&rebuilt, executions, q.AvgHarnessOverhead*time.Duration(len(executions)), finalizeOptions{
+			freshConn:   saved.Config.FreshConnPerQuery,
+			measureCold: saved.Config.MeasureCold,
+			minSamples:  saved.Config.PercentileMinSamples,
+			percentiles: utils.PercentileLinear,
+		})
+		result.QueryResults[i] = rebuilt
+	}
+
+	attributeTotalTime(result.QueryResults)
+	result.Summary = calculateSummary(result.QueryResults, utils.PercentileLinear)
+	result.TableBreakdown = tableBreakdown(result.QueryResults)
+	result.SchemaSpread = schemaSpread(result.QueryResults)
+	result.CostLatency = costLatency(result.QueryResults)
+	return result, nil
+}
+
+// restoreError sets the Error of a decoded execution. Error isn't serialized;
+// without it a failed execution would be counted as successful.
+func restoreError(exec model.QueryExecution) model.QueryExecution {
+	if exec.ErrorMessage != "" && exec.Error == nil {
+		exec.Error = errors.New(exec.ErrorMessage)
+	}
+	return exec
+}
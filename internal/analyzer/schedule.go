@@ -0,0 +1,97 @@
+// internal/analyzer/schedule.go
+package analyzer
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+)
+
+// Execution orders control how (query, iteration) tasks are handed to the
+// worker pool.
+const (
+	OrderRoundRobin = "round-robin" // One iteration of each query in turn
+	OrderSequential = "sequential"  // All iterations of a query before the next
+	OrderShuffled   = "shuffled"    // Random order across all tasks
+)
+
+// ExecutionOrders lists the accepted execution orders, default first.
+var ExecutionOrders = []string{OrderRoundRobin, OrderSequential, OrderShuffled}
+
+// UsesSeed reports whether a run with cfg makes random choices, which
+// Config.Seed then drives. Runs that don't are reproducible without one.
+func UsesSeed(cfg config.Config) bool {
+	return cfg.ExecutionOrder == OrderShuffled
+}
+
+// NewSeed picks a random seed for a run that wasn't given one. It is never 0,
+// which means "pick one".
+func NewSeed() uint64 {
+	for {
+		if seed := rand.Uint64(); seed != 0 {
+			return seed
+		}
+	}
+}
+
+// Connection modes control which connection each execution runs on.
+const (
+	ConnModePool      = "pool"      // Check out a connection from the shared pool per execution
+	ConnModeDedicated = "dedicated" // Pin one long-lived connection to each worker
+	ConnModeFresh     = "fresh"     // Open a new connection per execution (FreshConnPerQuery)
+)
+
+// ConnectionModes lists the configurable connection modes, default first.
+var ConnectionModes = []string{ConnModePool, ConnModeDedicated}
+
+// EffectiveConnectionMode returns the connection mode a run with cfg uses.
+func EffectiveConnectionMode(cfg config.Config) string {
+	switch {
+	case cfg.FreshConnPerQuery:
+		return ConnModeFresh
+	case cfg.ConnectionMode == "":
+		return ConnModePool
+	default:
+		return cfg.ConnectionMode
+	}
+}
+
+// task is a single execution of one query.
+type task struct {
+	query     int
+	iteration int
+	released  time.Time // End of the last guardrail pause before it was dispatched
+}
+
+// scheduleTasks returns the iterations of numQueries queries in the requested
+// order. An empty order means round-robin. A shuffled order is drawn from rng,
+// so it is the same for the same seed.
+func scheduleTasks(numQueries, iterations int, order string, rng *rand.Rand) ([]task, error) {
+	tasks := make([]task, 0, numQueries*iterations)
+
+	switch order {
+	case "", OrderRoundRobin:
+		for iter := range iterations {
+			for q := range numQueries {
+				tasks = append(tasks, task{query: q, iteration: iter})
+			}
+		}
+	case OrderSequential, OrderShuffled:
+		for q := range numQueries {
+			for iter := range iterations {
+				tasks = append(tasks, task{query: q, iteration: iter})
+			}
+		}
+		if order == OrderShuffled {
+			rng.Shuffle(len(tasks), func(i, j int) {
+				tasks[i], tasks[j] = tasks[j], tasks[i]
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unknown execution order %q (want round-robin, sequential or shuffled)", order)
+	}
+
+	return tasks, nil
+}
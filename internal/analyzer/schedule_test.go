@@ -0,0 +1,113 @@
+// internal/analyzer/schedule_test.go
+package analyzer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+func TestExecuteBatchOrder(t *testing.T) {
+	queries := []model.Query{
+		{Name: "a", SQL: "SELECT 'a'"},
+		{Name: "b", SQL: "SELECT 'b'"},
+		{Name: "c", SQL: "SELECT 'c'"},
+	}
+	const iterations = 4
+
+	tests := []struct {
+		order string
+		want  string // Queries in execution order; "" to only check the counts
+	}{
+		{"", "abcabcabcabc"},
+		{OrderRoundRobin, "abcabcabcabc"},
+		{OrderSequential, "aaaabbbbcccc"},
+		{OrderShuffled, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			fake := &fakeDB{}
+			qe := NewQueryExecutor(fake.open(t), config.Config{
+				Timeout:        time.Second,
+				Concurrency:    1,
+				ExecutionOrder: tt.order,
+				Seed:           1,
+			})
+
+			results, err := qe.ExecuteBatchContext(t.Context(), queries, iterations)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var order strings.Builder
+			for _, q := range fake.queries() {
+				order.WriteString(strings.Trim(strings.TrimPrefix(q, "SELECT "), "'"))
+			}
+			got := order.String()
+
+			if tt.want != "" && got != tt.want {
+				t.Errorf("executed %s, want %s", got, tt.want)
+			}
+			if tt.order == OrderShuffled && (got == "abcabcabcabc" || got == "aaaabbbbcccc") {
+				t.Errorf("shuffled order %s isn't shuffled", got)
+			}
+			for i, q := range queries {
+				if n := strings.Count(got, q.Name); n != iterations {
+					t.Errorf("%s executed %d times, want %d", q.Name, n, iterations)
+				}
+				if results[i].Name != q.Name || results[i].SuccessfulExecutions != iterations || len(results[i].Executions) != iterations {
+					t.Errorf("result %d = %s with %d successes and %d executions, want %s with %d",
+						i, results[i].Name, results[i].SuccessfulExecutions, len(results[i].Executions), q.Name, iterations)
+				}
+			}
+		})
+	}
+}
+
+// A query's executions are spread over the whole batch rather than bunched
+// at the start or the end, whatever the concurrency.
+func TestExecuteBatchInterleaves(t *testing.T) {
+	fake := &fakeDB{}
+	qe := NewQueryExecutor(fake.open(t), config.Config{Timeout: time.Second, Concurrency: 3})
+
+	var queries []model.Query
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		queries = append(queries, model.Query{Name: name, SQL: "SELECT '" + name + "'"})
+	}
+	if _, err := qe.ExecuteBatchContext(t.Context(), queries, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	executed := fake.queries()
+	if len(executed) != 60 {
+		t.Fatalf("executed %d statements, want 60", len(executed))
+	}
+	// Every query runs in the first and the last fifth of the batch.
+	for _, part := range [][]string{executed[:12], executed[48:]} {
+		for _, q := range queries {
+			if !slices.Contains(part, q.SQL) {
+				t.Errorf("%s missing from %v", q.Name, part)
+			}
+		}
+	}
+}
+
+func TestScheduleTasksShuffledIsSeeded(t *testing.T) {
+	first, err := scheduleTasks(5, 5, OrderShuffled, NewQueryExecutor(nil, config.Config{Seed: 7}).rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := scheduleTasks(5, 5, OrderShuffled, NewQueryExecutor(nil, config.Config{Seed: 7}).rng)
+	if !slices.Equal(first, again) {
+		t.Error("the same seed gave different shuffled orders")
+	}
+
+	if _, err := scheduleTasks(1, 1, "random", nil); err == nil {
+		t.Error("scheduleTasks() accepted an unknown order")
+	}
+}
@@ -0,0 +1,96 @@
+// internal/analyzer/schemas.go
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+// SchemaPlaceholder marks where a query's SQL names the schema to run in.
+const SchemaPlaceholder = "{{schema}}"
+
+// slowestSchemas is how many schemas each query's spread names.
+const slowestSchemas = 5
+
+// Schema names are substituted into SQL unquoted, so only plain identifiers
+// are accepted.
+var schemaNamePattern = regexp.MustCompile(`^[A-Za-z0-9_$]+$`)
+
+// ExpandSchemas fans every query containing SchemaPlaceholder out into one
+// query per schema, named "<query>@<schema>". Queries without the
+// placeholder run once, unchanged.
+func ExpandSchemas(queries []model.Query, schemas []string) ([]model.Query, error) {
+	for _, schema := range schemas {
+		if !schemaNamePattern.MatchString(schema) {
+			return nil, fmt.Errorf("invalid schema name %q: only letters, digits, _ and $ are allowed", schema)
+		}
+	}
+
+	expanded := make([]model.Query, 0, len(queries)*len(schemas))
+	for _, q := range queries {
+		if !strings.Contains(q.SQL, SchemaPlaceholder) {
+			expanded = append(expanded, q)
+			continue
+		}
+		for _, schema := range schemas {
+			fanned := q
+			fanned.Name = q.Name + "@" + schema
+			fanned.SQL = strings.ReplaceAll(q.SQL, SchemaPlaceholder, schema)
+			fanned.Schema = schema
+			fanned.Template = q.Name
+			expanded = append(expanded, fanned)
+		}
+	}
+	return expanded, nil
+}
+
+// schemaSpread summarizes, for each fanned-out query, how its average
+// latency is distributed across schemas and which schemas are slowest.
+func schemaSpread(results []model.QueryResult) []model.SchemaSpread {
+	bySchema := make(map[string][]model.SchemaLatency)
+	var order []string
+
+	for _, r := range results {
+		if r.Template == "" || r.SuccessfulExecutions == 0 {
+			continue
+		}
+		if _, ok := bySchema[r.Template]; !ok {
+			order = append(order, r.Template)
+		}
+		bySchema[r.Template] = append(bySchema[r.Template], model.SchemaLatency{
+			Schema: r.Schema,
+			AvgMs:  float64(r.AvgDuration.Microseconds()) / 1000,
+		})
+	}
+
+	spread := make([]model.SchemaSpread, 0, len(order))
+	for _, name := range order {
+		latencies := bySchema[name]
+		sort.SliceStable(latencies, func(i, j int) bool {
+			return latencies[i].AvgMs > latencies[j].AvgMs
+		})
+
+		avgs := make([]time.Duration, len(latencies))
+		for i, l := range latencies {
+			avgs[i] = time.Duration(l.AvgMs * float64(time.Millisecond))
+		}
+		stats := utils.CalculateStats(avgs)
+
+		spread = append(spread, model.SchemaSpread{
+			Query:    name,
+			Schemas:  len(latencies),
+			MinMs:    float64(stats.Min.Microseconds()) / 1000,
+			MedianMs: float64(stats.Median.Microseconds()) / 1000,
+			P95Ms:    float64(stats.P95.Microseconds()) / 1000,
+			MaxMs:    float64(stats.Max.Microseconds()) / 1000,
+			Slowest:  latencies[:min(len(latencies), slowestSchemas)],
+		})
+	}
+	return spread
+}
@@ -0,0 +1,157 @@
+// internal/analyzer/soak.go
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// OnSnapshot registers fn to receive each snapshot of a soak run as it is
+// finalized. fn is called from the goroutine running the soak, which waits
+// for it. Register it before the run starts.
+func (a *Analyzer) OnSnapshot(fn func(results []model.QueryResult, soak model.Soak)) {
+	a.onSnapshot = fn
+}
+
+// Soak returns the soak's trends across its snapshots, or nil for a run that
+// isn't a soak.
+func (a *Analyzer) Soak() *model.Soak {
+	return a.soak
+}
+
+// runSoak runs the suite back to back until the soak's duration has passed,
+// finalizing a snapshot every SnapshotSeconds. A snapshot is made of whole
+// batches of the suite, so it runs over its interval by up to one batch. Its
+// executions are dropped once it has been handed to the snapshot callback,
+// so memory is bounded by one snapshot however long the soak; only a point
+// per query and snapshot is kept for the trends, and the heatmap's counts.
+// The last snapshot's results are returned.
+func (a *Analyzer) runSoak(ctx context.Context) ([]model.QueryResult, error) {
+	qe := a.executor
+	duration := time.Duration(a.config.Soak.DurationSeconds * float64(time.Second))
+	interval := time.Duration(a.config.Soak.SnapshotSeconds * float64(time.Second))
+
+	// Per-batch results would be partial; the callback gets each snapshot's
+	// merged results instead.
+	onQueryDone := qe.onQueryDone
+	qe.onQueryDone = nil
+	defer func() { qe.onQueryDone = onQueryDone }()
+
+	start := time.Now()
+	end := start.Add(duration)
+
+	// Every batch counts onto one grid from the soak's start, so the heatmap
+	// covers the whole soak.
+	qe.heatmapFrom = start
+	defer func() { qe.heatmapFrom = time.Time{} }()
+	heatmap := newHeatmapCounter(qe.heatmapCfg, start)
+	shares := NormalizeWeights(a.queries)
+	a.soak = &model.Soak{WindowStart: start, Trends: make([]model.SoakTrend, len(a.queries))}
+	for i, query := range a.queries {
+		a.soak.Trends[i].Query = query.Name
+	}
+
+	log.Printf("Soaking for %s with a snapshot every %s", duration, interval)
+
+	var results []model.QueryResult
+	var err error
+	for seq := 1; err == nil && time.Now().Before(end); seq++ {
+		windowStart := time.Now()
+		windowEnd := windowStart.Add(interval)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+
+		executions := make([][]model.QueryExecution, len(a.queries))
+		overhead := make([]time.Duration, len(a.queries))
+		firstPlans := make([]string, len(a.queries))
+		lastPlans := make([]string, len(a.queries))
+		batches := 0
+		for err == nil && (batches == 0 || time.Now().Before(windowEnd)) {
+			batchStart := time.Now()
+			var batch []model.QueryResult
+			qe.heatmap = nil
+			batch, err = qe.ExecuteBatchContext(ctx, a.queries, a.iterations)
+			batches++
+			if qe.heatmap != nil {
+				heatmap.merge(qe.heatmap)
+			}
+			if batches == 1 && time.Since(batchStart) > interval {
+				log.Printf("Warning: one pass of the suite took %s, longer than the %s snapshot interval",
+					time.Since(batchStart).Round(time.Second), interval)
+			}
+
+			for i, q := range batch {
+				returned := q.Executions
+				if q.ColdExecution != nil {
+					returned = append(returned, *q.ColdExecution)
+				}
+				executions[i] = append(executions[i], returned...)
+				overhead[i] += q.AvgHarnessOverhead * time.Duration(len(returned))
+				if len(q.PlanFingerprints) == 2 {
+					if firstPlans[i] == "" {
+						firstPlans[i] = q.PlanFingerprints[0]
+					}
+					lastPlans[i] = q.PlanFingerprints[1]
+				}
+			}
+		}
+
+		// Only the soak's very first execution of a query finds its caches
+		// cold.
+		opts := qe.finalizeOptions()
+		opts.measureCold = opts.measureCold && seq == 1
+
+		results = make([]model.QueryResult, len(a.queries))
+		for i, query := range a.queries {
+			results[i] = qe.newQueryResult(query, shares[i], len(executions[i]))
+			mergeExecutions(&results[i], executions[i], overhead[i], opts)
+			recordPlanFingerprints(&results[i], firstPlans[i], lastPlans[i])
+			a.soak.Trends[i].Points = append(a.soak.Trends[i].Points, soakPoint(seq, results[i]))
+		}
+
+		a.soak.Snapshots = seq
+		a.soak.WindowEnd = time.Now()
+		log.Printf("Soak snapshot %d: %d batches in %s", seq, batches, a.soak.WindowEnd.Sub(windowStart).Round(time.Second))
+
+		if a.onSnapshot != nil {
+			a.onSnapshot(results, model.Soak{
+				Sequence:    seq,
+				WindowStart: windowStart,
+				WindowEnd:   a.soak.WindowEnd,
+				Snapshots:   seq,
+			})
+		}
+		if onQueryDone != nil {
+			for _, result := range results {
+				onQueryDone(result)
+			}
+		}
+	}
+
+	qe.heatmap = heatmap
+
+	if err != nil {
+		return results, fmt.Errorf("soak stopped after %d snapshots: %w", a.soak.Snapshots, err)
+	}
+	return results, nil
+}
+
+// soakPoint summarizes result as one point of its query's trend.
+func soakPoint(seq int, result model.QueryResult) model.SoakPoint {
+	point := model.SoakPoint{
+		Sequence:               seq,
+		Executions:             len(result.Executions),
+		AvgMs:                  float64(result.AvgDuration) / float64(time.Millisecond),
+		P95Ms:                  float64(result.Percentile95) / float64(time.Millisecond),
+		P95InsufficientSamples: result.P95InsufficientSamples,
+	}
+	if total := result.SuccessfulExecutions + result.Errors; total > 0 {
+		point.ErrorRate = float64(result.Errors) / float64(total)
+	}
+	return point
+}
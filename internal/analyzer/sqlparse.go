@@ -0,0 +1,116 @@
+// internal/analyzer/sqlparse.go
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// parseStatement parses sql with the MySQL grammar. It reports false for
+// anything the parser doesn't cover (CTEs, window functions, some DDL), in
+// which case callers fall back to keyword heuristics.
+func parseStatement(sql string) (sqlparser.Statement, bool) {
+	stmt, err := sqlparser.ParseStrictDDL(sql)
+	if err != nil || stmt == nil {
+		return nil, false
+	}
+	return stmt, true
+}
+
+func parsedStatementKind(stmt sqlparser.Statement) string {
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.ParenSelect:
+		return StatementSelect
+	case *sqlparser.Insert:
+		return StatementInsert
+	case *sqlparser.Update:
+		return StatementUpdate
+	case *sqlparser.Delete:
+		return StatementDelete
+	default:
+		return StatementOther
+	}
+}
+
+func parsedFeatures(stmt sqlparser.Statement) queryFeatures {
+	var f queryFeatures
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.Select:
+			if len(n.From) > 1 {
+				f.joins += len(n.From) - 1 // Comma joins
+			}
+			if len(n.GroupBy) > 0 {
+				f.aggregations++
+			}
+			if n.Having != nil {
+				f.aggregations++
+				f.having = true
+			}
+			if len(n.OrderBy) > 0 {
+				f.ordering = true
+			}
+		case *sqlparser.Union:
+			f.unions++
+			if len(n.OrderBy) > 0 {
+				f.ordering = true
+			}
+		case *sqlparser.JoinTableExpr:
+			f.joins++
+		case *sqlparser.Subquery:
+			f.subqueries++
+		case *sqlparser.FuncExpr:
+			if n.IsAggregate() {
+				f.aggregations++
+			}
+		case *sqlparser.GroupConcatExpr:
+			f.aggregations++
+		case *sqlparser.AndExpr, *sqlparser.OrExpr:
+			f.conditions++
+		}
+		return true, nil
+	}, stmt)
+
+	return f
+}
+
+// parsedTables collects the base tables referenced anywhere in stmt,
+// including inside subqueries. Column qualifiers and aliases are TableNames
+// in the tree too, so only table positions are inspected.
+func parsedTables(stmt sqlparser.Statement) []string {
+	tables := []string{}
+	seen := make(map[string]bool)
+
+	add := func(name sqlparser.TableName) {
+		// The parser fills in dual for a SELECT without FROM.
+		if name.IsEmpty() || name.Qualifier.IsEmpty() && name.Name.String() == "dual" {
+			return
+		}
+		table := strings.ToLower(name.Name.String())
+		if !name.Qualifier.IsEmpty() {
+			table = strings.ToLower(name.Qualifier.String()) + "." + table
+		}
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.AliasedTableExpr:
+			if name, ok := n.Expr.(sqlparser.TableName); ok {
+				add(name)
+			}
+		case *sqlparser.Insert:
+			add(n.Table)
+		case *sqlparser.DDL:
+			add(n.Table)
+		}
+		return true, nil
+	}, stmt)
+
+	return tables
+}
@@ -0,0 +1,66 @@
+// internal/analyzer/tables.go
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// tableBreakdown aggregates query results by the tables each query touches.
+// A query that joins several tables counts toward each of them. Tables are
+// ordered hottest first, by the weighted latency of the queries touching
+// them, so the first is the one whose queries cost the workload most; ties
+// go to total time spent in them.
+func tableBreakdown(results []model.QueryResult) []model.TableStats {
+	type accumulator struct {
+		stats model.TableStats
+		total time.Duration
+		count int
+	}
+	byTable := make(map[string]*accumulator)
+	var order []string
+	var suiteWeightedMs float64
+
+	for _, result := range results {
+		weightedMs := result.WeightShare * float64(result.AvgDuration.Microseconds()) / 1000
+		suiteWeightedMs += weightedMs
+		for _, table := range AnalyzeTablesInQuery(result.SQL) {
+			acc, ok := byTable[table]
+			if !ok {
+				acc = &accumulator{stats: model.TableStats{Table: table}}
+				byTable[table] = acc
+				order = append(order, table)
+			}
+			acc.stats.Queries = append(acc.stats.Queries, result.Name)
+			acc.stats.Executions += result.SuccessfulExecutions + result.Errors
+			acc.stats.RowsReturned += result.RowsAffected
+			acc.total += result.AvgDuration * time.Duration(result.SuccessfulExecutions)
+			acc.count += result.SuccessfulExecutions
+			acc.stats.WeightedLatencyMs += weightedMs
+		}
+	}
+
+	breakdown := make([]model.TableStats, 0, len(order))
+	for _, table := range order {
+		acc := byTable[table]
+		acc.stats.TotalDurationMs = float64(acc.total.Microseconds()) / 1000
+		if acc.count > 0 {
+			acc.stats.AvgDurationMs = float64((acc.total / time.Duration(acc.count)).Microseconds()) / 1000
+		}
+		if suiteWeightedMs > 0 {
+			acc.stats.LatencyShare = acc.stats.WeightedLatencyMs / suiteWeightedMs
+		}
+		breakdown = append(breakdown, acc.stats)
+	}
+
+	sort.SliceStable(breakdown, func(i, j int) bool {
+		if breakdown[i].WeightedLatencyMs != breakdown[j].WeightedLatencyMs {
+			return breakdown[i].WeightedLatencyMs > breakdown[j].WeightedLatencyMs
+		}
+		return breakdown[i].TotalDurationMs > breakdown[j].TotalDurationMs
+	})
+
+	return breakdown
+}
@@ -0,0 +1,41 @@
+// internal/analyzer/tag.go
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExecutionTag identifies a single execution in server-side logs such as the
+// general log and performance_schema.events_statements_history.
+type ExecutionTag struct {
+	Run       string
+	Query     string
+	Iteration int
+}
+
+type executionTagKey struct{}
+
+// WithExecutionTag returns a copy of ctx carrying tag.
+func WithExecutionTag(ctx context.Context, tag ExecutionTag) context.Context {
+	return context.WithValue(ctx, executionTagKey{}, tag)
+}
+
+func executionTagFrom(ctx context.Context) (ExecutionTag, bool) {
+	tag, ok := ctx.Value(executionTagKey{}).(ExecutionTag)
+	return tag, ok
+}
+
+// Comment renders the tag as a SQL comment. Values are sanitized so they
+// can't terminate the comment early.
+func (t ExecutionTag) Comment() string {
+	return fmt.Sprintf("/* fn-analyzer run=%s query=%s iter=%d */",
+		sanitizeCommentValue(t.Run), sanitizeCommentValue(t.Query), t.Iteration)
+}
+
+var commentValueReplacer = strings.NewReplacer("*/", "* /", "/*", "/ *", " ", "_", "\n", "_", "\r", "_")
+
+func sanitizeCommentValue(value string) string {
+	return commentValueReplacer.Replace(value)
+}
@@ -0,0 +1,69 @@
+// internal/analyzer/trace.go
+package analyzer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// TraceExecutions makes the run emit a span per query execution, nested
+// under one span for the whole run. Without it, or with a nil tracer, no
+// spans are created. Set it before the run starts.
+func (a *Analyzer) TraceExecutions(tracer trace.Tracer) {
+	a.executor.tracer = tracer
+}
+
+// startRunSpan starts the span the run's executions are nested under, if
+// tracing. end records err on it and ends it.
+func (a *Analyzer) startRunSpan(ctx context.Context) (context.Context, func(err error)) {
+	tracer := a.executor.tracer
+	if tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := tracer.Start(ctx, "fn-analyzer run", trace.WithAttributes(
+		attribute.String("fn.run.label", a.config.Label),
+		attribute.Int("fn.run.queries", len(a.queries)),
+		attribute.Int("fn.run.iterations", a.iterations),
+		attribute.Int("fn.run.concurrency", a.concurrency),
+	))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// traceExecution records a finished execution as a span under the run span
+// in ctx. The span is created after the fact from the execution's own
+// timings, so tracing adds nothing to the measured time.
+func (qe *QueryExecutor) traceExecution(ctx context.Context, q model.Query, complexity string, iteration int, execution model.QueryExecution) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "mysql"),
+		attribute.String("fn.query.name", q.Name),
+		attribute.String("fn.query.complexity", complexity),
+		attribute.Int("fn.query.iteration", iteration+1),
+		attribute.Int64("db.response.returned_rows", execution.RowCount),
+	}
+	if execution.AcquireDuration > 0 {
+		attrs = append(attrs, attribute.Int64("fn.acquire_wait_us", execution.AcquireDuration.Microseconds()))
+	}
+
+	_, span := qe.tracer.Start(ctx, q.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(execution.StartTime),
+		trace.WithAttributes(attrs...),
+	)
+	if execution.Error != nil {
+		span.SetAttributes(attribute.String("error.type", classifyErrorMessage(execution.ErrorMessage)))
+		span.SetStatus(codes.Error, execution.ErrorMessage)
+	}
+	span.End(trace.WithTimestamp(execution.StartTime.Add(execution.Duration)))
+}
@@ -3,7 +3,9 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
@@ -15,41 +17,369 @@ type Config struct {
 	OutputDir        string        `json:"outputDir"`        // Directory to save results
 	Iterations       int           `json:"iterations"`       // Number of iterations per query
 	Concurrency      int           `json:"concurrency"`      // Maximum concurrent queries
+	ExecutionOrder   string        `json:"executionOrder"`   // Task order: round-robin, sequential or shuffled
+	Seed             uint64        `json:"seed,omitempty"`   // Seeds the run's randomness (the shuffled order); 0 picks one, recorded in the report
+	ConnectionMode   string        `json:"connectionMode"`   // pool, or dedicated to pin one connection per worker
+	IsolationLevel   string        `json:"isolationLevel"`   // Session isolation level, e.g. READ-COMMITTED; empty keeps the server default
+	TransactionMode  string        `json:"transactionMode"`  // none, commit (BEGIN/COMMIT) or rollback (BEGIN/ROLLBACK) around each execution
 	WarmupIterations int           `json:"warmupIterations"` // Warmup iterations to stabilize connection pool
 	Label            string        `json:"label"`            // Test run label (e.g., "before" or "after")
+	LabelFromGit     bool          `json:"labelFromGit"`     // Derive the label as <branch>-<short-sha> when none is given
+	GitSHA           string        `json:"gitSha,omitempty"` // Revision of the code under test, recorded in the report; FN_GIT_SHA when unset
 	Timeout          time.Duration `json:"timeoutSeconds"`   // Query timeout in seconds
 	Verbose          bool          `json:"verbose"`          // Verbose output
+	Quiet            bool          `json:"quiet"`            // Suppress logs and the console summary
+
+	FreshConnPerQuery   bool  `json:"freshConnPerQuery"`   // Open a new connection for every execution to measure connect cost
+	BenchConnect        int   `json:"benchConnect"`        // Before the run, open this many fresh connections to measure connect latency; 0 skips it
+	MeasureCold         bool  `json:"measureCold"`         // Report each query's first execution as its cold latency, apart from the warm statistics
+	ValidateOutput      bool  `json:"validateOutput"`      // Validate the JSON report against the embedded schema before writing
+	TagQueries          bool  `json:"tagQueries"`          // Prefix executed statements with a /* fn-analyzer ... */ correlation comment
+	CaptureSampleRows   int   `json:"captureSampleRows"`   // Store the first N result rows of each query's first iteration
+	CaptureColumnTypes  bool  `json:"captureColumnTypes"`  // Store the result column names and database types of each query's first iteration
+	MaxRows             int64 `json:"maxRows"`             // Stop reading and cancel a query once it returns this many rows; 0 means no cap
+	CollectExplainPlans bool  `json:"collectExplainPlans"` // Run EXPLAIN for each query after the run and flag plan warnings
+	ExplainPlanFiles    bool  `json:"explainPlanFiles"`    // Write each JSON explain plan to its own file and store only its path in the report
+	ProfileSlowest      bool  `json:"profileSlowest"`      // Re-run the slowest query with SHOW PROFILE after the run and record its stages
+	MeasureLocks        bool  `json:"measureLocks"`        // Run one query at a time and attribute the server's row lock waits to each
+	IncludeExecutions   bool  `json:"includeExecutions"`   // Write every execution to the JSON report, not just per-query aggregates
+
+	SampleCellMaxBytes int      `json:"sampleCellMaxBytes"`          // Sample row values longer than this are cut short
+	SampleMaskColumns  []string `json:"sampleMaskColumns,omitempty"` // Columns whose sample values are masked: globs, or regular expressions in slashes
+
+	MaxExecutionsInReport int  `json:"maxExecutionsInReport,omitempty"` // Cap on executions written per query to the JSON report
+	CompressReports       bool `json:"compressReports,omitempty"`       // Write JSON and CSV reports gzipped (.json.gz, .csv.gz)
+	StreamCSV             bool `json:"streamCsv,omitempty"`             // Append each query's CSV row to a partial report as soon as the query finishes
+
+	Formats            []string `json:"formats,omitempty"`            // Report formats to write: json, csv, html, md, junit
+	SummaryTopN        int      `json:"summaryTopN,omitempty"`        // Length of the ranked lists in the console summary (default 5)
+	DurationUnit       string   `json:"durationUnit,omitempty"`       // Unit for durations in the summary, CSV, HTML and Markdown: ms, us, ns or auto
+	CSVColumns         []string `json:"csvColumns,omitempty"`         // Pin the CSV reports to these columns, in this order; all columns when empty
+	CSVDelimiter       string   `json:"csvDelimiter,omitempty"`       // Field separator of the CSV reports, e.g. ";" for European Excel; a comma when empty
+	CSVBOM             bool     `json:"csvBom,omitempty"`             // Start the CSV reports with a UTF-8 byte order mark
+	NoSummary          bool     `json:"noSummary,omitempty"`          // Don't print the console summary
+	OutputNameTemplate string   `json:"outputNameTemplate,omitempty"` // Go template for report file names, e.g. {{.Label}}/{{.Timestamp}}-{{.Kind}}
+	DirPerRun          bool     `json:"dirPerRun,omitempty"`          // Write each run's files to its own <outputDir>/<label>-<timestamp>/ directory
+
+	Only []string `json:"only,omitempty"` // Run only queries whose names match one of these patterns
+	Skip []string `json:"skip,omitempty"` // Skip queries whose names match one of these patterns
+
+	Statements string `json:"statements,omitempty"` // Run only reads (SELECT) or writes (INSERT, UPDATE, DELETE)
+	StrictLint bool   `json:"strictLint,omitempty"` // Refuse to run a suite with lint warnings
+
+	// Refuse to load a suite with a query touching a table outside these
+	// patterns: globs, or regular expressions in slashes
+	AllowedTablesPattern string `json:"allowedTablesPattern,omitempty"` // Every table must match, e.g. "app.*"
+	DeniedTablesPattern  string `json:"deniedTablesPattern,omitempty"`  // No table may match, e.g. "*_pii"
+
+	FailOnNonDeterministic bool `json:"failOnNonDeterministic,omitempty"` // Fail the run if any query's row count varies between executions
+
+	WeightCoverage float64 `json:"weightCoverage,omitempty"` // Run only the top-weight queries covering this percent of total weight
+
+	CompareBaselineDir string `json:"compareBaselineDir,omitempty"` // Compare against the newest earlier report in this directory
+
+	CompareThresholdReport  bool    `json:"compareThresholdReport,omitempty"` // With each comparison, also write one listing only the queries that moved
+	CompareThresholdPercent float64 `json:"compareThresholdPercent"`          // How far a query's average latency must move to be listed
+
+	BeforeDSN string `json:"beforeDsn,omitempty"` // With afterDsn, run the suite against both targets and compare them in one invocation
+	AfterDSN  string `json:"afterDsn,omitempty"`
+
+	Schemas     []string `json:"schemas,omitempty"`     // Run each query containing {{schema}} once per schema, e.g. per tenant
+	SchemaQuery string   `json:"schemaQuery,omitempty"` // SQL returning more schema names in its first column, added to schemas
+
+	Workers          []string `json:"workers,omitempty"`          // Addresses of 'worker' processes to split the run's iterations and concurrency across
+	WorkerExecutions bool     `json:"workerExecutions,omitempty"` // Have workers return every execution rather than per-query aggregates
+
+	ComplexityRules ComplexityRules `json:"complexityRules"` // Thresholds for the complexity levels; recorded so runs classified differently can be told apart
+
+	ConnectRetry ConnectRetry `json:"connectRetry"` // Wait for a database that isn't accepting connections yet
+
+	RollbackWrites bool `json:"rollbackWrites,omitempty"` // Run INSERT, UPDATE and DELETE queries in a transaction that is rolled back, leaving the data as it was
+
+	MinServerVersion string `json:"minServerVersion,omitempty"` // Refuse to run against an older server, e.g. "8.0" or "MariaDB 10.6"
+
+	HealthCheckSeconds float64 `json:"healthCheckSeconds,omitempty"` // Ping idle pooled connections this often during the run and close bad ones; 0 disables
+
+	ConnectionLimit ConnectionLimit `json:"connectionLimit"` // Keep the run's connections within a share of the server's max_connections
+
+	Heatmap Heatmap `json:"heatmap"` // Time windows and latency buckets of the run's latency heatmap
+
+	PercentileMinSamples PercentileMinSamples `json:"percentileMinSamples"` // Fewer successful executions than this and a percentile is reported as n/a
+
+	PercentileMethod string `json:"percentileMethod"` // linear, or nearest-rank as reports before schema version 3 were computed
+
+	Alerts Alerts `json:"alerts"` // Rules checked against server metrics sampled during the run
+
+	Guardrails Guardrails `json:"guardrails"` // Pause dispatch while server metrics are over a threshold
+
+	Soak Soak `json:"soak"` // Repeat the suite for a fixed time, writing a report per snapshot
+
+	StepSummary StepSummary `json:"stepSummary"` // Markdown summary for a CI job page, such as GitHub Actions'
+
+	StatsD StatsD `json:"statsd"` // Send each execution to a statsd or Datadog agent as the run goes
+
+	SetupScripts         []string `json:"setupScripts,omitempty"`    // SQL files run in order before warmup, e.g. to seed data; a failure aborts the run
+	TeardownScripts      []string `json:"teardownScripts,omitempty"` // SQL files run in order after the reports are written
+	ScriptTimeoutSeconds float64  `json:"scriptTimeoutSeconds"`      // Timeout for each statement of the setup and teardown scripts
 }
 
-func LoadConfig(path string) (*Config, error) {
-	config := &Config{
-		DSN:              "root:password@tcp(localhost:3306)/database",
-		OutputDir:        "./performance-results",
-		Iterations:       50,
-		Concurrency:      5,
-		WarmupIterations: 100,
-		Label:            "baseline",
-		Timeout:          30 * time.Second,
-		Verbose:          false,
-	}
+// Soak repeats the suite back to back for DurationSeconds, finalizing a
+// snapshot report every SnapshotSeconds, to see whether latency degrades over
+// hours. Only one snapshot's executions are held at a time.
+type Soak struct {
+	DurationSeconds float64 `json:"durationSeconds,omitempty"` // 0 runs the suite once
+	SnapshotSeconds float64 `json:"snapshotSeconds"`
+}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		dir := filepath.Dir(path)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("couldn't create config directory: %w", err)
-		}
+// Enabled reports whether the run is a soak.
+func (s Soak) Enabled() bool {
+	return s.DurationSeconds > 0
+}
 
-		data, err := json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			return nil, fmt.Errorf("error creating default config: %w", err)
-		}
+// StepSummary appends a Markdown summary of each run to Path, as GitHub
+// Actions reads from $GITHUB_STEP_SUMMARY. Query tables stop after MaxRows
+// rows to stay under GitHub's size limit.
+type StepSummary struct {
+	Path    string `json:"path,omitempty"` // Empty uses $GITHUB_STEP_SUMMARY when it is set
+	MaxRows int    `json:"maxRows"`
+}
+
+// StatsD sends a timing per execution, and every FlushSeconds a gauge of each
+// query's error rate over that window, to a statsd agent over UDP. With
+// DatadogTags the query and Tags are sent with the DogStatsD tag extension;
+// plain statsd has no tags, so the query name goes in the metric name.
+type StatsD struct {
+	Host         string   `json:"host,omitempty"`        // Agent host; empty sends nothing
+	Port         int      `json:"port"`                  // Agent UDP port
+	Prefix       string   `json:"prefix"`                // Prepended to every metric name
+	Tags         []string `json:"tags,omitempty"`        // key:value tags on every metric; need datadogTags
+	DatadogTags  bool     `json:"datadogTags,omitempty"` // Use DogStatsD tags
+	FlushSeconds float64  `json:"flushSeconds"`          // Window of the error rate gauges
+}
+
+// Enabled reports whether metrics are sent.
+func (s StatsD) Enabled() bool {
+	return s.Host != ""
+}
+
+// Alerts samples server metrics during a run and checks them against rules
+// such as "bufferPoolHitRate < 95". Nothing is sampled without rules.
+type Alerts struct {
+	Rules           []string `json:"rules,omitempty"`
+	IntervalSeconds float64  `json:"intervalSeconds"`       // Time between samples
+	FailOnAlert     bool     `json:"failOnAlert,omitempty"` // Fail the run if any rule held
+}
 
-		if err := os.WriteFile(path, data, 0644); err != nil {
-			return nil, fmt.Errorf("error writing default config: %w", err)
+// Guardrails samples server metrics during a run and pauses dispatching new
+// executions while a rule such as "threadsRunning > 150 resume < 100" has
+// tripped, so a run against a server with live traffic backs off rather than
+// piling on. Nothing is sampled without rules.
+type Guardrails struct {
+	Rules           []string `json:"rules,omitempty"`
+	IntervalSeconds float64  `json:"intervalSeconds"` // Time between samples
+}
+
+// PercentileMinSamples are the fewest successful executions a query needs
+// before its p95 and p99 mean anything; with 3 samples, "p99" is just the
+// maximum. 0 disables the guard.
+type PercentileMinSamples struct {
+	P95 int `json:"p95"`
+	P99 int `json:"p99"`
+}
+
+// Heatmap sets the grid the run's executions are counted in: rows are time
+// windows since the run started, columns latency buckets.
+type Heatmap struct {
+	WindowSeconds float64   `json:"windowSeconds"` // Width of each time window
+	BucketsMs     []float64 `json:"bucketsMs"`     // Ascending bucket upper bounds; slower executions fall in a final overflow bucket
+}
+
+// Validate reports a window or bucket list the heatmap can't use.
+func (h Heatmap) Validate() error {
+	if h.WindowSeconds <= 0 {
+		return fmt.Errorf("windowSeconds must be positive, got %g", h.WindowSeconds)
+	}
+	if len(h.BucketsMs) == 0 {
+		return fmt.Errorf("bucketsMs must not be empty")
+	}
+	for i, b := range h.BucketsMs {
+		if b <= 0 || (i > 0 && b <= h.BucketsMs[i-1]) {
+			return fmt.Errorf("bucketsMs must be positive and ascending, got %v", h.BucketsMs)
 		}
+	}
+	return nil
+}
+
+// What to do when the run could open more connections than
+// ConnectionLimit.MaxFraction of the server's max_connections.
+const (
+	OnConnectionLimitClamp = "clamp" // Shrink the pool to the limit and warn
+	OnConnectionLimitError = "error" // Refuse to run
+)
 
-		fmt.Printf("Created default config file at %s\n", path)
-		return config, nil
+// ConnectionLimit keeps a run from exhausting the server's connections and
+// locking out applications that share it.
+type ConnectionLimit struct {
+	MaxFraction float64 `json:"maxFraction"` // Share of max_connections the run may open; 0 disables the check
+	OnExceed    string  `json:"onExceed"`    // clamp or error
+}
+
+// Validate reports a fraction or policy the check can't use.
+func (l ConnectionLimit) Validate() error {
+	if l.MaxFraction < 0 || l.MaxFraction > 1 {
+		return fmt.Errorf("maxFraction must be between 0 and 1, got %g", l.MaxFraction)
+	}
+	if l.OnExceed != OnConnectionLimitClamp && l.OnExceed != OnConnectionLimitError {
+		return fmt.Errorf("onExceed must be %s or %s, got %q", OnConnectionLimitClamp, OnConnectionLimitError, l.OnExceed)
+	}
+	return nil
+}
+
+// ConnectRetry controls retrying the initial connection, so the tool can start
+// alongside a database container that is still initializing.
+type ConnectRetry struct {
+	MaxAttempts    int     `json:"maxAttempts"`    // Connection attempts before giving up; 0 or 1 means no retry
+	BackoffSeconds float64 `json:"backoffSeconds"` // Delay before the first retry, doubling after each attempt
+	TimeoutSeconds float64 `json:"timeoutSeconds"` // Give up on each attempt after this long; 0 waits for the network to give up
+}
+
+// How percentiles and medians are read off the executions.
+const (
+	PercentileLinear      = "linear"       // Interpolate between the closest ranks (R-7)
+	PercentileNearestRank = "nearest-rank" // The value at index floor(n·p); reads high on small samples
+)
+
+// How a query's joins, subqueries and aggregates are counted.
+const (
+	ComplexityAnalyzerParser    = "parser"    // From the parse tree, falling back to the heuristic for statements the parser rejects
+	ComplexityAnalyzerHeuristic = "heuristic" // Keyword matching only, as before the parser was added
+)
+
+// ComplexityRules are the thresholds that map a query's joins and AND/OR
+// conditions to a complexity level. Window functions, unions, CTEs and
+// aggregation with HAVING always make a query high.
+type ComplexityRules struct {
+	HighJoins        int    `json:"highJoins"`          // More joins than this, with aggregation or a subquery, is high
+	HighConditions   int    `json:"highConditions"`     // More conditions than this is high
+	MediumJoins      int    `json:"mediumJoins"`        // More joins than this is medium
+	MediumConditions int    `json:"mediumConditions"`   // More conditions than this is medium
+	Analyzer         string `json:"analyzer,omitempty"` // parser or heuristic
+}
+
+// DefaultComplexityRules returns the thresholds used when the config file
+// doesn't override them.
+func DefaultComplexityRules() ComplexityRules {
+	return ComplexityRules{
+		HighJoins:        2,
+		HighConditions:   5,
+		MediumJoins:      1,
+		MediumConditions: 2,
+		Analyzer:         ComplexityAnalyzerParser,
+	}
+}
+
+// OrDefault returns r, or the default rules if r is unset, as in reports
+// written before the rules were configurable. Reports written before the
+// analyzer was configurable used the parser.
+func (r ComplexityRules) OrDefault() ComplexityRules {
+	if r == (ComplexityRules{}) {
+		return DefaultComplexityRules()
+	}
+	if r.Analyzer == "" {
+		r.Analyzer = ComplexityAnalyzerParser
+	}
+	return r
+}
+
+// MarshalJSON writes Timeout as seconds, fractional below a second, to match
+// its timeoutSeconds key.
+func (c Config) MarshalJSON() ([]byte, error) {
+	type alias Config
+	return json.Marshal(struct {
+		alias
+		Timeout float64 `json:"timeoutSeconds"`
+	}{
+		alias:   alias(c),
+		Timeout: c.Timeout.Seconds(),
+	})
+}
+
+// legacyTimeoutThreshold is the smallest timeoutSeconds read as nanoseconds,
+// as config files and reports written before it was seconds hold it: no one
+// times a query out after eleven days, and no one did after a millisecond.
+const legacyTimeoutThreshold = 1e6
+
+// UnmarshalJSON reads timeoutSeconds as seconds rather than nanoseconds, except
+// for the nanosecond values written by earlier versions.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type alias Config
+	aux := struct {
+		*alias
+		Timeout float64 `json:"timeoutSeconds"`
+	}{
+		alias:   (*alias)(c),
+		Timeout: c.Timeout.Seconds(),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.Timeout >= legacyTimeoutThreshold {
+		c.Timeout = time.Duration(aux.Timeout)
+		return nil
+	}
+	c.Timeout = time.Duration(math.Round(aux.Timeout * float64(time.Second)))
+	return nil
+}
+
+// ErrNotFound is returned by LoadConfig when the config file doesn't exist.
+var ErrNotFound = errors.New("config file not found")
+
+// Default returns the configuration used for any setting not present in the
+// config file.
+func Default() *Config {
+	return &Config{
+		DSN:                  "root:password@tcp(localhost:3306)/database",
+		QueriesFile:          "queries.json",
+		OutputDir:            "./performance-results",
+		Iterations:           50,
+		Concurrency:          5,
+		ExecutionOrder:       "round-robin",
+		ConnectionMode:       "pool",
+		TransactionMode:      "none",
+		WarmupIterations:     100,
+		Label:                "baseline",
+		Timeout:              30 * time.Second,
+		Verbose:              false,
+		IncludeExecutions:    true,
+		Formats:              []string{"json", "csv"},
+		DurationUnit:         "ms",
+		ComplexityRules:      DefaultComplexityRules(),
+		ConnectRetry:         ConnectRetry{MaxAttempts: 1, BackoffSeconds: 1, TimeoutSeconds: 10},
+		ConnectionLimit:      ConnectionLimit{MaxFraction: 0.5, OnExceed: OnConnectionLimitClamp},
+		PercentileMinSamples: PercentileMinSamples{P95: 20, P99: 100},
+		Alerts:               Alerts{IntervalSeconds: 5},
+		Guardrails:           Guardrails{IntervalSeconds: 5},
+		Soak:                 Soak{SnapshotSeconds: 600},
+		StepSummary:          StepSummary{MaxRows: 50},
+		StatsD:               StatsD{Port: 8125, Prefix: "fn_analyzer", FlushSeconds: 10},
+		ScriptTimeoutSeconds: 1800,
+		Heatmap: Heatmap{
+			WindowSeconds: 10,
+			BucketsMs:     []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+		},
+		CompareThresholdPercent: 10,
+		PercentileMethod:        PercentileLinear,
+		SampleCellMaxBytes:      256,
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	config := Default()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s (run 'fn-analyzer init' to create one)", ErrNotFound, path)
 	}
 
 	data, err := os.ReadFile(path)
@@ -75,5 +405,86 @@ func LoadConfig(path string) (*Config, error) {
 		config.WarmupIterations = 100
 	}
 
+	if _, err := ParseOutputNameTemplate(config.OutputNameTemplate); err != nil {
+		return nil, fmt.Errorf("invalid outputNameTemplate: %w", err)
+	}
+
+	if err := config.Heatmap.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid heatmap: %w", err)
+	}
+
+	if err := config.ConnectionLimit.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid connectionLimit: %w", err)
+	}
+
+	if config.Soak.DurationSeconds < 0 || config.Soak.SnapshotSeconds <= 0 {
+		return nil, fmt.Errorf("invalid soak: durationSeconds must not be negative and snapshotSeconds must be positive")
+	}
+
+	if config.ScriptTimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("invalid scriptTimeoutSeconds: must be positive, got %g", config.ScriptTimeoutSeconds)
+	}
+
+	if config.ConnectRetry.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("invalid connectRetry.timeoutSeconds: must not be negative, got %g", config.ConnectRetry.TimeoutSeconds)
+	}
+
+	if config.Alerts.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("invalid alerts: intervalSeconds must be positive, got %g", config.Alerts.IntervalSeconds)
+	}
+
+	if config.Guardrails.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("invalid guardrails: intervalSeconds must be positive, got %g", config.Guardrails.IntervalSeconds)
+	}
+
+	if config.PercentileMethod == "" {
+		config.PercentileMethod = PercentileLinear
+	}
+	if m := config.PercentileMethod; m != PercentileLinear && m != PercentileNearestRank {
+		return nil, fmt.Errorf("invalid percentileMethod: must be %s or %s, got %q", PercentileLinear, PercentileNearestRank, m)
+	}
+
+	if config.CompareThresholdPercent < 0 {
+		return nil, fmt.Errorf("invalid compareThresholdPercent: must not be negative, got %g", config.CompareThresholdPercent)
+	}
+
+	if a := config.ComplexityRules.Analyzer; a != "" && a != ComplexityAnalyzerParser && a != ComplexityAnalyzerHeuristic {
+		return nil, fmt.Errorf("invalid complexityRules: analyzer must be %s or %s, got %q", ComplexityAnalyzerParser, ComplexityAnalyzerHeuristic, a)
+	}
+
+	if config.StatsD.Enabled() && (config.StatsD.Port <= 0 || config.StatsD.Port > 65535 || config.StatsD.FlushSeconds <= 0) {
+		return nil, fmt.Errorf("invalid statsd: port must be between 1 and 65535 and flushSeconds must be positive")
+	}
+
+	if config.HealthCheckSeconds < 0 {
+		return nil, fmt.Errorf("invalid healthCheckSeconds: must not be negative, got %g", config.HealthCheckSeconds)
+	}
+
+	if config.SampleCellMaxBytes <= 0 {
+		return nil, fmt.Errorf("invalid sampleCellMaxBytes: must be positive, got %d", config.SampleCellMaxBytes)
+	}
+
+	if config.StepSummary.MaxRows <= 0 {
+		return nil, fmt.Errorf("invalid stepSummary: maxRows must be positive, got %d", config.StepSummary.MaxRows)
+	}
+
 	return config, nil
 }
+
+// Save writes cfg to path as indented JSON, creating the parent directory.
+func Save(cfg *Config, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("couldn't create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing config: %w", err)
+	}
+
+	return nil
+}
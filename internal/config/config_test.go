@@ -0,0 +1,98 @@
+// internal/config/config_test.go
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigTimeoutJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want time.Duration
+	}{
+		{"seconds", `{"timeoutSeconds": 30}`, 30 * time.Second},
+		{"fractional seconds", `{"timeoutSeconds": 0.5}`, 500 * time.Millisecond},
+		{"legacy nanoseconds", `{"timeoutSeconds": 30000000000}`, 30 * time.Second},
+		{"unset keeps default", `{}`, Default().Timeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			if err := json.Unmarshal([]byte(tt.json), cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Timeout != tt.want {
+				t.Errorf("Timeout = %v, want %v", cfg.Timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTimeoutRoundTrip(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    float64 // timeoutSeconds as written
+	}{
+		{500 * time.Millisecond, 0.5},
+		{1500 * time.Millisecond, 1.5},
+		{100 * time.Millisecond, 0.1},
+		{time.Millisecond, 0.001},
+		{30 * time.Second, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timeout.String(), func(t *testing.T) {
+			cfg := Default()
+			cfg.Timeout = tt.timeout
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if fields["timeoutSeconds"] != tt.want {
+				t.Errorf("timeoutSeconds = %v, want %v", fields["timeoutSeconds"], tt.want)
+			}
+
+			var read Config
+			if err := json.Unmarshal(data, &read); err != nil {
+				t.Fatal(err)
+			}
+			if read.Timeout != tt.timeout {
+				t.Errorf("Timeout = %v after a round trip, want %v", read.Timeout, tt.timeout)
+			}
+		})
+	}
+}
+
+// A sub-second timeout saved to a config file must not come back as the
+// default, which LoadConfig substitutes for 0.
+func TestLoadConfigSubSecondTimeout(t *testing.T) {
+	cfg := Default()
+	cfg.Timeout = 250 * time.Millisecond
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Timeout != cfg.Timeout {
+		t.Errorf("Timeout = %v, want %v", loaded.Timeout, cfg.Timeout)
+	}
+}
@@ -0,0 +1,48 @@
+// internal/config/naming.go
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultOutputNameTemplate reproduces the historical report names, e.g.
+// performance-baseline-20240102-150405.
+const DefaultOutputNameTemplate = "{{.Kind}}-{{.Label}}-{{.Timestamp}}"
+
+// OutputNameFields are the values available to OutputNameTemplate. The file
+// extension is appended to the rendered name automatically.
+type OutputNameFields struct {
+	Kind      string // performance, performance-detailed, summary or comparison
+	Label     string // Run label; "<before>-vs-<after>" for comparisons
+	Timestamp string // Run time as 20060102-150405
+	Format    string // Report format: json, csv, html, md or junit
+	Hostname  string // Host the analyzer ran on
+	Driver    string // Database driver, e.g. mysql
+	GitSHA    string // Short commit of the code under test (gitSha), else of the working directory, or "nogit"
+}
+
+// ParseOutputNameTemplate parses an output name template and checks that it
+// only refers to fields of OutputNameFields.
+func ParseOutputNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultOutputNameTemplate
+	}
+
+	tmpl, err := template.New("outputName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := OutputNameFields{Kind: "performance", Label: "label", Timestamp: "20060102-150405", Format: "json", Hostname: "host", Driver: "mysql", GitSHA: "0000000"}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, sample); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return nil, fmt.Errorf("template renders an empty name")
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,109 @@
+// internal/database/alerts.go
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// alertMetrics are the DBMetrics fields alert rules can test, by their JSON
+// names. A metric that isn't available in a sample reports false.
+var alertMetrics = map[string]func(DBMetrics) (float64, bool){
+	// The interval rate: the cumulative one covers the server's whole uptime
+	// and barely moves during a run.
+	"bufferPoolHitRate": func(m DBMetrics) (float64, bool) {
+		if m.IntervalBufferPoolHitRate == nil {
+			return 0, false
+		}
+		return *m.IntervalBufferPoolHitRate, true
+	},
+	"threadsRunning":          func(m DBMetrics) (float64, bool) { return float64(m.ThreadsRunning), true },
+	"threadsConnected":        func(m DBMetrics) (float64, bool) { return float64(m.ThreadsConnected), true },
+	"longRunningTransactions": func(m DBMetrics) (float64, bool) { return float64(m.LongRunningTransCount), true },
+	"activeTransactions":      func(m DBMetrics) (float64, bool) { return float64(m.ActiveTransactions), true },
+	"innodbHistoryListLength": func(m DBMetrics) (float64, bool) { return float64(m.InnodbHistoryListLen), true },
+	"slowQueries":             func(m DBMetrics) (float64, bool) { return float64(m.SlowQueries), true },
+}
+
+var alertOperators = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// AlertRule is a threshold on one server metric, e.g. "threadsRunning > 200".
+type AlertRule struct {
+	Expr      string
+	Metric    string
+	Op        string
+	Threshold float64
+}
+
+// ParseAlertRule parses a rule of the form "<metric> <op> <number>", where op
+// is one of < <= > >= == !=.
+func ParseAlertRule(expr string) (AlertRule, error) {
+	rule := AlertRule{Expr: strings.TrimSpace(expr)}
+
+	for _, op := range alertOperators {
+		metric, threshold, found := strings.Cut(rule.Expr, op)
+		if !found {
+			continue
+		}
+		rule.Metric, rule.Op = strings.TrimSpace(metric), op
+
+		if _, ok := alertMetrics[rule.Metric]; !ok {
+			return rule, fmt.Errorf("unknown metric %q in alert rule %q (known: %s)", rule.Metric, rule.Expr, strings.Join(AlertMetricNames(), ", "))
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(threshold), 64)
+		if err != nil {
+			return rule, fmt.Errorf("invalid threshold in alert rule %q: %w", rule.Expr, err)
+		}
+		rule.Threshold = value
+		return rule, nil
+	}
+	return rule, fmt.Errorf("invalid alert rule %q: want <metric> <op> <number>", rule.Expr)
+}
+
+// ParseAlertRules parses every rule, failing on the first invalid one.
+func ParseAlertRules(exprs []string) ([]AlertRule, error) {
+	rules := make([]AlertRule, 0, len(exprs))
+	for _, expr := range exprs {
+		rule, err := ParseAlertRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// AlertMetricNames returns the metrics alert rules can test, sorted.
+func AlertMetricNames() []string {
+	names := make([]string, 0, len(alertMetrics))
+	for name := range alertMetrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Check reports the rule's metric in m and whether the rule holds. It never
+// holds when the metric isn't in the sample.
+func (r AlertRule) Check(m DBMetrics) (float64, bool) {
+	value, ok := alertMetrics[r.Metric](m)
+	if !ok {
+		return 0, false
+	}
+	switch r.Op {
+	case "<":
+		return value, value < r.Threshold
+	case "<=":
+		return value, value <= r.Threshold
+	case ">":
+		return value, value > r.Threshold
+	case ">=":
+		return value, value >= r.Threshold
+	case "==":
+		return value, value == r.Threshold
+	default:
+		return value, value != r.Threshold
+	}
+}
@@ -0,0 +1,124 @@
+// internal/database/connbench.go
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+	"github.com/go-sql-driver/mysql"
+)
+
+// ConnectLatency summarizes one batch of connection attempts: opened one
+// after another or all at once, with or without TLS.
+type ConnectLatency struct {
+	Mode       string        `json:"mode"` // sequential or concurrent
+	TLS        bool          `json:"tls"`
+	Attempts   int           `json:"attempts"`
+	Failures   int           `json:"failures"`
+	Min        time.Duration `json:"minNs"`
+	Avg        time.Duration `json:"avgNs"`
+	P95        time.Duration `json:"p95Ns"`
+	Max        time.Duration `json:"maxNs"`
+	FirstError string        `json:"firstError,omitempty"`
+}
+
+// ConnectionBenchmark is the cost of establishing new connections: TCP
+// connect, handshake, TLS negotiation and authentication, without any query.
+type ConnectionBenchmark struct {
+	Connections int              `json:"connections"` // Connections opened per batch
+	Concurrency int              `json:"concurrency"` // Parallel openers in the concurrent batches
+	Batches     []ConnectLatency `json:"batches"`
+}
+
+// BenchmarkConnect opens n fresh connections to dsn sequentially and then n
+// more across concurrency workers, timing each through the driver directly
+// so no pool is involved. When the DSN enables TLS, both batches are
+// repeated with TLS turned off to show what it costs.
+func BenchmarkConnect(ctx context.Context, dsn string, n, concurrency int) (ConnectionBenchmark, error) {
+	bench := ConnectionBenchmark{Connections: n, Concurrency: max(concurrency, 1)}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return bench, fmt.Errorf("error parsing DSN: %w", err)
+	}
+
+	configs := []*mysql.Config{cfg}
+	if cfg.TLS != nil {
+		plain := cfg.Clone()
+		plain.TLS = nil
+		plain.TLSConfig = "false"
+		configs = append(configs, plain)
+	}
+
+	for _, c := range configs {
+		connector, err := mysql.NewConnector(c)
+		if err != nil {
+			return bench, fmt.Errorf("error creating connector: %w", err)
+		}
+		tls := c.TLS != nil
+		bench.Batches = append(bench.Batches,
+			connectBatch(ctx, connector, "sequential", tls, n, 1),
+			connectBatch(ctx, connector, "concurrent", tls, n, bench.Concurrency))
+	}
+
+	return bench, nil
+}
+
+func connectBatch(ctx context.Context, c driver.Connector, mode string, tls bool, n, workers int) ConnectLatency {
+	result := ConnectLatency{Mode: mode, TLS: tls}
+
+	var mu sync.Mutex
+	var durations []time.Duration
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				startTime := time.Now()
+				conn, err := c.Connect(ctx)
+				elapsed := time.Since(startTime)
+
+				mu.Lock()
+				if err != nil {
+					result.Failures++
+					if result.FirstError == "" {
+						result.FirstError = err.Error()
+					}
+				} else {
+					durations = append(durations, elapsed)
+				}
+				mu.Unlock()
+
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	for range n {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- struct{}{}
+		result.Attempts++
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(durations) > 0 {
+		stats := utils.CalculateStats(durations)
+		result.Min = stats.Min
+		result.Avg = stats.Mean
+		result.P95 = stats.P95
+		result.Max = stats.Max
+	}
+	return result
+}
@@ -2,25 +2,94 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-func Connect(dsn string, concurrency int) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// DriverName is the database/sql driver every connection is opened with.
+const DriverName = "mysql"
+
+// maxRetryBackoff caps the doubling delay between connection attempts.
+const maxRetryBackoff = 30 * time.Second
+
+// ConnectRetry controls how long Connect and TestConnection wait for a server
+// that isn't accepting connections yet, e.g. a MySQL container still starting.
+// The delay between attempts starts at Backoff and doubles up to
+// maxRetryBackoff. MaxAttempts of 0 or 1 means a single attempt. Each attempt
+// gives up after Timeout, if set, rather than waiting minutes for TCP on a
+// host that drops packets.
+type ConnectRetry struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Timeout     time.Duration
+}
+
+// ErrConnectTimeout is returned, wrapped, when a connection attempt takes
+// longer than ConnectRetry.Timeout.
+var ErrConnectTimeout = errors.New("timed out connecting to the database")
+
+// withRetry calls attempt until it succeeds, the attempts run out or ctx is
+// done. Errors returned by the server itself, such as access denied, mean it
+// is already up and are not retried.
+func withRetry(ctx context.Context, retry ConnectRetry, attempt func(context.Context) error) error {
+	backoff := retry.Backoff
+	for n := 1; ; n++ {
+		err := attemptWithTimeout(ctx, retry.Timeout, attempt)
+		var serverErr *mysql.MySQLError
+		if err == nil || n >= retry.MaxAttempts || errors.As(err, &serverErr) {
+			return err
+		}
+
+		log.Printf("Database not ready (attempt %d/%d): %v; retrying in %v", n, retry.MaxAttempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// attemptWithTimeout calls attempt, cancelling it after timeout if set. An
+// attempt cut short by the timeout returns ErrConnectTimeout instead of the
+// driver's error, which doesn't say why it gave up.
+func attemptWithTimeout(ctx context.Context, timeout time.Duration, attempt func(context.Context) error) error {
+	if timeout <= 0 {
+		return attempt(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := attempt(attemptCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %v; is the host reachable?", ErrConnectTimeout, timeout)
+	}
+	return err
+}
+
+// MaxOpenConns is the most connections Connect's pool opens for concurrency
+// workers.
+func MaxOpenConns(concurrency int) int {
+	return concurrency * 2
+}
+
+func Connect(dsn string, concurrency int, retry ConnectRetry) (*sql.DB, error) {
+	db, err := sql.Open(DriverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	db.SetMaxOpenConns(concurrency * 2)
+	db.SetMaxOpenConns(MaxOpenConns(concurrency))
 	db.SetMaxIdleConns(concurrency)
 	db.SetConnMaxLifetime(time.Minute * 5)
 
-	if err := db.Ping(); err != nil {
+	if err := withRetry(context.Background(), retry, db.PingContext); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("error pinging database: %w", err)
 	}
@@ -28,30 +97,106 @@ func Connect(dsn string, concurrency int) (*sql.DB, error) {
 	return db, nil
 }
 
-func TestConnection(dsn string) error {
-	log.Println("Testing database connection...")
+// PoolLimit records how the pool was sized against the server's connection
+// limit.
+type PoolLimit struct {
+	ServerMaxConnections int  `json:"serverMaxConnections"`
+	Allowed              int  `json:"allowed"`      // Connections the run may open under connectionLimit
+	Requested            int  `json:"requested"`    // Pool size the concurrency asked for
+	MaxOpenConns         int  `json:"maxOpenConns"` // Pool size the run used
+	Clamped              bool `json:"clamped,omitempty"`
+}
 
-	db, err := sql.Open("mysql", dsn)
+// ServerMaxConnections reads the server's max_connections.
+func ServerMaxConnections(db *sql.DB) (int, error) {
+	var name string
+	var value int
+	if err := db.QueryRow("SHOW VARIABLES LIKE 'max_connections'").Scan(&name, &value); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// ClampPool shrinks db's pool to at most maxOpen connections.
+func ClampPool(db *sql.DB, maxOpen, concurrency int) {
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(min(concurrency, maxOpen))
+}
+
+// OpenSingle opens a dedicated, unpooled connection and verifies it with a
+// ping. The caller is responsible for closing it.
+func OpenSingle(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(DriverName, dsn)
 	if err != nil {
-		return fmt.Errorf("error opening database connection: %w", err)
+		return nil, fmt.Errorf("error opening database connection: %w", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error pinging database: %w", err)
+	}
+
+	return db, nil
+}
+
+// ProbeResult is the outcome of a successful Probe.
+type ProbeResult struct {
+	PingTime time.Duration `json:"pingTimeNs"`
+	Version  string        `json:"version,omitempty"`
+}
+
+// Probe opens a connection to dsn, pings it and reads the server version.
+// A version lookup failure is not an error; the version is left empty.
+func Probe(ctx context.Context, dsn string) (ProbeResult, error) {
+	var result ProbeResult
+
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return result, fmt.Errorf("error opening database connection: %w", err)
 	}
 	defer db.Close()
 
 	startTime := time.Now()
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("error connecting to database: %w", err)
+	if err := db.PingContext(ctx); err != nil {
+		return result, fmt.Errorf("error connecting to database: %w", err)
 	}
-	pingTime := time.Since(startTime)
+	result.PingTime = time.Since(startTime)
 
-	log.Printf("✓ Database connection successful! (Ping time: %v)", pingTime)
+	db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&result.Version)
 
-	var version string
-	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
-		log.Printf("Warning: Could not get database version: %v", err)
+	return result, nil
+}
+
+func TestConnection(dsn string, retry ConnectRetry) error {
+	log.Println("Testing database connection...")
+
+	var probe ProbeResult
+	err := withRetry(context.Background(), retry, func(ctx context.Context) error {
+		var err error
+		probe, err = Probe(ctx, dsn)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✓ Database connection successful! (Ping time: %v)", probe.PingTime)
+
+	if probe.Version == "" {
+		log.Printf("Warning: Could not get database version")
 	} else {
-		log.Printf("✓ Connected to MySQL server version: %s", version)
+		log.Printf("✓ Connected to MySQL server version: %s", probe.Version)
 	}
 
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return fmt.Errorf("error opening database connection: %w", err)
+	}
+	defer db.Close()
+
 	info, err := GetConnectionInfo(db)
 	if err != nil {
 		log.Printf("Warning: Could not get detailed connection info: %v", err)
@@ -65,7 +210,7 @@ func TestConnection(dsn string) error {
 		log.Printf("  - Questions per second: %.2f", info.QuestionsPerSec)
 	}
 
-	startTime = time.Now()
+	startTime := time.Now()
 	rows, err := db.Query("SELECT 1")
 	if err != nil {
 		log.Printf("Warning: Simple query test failed: %v", err)
@@ -100,6 +245,20 @@ type ConnectionInfo struct {
 	SlowQueries      int     `json:"slowQueries"`
 	Uptime           int     `json:"uptimeSeconds"`
 	QuestionsPerSec  float64 `json:"questionsPerSecond"`
+
+	PoolLimit *PoolLimit `json:"poolLimit,omitempty"` // Pool size checked against max_connections
+
+	// Filled in after the run
+	ConnectionMode string        `json:"connectionMode,omitempty"` // pool, dedicated or fresh
+	PoolWait       time.Duration `json:"poolWaitNs,omitempty"`     // Time executions spent waiting for a pooled connection
+	Reconnects     int           `json:"reconnects,omitempty"`     // Dedicated connections replaced after dying
+
+	// Background checks of idle pooled connections, with healthCheckSeconds
+	HealthChecks         int `json:"healthChecks,omitempty"`         // Idle connections pinged
+	HealthCheckEvictions int `json:"healthCheckEvictions,omitempty"` // Connections that failed a ping and were closed
+
+	// Change in the server's scan and join counters over the run
+	Workload *WorkloadCounters `json:"workloadCounters,omitempty"`
 }
 
 func GetConnectionInfo(db *sql.DB) (ConnectionInfo, error) {
@@ -0,0 +1,89 @@
+// internal/database/guardrails.go
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GuardrailRule pauses a run while the server is over a threshold, e.g.
+// "threadsRunning > 150 resume < 100": dispatch pauses once a sample has
+// more than 150 threads running and resumes once one has fewer than 100.
+// Without a resume clause it resumes as soon as the pause condition no longer
+// holds.
+type GuardrailRule struct {
+	Expr   string
+	Pause  AlertRule
+	Resume *AlertRule // On the same metric; nil resumes when Pause stops holding
+}
+
+// ParseGuardrailRule parses a rule of the form
+// "<metric> <op> <number> [resume <op> <number>]".
+func ParseGuardrailRule(expr string) (GuardrailRule, error) {
+	rule := GuardrailRule{Expr: strings.TrimSpace(expr)}
+
+	pause, resume, hasResume := strings.Cut(rule.Expr, " resume ")
+	var err error
+	if rule.Pause, err = ParseAlertRule(pause); err != nil {
+		return rule, fmt.Errorf("invalid guardrail %q: %w", rule.Expr, err)
+	}
+	if !hasResume {
+		return rule, nil
+	}
+
+	r, err := ParseAlertRule(rule.Pause.Metric + " " + strings.TrimSpace(resume))
+	if err != nil {
+		return rule, fmt.Errorf("invalid guardrail %q: want resume <op> <number>", rule.Expr)
+	}
+	if !recovers(rule.Pause, r) {
+		return rule, fmt.Errorf("invalid guardrail %q: resume %s %g must be on the other side of the pause threshold", rule.Expr, r.Op, r.Threshold)
+	}
+	rule.Resume = &r
+	return rule, nil
+}
+
+// ParseGuardrailRules parses every rule, failing on the first invalid one.
+func ParseGuardrailRules(exprs []string) ([]GuardrailRule, error) {
+	rules := make([]GuardrailRule, 0, len(exprs))
+	for _, expr := range exprs {
+		rule, err := ParseGuardrailRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Trips reports the rule's metric in m and whether it calls for a pause.
+func (r GuardrailRule) Trips(m DBMetrics) (float64, bool) {
+	return r.Pause.Check(m)
+}
+
+// Clears reports the rule's metric in m and whether a pause it called for
+// can end. A sample without the metric clears nothing.
+func (r GuardrailRule) Clears(m DBMetrics) (float64, bool) {
+	value, ok := alertMetrics[r.Pause.Metric](m)
+	if !ok {
+		return 0, false
+	}
+	if r.Resume != nil {
+		return r.Resume.Check(m)
+	}
+	_, holds := r.Pause.Check(m)
+	return value, !holds
+}
+
+// recovers reports whether resume only holds once the metric is back on the
+// safe side of pause's threshold, so a paused sample can't resume straight
+// away.
+func recovers(pause, resume AlertRule) bool {
+	switch pause.Op {
+	case ">", ">=":
+		return (resume.Op == "<" || resume.Op == "<=") && resume.Threshold <= pause.Threshold
+	case "<", "<=":
+		return (resume.Op == ">" || resume.Op == ">=") && resume.Threshold >= pause.Threshold
+	default:
+		return true
+	}
+}
@@ -0,0 +1,102 @@
+// internal/database/health.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds on one health check. A connection that takes longer than
+// healthPingTimeout to answer a ping is as bad as one that errors.
+const (
+	healthAcquireTimeout = 50 * time.Millisecond
+	healthPingTimeout    = time.Second
+)
+
+// HealthChecker pings a pool's idle connections in the background and
+// evicts those that fail, so a connection that went stale between executions
+// is found by the checker rather than by a measured execution.
+type HealthChecker struct {
+	checked atomic.Int64
+	evicted atomic.Int64
+	done    chan struct{}
+}
+
+// StartHealthChecker checks db's idle connections every interval until ctx
+// is done. Wait for the checker to stop before closing db.
+func StartHealthChecker(ctx context.Context, db *sql.DB, interval time.Duration) *HealthChecker {
+	h := &HealthChecker{done: make(chan struct{})}
+
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.sweep(ctx, db)
+			}
+		}
+	}()
+
+	return h
+}
+
+// Wait blocks until the checker has stopped.
+func (h *HealthChecker) Wait() {
+	<-h.done
+}
+
+// Checked returns how many connections have been pinged.
+func (h *HealthChecker) Checked() int {
+	return int(h.checked.Load())
+}
+
+// Evicted returns how many connections failed their ping and were closed.
+func (h *HealthChecker) Evicted() int {
+	return int(h.evicted.Load())
+}
+
+// sweep pings as many connections as the pool holds idle. They are all held
+// until the sweep ends so each is a different connection; a worker that needs
+// one meanwhile waits for it or opens another, as the pool allows. If none
+// is available quickly the sweep ends early rather than hold up the run.
+func (h *HealthChecker) sweep(ctx context.Context, db *sql.DB) {
+	idle := db.Stats().Idle
+	conns := make([]*sql.Conn, 0, idle)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for range idle {
+		acquireCtx, cancel := context.WithTimeout(ctx, healthAcquireTimeout)
+		conn, err := db.Conn(acquireCtx)
+		cancel()
+		if err != nil {
+			return
+		}
+		conns = append(conns, conn)
+
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		err = conn.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		h.checked.Add(1)
+		if err != nil {
+			// ErrBadConn from Raw makes the pool close the connection
+			// instead of handing it out again.
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+			h.evicted.Add(1)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -10,30 +11,38 @@ import (
 )
 
 type DBMetrics struct {
-	ThreadsRunning         int     `json:"threadsRunning"`
-	ThreadsConnected       int     `json:"threadsConnected"`
-	ThreadsCreated         int     `json:"threadsCreated"`
-	OpenTables             int     `json:"openTables"`
-	OpenFiles              int     `json:"openFiles"`
-	SlowQueries            int     `json:"slowQueries"`
-	InnodbRowsRead         int64   `json:"innodbRowsRead"`
-	InnodbRowsInserted     int64   `json:"innodbRowsInserted"`
-	InnodbRowsUpdated      int64   `json:"innodbRowsUpdated"`
-	InnodbRowsDeleted      int64   `json:"innodbRowsDeleted"`
-	QPS                    float64 `json:"queriesPerSecond"`
-	LockTimeAvg            float64 `json:"avgLockTimeMs"`
-	TableCacheHitRate      float64 `json:"tableCacheHitRate"`
-	BufferPoolHitRate      float64 `json:"bufferPoolHitRate"`
-	DeadlocksTotal         int     `json:"deadlocksTotal"`
-	ActiveTransactions     int     `json:"activeTransactions"`
-	MemoryUsedBytes        int64   `json:"memoryUsedBytes"`
-	LongRunningTransCount  int     `json:"longRunningTransactions"`
-	InnodbHistoryListLen   int     `json:"innodbHistoryListLength"`
-	InnodbBufferPoolStatus string  `json:"innodbBufferPoolStatus"`
+	Timestamp              time.Time `json:"timestamp"`
+	ThreadsRunning         int       `json:"threadsRunning"`
+	ThreadsConnected       int       `json:"threadsConnected"`
+	ThreadsCreated         int       `json:"threadsCreated"`
+	OpenTables             int       `json:"openTables"`
+	OpenFiles              int       `json:"openFiles"`
+	SlowQueries            int       `json:"slowQueries"`
+	InnodbRowsRead         int64     `json:"innodbRowsRead"`
+	InnodbRowsInserted     int64     `json:"innodbRowsInserted"`
+	InnodbRowsUpdated      int64     `json:"innodbRowsUpdated"`
+	InnodbRowsDeleted      int64     `json:"innodbRowsDeleted"`
+	QPS                    float64   `json:"queriesPerSecond"`
+	LockTimeAvg            float64   `json:"avgLockTimeMs"`
+	TableCacheHitRate      float64   `json:"tableCacheHitRate"`
+	BufferPoolHitRate      float64   `json:"bufferPoolHitRate"`
+	DeadlocksTotal         int       `json:"deadlocksTotal"`
+	ActiveTransactions     int       `json:"activeTransactions"`
+	MemoryUsedBytes        int64     `json:"memoryUsedBytes"`
+	LongRunningTransCount  int       `json:"longRunningTransactions"`
+	InnodbHistoryListLen   int       `json:"innodbHistoryListLength"`
+	InnodbBufferPoolStatus string    `json:"innodbBufferPoolStatus"`
+
+	// Buffer pool hit rate (percent) since the previous sample of a
+	// collector; nil for a single snapshot
+	IntervalBufferPoolHitRate *float64 `json:"intervalBufferPoolHitRate,omitempty"`
+
+	bufferPoolReadRequests int64
+	bufferPoolReads        int64
 }
 
 func GetDetailedMetrics(db *sql.DB) (DBMetrics, error) {
-	metrics := DBMetrics{}
+	metrics := DBMetrics{Timestamp: time.Now()}
 
 	rows, err := db.Query("SHOW GLOBAL STATUS")
 	if err != nil {
@@ -78,6 +87,7 @@ func GetDetailedMetrics(db *sql.DB) (DBMetrics, error) {
 			var requests, diskReads int64
 			fmt.Sscanf(readRequests, "%d", &requests)
 			fmt.Sscanf(reads, "%d", &diskReads)
+			metrics.bufferPoolReadRequests, metrics.bufferPoolReads = requests, diskReads
 			if requests > 0 {
 				metrics.BufferPoolHitRate = (1.0 - float64(diskReads)/float64(requests)) * 100.0
 			}
@@ -128,18 +138,232 @@ func GetDetailedMetrics(db *sql.DB) (DBMetrics, error) {
 	return metrics, nil
 }
 
-func RunMetricsCollector(db *sql.DB, interval time.Duration, metricsCallback func(DBMetrics)) {
+// WorkloadCounters holds the GLOBAL STATUS counters that show how much
+// scanning a workload caused. As a diff between two snapshots it covers every
+// session on the server, not only the analyzer's.
+type WorkloadCounters struct {
+	HandlerReadFirst   int64 `json:"handlerReadFirst"`
+	HandlerReadKey     int64 `json:"handlerReadKey"`
+	HandlerReadLast    int64 `json:"handlerReadLast"`
+	HandlerReadNext    int64 `json:"handlerReadNext"`
+	HandlerReadPrev    int64 `json:"handlerReadPrev"`
+	HandlerReadRnd     int64 `json:"handlerReadRnd"`
+	HandlerReadRndNext int64 `json:"handlerReadRndNext"` // Rows read by table scans
+	SelectScan         int64 `json:"selectScan"`         // Joins that fully scanned the first table
+	SelectFullJoin     int64 `json:"selectFullJoin"`     // Joins that scanned a table without an index
+	SortScan           int64 `json:"sortScan"`           // Sorts done by scanning a table
+}
+
+// GetWorkloadCounters snapshots the current workload counters.
+func GetWorkloadCounters(db *sql.DB) (WorkloadCounters, error) {
+	var counters WorkloadCounters
+
+	rows, err := db.Query("SHOW GLOBAL STATUS WHERE Variable_name LIKE 'Handler_read_%' OR Variable_name IN ('Select_scan', 'Select_full_join', 'Sort_scan')")
+	if err != nil {
+		return counters, fmt.Errorf("error getting global status: %w", err)
+	}
+	defer rows.Close()
+
+	statusVars := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return counters, err
+		}
+		statusVars[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return counters, err
+	}
+
+	parseIntVar64(&counters.HandlerReadFirst, statusVars, "Handler_read_first")
+	parseIntVar64(&counters.HandlerReadKey, statusVars, "Handler_read_key")
+	parseIntVar64(&counters.HandlerReadLast, statusVars, "Handler_read_last")
+	parseIntVar64(&counters.HandlerReadNext, statusVars, "Handler_read_next")
+	parseIntVar64(&counters.HandlerReadPrev, statusVars, "Handler_read_prev")
+	parseIntVar64(&counters.HandlerReadRnd, statusVars, "Handler_read_rnd")
+	parseIntVar64(&counters.HandlerReadRndNext, statusVars, "Handler_read_rnd_next")
+	parseIntVar64(&counters.SelectScan, statusVars, "Select_scan")
+	parseIntVar64(&counters.SelectFullJoin, statusVars, "Select_full_join")
+	parseIntVar64(&counters.SortScan, statusVars, "Sort_scan")
+
+	return counters, nil
+}
+
+// Sub returns the change in each counter since before.
+func (c WorkloadCounters) Sub(before WorkloadCounters) WorkloadCounters {
+	return WorkloadCounters{
+		HandlerReadFirst:   c.HandlerReadFirst - before.HandlerReadFirst,
+		HandlerReadKey:     c.HandlerReadKey - before.HandlerReadKey,
+		HandlerReadLast:    c.HandlerReadLast - before.HandlerReadLast,
+		HandlerReadNext:    c.HandlerReadNext - before.HandlerReadNext,
+		HandlerReadPrev:    c.HandlerReadPrev - before.HandlerReadPrev,
+		HandlerReadRnd:     c.HandlerReadRnd - before.HandlerReadRnd,
+		HandlerReadRndNext: c.HandlerReadRndNext - before.HandlerReadRndNext,
+		SelectScan:         c.SelectScan - before.SelectScan,
+		SelectFullJoin:     c.SelectFullJoin - before.SelectFullJoin,
+		SortScan:           c.SortScan - before.SortScan,
+	}
+}
+
+// ServerCounters holds the GLOBAL STATUS counters that summarize the load a
+// run put on the server. Like WorkloadCounters, a diff between two snapshots
+// covers every session on the server.
+type ServerCounters struct {
+	Questions             int64 `json:"questions"`
+	InnodbRowsRead        int64 `json:"innodbRowsRead"`
+	CreatedTmpDiskTables  int64 `json:"createdTmpDiskTables"`
+	SelectFullJoin        int64 `json:"selectFullJoin"`
+	SortMergePasses       int64 `json:"sortMergePasses"`
+	InnodbBufferPoolReads int64 `json:"innodbBufferPoolReads"` // Page reads that missed the buffer pool and went to disk
+	BytesSent             int64 `json:"bytesSent"`
+	BytesReceived         int64 `json:"bytesReceived"`
+
+	InnodbBufferPoolReadRequests int64 `json:"innodbBufferPoolReadRequests"` // Logical page reads, served from memory or disk
+}
+
+// BufferPoolHitRate is the fraction of page reads served from the buffer
+// pool. On a delta it covers just that window, unlike the cumulative rate
+// since server start. It reports false when there were no reads.
+func (c ServerCounters) BufferPoolHitRate() (float64, bool) {
+	if c.InnodbBufferPoolReadRequests <= 0 {
+		return 0, false
+	}
+	return 1 - float64(c.InnodbBufferPoolReads)/float64(c.InnodbBufferPoolReadRequests), true
+}
+
+// GetServerCounters snapshots the current server counters.
+func GetServerCounters(db *sql.DB) (ServerCounters, error) {
+	var counters ServerCounters
+
+	rows, err := db.Query("SHOW GLOBAL STATUS WHERE Variable_name IN ('Questions', 'Innodb_rows_read', 'Created_tmp_disk_tables', 'Select_full_join', 'Sort_merge_passes', 'Innodb_buffer_pool_reads', 'Innodb_buffer_pool_read_requests', 'Bytes_sent', 'Bytes_received')")
+	if err != nil {
+		return counters, fmt.Errorf("error getting global status: %w", err)
+	}
+	defer rows.Close()
+
+	statusVars := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return counters, err
+		}
+		statusVars[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return counters, err
+	}
+
+	parseIntVar64(&counters.Questions, statusVars, "Questions")
+	parseIntVar64(&counters.InnodbRowsRead, statusVars, "Innodb_rows_read")
+	parseIntVar64(&counters.CreatedTmpDiskTables, statusVars, "Created_tmp_disk_tables")
+	parseIntVar64(&counters.SelectFullJoin, statusVars, "Select_full_join")
+	parseIntVar64(&counters.SortMergePasses, statusVars, "Sort_merge_passes")
+	parseIntVar64(&counters.InnodbBufferPoolReads, statusVars, "Innodb_buffer_pool_reads")
+	parseIntVar64(&counters.BytesSent, statusVars, "Bytes_sent")
+	parseIntVar64(&counters.BytesReceived, statusVars, "Bytes_received")
+	parseIntVar64(&counters.InnodbBufferPoolReadRequests, statusVars, "Innodb_buffer_pool_read_requests")
+
+	return counters, nil
+}
+
+// Sub returns the change in each counter since before.
+func (c ServerCounters) Sub(before ServerCounters) ServerCounters {
+	return ServerCounters{
+		Questions:             c.Questions - before.Questions,
+		InnodbRowsRead:        c.InnodbRowsRead - before.InnodbRowsRead,
+		CreatedTmpDiskTables:  c.CreatedTmpDiskTables - before.CreatedTmpDiskTables,
+		SelectFullJoin:        c.SelectFullJoin - before.SelectFullJoin,
+		SortMergePasses:       c.SortMergePasses - before.SortMergePasses,
+		InnodbBufferPoolReads: c.InnodbBufferPoolReads - before.InnodbBufferPoolReads,
+		BytesSent:             c.BytesSent - before.BytesSent,
+		BytesReceived:         c.BytesReceived - before.BytesReceived,
+
+		InnodbBufferPoolReadRequests: c.InnodbBufferPoolReadRequests - before.InnodbBufferPoolReadRequests,
+	}
+}
+
+// LockCounters holds the GLOBAL STATUS counters of InnoDB row lock waits.
+// Like WorkloadCounters, a diff between two snapshots covers every session on
+// the server.
+type LockCounters struct {
+	RowLockWaits  int64 // Row lock acquisitions that had to wait
+	RowLockTimeMs int64 // Total time spent waiting for row locks
+}
+
+// GetLockCounters snapshots the current row lock counters.
+func GetLockCounters(db *sql.DB) (LockCounters, error) {
+	var counters LockCounters
+
+	rows, err := db.Query("SHOW GLOBAL STATUS WHERE Variable_name IN ('Innodb_row_lock_waits', 'Innodb_row_lock_time')")
+	if err != nil {
+		return counters, fmt.Errorf("error getting global status: %w", err)
+	}
+	defer rows.Close()
+
+	statusVars := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return counters, err
+		}
+		statusVars[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return counters, err
+	}
+
+	parseIntVar64(&counters.RowLockWaits, statusVars, "Innodb_row_lock_waits")
+	parseIntVar64(&counters.RowLockTimeMs, statusVars, "Innodb_row_lock_time")
+
+	return counters, nil
+}
+
+// Sub returns the change in each counter since before.
+func (c LockCounters) Sub(before LockCounters) LockCounters {
+	return LockCounters{
+		RowLockWaits:  c.RowLockWaits - before.RowLockWaits,
+		RowLockTimeMs: c.RowLockTimeMs - before.RowLockTimeMs,
+	}
+}
+
+// RunMetricsCollector samples GetDetailedMetrics immediately and then every
+// interval until ctx is done, passing each sample to metricsCallback. From the
+// second sample on, IntervalBufferPoolHitRate covers the time since the
+// previous one, so a drop during a run isn't averaged away by the server's
+// whole uptime.
+func RunMetricsCollector(ctx context.Context, db *sql.DB, interval time.Duration, metricsCallback func(DBMetrics)) {
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		var prev *DBMetrics
+		for first := true; ; first = false {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+
 			metrics, err := GetDetailedMetrics(db)
+			if ctx.Err() != nil {
+				return
+			}
 			if err != nil {
 				log.Printf("Error collecting metrics: %v", err)
 				continue
 			}
 
+			if prev != nil {
+				if requests := metrics.bufferPoolReadRequests - prev.bufferPoolReadRequests; requests > 0 {
+					rate := (1 - float64(metrics.bufferPoolReads-prev.bufferPoolReads)/float64(requests)) * 100
+					metrics.IntervalBufferPoolHitRate = &rate
+				}
+			}
+			prev = &metrics
+
 			metricsCallback(metrics)
 		}
 	}()
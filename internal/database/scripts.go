@@ -0,0 +1,180 @@
+// internal/database/scripts.go
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ScriptStatement is one statement of a SQL script and the line it starts on.
+type ScriptStatement struct {
+	SQL  string
+	Line int
+}
+
+// ScriptRun records running a list of SQL scripts, such as a run's setup.
+type ScriptRun struct {
+	Scripts    []string      `json:"scripts"`
+	Statements int           `json:"statements"`
+	Duration   time.Duration `json:"durationNs"`
+}
+
+// SplitStatements splits a SQL script into statements at semicolons outside
+// quotes and comments. A mysql-client style "DELIMITER //" line changes the
+// delimiter, so scripts can define procedures and triggers. Statements that
+// hold nothing but comments are dropped.
+func SplitStatements(script string) []ScriptStatement {
+	var statements []ScriptStatement
+	var buf strings.Builder
+	delimiter := ";"
+	line, startLine := 1, 0
+	var quote byte
+	lineComment, blockComment := false, false
+
+	flush := func() {
+		if startLine > 0 {
+			statements = append(statements, ScriptStatement{SQL: strings.TrimSpace(buf.String()), Line: startLine})
+		}
+		buf.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+			}
+		case blockComment:
+			if c == '*' && i+1 < len(script) && script[i+1] == '/' {
+				buf.WriteByte(c)
+				i++
+				c = script[i]
+				blockComment = false
+			}
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				buf.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		default:
+			if startLine == 0 && isLineStart(script, i) && hasPrefixFold(script[i:], "DELIMITER ") {
+				end := strings.IndexByte(script[i:], '\n')
+				if end < 0 {
+					end = len(script) - i
+				}
+				if d := strings.TrimSpace(script[i+len("DELIMITER ") : i+end]); d != "" {
+					delimiter = d
+				}
+				i += end - 1
+				continue
+			}
+			if strings.HasPrefix(script[i:], delimiter) {
+				flush()
+				i += len(delimiter) - 1
+				continue
+			}
+
+			switch {
+			case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+				lineComment = true
+			case c == '/' && strings.HasPrefix(script[i:], "/*"):
+				blockComment = true
+				buf.WriteByte(c)
+				i++
+				c = script[i]
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+				fallthrough
+			case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+				if startLine == 0 {
+					startLine = line
+				}
+			}
+		}
+
+		if !lineComment {
+			buf.WriteByte(c)
+		}
+		if c == '\n' {
+			line++
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// isLineStart reports whether only spaces and tabs precede position i on its
+// line.
+func isLineStart(s string, i int) bool {
+	for i > 0 {
+		i--
+		switch s[i] {
+		case '\n':
+			return true
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// RunScripts runs every statement of each script in order, on one connection
+// so session settings such as SET FOREIGN_KEY_CHECKS = 0 carry over between
+// statements. Each statement gets timeout. It stops at the first failing
+// statement, naming its script, line and text.
+func RunScripts(ctx context.Context, db *sql.DB, paths []string, timeout time.Duration) (run ScriptRun, err error) {
+	run.Scripts = paths
+	start := time.Now()
+	defer func() { run.Duration = time.Since(start) }()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return run, fmt.Errorf("error opening connection for scripts: %w", err)
+	}
+	defer conn.Close()
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return run, fmt.Errorf("error reading script: %w", err)
+		}
+
+		for _, stmt := range SplitStatements(string(data)) {
+			stmtCtx, cancel := context.WithTimeout(ctx, timeout)
+			_, err := conn.ExecContext(stmtCtx, stmt.SQL)
+			cancel()
+			if err != nil {
+				return run, fmt.Errorf("%s:%d: %w\n  statement: %s", path, stmt.Line, err, truncateStatement(stmt.SQL))
+			}
+			run.Statements++
+		}
+	}
+	return run, nil
+}
+
+// truncateStatement shortens a statement for an error message, so a failing
+// bulk INSERT doesn't print thousands of rows.
+func truncateStatement(sql string) string {
+	const maxLen = 300
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLen {
+		return sql[:maxLen] + "..."
+	}
+	return sql
+}
@@ -0,0 +1,142 @@
+// internal/database/session.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// IsolationLevels are the accepted values of the isolationLevel setting.
+var IsolationLevels = []string{"READ-UNCOMMITTED", "READ-COMMITTED", "REPEATABLE-READ", "SERIALIZABLE"}
+
+// NormalizeIsolationLevel accepts an isolation level written with spaces,
+// dashes or underscores in any case and returns the form MySQL expects,
+// e.g. "read committed" -> "READ-COMMITTED".
+func NormalizeIsolationLevel(level string) (string, error) {
+	normalized := strings.ToUpper(strings.NewReplacer(" ", "-", "_", "-").Replace(strings.TrimSpace(level)))
+	for _, l := range IsolationLevels {
+		if normalized == l {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown isolation level %q (want one of %s)", level, strings.Join(IsolationLevels, ", "))
+}
+
+// ServerVariables are the global variables recorded with every run, chosen
+// because they change the numbers most between otherwise identical servers.
+var ServerVariables = []string{
+	"innodb_buffer_pool_size",
+	"innodb_flush_log_at_trx_commit",
+	"query_cache_type",
+	"query_cache_size",
+}
+
+// GetServerVariables reads ServerVariables. Variables the server doesn't have,
+// such as the query cache settings removed in MySQL 8.0, are left out.
+func GetServerVariables(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('" + strings.Join(ServerVariables, "', '") + "')")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vars := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		vars[name] = value
+	}
+	return vars, rows.Err()
+}
+
+// ListSchemas runs query and returns the first column of every row, e.g.
+// the tenant schemas from information_schema.SCHEMATA.
+func ListSchemas(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []string
+	values := make([]any, len(columns))
+	for rows.Next() {
+		var name string
+		values[0] = &name
+		for i := 1; i < len(values); i++ {
+			values[i] = new(sql.RawBytes)
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+	return schemas, rows.Err()
+}
+
+// DSNHost returns the server address of dsn without credentials or database
+// name, or "" if dsn can't be parsed.
+func DSNHost(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return ""
+	}
+	return cfg.Addr
+}
+
+// RedactDSN returns dsn with its password masked, for recording in reports.
+// A dsn that can't be parsed is dropped entirely rather than risk keeping it.
+func RedactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return ""
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = "xxxxx"
+	}
+	return cfg.FormatDSN()
+}
+
+// MultiStatementsEnabled reports whether dsn sets multiStatements=true, which
+// the driver needs to send several ;-separated statements in one query.
+func MultiStatementsEnabled(dsn string) bool {
+	cfg, err := mysql.ParseDSN(dsn)
+	return err == nil && cfg.MultiStatements
+}
+
+// WithIsolationLevel returns dsn with the session transaction isolation set
+// to level on every new connection. An empty level returns dsn unchanged.
+func WithIsolationLevel(dsn, level string) (string, error) {
+	if level == "" {
+		return dsn, nil
+	}
+
+	level, err := NormalizeIsolationLevel(level)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("error parsing DSN: %w", err)
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	cfg.Params["transaction_isolation"] = "'" + level + "'"
+
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,91 @@
+// internal/database/version.go
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion is a MySQL or MariaDB server's version number, as parsed from
+// SELECT VERSION().
+type ServerVersion struct {
+	MariaDB bool
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+var versionNumber = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// ParseServerVersion parses a version string such as "8.0.36",
+// "5.7.44-log", "8.0.35-0ubuntu0.22.04.1" or "10.6.12-MariaDB-log". The
+// "5.5.5-" prefix older MariaDB servers report for replication compatibility
+// is skipped.
+func ParseServerVersion(s string) (ServerVersion, error) {
+	s = strings.TrimSpace(s)
+	var v ServerVersion
+	v.MariaDB = strings.Contains(strings.ToLower(s), "mariadb")
+	if v.MariaDB {
+		s = strings.TrimPrefix(s, "5.5.5-")
+	}
+
+	m := versionNumber.FindStringSubmatch(s)
+	if m == nil {
+		return v, fmt.Errorf("unrecognized server version %q", s)
+	}
+	for i, part := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] != "" {
+			*part, _ = strconv.Atoi(m[i+1])
+		}
+	}
+	return v, nil
+}
+
+// ParseMinServerVersion parses a minimum version such as "8.0" or "8.0.13",
+// which MySQL servers must meet, or "MariaDB 10.6", which MariaDB servers
+// must meet.
+func ParseMinServerVersion(s string) (ServerVersion, error) {
+	spec := strings.TrimSpace(s)
+	mariaDB := false
+	if len(spec) > len("mariadb") && strings.EqualFold(spec[:len("mariadb")], "mariadb") {
+		mariaDB = true
+		spec = strings.TrimLeft(spec[len("mariadb"):], " -")
+	}
+
+	m := versionNumber.FindStringSubmatch(spec)
+	if m == nil || len(m[0]) != len(spec) {
+		return ServerVersion{}, fmt.Errorf("invalid minimum server version %q: want e.g. 8.0, 8.0.13 or MariaDB 10.6", s)
+	}
+	v, err := ParseServerVersion(spec)
+	v.MariaDB = mariaDB
+	return v, err
+}
+
+// AtLeast reports whether v is min or newer. A server of the other flavor
+// never is: MariaDB and MySQL version numbers aren't comparable.
+func (v ServerVersion) AtLeast(min ServerVersion) bool {
+	if v.MariaDB != min.MariaDB {
+		return false
+	}
+	if v.Major != min.Major {
+		return v.Major > min.Major
+	}
+	if v.Minor != min.Minor {
+		return v.Minor > min.Minor
+	}
+	return v.Patch >= min.Patch
+}
+
+// Flavor returns "MySQL" or "MariaDB".
+func (v ServerVersion) Flavor() string {
+	if v.MariaDB {
+		return "MariaDB"
+	}
+	return "MySQL"
+}
+
+func (v ServerVersion) String() string {
+	return fmt.Sprintf("%s %d.%d.%d", v.Flavor(), v.Major, v.Minor, v.Patch)
+}
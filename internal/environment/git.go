@@ -0,0 +1,70 @@
+// internal/environment/git.go
+package environment
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GitInfo describes the commit checked out in a working directory.
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch"`
+	Dirty  bool   `json:"dirty"`
+}
+
+// ShortCommit returns the abbreviated commit hash.
+func (g GitInfo) ShortCommit() string {
+	if len(g.Commit) > 7 {
+		return g.Commit[:7]
+	}
+	return g.Commit
+}
+
+// Label returns a run label of the form <branch>-<short-sha> that is safe to
+// use in file names.
+func (g GitInfo) Label() string {
+	branch := strings.NewReplacer("/", "-", "\\", "-", " ", "-").Replace(g.Branch)
+	if branch == "" || branch == "HEAD" {
+		branch = "detached"
+	}
+	return fmt.Sprintf("%s-%s", branch, g.ShortCommit())
+}
+
+// DetectGit reads commit, branch and dirty-tree state for the repository
+// containing dir. It returns an error when dir isn't inside a git repository
+// or git isn't installed.
+func DetectGit(dir string) (GitInfo, error) {
+	var info GitInfo
+
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return info, err
+	}
+	info.Commit = commit
+
+	branch, err := git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return info, err
+	}
+	info.Branch = branch
+
+	status, err := git(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return info, err
+	}
+	info.Dirty = status != ""
+
+	return info, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -2,10 +2,12 @@
 package model
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/0xsj/fn-analyzer/internal/config"
 	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
 )
 
 type Query struct {
@@ -13,60 +15,432 @@ type Query struct {
 	Description string `json:"description"`
 	SQL         string `json:"sql"`
 	Weight      int    `json:"weight"`
+
+	// SLA thresholds; zero means not checked
+	MinSuccessRate float64 `json:"minSuccessRate,omitempty"` // Minimum fraction of executions that must succeed, e.g. 0.99
+
+	// Latency SLO: sloPercentile percent of executions finish within sloMs
+	SLOMs         float64 `json:"sloMs,omitempty"`
+	SLOPercentile float64 `json:"sloPercentile,omitempty"` // Defaults to 99
+
+	CheckPlanStability bool `json:"checkPlanStability,omitempty"` // EXPLAIN before the first and after the last execution to catch a plan flip
+
+	CaptureSampleRows int `json:"captureSampleRows,omitempty"` // Store this many rows of the first iteration's result; overrides the config's captureSampleRows
+
+	ExplainParams []any `json:"explainParams,omitempty"` // Representative values bound to the query's ? placeholders, in order, when it is EXPLAINed
+
+	// Set when the query is loaded, not read from the file
+	LintWarnings []string `json:"-"`
+	Schema       string   `json:"-"` // Schema substituted for {{schema}} when the suite fans out
+	Template     string   `json:"-"` // Name of the query this one was fanned out from
 }
 
 // QueryExecution represents a single execution of a query
 type QueryExecution struct {
-	SQL          string        `json:"sql"`
 	StartTime    time.Time     `json:"startTime"`
 	Duration     time.Duration `json:"duration"`
 	RowCount     int64         `json:"rowCount"`
 	Error        error         `json:"-"`
 	ErrorMessage string        `json:"error,omitempty"`
+
+	// Populated only in fresh-connection mode
+	ConnectDuration time.Duration `json:"connectDurationNs,omitempty"`
+	CloseDuration   time.Duration `json:"closeDurationNs,omitempty"`
+
+	// The run's worker that ran it, from 1, and in dedicated connection
+	// mode the server's CONNECTION_ID() of the connection it ran on
+	Worker       int   `json:"worker,omitempty"`
+	ConnectionID int64 `json:"connectionId,omitempty"`
+
+	// BEGIN plus COMMIT/ROLLBACK time when executions run in a transaction
+	TxOverhead time.Duration `json:"txOverheadNs,omitempty"`
+
+	// Pool mode: time spent waiting for a pooled connection, not part of
+	// Duration
+	AcquireDuration time.Duration `json:"acquireDurationNs,omitempty"`
+
+	// Set when the query was cancelled after returning MaxRows rows
+	RowCapExceeded bool `json:"rowCapExceeded,omitempty"`
+
+	// Result sets returned when more than one, e.g. by a CALL; RowCount
+	// sums their rows
+	ResultSets int `json:"resultSets,omitempty"`
+
+	// Set when the execution hit the query timeout; Duration is then the
+	// timeout itself
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	// First rows of the result, captured on the first iteration when
+	// CaptureSampleRows is set. They are moved to QueryResult.SampleRows;
+	// only reports written before that have them here.
+	SampleRows []SampleRow `json:"sampleRows,omitempty"`
+
+	// Result columns, captured on the first iteration when
+	// CaptureColumnTypes is set
+	ColumnTypes []ColumnType `json:"columnTypes,omitempty"`
+}
+
+// SampleRow is one captured result row: each column's value as a string, with
+// SQL NULL as "NULL", long values cut short and masked columns as "****".
+type SampleRow map[string]string
+
+// ColumnType is one column of a query's result as the server describes it.
+type ColumnType struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"databaseType"`        // e.g. DECIMAL, DOUBLE, VARCHAR
+	Precision    int64  `json:"precision,omitempty"` // DECIMAL only
+	Scale        int64  `json:"scale,omitempty"`     // DECIMAL only
+	Nullable     *bool  `json:"nullable,omitempty"`  // nil when the driver doesn't say
+}
+
+// Distributions are a query's latency histograms, which stand in for its
+// executions where those aren't kept. Histograms from several processes merge
+// into those of all their executions.
+type Distributions struct {
+	Latency  *utils.Histogram `json:"latency"`  // Successful executions
+	Censored *utils.Histogram `json:"censored"` // Successful and timed-out executions
+	Acquire  *utils.Histogram `json:"acquire"`  // Connection waits of successful executions that waited
 }
 
 // QueryResult represents the performance metrics for a query
 type QueryResult struct {
-	Name                 string           `json:"name"`
-	Description          string           `json:"description"`
-	SQL                  string           `json:"sql"`
-	Executions           []QueryExecution `json:"executions,omitempty"`
-	SuccessfulExecutions int              `json:"successfulExecutions"`
-	Errors               int              `json:"errors"`
-	ErrorDetails         []string         `json:"errorDetails,omitempty"`
-	TotalDuration        time.Duration    `json:"totalDurationNs"`
-	AvgDuration          time.Duration    `json:"avgDurationNs"`
-	MinDuration          time.Duration    `json:"minDurationNs"`
-	MaxDuration          time.Duration    `json:"maxDurationNs"`
-	MedianDuration       time.Duration    `json:"medianDurationNs"`
-	StdDevDuration       time.Duration    `json:"stdDevDurationNs"`
-	Percentile95         time.Duration    `json:"percentile95Ns"`
-	Percentile99         time.Duration    `json:"percentile99Ns"`
-	RowsAffected         int64            `json:"rowsAffected"`
-	Weight               int              `json:"weight"`
-	QueryComplexity      string           `json:"queryComplexity"`
-	FirstExecutedAt      time.Time        `json:"firstExecutedAt"`
-	LastExecutedAt       time.Time        `json:"lastExecutedAt"`
-	ExplainPlan          string           `json:"explainPlan,omitempty"`
+	Name                     string           `json:"name"`
+	Description              string           `json:"description"`
+	SQL                      string           `json:"sql"`
+	Executions               []QueryExecution `json:"executions,omitempty"`
+	Distributions            *Distributions   `json:"-"`                             // Latency histograms in place of Executions, for a distributed run without workerExecutions
+	ExecutionsTruncated      int              `json:"executionsTruncated,omitempty"` // Executions left out of the report by maxExecutionsInReport; those kept are spread evenly over the run
+	SuccessfulExecutions     int              `json:"successfulExecutions"`
+	Errors                   int              `json:"errors"`
+	SuccessRate              float64          `json:"successRate"` // Successful executions / all executions
+	ErrorDetails             []string         `json:"errorDetails,omitempty"`
+	ErrorsByType             map[string]int   `json:"errorsByType,omitempty"` // Failed executions of this query by error type
+	TotalDuration            time.Duration    `json:"totalDurationNs"`
+	AvgDuration              time.Duration    `json:"avgDurationNs"`
+	MinDuration              time.Duration    `json:"minDurationNs"`
+	ColdDuration             time.Duration    `json:"coldDurationNs,omitempty"` // First execution, kept out of the other statistics when measureCold is set
+	ColdExecution            *QueryExecution  `json:"coldExecution,omitempty"`
+	ColdWarmRatio            float64          `json:"coldWarmRatio,omitempty"` // ColdDuration / MedianDuration of the warm executions
+	MaxDuration              time.Duration    `json:"maxDurationNs"`
+	MedianDuration           time.Duration    `json:"medianDurationNs"`
+	StdDevDuration           time.Duration    `json:"stdDevDurationNs"`
+	Percentile95             time.Duration    `json:"percentile95Ns"`
+	Percentile99             time.Duration    `json:"percentile99Ns"`
+	RowsAffected             int64            `json:"rowsAffected"`
+	ZeroRows                 bool             `json:"zeroRows,omitempty"` // A read query that never returned a row
+	MinRowCount              int64            `json:"minRowCount"`
+	MaxRowCount              int64            `json:"maxRowCount"`
+	NonDeterministicRowCount bool             `json:"nonDeterministicRowCount,omitempty"` // Row count differed between successful executions
+	Timeouts                 int              `json:"timeouts,omitempty"`                 // Executions that hit the query timeout
+	TimeoutRate              float64          `json:"timeoutRate,omitempty"`              // Timeouts / all executions
+	CensoredPercentile95     time.Duration    `json:"censoredPercentile95Ns,omitempty"`   // P95 counting timeouts at the timeout
+	CensoredPercentile99     time.Duration    `json:"censoredPercentile99Ns,omitempty"`   // P99 counting timeouts at the timeout
+	P95InsufficientSamples   bool             `json:"p95InsufficientSamples,omitempty"`   // Too few successful executions for Percentile95 to mean anything
+	P99InsufficientSamples   bool             `json:"p99InsufficientSamples,omitempty"`   // Too few successful executions for Percentile99 to mean anything
+	TimeoutCensored          bool             `json:"timeoutCensored,omitempty"`          // Too many timeouts for the latency statistics to be trusted
+	Weight                   int              `json:"weight"`
+	WeightShare              float64          `json:"weightShare"`        // Weight as a fraction of the suite's total weight
+	PercentOfTotalTime       float64          `json:"percentOfTotalTime"` // TotalDuration as a percentage of the run's total across all queries
+	Schema                   string           `json:"schema,omitempty"`   // Schema this run of a fanned-out query used
+	Template                 string           `json:"template,omitempty"` // Name of the query it was fanned out from
+	QueryComplexity          string           `json:"queryComplexity"`
+	ComplexityScore          int              `json:"complexityScore"`
+	StatementType            string           `json:"statementType"`                  // select, insert, update, delete or other
+	ComplexityComponents     map[string]int   `json:"complexityComponents,omitempty"` // Points contributed to ComplexityScore by each construct
+	FirstExecutedAt          time.Time        `json:"firstExecutedAt"`
+	LastExecutedAt           time.Time        `json:"lastExecutedAt"`
+	ExplainPlan              string           `json:"explainPlan,omitempty"`
+	PlanWarnings             []string         `json:"planWarnings,omitempty"`     // Full scans, filesorts and temporary tables found in ExplainPlan
+	PlanFingerprints         []string         `json:"planFingerprints,omitempty"` // Plan shape before the first and after the last execution, with checkPlanStability
+	PlanFlipped              bool             `json:"planFlipped,omitempty"`      // The plan changed between the two fingerprints
+	EstimatedCost            *float64         `json:"estimatedCost"`              // Optimizer's query_cost from ExplainPlan; null when unknown
+	EstimatedRows            *int64           `json:"estimatedRows"`              // Optimizer's estimate of rows produced by the join; null when unknown
+	LintWarnings             []string         `json:"lintWarnings,omitempty"`     // SELECT *, ORDER BY without LIMIT, UPDATE/DELETE without WHERE
+	ColumnTypes              []ColumnType     `json:"columnTypes,omitempty"`      // Result columns of the first execution that captured them
+	SampleRows               []SampleRow      `json:"sampleRows,omitempty"`       // Leading result rows of the first execution that captured them
+	Profile                  []ProfileStage   `json:"profile,omitempty"`          // Stage timings from SHOW PROFILE, slowest query only
+	AchievedQPS              float64          `json:"achievedQps"`
+	MinSuccessRate           float64          `json:"minSuccessRate,omitempty"`
+	SLAViolations            []string         `json:"slaViolations,omitempty"`
+	SLO                      *SLOResult       `json:"slo,omitempty"`             // Compliance with the query's latency SLO, if it declares one
+	LockWaits                int64            `json:"lockWaits,omitempty"`       // Server row lock waits while the query's iterations ran, with measureLocks
+	LockWaitTimeMs           int64            `json:"lockWaitTimeMs,omitempty"`  // Time spent in those waits
+	AvgTxOverhead            time.Duration    `json:"avgTxOverheadNs,omitempty"` // Transaction BEGIN+COMMIT/ROLLBACK cost per execution
+	RolledBack               bool             `json:"rolledBack,omitempty"`      // Every execution ran in a transaction that was rolled back
+	AvgHarnessOverhead       time.Duration    `json:"avgHarnessOverheadNs"`      // Time per execution spent in the analyzer itself rather than the query
+
+	// Fresh-connection mode: cost of opening and closing a connection per execution
+	AvgConnectDuration  time.Duration `json:"avgConnectDurationNs,omitempty"`
+	AvgCloseDuration    time.Duration `json:"avgCloseDurationNs,omitempty"`
+	AvgFreshConnOverall time.Duration `json:"avgFreshConnOverallNs,omitempty"`
+
+	// Pool mode: time per execution spent waiting for a pooled connection
+	AvgAcquireDuration time.Duration `json:"avgAcquireDurationNs,omitempty"`
+	P95AcquireDuration time.Duration `json:"p95AcquireDurationNs,omitempty"`
+	MaxAcquireDuration time.Duration `json:"maxAcquireDurationNs,omitempty"`
+}
+
+// FullyFailed reports whether every execution of the query failed.
+func (q QueryResult) FullyFailed() bool {
+	return q.Errors > 0 && q.SuccessfulExecutions == 0
 }
 
+// Timing schemes record what QueryExecution.Duration covers in a report.
+const (
+	// TimingIncludesAcquire: Duration includes waiting for a pooled
+	// connection. Reports without a timing scheme were measured this way.
+	TimingIncludesAcquire = "includes-acquire"
+	// TimingExcludesAcquire: the connection is acquired first and timed as
+	// AcquireDuration; Duration covers only the statement.
+	TimingExcludesAcquire = "excludes-acquire"
+)
+
+// SchemaVersion is the version of the TestResult JSON layout written by this
+// build. Bump it when a change needs LoadResult to upgrade older reports.
+// Version 1 reports carry no schemaVersion field.
+const SchemaVersion = 4
+
 // TestResult represents the overall results of a performance test
 type TestResult struct {
-	Timestamp      time.Time               `json:"timestamp"`
-	Label          string                  `json:"label"`
-	Config         config.Config           `json:"config"`
-	TotalDuration  time.Duration           `json:"totalDurationNs"`
-	QueryResults   []QueryResult           `json:"queryResults"`
-	ConnectionInfo database.ConnectionInfo `json:"connectionInfo"`
-	MetricsHistory []database.DBMetrics    `json:"metricsHistory,omitempty"`
-	Summary        ResultSummary           `json:"summary"`
+	SchemaVersion  int                      `json:"schemaVersion"`
+	Timestamp      time.Time                `json:"timestamp"`
+	Label          string                   `json:"label"`
+	Config         config.Config            `json:"config"`
+	TotalDuration  time.Duration            `json:"totalDurationNs"`
+	QueryResults   []QueryResult            `json:"queryResults"`
+	ConnectionInfo database.ConnectionInfo  `json:"connectionInfo"`
+	MetricsHistory []database.DBMetrics     `json:"metricsHistory,omitempty"`
+	Summary        ResultSummary            `json:"summary"`
+	Environment    Environment              `json:"environment"`
+	Metadata       RunMetadata              `json:"metadata"`
+	TableBreakdown []TableStats             `json:"tableBreakdown,omitempty"`
+	SchemaSpread   []SchemaSpread           `json:"schemaSpread,omitempty"` // Per fanned-out query, how latency varies across schemas
+	Heatmap        *Heatmap                 `json:"heatmap,omitempty"`      // Successful executions by time window and latency bucket
+	CostLatency    []CostLatency            `json:"costLatency,omitempty"`  // Optimizer cost against measured latency, for queries with a cost estimate
+	Connections    []ConnectionStats        `json:"connections,omitempty"`  // Latency per connection (dedicated mode) or worker, slowest first
+	TimingScheme   string                   `json:"timingScheme,omitempty"` // What execution durations cover; empty means TimingIncludesAcquire
+	ServerDelta    *database.ServerCounters `json:"serverDelta,omitempty"`  // Change in server counters over the run, all sessions
+
+	ConnectionBenchmark *database.ConnectionBenchmark `json:"connectionBenchmark,omitempty"` // Connect latency measured before the run
+
+	Workers []WorkerRun `json:"workers,omitempty"` // Load-generating workers of a distributed run
+
+	Alerts []Alert `json:"alerts,omitempty"` // Alert rules that held on the server during the run
+
+	Guardrails *Guardrails `json:"guardrails,omitempty"` // Dispatch paused while the server was over a guardrail; nil without rules
+
+	Seed uint64 `json:"seed,omitempty"` // Seed that drove the run's random choices; 0 when it made none
+
+	Soak *Soak `json:"soak,omitempty"` // Snapshot position, and in the final report the trend across snapshots
+
+	Setup *database.ScriptRun `json:"setup,omitempty"` // Setup scripts run before warmup; not part of totalDurationNs
+
+	StatsD *StatsDEmission `json:"statsd,omitempty"` // Metrics sent to a statsd agent during the run
+}
+
+// StatsDEmission counts the metrics a run sent to its statsd agent. Dropped
+// and failed metrics never left the process, so dashboards fed by the agent
+// are missing them. UDP gives no delivery receipt, so sent metrics may still
+// have been lost on the way.
+type StatsDEmission struct {
+	Address string `json:"address"`
+	Sent    int64  `json:"sent"`
+	Dropped int64  `json:"dropped"` // Discarded because the send queue was full
+	Failed  int64  `json:"failed"`  // Rejected by the socket
+}
+
+// Lost returns the metrics that were dropped or failed.
+func (e StatsDEmission) Lost() int64 {
+	return e.Dropped + e.Failed
+}
+
+// Soak places a report within a soak run. A snapshot report carries its
+// Sequence; the final one carries every query's trend, one point per
+// snapshot, and the last snapshot as its query results.
+type Soak struct {
+	Sequence    int         `json:"sequence,omitempty"` // 1-based snapshot number; 0 in the final report
+	WindowStart time.Time   `json:"windowStart"`        // When the snapshot (or, finally, the soak) started
+	WindowEnd   time.Time   `json:"windowEnd"`
+	Snapshots   int         `json:"snapshots"` // Snapshots finalized so far
+	Trends      []SoakTrend `json:"trends,omitempty"`
+}
+
+// SoakTrend is one query's statistics in each snapshot of a soak.
+type SoakTrend struct {
+	Query  string      `json:"query"`
+	Points []SoakPoint `json:"points"`
+}
+
+// SoakPoint is one query's statistics over one snapshot.
+type SoakPoint struct {
+	Sequence               int     `json:"sequence"`
+	Executions             int     `json:"executions"`
+	AvgMs                  float64 `json:"avgMs"`
+	P95Ms                  float64 `json:"p95Ms"`
+	P95InsufficientSamples bool    `json:"p95InsufficientSamples,omitempty"`
+	ErrorRate              float64 `json:"errorRate"` // Failed executions as a fraction of all executions
+}
+
+// EffectiveTimingScheme returns the timing scheme the result was measured
+// with, accounting for reports written before it was recorded.
+func (r TestResult) EffectiveTimingScheme() string {
+	if r.TimingScheme == "" {
+		return TimingIncludesAcquire
+	}
+	return r.TimingScheme
+}
+
+// TableStats aggregates the queries that touch one table.
+type TableStats struct {
+	Table           string   `json:"table"`
+	Queries         []string `json:"queries"`
+	Executions      int      `json:"executions"`
+	AvgDurationMs   float64  `json:"avgDurationMs"`   // Across successful executions of all the queries
+	TotalDurationMs float64  `json:"totalDurationMs"` // Time spent in successful executions of all the queries
+	RowsReturned    int64    `json:"rowsReturned"`
+
+	// Sum over the queries of weight share times average latency: the
+	// table's part of the latency of an average statement of the weighted
+	// workload, and that part as a fraction of the whole suite's
+	WeightedLatencyMs float64 `json:"weightedLatencyMs"`
+	LatencyShare      float64 `json:"latencyShare"`
+}
+
+// SchemaSpread is the distribution of one fanned-out query's average latency
+// across the schemas it ran in.
+type SchemaSpread struct {
+	Query    string          `json:"query"`
+	Schemas  int             `json:"schemas"` // Schemas with at least one successful execution
+	MinMs    float64         `json:"minMs"`
+	MedianMs float64         `json:"medianMs"`
+	P95Ms    float64         `json:"p95Ms"`
+	MaxMs    float64         `json:"maxMs"`
+	Slowest  []SchemaLatency `json:"slowest"` // Up to five, slowest first
+}
+
+// WorkerRun is one worker's share of a distributed run. A worker that failed
+// contributed no executions; the run's counts are short by its share.
+type WorkerRun struct {
+	Address     string `json:"address"`
+	Iterations  int    `json:"iterations"`  // Iterations of each query assigned to it
+	Concurrency int    `json:"concurrency"` // Its parallel executions
+	Executions  int    `json:"executions"`  // Executions it returned
+	Error       string `json:"error,omitempty"`
+}
+
+// Alert is one episode of an alert rule holding on the server: from the sample
+// it first held in to the first one it no longer did.
+type Alert struct {
+	Rule       string     `json:"rule"`
+	Metric     string     `json:"metric"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"`      // The metric when the alert fired
+	WorstValue float64    `json:"worstValue"` // Furthest past the threshold while it held
+	FiredAt    time.Time  `json:"firedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"` // Nil if it still held when the run ended
+	Samples    int        `json:"samples"`              // Consecutive samples it held in
+}
+
+// Guardrails records how long a run held back new executions because the
+// server was over a guardrail. Paused time is part of totalDurationNs, so
+// throughput over the run is lower than the server would otherwise sustain.
+type Guardrails struct {
+	Rules      int              `json:"rules"` // Guardrail rules checked
+	Pauses     []GuardrailPause `json:"pauses,omitempty"`
+	PausedTime time.Duration    `json:"pausedTimeNs"`
+}
+
+// Throttled reports whether dispatch paused at all. It is false for a nil
+// Guardrails.
+func (g *Guardrails) Throttled() bool {
+	return g != nil && len(g.Pauses) > 0
+}
+
+// GuardrailPause is one stretch of paused dispatch, from the sample a rule
+// tripped in until every tripped rule had cleared.
+type GuardrailPause struct {
+	Rule      string        `json:"rule"` // The rule that started it
+	Metric    string        `json:"metric"`
+	Value     float64       `json:"value"` // The metric when it tripped
+	PausedAt  time.Time     `json:"pausedAt"`
+	ResumedAt *time.Time    `json:"resumedAt,omitempty"` // Nil if still paused when the run ended
+	Duration  time.Duration `json:"durationNs"`
+}
+
+// Heatmap counts a run's successful executions in a grid of time windows
+// (rows, by start time) and latency buckets (columns).
+type Heatmap struct {
+	Start         time.Time `json:"start"`
+	WindowSeconds float64   `json:"windowSeconds"`
+	BucketsMs     []float64 `json:"bucketsMs"` // Upper bounds; the last column counts executions slower than the last bound
+	Counts        [][]int   `json:"counts"`    // Counts[window][bucket], len(BucketsMs)+1 columns
+}
+
+// ConnectionStats is the latency of the executions that ran on one
+// connection in dedicated connection mode, or through one worker otherwise.
+// Connections run different mixes of queries, so RelativeLatency compares
+// them: the mean of each execution's duration over its query's median, 1 for
+// a typical connection.
+type ConnectionStats struct {
+	Worker          int     `json:"worker"`
+	ConnectionID    int64   `json:"connectionId,omitempty"` // Server CONNECTION_ID(), dedicated mode only
+	Executions      int     `json:"executions"`
+	Errors          int     `json:"errors"`
+	AvgMs           float64 `json:"avgMs"`
+	P95Ms           float64 `json:"p95Ms"`
+	RelativeLatency float64 `json:"relativeLatency"`
+}
+
+// CostLatency is one point of a cost vs actual latency scatter.
+type CostLatency struct {
+	Query         string  `json:"query"`
+	EstimatedCost float64 `json:"estimatedCost"`
+	EstimatedRows *int64  `json:"estimatedRows"`
+	AvgMs         float64 `json:"avgMs"`
+	AvgRows       float64 `json:"avgRows"`       // Rows actually returned per successful execution
+	RelativeSpeed float64 `json:"relativeSpeed"` // Latency per unit of cost relative to the suite median; far from 1 means the estimate is off
+}
+
+// SchemaLatency is a query's average latency in one schema.
+type SchemaLatency struct {
+	Schema string  `json:"schema"`
+	AvgMs  float64 `json:"avgMs"`
+}
+
+// RunMetadata describes the analyzer build and the host a run executed on.
+type RunMetadata struct {
+	Version   string `json:"version"`          // Analyzer version
+	GoVersion string `json:"goVersion"`        // Go toolchain the analyzer was built with
+	Hostname  string `json:"hostname"`         // Host the analyzer ran on
+	OS        string `json:"os"`               // GOOS of the analyzer
+	Arch      string `json:"arch"`             // GOARCH of the analyzer
+	CPUs      int    `json:"cpus"`             // Logical CPUs on the analyzer host
+	GitSHA    string `json:"gitSha,omitempty"` // Revision of the deployed code under test, from --git-sha or FN_GIT_SHA
+}
+
+// Environment records where a test run came from so archived results stay
+// attributable
+type Environment struct {
+	GitCommit string `json:"gitCommit,omitempty"`
+	GitBranch string `json:"gitBranch,omitempty"`
+	GitDirty  bool   `json:"gitDirty,omitempty"`
+
+	// The database server the run measured
+	DSNHost         string            `json:"dsnHost,omitempty"` // Host and port from the DSN, without credentials
+	ServerVersion   string            `json:"serverVersion,omitempty"`
+	ServerVariables map[string]string `json:"serverVariables,omitempty"` // Settings that most affect performance, e.g. innodb_buffer_pool_size
 }
 
 // ResultSummary provides aggregate statistics for the test
 type ResultSummary struct {
-	TotalQueries         int            `json:"totalQueries"`
-	SuccessfulQueries    int            `json:"successfulQueries"`
-	FailedQueries        int            `json:"failedQueries"`
+	TotalQueries      int `json:"totalQueries"`
+	SuccessfulQueries int `json:"successfulQueries"` // No execution failed
+	FailedQueries     int `json:"failedQueries"`     // At least one execution failed
+
+	// FailedQueries split by whether any of their executions succeeded
+	PartiallyFailedQueries int `json:"partiallyFailedQueries"`
+	FullyFailedQueries     int `json:"fullyFailedQueries"`
+
 	TotalExecutions      int            `json:"totalExecutions"`
 	SuccessfulExecutions int            `json:"successfulExecutions"`
 	FailedExecutions     int            `json:"failedExecutions"`
@@ -79,6 +453,58 @@ type ResultSummary struct {
 	TotalRowsReturned    int64          `json:"totalRowsReturned"`
 	QueriesByComplexity  map[string]int `json:"queriesByComplexity"`
 	ErrorsByType         map[string]int `json:"errorsByType"`
+	AchievedQPS          float64        `json:"achievedQps"`
+	HarnessOverheadUs    float64        `json:"harnessOverheadUs"` // Average analyzer overhead per execution
+
+	ByStatementType         map[string]StatementTypeSummary `json:"byStatementType,omitempty"`
+	LintWarnings            int                             `json:"lintWarnings"`            // Lint warnings across all queries
+	NonDeterministicQueries int                             `json:"nonDeterministicQueries"` // Queries whose row count varied between executions
+	TimeoutCensoredQueries  int                             `json:"timeoutCensoredQueries"`  // Queries whose latency statistics are censored by timeouts
+	PlanFlippedQueries      int                             `json:"planFlippedQueries"`      // Queries whose plan changed during the run
+	SLOQueries              int                             `json:"sloQueries"`              // Queries that declare a latency SLO
+	SLOMissedQueries        int                             `json:"sloMissedQueries"`        // Queries that missed their latency SLO
+
+	// The slowest connection's relative latency over the median of the
+	// others', and whether that is far enough apart to suspect the path to
+	// the server (proxy, load balancer) rather than the queries
+	ConnectionLatencySpread float64 `json:"connectionLatencySpread,omitempty"`
+	UnevenConnections       bool    `json:"unevenConnections,omitempty"`
+
+	// Pearson and Spearman correlation between complexity score and average
+	// latency across the queries that completed, average latency by
+	// complexity level, and the low-scoring queries that are slow anyway
+	ComplexityLatencyCorrelation float64            `json:"complexityLatencyCorrelation"`
+	ComplexityLatencySpearman    float64            `json:"complexityLatencySpearman"`
+	ComplexityLatencyQueries     int                `json:"complexityLatencyQueries"` // Queries the correlations are computed over
+	AvgDurationMsByComplexity    map[string]float64 `json:"avgDurationMsByComplexity,omitempty"`
+	SimpleButSlow                []string           `json:"simpleButSlow,omitempty"`
+
+	// Fresh-connection mode only
+	AvgConnectMs float64 `json:"avgConnectMs,omitempty"`
+	AvgCloseMs   float64 `json:"avgCloseMs,omitempty"`
+
+	// Transaction mode only: BEGIN plus COMMIT/ROLLBACK per execution
+	AvgTxOverheadMs float64 `json:"avgTxOverheadMs,omitempty"`
+
+	// Pool mode only: waiting for a pooled connection per execution
+	AvgAcquireMs float64 `json:"avgAcquireMs,omitempty"`
+	P95AcquireMs float64 `json:"p95AcquireMs,omitempty"`
+}
+
+// ProfileStage is one execution stage reported by SHOW PROFILE.
+type ProfileStage struct {
+	Stage    string        `json:"stage"`
+	Duration time.Duration `json:"durationNs"`
+	Percent  float64       `json:"percent"` // Share of the profiled execution's total time
+}
+
+// StatementTypeSummary aggregates the executions of one statement type.
+type StatementTypeSummary struct {
+	Queries       int     `json:"queries"`
+	Executions    int     `json:"executions"`
+	AvgDurationMs float64 `json:"avgDurationMs"`
+	P95DurationMs float64 `json:"p95DurationMs"`
+	ErrorRate     float64 `json:"errorRate"` // Failed executions as a fraction of all executions
 }
 
 // ComparisonResult represents a comparison between two test runs
@@ -88,6 +514,82 @@ type ComparisonResult struct {
 	ImprovementSummary ImprovementStats  `json:"improvementSummary"`
 	QueryComparisons   []QueryComparison `json:"queryComparisons"`
 	ErrorsReduced      map[string]int    `json:"errorsReduced"`
+	BeforeCommit       string            `json:"beforeCommit,omitempty"`
+	AfterCommit        string            `json:"afterCommit,omitempty"`
+	Warnings           []string          `json:"warnings,omitempty"` // Differences between the runs that make them less comparable
+	Ordering           string            `json:"ordering,omitempty"` // How the runs were scheduled when both ran in one invocation
+}
+
+// ComparisonChanges is the triage view of a comparison: only the queries
+// whose average latency moved by more than ThresholdPercent, largest move
+// first.
+type ComparisonChanges struct {
+	BeforeLabel      string            `json:"beforeLabel"`
+	AfterLabel       string            `json:"afterLabel"`
+	BeforeCommit     string            `json:"beforeCommit,omitempty"`
+	AfterCommit      string            `json:"afterCommit,omitempty"`
+	ThresholdPercent float64           `json:"thresholdPercent"`
+	Changes          []QueryComparison `json:"changes"`
+	Improved         int               `json:"improved"`
+	Regressed        int               `json:"regressed"`
+	Unchanged        int               `json:"unchanged"` // Queries in both runs that moved less than the threshold
+	Warnings         []string          `json:"warnings,omitempty"`
+}
+
+// SLACheck is a run judged against a fixed file of per-query p95 budgets,
+// rather than against another run.
+type SLACheck struct {
+	Label            string          `json:"label"`
+	Commit           string          `json:"commit,omitempty"`
+	SLAFile          string          `json:"slaFile"`
+	PercentileMethod string          `json:"percentileMethod"` // How the run's p95s were computed
+	Checks           []SLAQueryCheck `json:"checks"`           // Breaches first, then by name
+	Passed           int             `json:"passed"`
+	Breached         int             `json:"breached"`
+	Unchecked        []string        `json:"unchecked,omitempty"` // Queries in the run that the SLA file doesn't name
+	Warnings         []string        `json:"warnings,omitempty"`
+}
+
+// SLAQueryCheck is one query's p95 against its budget.
+type SLAQueryCheck struct {
+	Name                string  `json:"name"`
+	MaxP95Ms            float64 `json:"maxP95Ms"`
+	P95Ms               float64 `json:"p95Ms"`
+	Passed              bool    `json:"passed"`
+	Reason              string  `json:"reason,omitempty"`              // Why it breached without a p95 to judge, e.g. it wasn't in the run
+	InsufficientSamples bool    `json:"insufficientSamples,omitempty"` // The p95 comes from fewer executions than percentileMinSamples.p95
+}
+
+// OrderingSequential: one invocation ran the whole suite against the before
+// target, then against the after target. Load that changes over time can
+// favour either side.
+const OrderingSequential = "sequential (before, then after)"
+
+// SLOResult is a query's compliance with its latency SLO: Percentile percent
+// of executions finishing within ThresholdMs. Failed executions count as
+// misses however fast they failed.
+type SLOResult struct {
+	ThresholdMs     float64 `json:"thresholdMs"`
+	Percentile      float64 `json:"percentile"`
+	Executions      int     `json:"executions"`
+	Within          int     `json:"within"`          // Successful executions that finished within ThresholdMs
+	Compliance      float64 `json:"compliance"`      // Within as a fraction of Executions
+	ErrorBudgetBurn float64 `json:"errorBudgetBurn"` // Misses as a fraction of those the SLO allows; above 1 it is missed
+	Met             bool    `json:"met"`
+}
+
+// Objective renders the SLO, e.g. "99% < 250ms".
+func (s SLOResult) Objective() string {
+	return fmt.Sprintf("%g%% < %gms", s.Percentile, s.ThresholdMs)
+}
+
+// SLOComparison is one query's SLO compliance in two runs.
+type SLOComparison struct {
+	Objective        string  `json:"objective"`
+	BeforeCompliance float64 `json:"beforeCompliance"`
+	AfterCompliance  float64 `json:"afterCompliance"`
+	BeforeMet        bool    `json:"beforeMet"`
+	AfterMet         bool    `json:"afterMet"`
 }
 
 // ImprovementStats holds performance improvement statistics
@@ -102,12 +604,27 @@ type ImprovementStats struct {
 
 // QueryComparison compares before/after metrics for a single query
 type QueryComparison struct {
-	Name               string  `json:"name"`
-	BeforeAvgMs        float64 `json:"beforeAvgMs"`
-	AfterAvgMs         float64 `json:"afterAvgMs"`
-	ImprovementPercent float64 `json:"improvementPercent"`
-	BeforeErrors       int     `json:"beforeErrors"`
-	AfterErrors        int     `json:"afterErrors"`
-	BeforeRows         int64   `json:"beforeRows"`
-	AfterRows          int64   `json:"afterRows"`
+	Name               string         `json:"name"`
+	BeforeAvgMs        float64        `json:"beforeAvgMs"`
+	AfterAvgMs         float64        `json:"afterAvgMs"`
+	ImprovementPercent float64        `json:"improvementPercent"`
+	BeforeErrors       int            `json:"beforeErrors"`
+	AfterErrors        int            `json:"afterErrors"`
+	BeforeRows         int64          `json:"beforeRows"`
+	AfterRows          int64          `json:"afterRows"`
+	PValue             float64        `json:"pValue"`        // Mann-Whitney U two-sided p-value; 1 when executions are unavailable
+	Significant        bool           `json:"significant"`   // Whether the latency change is statistically significant (p < 0.05)
+	SLO                *SLOComparison `json:"slo,omitempty"` // When the query declares the same latency SLO in both runs
+
+	SignificanceUnavailable bool `json:"significanceUnavailable,omitempty"` // A report left out the query's executions, so pValue and significant say nothing
+
+	AfterFailed bool `json:"afterFailed,omitempty"` // Every execution failed in the after run but not before: a regression whatever the averages say
+}
+
+// QueryTrend is one query's average latency across a series of runs.
+type QueryTrend struct {
+	Name      string    `json:"name"`
+	AvgMs     []float64 `json:"avgMs"`     // One entry per run, oldest first; 0 where the query didn't run or never succeeded
+	SlopeMs   float64   `json:"slopeMs"`   // Least-squares change in average latency per run
+	Worsening bool      `json:"worsening"` // The fitted line rises by more than the trend threshold over the series
 }
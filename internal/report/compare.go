@@ -0,0 +1,441 @@
+// internal/report/compare.go
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+func SaveComparisonJSON(before, after model.TestResult, outputDir string) error {
+	return SaveComparison(BuildComparison(before, after), outputDir)
+}
+
+// significanceLevel is the p-value below which a latency change is reported
+// as significant.
+const significanceLevel = 0.05
+
+// successfulDurations returns the durations of result's stored successful
+// executions, and whether they are stored at all: a report written with
+// includeExecutions false keeps only the aggregates.
+func successfulDurations(result model.QueryResult) ([]time.Duration, bool) {
+	durations := make([]time.Duration, 0, result.SuccessfulExecutions)
+	for _, exec := range result.Executions {
+		if exec.ErrorMessage == "" {
+			durations = append(durations, exec.Duration)
+		}
+	}
+	return durations, len(durations) > 0 || result.SuccessfulExecutions == 0
+}
+
+// BuildComparison computes per-query and overall changes between two runs.
+func BuildComparison(before, after model.TestResult) model.ComparisonResult {
+	afterMap := make(map[string]model.QueryResult)
+	for _, q := range after.QueryResults {
+		afterMap[q.Name] = q
+	}
+
+	comparisons := make([]model.QueryComparison, 0, len(before.QueryResults))
+	var unavailable, sampled []string
+
+	for _, beforeQ := range before.QueryResults {
+		afterQ, found := afterMap[beforeQ.Name]
+		if !found {
+			continue
+		}
+
+		beforeAvgMs := float64(beforeQ.AvgDuration.Microseconds()) / 1000
+		afterAvgMs := float64(afterQ.AvgDuration.Microseconds()) / 1000
+
+		// A query that never succeeded has no average, not a fast one.
+		var improvementPct float64
+		if beforeAvgMs > 0 && afterQ.SuccessfulExecutions > 0 {
+			improvementPct = (beforeAvgMs - afterAvgMs) / beforeAvgMs * 100
+		}
+
+		comparison := model.QueryComparison{
+			Name:               beforeQ.Name,
+			BeforeAvgMs:        beforeAvgMs,
+			AfterAvgMs:         afterAvgMs,
+			ImprovementPercent: improvementPct,
+			BeforeErrors:       beforeQ.Errors,
+			AfterErrors:        afterQ.Errors,
+			BeforeRows:         beforeQ.RowsAffected,
+			AfterRows:          afterQ.RowsAffected,
+		}
+
+		beforeDurations, beforeStored := successfulDurations(beforeQ)
+		afterDurations, afterStored := successfulDurations(afterQ)
+		_, comparison.PValue = utils.MannWhitneyU(beforeDurations, afterDurations)
+		comparison.Significant = comparison.PValue < significanceLevel
+		if !beforeStored || !afterStored {
+			comparison.SignificanceUnavailable = true
+			unavailable = append(unavailable, beforeQ.Name)
+		} else if beforeQ.ExecutionsTruncated > 0 || afterQ.ExecutionsTruncated > 0 {
+			sampled = append(sampled, beforeQ.Name)
+		}
+		comparison.SLO = compareSLO(beforeQ.SLO, afterQ.SLO)
+		comparison.AfterFailed = afterQ.FullyFailed() && !beforeQ.FullyFailed()
+
+		comparisons = append(comparisons, comparison)
+	}
+
+	// Best first; queries that stopped working entirely are the worst.
+	sort.Slice(comparisons, func(i, j int) bool {
+		if comparisons[i].AfterFailed != comparisons[j].AfterFailed {
+			return comparisons[j].AfterFailed
+		}
+		return comparisons[i].ImprovementPercent > comparisons[j].ImprovementPercent
+	})
+
+	var beforeTotal, afterTotal time.Duration
+	var beforeCount, afterCount int
+
+	for _, q := range before.QueryResults {
+		if q.SuccessfulExecutions > 0 {
+			beforeTotal += q.AvgDuration
+			beforeCount++
+		}
+	}
+
+	for _, q := range after.QueryResults {
+		if q.SuccessfulExecutions > 0 {
+			afterTotal += q.AvgDuration
+			afterCount++
+		}
+	}
+
+	var avgTimeImprovement float64
+	if beforeCount > 0 && afterCount > 0 {
+		beforeAvg := float64(beforeTotal.Microseconds()) / float64(beforeCount) / 1000
+		afterAvg := float64(afterTotal.Microseconds()) / float64(afterCount) / 1000
+
+		if beforeAvg > 0 {
+			avgTimeImprovement = (beforeAvg - afterAvg) / beforeAvg * 100
+		}
+	}
+
+	comparison := model.ComparisonResult{
+		Before: before,
+		After:  after,
+		ImprovementSummary: model.ImprovementStats{
+			AvgTimeImprovement: avgTimeImprovement,
+		},
+		QueryComparisons: comparisons,
+		BeforeCommit:     describeCommit(before.Environment),
+		AfterCommit:      describeCommit(after.Environment),
+	}
+
+	if before.EffectiveTimingScheme() != after.EffectiveTimingScheme() {
+		comparison.Warnings = append(comparison.Warnings,
+			fmt.Sprintf("query times were measured differently (before %s, after %s); pool wait is only counted in %s durations",
+				before.EffectiveTimingScheme(), after.EffectiveTimingScheme(), model.TimingIncludesAcquire))
+	}
+
+	if len(unavailable) > 0 {
+		comparison.Warnings = append(comparison.Warnings,
+			fmt.Sprintf("%d queries have no stored executions in one of the reports (written with includeExecutions false?), so whether they changed significantly is unknown: %s",
+				len(unavailable), strings.Join(unavailable, ", ")))
+	}
+
+	if len(sampled) > 0 {
+		comparison.Warnings = append(comparison.Warnings,
+			fmt.Sprintf("%d queries had executions truncated by maxExecutionsInReport, so their significance is computed from a sample: %s",
+				len(sampled), strings.Join(sampled, ", ")))
+	}
+
+	if before.Config.PercentileMethod != after.Config.PercentileMethod {
+		comparison.Warnings = append(comparison.Warnings,
+			fmt.Sprintf("percentiles were computed differently (before %s, after %s); nearest-rank reads higher on small samples, so replay the %s report to compare like with like",
+				before.Config.PercentileMethod, after.Config.PercentileMethod, config.PercentileNearestRank))
+	}
+
+	if before.Seed != after.Seed {
+		comparison.Warnings = append(comparison.Warnings,
+			fmt.Sprintf("the runs used different seeds (before %s, after %s), so their shuffled orders differ; pass the same --seed to both runs",
+				describeSeed(before.Seed), describeSeed(after.Seed)))
+	}
+
+	if describeEnvironment(before) != describeEnvironment(after) {
+		comparison.Warnings = append(comparison.Warnings,
+			"the runs come from different environments (server, settings or client host); see the environments below")
+	}
+
+	if before.Config.ComplexityRules.OrDefault() != after.Config.ComplexityRules.OrDefault() {
+		comparison.Warnings = append(comparison.Warnings,
+			"the runs used different complexityRules, so their complexity levels aren't comparable")
+	}
+
+	return comparison
+}
+
+// compareSLO pairs a query's SLO compliance in two runs, or returns nil
+// unless both runs judged it against the same objective.
+func compareSLO(before, after *model.SLOResult) *model.SLOComparison {
+	if before == nil || after == nil || before.Executions == 0 || after.Executions == 0 {
+		return nil
+	}
+	if before.Objective() != after.Objective() {
+		return nil
+	}
+	return &model.SLOComparison{
+		Objective:        after.Objective(),
+		BeforeCompliance: before.Compliance,
+		AfterCompliance:  after.Compliance,
+		BeforeMet:        before.Met,
+		AfterMet:         after.Met,
+	}
+}
+
+func describeSeed(seed uint64) string {
+	if seed == 0 {
+		return "none"
+	}
+	return strconv.FormatUint(seed, 10)
+}
+
+// SaveComparison writes a comparison built by BuildComparison to outputDir.
+func SaveComparison(comparison model.ComparisonResult, outputDir string) error {
+	filename, err := reportPath(outputDir, comparison.After.Config.OutputNameTemplate, reportName{
+		kind:      "comparison",
+		label:     comparison.Before.Label + "-vs-" + comparison.After.Label,
+		format:    "json",
+		ext:       "json",
+		timestamp: time.Now(),
+		gitCommit: reportCommit(comparison.After),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(comparison, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling comparison: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing comparison file: %w", err)
+	}
+
+	for _, w := range comparison.Warnings {
+		log.Printf("Warning: %s", w)
+	}
+
+	if comparison.Ordering != "" {
+		log.Printf("Run ordering: %s", comparison.Ordering)
+	}
+	log.Printf("Before environment: %s", describeEnvironment(comparison.Before))
+	log.Printf("After environment:  %s", describeEnvironment(comparison.After))
+
+	if comparison.BeforeCommit != "" || comparison.AfterCommit != "" {
+		log.Printf("Compared commits: %s -> %s",
+			orUnknown(comparison.BeforeCommit), orUnknown(comparison.AfterCommit))
+	}
+
+	for _, qc := range comparison.QueryComparisons {
+		if qc.AfterFailed {
+			log.Printf("Warning: every execution of %s failed in the after run", qc.Name)
+		}
+		if slo := qc.SLO; slo != nil {
+			log.Printf("SLO %s (%s): %.2f%% -> %.2f%% compliant, %s -> %s",
+				qc.Name, slo.Objective, slo.BeforeCompliance*100, slo.AfterCompliance*100, sloStatus(slo.BeforeMet), sloStatus(slo.AfterMet))
+		}
+	}
+
+	log.Printf("Comparison results saved to %s", filename)
+	return nil
+}
+
+func sloStatus(met bool) string {
+	if met {
+		return "met"
+	}
+	return "missed"
+}
+
+// BuildChanges keeps the queries of comparison whose average latency moved by
+// more than thresholdPercent either way, largest move first, and counts the
+// rest. Queries whose every execution failed in the after run are regressions
+// whatever their latency, and come first.
+func BuildChanges(comparison model.ComparisonResult, thresholdPercent float64) model.ComparisonChanges {
+	changes := model.ComparisonChanges{
+		BeforeLabel:      comparison.Before.Label,
+		AfterLabel:       comparison.After.Label,
+		BeforeCommit:     comparison.BeforeCommit,
+		AfterCommit:      comparison.AfterCommit,
+		ThresholdPercent: thresholdPercent,
+		Changes:          []model.QueryComparison{},
+		Warnings:         comparison.Warnings,
+	}
+
+	for _, qc := range comparison.QueryComparisons {
+		switch {
+		case qc.AfterFailed:
+			changes.Regressed++
+		case qc.ImprovementPercent > thresholdPercent:
+			changes.Improved++
+		case qc.ImprovementPercent < -thresholdPercent:
+			changes.Regressed++
+		default:
+			changes.Unchanged++
+			continue
+		}
+		changes.Changes = append(changes.Changes, qc)
+	}
+
+	sort.SliceStable(changes.Changes, func(i, j int) bool {
+		if changes.Changes[i].AfterFailed != changes.Changes[j].AfterFailed {
+			return changes.Changes[i].AfterFailed
+		}
+		return math.Abs(changes.Changes[i].ImprovementPercent) > math.Abs(changes.Changes[j].ImprovementPercent)
+	})
+	return changes
+}
+
+// SaveChanges writes the changes of comparison beyond thresholdPercent, as
+// built by BuildChanges, to outputDir and logs them.
+func SaveChanges(comparison model.ComparisonResult, thresholdPercent float64, outputDir string) error {
+	changes := BuildChanges(comparison, thresholdPercent)
+
+	filename, err := reportPath(outputDir, comparison.After.Config.OutputNameTemplate, reportName{
+		kind:      "changes",
+		label:     comparison.Before.Label + "-vs-" + comparison.After.Label,
+		format:    "json",
+		ext:       "json",
+		timestamp: time.Now(),
+		gitCommit: reportCommit(comparison.After),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling changes: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing changes file: %w", err)
+	}
+
+	log.Printf("%d queries moved by more than %g%% (%d faster, %d slower); %d unchanged",
+		len(changes.Changes), thresholdPercent, changes.Improved, changes.Regressed, changes.Unchanged)
+	for _, qc := range changes.Changes {
+		if qc.AfterFailed {
+			log.Printf("  %s: %.2f ms -> every execution failed", qc.Name, qc.BeforeAvgMs)
+			continue
+		}
+		significance := "not significant"
+		switch {
+		case qc.SignificanceUnavailable:
+			significance = "significance unknown"
+		case qc.Significant:
+			significance = "significant"
+		}
+		log.Printf("  %s: %.2f ms -> %.2f ms (%+.1f%%, %s)", qc.Name, qc.BeforeAvgMs, qc.AfterAvgMs, -qc.ImprovementPercent, significance)
+	}
+
+	log.Printf("Changes saved to %s", filename)
+	return nil
+}
+
+// FindLatestResult returns the newest full JSON report (optionally gzipped)
+// under dir that was written before the given time, which should be when the
+// current run started. Since outputNameTemplate can place reports anywhere
+// below dir, candidates are found by walking the tree and summary or
+// comparison files are skipped by content, not name. Soak snapshots are
+// skipped too: they are part of a run, not a run of their own.
+func FindLatestResult(dir string, before time.Time) (string, error) {
+	paths, err := resultFiles(dir, before)
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		result, err := LoadResult(path)
+		if err != nil || len(result.QueryResults) == 0 {
+			continue
+		}
+		if result.Soak != nil && result.Soak.Sequence > 0 {
+			continue
+		}
+		return path, nil
+	}
+
+	return "", fmt.Errorf("no previous results found in %s", dir)
+}
+
+// resultFiles lists the JSON files (optionally gzipped) under dir modified
+// before the given time, newest first.
+func resultFiles(dir string, before time.Time) ([]string, error) {
+	type candidate struct {
+		path    string
+		modTime time.Time
+	}
+	var candidates []candidate
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !strings.HasSuffix(path, ".json") && !strings.HasSuffix(path, ".json.gz") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			return nil
+		}
+		candidates = append(candidates, candidate{path, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].modTime.After(candidates[j].modTime)
+	})
+
+	paths := make([]string, len(candidates))
+	for i, c := range candidates {
+		paths[i] = c.path
+	}
+	return paths, nil
+}
+
+// describeCommit renders a run's commit as "<sha> (<branch>[, dirty])".
+func describeCommit(env model.Environment) string {
+	if env.GitCommit == "" {
+		return ""
+	}
+	desc := env.GitCommit
+	if env.GitBranch != "" {
+		desc += " (" + env.GitBranch
+		if env.GitDirty {
+			desc += ", dirty"
+		}
+		desc += ")"
+	} else if env.GitDirty {
+		desc += " (dirty)"
+	}
+	return desc
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+// internal/report/compare_test.go
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+func TestFindLatestResult(t *testing.T) {
+	dir := t.TempDir()
+	runStart := time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC)
+
+	write := func(name string, modTime time.Time, result model.TestResult) string {
+		t.Helper()
+		result.SchemaVersion = model.SchemaVersion
+		result.QueryResults = []model.QueryResult{{Name: "orders", SuccessfulExecutions: 1}}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	baseline := write("performance-nightly-20240513.json", runStart.Add(-24*time.Hour), model.TestResult{Label: "nightly"})
+	// The current soak run's snapshots, one in a per-run directory, are
+	// written after it started; earlier runs' snapshots before it.
+	write("soak/performance-nightly-snapshot-001.json", runStart.Add(10*time.Minute), model.TestResult{Soak: &model.Soak{Sequence: 1}})
+	write("performance-nightly-snapshot-002.json", runStart.Add(20*time.Minute), model.TestResult{Soak: &model.Soak{Sequence: 2}})
+	write("performance-old-snapshot-003.json", runStart.Add(-time.Hour), model.TestResult{Soak: &model.Soak{Sequence: 3}})
+
+	got, err := FindLatestResult(dir, runStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != baseline {
+		t.Errorf("FindLatestResult() = %s, want %s", got, baseline)
+	}
+
+	// A finished soak's final report is a run like any other.
+	final := write("performance-soak-final.json", runStart.Add(-30*time.Minute), model.TestResult{Soak: &model.Soak{Snapshots: 3}})
+	if got, err := FindLatestResult(dir, runStart); err != nil || got != final {
+		t.Errorf("FindLatestResult() = %s, %v, want %s", got, err, final)
+	}
+
+	if _, err := FindLatestResult(dir, runStart.Add(-48*time.Hour)); err == nil {
+		t.Error("FindLatestResult() found a report older than any in the directory")
+	}
+}
@@ -2,88 +2,357 @@
 package report
 
 import (
+	"encoding/csv"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/0xsj/fn-analyzer/internal/config"
 	"github.com/0xsj/fn-analyzer/internal/model"
 )
 
+// CSVColumns lists the columns of the CSV reports in their default order.
+// Duration columns are named with the report's unit as a suffix, e.g.
+// avg_ms. The detailed CSV also has the query's SQL after its description.
+var CSVColumns = []string{
+	"name", "description", "executions", "errors", "success_rate",
+	"avg", "median", "p95", "p99", "stddev", "min", "max",
+	"rows", "complexity", "cold", "cold_warm_ratio",
+}
+
+// csvColumn renders one column of the CSV reports.
+type csvColumn struct {
+	duration bool // The header takes the unit as a suffix
+	value    func(u durationUnit, q model.QueryResult) string
+}
+
+var csvColumnValues = map[string]csvColumn{
+	"name": {value: func(u durationUnit, q model.QueryResult) string {
+		return q.Name
+	}},
+	"description": {value: func(u durationUnit, q model.QueryResult) string {
+		return q.Description
+	}},
+	// On one line, so each query stays one line of the file
+	"sql": {value: func(u durationUnit, q model.QueryResult) string {
+		return sqlLineBreaks.Replace(q.SQL)
+	}},
+	"executions": {value: func(u durationUnit, q model.QueryResult) string {
+		return strconv.Itoa(len(q.Executions))
+	}},
+	"errors": {value: func(u durationUnit, q model.QueryResult) string {
+		return strconv.Itoa(q.Errors)
+	}},
+	"success_rate": {value: func(u durationUnit, q model.QueryResult) string {
+		return fmt.Sprintf("%.4f", q.SuccessRate)
+	}},
+	"avg": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.number(q.AvgDuration)
+	}},
+	"median": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.number(q.MedianDuration)
+	}},
+	"p95": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.p95(q)
+	}},
+	"p99": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.p99(q)
+	}},
+	"stddev": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.number(q.StdDevDuration)
+	}},
+	"min": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.number(q.MinDuration)
+	}},
+	"max": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		return u.number(q.MaxDuration)
+	}},
+	"rows": {value: func(u durationUnit, q model.QueryResult) string {
+		return strconv.FormatInt(q.RowsAffected, 10)
+	}},
+	"complexity": {value: func(u durationUnit, q model.QueryResult) string {
+		return q.QueryComplexity
+	}},
+	// Empty unless the run measured cold executions
+	"cold": {duration: true, value: func(u durationUnit, q model.QueryResult) string {
+		if q.ColdDuration == 0 {
+			return ""
+		}
+		return u.number(q.ColdDuration)
+	}},
+	"cold_warm_ratio": {value: func(u durationUnit, q model.QueryResult) string {
+		if q.ColdDuration == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.2f", q.ColdWarmRatio)
+	}},
+}
+
+var sqlLineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// utf8BOM starts the CSV reports when csvBom is set, so spreadsheet software
+// reads them as UTF-8 rather than the system's code page.
+const utf8BOM = "\uFEFF"
+
+// ValidateCSVDelimiter returns an error unless delimiter is a single
+// character that can separate CSV fields. An empty delimiter means a comma.
+func ValidateCSVDelimiter(delimiter string) error {
+	if delimiter == "" {
+		return nil
+	}
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return fmt.Errorf("CSV delimiter must be a single character other than a quote or line break, got %q", delimiter)
+	}
+	return nil
+}
+
+// encodeCSV renders records in the configured CSV dialect, preceded by a
+// byte order mark if bom is set and the config asks for one.
+func encodeCSV(cfg config.Config, bom bool, records ...[]string) (string, error) {
+	var b strings.Builder
+	if bom && cfg.CSVBOM {
+		b.WriteString(utf8BOM)
+	}
+
+	w := csv.NewWriter(&b)
+	if cfg.CSVDelimiter != "" {
+		w.Comma, _ = utf8.DecodeRuneInString(cfg.CSVDelimiter)
+	}
+	if err := w.WriteAll(records); err != nil {
+		return "", fmt.Errorf("error encoding CSV: %w", err)
+	}
+	return b.String(), nil
+}
+
+// ValidateCSVColumns returns an error if a column isn't one of CSVColumns or
+// is listed twice. No columns means all of them.
+func ValidateCSVColumns(columns []string) error {
+	seen := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if !slices.Contains(CSVColumns, c) {
+			return fmt.Errorf("unknown CSV column %q (want %s)", c, strings.Join(CSVColumns, ", "))
+		}
+		if seen[c] {
+			return fmt.Errorf("CSV column %q is listed twice", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// csvColumns returns the columns pinned by the config, or all of them.
+func csvColumns(cfg config.Config) []string {
+	if len(cfg.CSVColumns) > 0 {
+		return cfg.CSVColumns
+	}
+	return CSVColumns
+}
+
+// detailedCSVColumns returns columns with sql after the description, or
+// after the name without one.
+func detailedCSVColumns(columns []string) []string {
+	at := 0
+	if i := slices.Index(columns, "description"); i >= 0 {
+		at = i + 1
+	} else if i := slices.Index(columns, "name"); i >= 0 {
+		at = i + 1
+	}
+	return slices.Insert(slices.Clone(columns), at, "sql")
+}
+
 func SaveCSV(result model.TestResult, outputDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	label := result.Label
-	if label == "" {
-		label = "test"
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance", "csv", "csv"))
+	if err != nil {
+		return err
 	}
 
-	filename := filepath.Join(outputDir, fmt.Sprintf("performance-%s-%s.csv", label, timestamp))
+	u := reportUnit(result)
+	columns := csvColumns(result.Config)
+	records := [][]string{csvHeader(u, columns)}
+	for _, q := range result.QueryResults {
+		records = append(records, csvRow(u, columns, q))
+	}
 
-	f, err := os.Create(filename)
+	data, err := encodeCSV(result.Config, true, records...)
 	if err != nil {
-		return fmt.Errorf("error creating CSV file: %w", err)
+		return err
 	}
-	defer f.Close()
+	if err := writeReportFile(filename, []byte(data), result.Config.CompressReports); err != nil {
+		return fmt.Errorf("error writing CSV file: %w", err)
+	}
+
+	log.Printf("CSV results saved to %s", filename)
 
-	f.WriteString("name,description,executions,errors,avg_ms,p95_ms,min_ms,max_ms,rows,complexity\n")
+	if result.Heatmap != nil {
+		return saveHeatmapCSV(result, outputDir)
+	}
+	return nil
+}
 
-	for _, q := range result.QueryResults {
-		avg := float64(q.AvgDuration.Microseconds()) / 1000
-		p95 := float64(q.Percentile95.Microseconds()) / 1000
-		min := float64(q.MinDuration.Microseconds()) / 1000
-		max := float64(q.MaxDuration.Microseconds()) / 1000
+// saveHeatmapCSV writes the run's heatmap with one row per time window,
+// labelled by its offset from the start of the run, and one column per
+// latency bucket.
+func saveHeatmapCSV(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "heatmap", "csv", "csv"))
+	if err != nil {
+		return err
+	}
 
-		desc := strings.ReplaceAll(q.Description, "\"", "\"\"")
-		desc = strings.ReplaceAll(desc, ",", " ")
+	h := result.Heatmap
+	header := []string{"window_start_s"}
+	for _, bound := range h.BucketsMs {
+		header = append(header, fmt.Sprintf("le_%gms", bound))
+	}
+	if len(h.BucketsMs) > 0 {
+		header = append(header, fmt.Sprintf("gt_%gms", h.BucketsMs[len(h.BucketsMs)-1]))
+	}
+	records := [][]string{header}
 
-		line := fmt.Sprintf("\"%s\",\"%s\",%d,%d,%.2f,%.2f,%.2f,%.2f,%d,%s\n",
-			q.Name, desc, len(q.Executions), q.Errors,
-			avg, p95, min, max, q.RowsAffected, q.QueryComplexity)
+	for w, row := range h.Counts {
+		record := []string{fmt.Sprintf("%g", float64(w)*h.WindowSeconds)}
+		for _, n := range row {
+			record = append(record, strconv.Itoa(n))
+		}
+		records = append(records, record)
+	}
 
-		f.WriteString(line)
+	data, err := encodeCSV(result.Config, true, records...)
+	if err != nil {
+		return err
+	}
+	if err := writeReportFile(filename, []byte(data), result.Config.CompressReports); err != nil {
+		return fmt.Errorf("error writing heatmap CSV file: %w", err)
 	}
 
-	log.Printf("CSV results saved to %s", filename)
+	log.Printf("Heatmap CSV saved to %s", filename)
 	return nil
 }
 
-func SaveDetailedCSV(result model.TestResult, outputDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	label := result.Label
+func csvHeader(u durationUnit, columns []string) []string {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c
+		if csvColumnValues[c].duration {
+			header[i] += "_" + u.name
+		}
+	}
+	return header
+}
+
+func csvRow(u durationUnit, columns []string, q model.QueryResult) []string {
+	cells := make([]string, len(columns))
+	for i, c := range columns {
+		cells[i] = csvColumnValues[c].value(u, q)
+	}
+	return cells
+}
+
+// CSVStream appends a CSV row per query while a run is in progress, so the
+// results of finished queries survive a crash. Rows use the same columns as
+// SaveCSV and are written to disk as soon as they arrive.
+type CSVStream struct {
+	mu      sync.Mutex
+	file    *os.File
+	cfg     config.Config
+	unit    durationUnit
+	columns []string
+	path    string
+}
+
+// OpenCSVStream creates the partial CSV report for a run starting at start
+// and writes its header. With durationUnit auto, rows are in milliseconds
+// since the fastest query isn't known yet.
+func OpenCSVStream(cfg config.Config, start time.Time) (*CSVStream, error) {
+	label := cfg.Label
 	if label == "" {
 		label = "test"
 	}
+	path, err := reportPath(cfg.OutputDir, cfg.OutputNameTemplate, reportName{
+		kind:      "performance-partial",
+		label:     label,
+		format:    "csv",
+		ext:       "csv",
+		timestamp: start,
+		gitCommit: cfg.GitSHA,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	filename := filepath.Join(outputDir, fmt.Sprintf("performance-detailed-%s-%s.csv", label, timestamp))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating partial CSV file: %w", err)
+	}
 
-	f, err := os.Create(filename)
+	s := &CSVStream{file: file, cfg: cfg, unit: reportUnit(model.TestResult{Config: cfg}), columns: csvColumns(cfg), path: path}
+	header, err := encodeCSV(cfg, true, csvHeader(s.unit, s.columns))
+	if err == nil {
+		err = s.write(header)
+	}
 	if err != nil {
-		return fmt.Errorf("error creating detailed CSV file: %w", err)
+		file.Close()
+		return nil, err
 	}
-	defer f.Close()
 
-	f.WriteString("name,description,sql,executions,errors,avg_ms,p95_ms,min_ms,max_ms,rows,complexity\n")
+	log.Printf("Streaming per-query CSV rows to %s", path)
+	return s, nil
+}
 
-	for _, q := range result.QueryResults {
-		avg := float64(q.AvgDuration.Microseconds()) / 1000
-		p95 := float64(q.Percentile95.Microseconds()) / 1000
-		min := float64(q.MinDuration.Microseconds()) / 1000
-		max := float64(q.MaxDuration.Microseconds()) / 1000
+// Write appends q's row and syncs it to disk. It is safe for concurrent use.
+func (s *CSVStream) Write(q model.QueryResult) error {
+	row, err := encodeCSV(s.cfg, false, csvRow(s.unit, s.columns, q))
+	if err != nil {
+		return err
+	}
+	return s.write(row)
+}
+
+func (s *CSVStream) write(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.WriteString(line); err != nil {
+		return fmt.Errorf("error writing partial CSV file: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("error syncing partial CSV file: %w", err)
+	}
+	return nil
+}
 
-		desc := strings.ReplaceAll(q.Description, "\"", "\"\"")
-		desc = strings.ReplaceAll(desc, ",", " ")
+// Close closes the partial CSV file.
+func (s *CSVStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
 
-		sql := strings.ReplaceAll(q.SQL, "\"", "\"\"")
-		sql = strings.ReplaceAll(sql, ",", " ")
-		sql = strings.ReplaceAll(sql, "\n", " ")
+func SaveDetailedCSV(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance-detailed", "csv", "csv"))
+	if err != nil {
+		return err
+	}
 
-		line := fmt.Sprintf("\"%s\",\"%s\",%d,%d,%.2f,%.2f,%.2f,%.2f,%d,%s\n",
-			q.Name, desc, len(q.Executions), q.Errors,
-			avg, p95, min, max, q.RowsAffected, q.QueryComplexity)
+	u := reportUnit(result)
+	columns := detailedCSVColumns(csvColumns(result.Config))
+	records := [][]string{csvHeader(u, columns)}
+	for _, q := range result.QueryResults {
+		records = append(records, csvRow(u, columns, q))
+	}
 
-		f.WriteString(line)
+	data, err := encodeCSV(result.Config, true, records...)
+	if err != nil {
+		return err
+	}
+	if err := writeReportFile(filename, []byte(data), result.Config.CompressReports); err != nil {
+		return fmt.Errorf("error writing detailed CSV file: %w", err)
 	}
 
 	log.Printf("Detailed CSV results saved to %s", filename)
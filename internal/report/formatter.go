@@ -3,98 +3,772 @@ package report
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/0xsj/fn-analyzer/internal/database"
 	"github.com/0xsj/fn-analyzer/internal/model"
 )
 
+// DefaultSummaryTopN is the length of the ranked lists in the console summary
+// when summaryTopN isn't configured.
+const DefaultSummaryTopN = 5
+
+// PrintSummary writes the human-readable run summary to stdout.
 func PrintSummary(result model.TestResult) {
+	topN := result.Config.SummaryTopN
+	if topN <= 0 {
+		topN = DefaultSummaryTopN
+	}
+	s := result.Summary
+	u := reportUnit(result)
+
 	fmt.Println("\n====== PERFORMANCE TEST SUMMARY ======")
-	fmt.Printf("Test Label: %s\n", result.Label)
-	fmt.Printf("Total Duration: %v\n", result.TotalDuration)
-	fmt.Printf("Queries: %d total, %d successful, %d with errors\n",
-		result.Summary.TotalQueries,
-		result.Summary.SuccessfulQueries,
-		result.Summary.TotalQueries-result.Summary.SuccessfulQueries)
-	fmt.Printf("Average Query Time: %.2f ms\n", result.Summary.AvgDurationMs)
-	fmt.Printf("Max Query Time: %.2f ms\n", result.Summary.MaxDurationMs)
-	fmt.Printf("Total Rows Returned: %d\n", result.Summary.TotalRowsReturned)
+	w := newTable()
+	fmt.Fprintf(w, "Test Label:\t%s\n", result.Label)
+	if m := result.Metadata; m.Version != "" {
+		fmt.Fprintf(w, "Analyzer:\tv%s (%s, %s/%s, %d CPUs) on %s\n", m.Version, m.GoVersion, m.OS, m.Arch, m.CPUs, m.Hostname)
+	}
+	if env := result.Environment; env.ServerVersion != "" {
+		fmt.Fprintf(w, "Server:\tMySQL %s at %s\n", env.ServerVersion, orUnknown(env.DSNHost))
+	}
+	if settings := serverSettings(result.Environment); settings != "" {
+		fmt.Fprintf(w, "Server Settings:\t%s\n", settings)
+	}
+	if result.Metadata.GitSHA != "" {
+		fmt.Fprintf(w, "Code Under Test:\t%s\n", result.Metadata.GitSHA)
+	}
+	if result.Seed != 0 {
+		fmt.Fprintf(w, "Seed:\t%d\n", result.Seed)
+	}
+	fmt.Fprintf(w, "Total Duration:\t%v\n", result.TotalDuration)
+	if setup := result.Setup; setup != nil {
+		fmt.Fprintf(w, "Setup:\t%v for %d statements (not in total duration)\n", setup.Duration.Round(time.Millisecond), setup.Statements)
+	}
+	fmt.Fprintf(w, "Queries:\t%d total, %d successful, %d partially failed, %d fully failed\n",
+		s.TotalQueries, s.SuccessfulQueries, s.PartiallyFailedQueries, s.FullyFailedQueries)
+	fmt.Fprintf(w, "Executions:\t%d total, %d failed\n", s.TotalExecutions, s.FailedExecutions)
+	fmt.Fprintf(w, "Average Query Time:\t%s\n", u.formatMs(s.AvgDurationMs))
+	fmt.Fprintf(w, "Median / P95 / P99:\t%s / %s / %s\n",
+		u.numberMs(s.MedianDurationMs), u.numberMs(s.P95DurationMs), u.formatMs(s.P99DurationMs))
+	fmt.Fprintf(w, "Max Query Time:\t%s\n", u.formatMs(s.MaxDurationMs))
+	fmt.Fprintf(w, "Achieved Throughput:\t%.1f queries/sec\n", s.AchievedQPS)
+	fmt.Fprintf(w, "Harness Overhead:\t%.1f µs per execution\n", s.HarnessOverheadUs)
+	fmt.Fprintf(w, "Total Rows Returned:\t%d\n", s.TotalRowsReturned)
+	if e := result.StatsD; e != nil {
+		fmt.Fprintf(w, "StatsD Metrics:\t%d sent to %s, %d dropped, %d failed\n", e.Sent, e.Address, e.Dropped, e.Failed)
+	}
+	w.Flush()
 
-	fmt.Println("\nQuery Complexity Distribution:")
-	complexities := make([]string, 0, len(result.Summary.QueriesByComplexity))
-	for complexity := range result.Summary.QueriesByComplexity {
-		complexities = append(complexities, complexity)
+	if len(result.Alerts) > 0 {
+		printAlerts(result.Alerts, result.Config.Alerts.IntervalSeconds)
+	}
+	if g := result.Guardrails; g.Throttled() {
+		printGuardrails(*g, result.TotalDuration)
+	}
+	if s.UnevenConnections && len(result.Connections) > 0 {
+		fmt.Printf("\nWarning: %s ran %.1fx slower than the others for the same queries; this usually points at its path to the server (proxy, load balancer) rather than the queries. See Latency by Connection below.\n",
+			connectionName(result.Connections[0]), s.ConnectionLatencySpread)
 	}
-	sort.Strings(complexities)
 
-	for _, complexity := range complexities {
-		count := result.Summary.QueriesByComplexity[complexity]
-		fmt.Printf("  %s: %d queries (%.1f%%)\n",
-			complexity,
-			count,
-			float64(count)/float64(result.Summary.TotalQueries)*100)
+	if result.Config.FreshConnPerQuery {
+		overall := s.AvgConnectMs + s.AvgDurationMs + s.AvgCloseMs
+		fmt.Println("\nFresh Connection Cost (connection opened per execution):")
+		w = newTable()
+		fmt.Fprintf(w, "  Avg Connect:\t%s\n", u.formatMs(s.AvgConnectMs))
+		fmt.Fprintf(w, "  Avg Query:\t%s\n", u.formatMs(s.AvgDurationMs))
+		fmt.Fprintf(w, "  Avg Close:\t%s\n", u.formatMs(s.AvgCloseMs))
+		if overall > 0 {
+			fmt.Fprintf(w, "  Avg Connect+Query+Close:\t%s (%.1f%% spent outside the query)\n",
+				u.formatMs(overall), (overall-s.AvgDurationMs)/overall*100)
+		}
+		w.Flush()
+	}
+
+	if mode := result.Config.TransactionMode; mode != "" && mode != "none" {
+		fmt.Printf("\nTransaction Overhead (%s): %s per execution on top of %s query time\n",
+			mode, u.formatMs(s.AvgTxOverheadMs), u.formatMs(s.AvgDurationMs))
+	}
+	if result.Config.RollbackWrites {
+		var rolledBack int
+		for _, q := range result.QueryResults {
+			if q.RolledBack {
+				rolledBack++
+			}
+		}
+		fmt.Printf("\nWrites Rolled Back: %d queries ran in BEGIN / statement / ROLLBACK, leaving the data unchanged; %s transaction overhead per execution is reported separately\n",
+			rolledBack, u.formatMs(s.AvgTxOverheadMs))
+	}
+	if result.Config.IsolationLevel != "" {
+		fmt.Printf("Isolation Level: %s\n", result.Config.IsolationLevel)
+	}
+
+	if len(s.ByStatementType) > 0 {
+		fmt.Println("\nLatency by Statement Type:")
+		w = newTable()
+		fmt.Fprintf(w, "  TYPE\tQUERIES\tEXECUTIONS\tAVG %[1]s\tP95 %[1]s\tERROR RATE\n", u.heading())
+		for _, kind := range statementKinds {
+			st, ok := s.ByStatementType[kind]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%d\t%s\t%s\t%.1f%%\n",
+				kind, st.Queries, st.Executions, u.numberMs(st.AvgDurationMs), u.numberMs(st.P95DurationMs), st.ErrorRate*100)
+		}
+		w.Flush()
+	}
+
+	fmt.Println("\nQuery Complexity Distribution:")
+	w = newTable()
+	for _, complexity := range sortedKeys(s.QueriesByComplexity) {
+		count := s.QueriesByComplexity[complexity]
+		fmt.Fprintf(w, "  %s:\t%d queries\t(%.1f%%)", complexity, count, float64(count)/float64(s.TotalQueries)*100)
+		if avg, ok := s.AvgDurationMsByComplexity[complexity]; ok {
+			fmt.Fprintf(w, "\tavg %s", u.formatMs(avg))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	fmt.Printf("Complexity Score vs. Avg Latency: Pearson r = %.2f, Spearman ρ = %.2f over %d queries\n",
+		s.ComplexityLatencyCorrelation, s.ComplexityLatencySpearman, s.ComplexityLatencyQueries)
+	if len(s.SimpleButSlow) > 0 {
+		fmt.Println("Simple but slow (score at or below median, slowest quarter):")
+		for _, name := range s.SimpleButSlow {
+			fmt.Printf("  %s\n", name)
+		}
 	}
 
-	fmt.Println("\nTop 5 Slowest Queries:")
 	sortedResults := make([]model.QueryResult, len(result.QueryResults))
 	copy(sortedResults, result.QueryResults)
-	sort.Slice(sortedResults, func(i, j int) bool {
+
+	fmt.Printf("\nTop %d Slowest Queries:\n", topN)
+	sort.SliceStable(sortedResults, func(i, j int) bool {
 		return sortedResults[i].AvgDuration > sortedResults[j].AvgDuration
 	})
-
+	w = newTable()
+	fmt.Fprintf(w, "  #\tQUERY\tAVG %[1]s\tP95 %[1]s\tP99 %[1]s\tTIMEOUTS\tQPS\tROWS\tCOMPLEXITY\tSCORE\n", u.heading())
 	for i, q := range sortedResults {
-		if i >= 5 {
+		if i >= topN {
 			break
 		}
-		avgMs := float64(q.AvgDuration.Microseconds()) / 1000
-		fmt.Printf("  %d. %s: %.2f ms avg, %d rows, %s complexity\n",
-			i+1, q.Name, avgMs, q.RowsAffected, q.QueryComplexity)
+		p95 := u.p95(q)
+		if q.TimeoutCensored {
+			p95 += "*"
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%s\t%.1f%%\t%.1f\t%d\t%s\t%d\n",
+			i+1, q.Name, u.number(q.AvgDuration), p95, u.p99(q),
+			q.TimeoutRate*100, q.AchievedQPS, q.RowsAffected, q.QueryComplexity, q.ComplexityScore)
+	}
+	w.Flush()
+	if anyInsufficientSamples(sortedResults[:min(topN, len(sortedResults))]) {
+		minSamples := result.Config.PercentileMinSamples
+		fmt.Printf("  n/a: insufficient samples, fewer than %d (p95) or %d (p99) successful executions\n", minSamples.P95, minSamples.P99)
+	}
+	if s.TimeoutCensoredQueries > 0 {
+		fmt.Println("  * too many executions timed out for this p95 to be trusted; see Timeout-censored Latency below")
+	}
+
+	printTotalTime(sortedResults, topN, u)
+
+	if len(result.TableBreakdown) > 0 {
+		fmt.Printf("\nTop %d Hottest Tables:\n", topN)
+		w = newTable()
+		fmt.Fprintf(w, "  #\tTABLE\tQUERIES\tEXECUTIONS\tAVG %[1]s\tTOTAL %[1]s\tWEIGHTED %[1]s\tSHARE\tROWS\n", u.heading())
+		for i, t := range result.TableBreakdown {
+			if i >= topN {
+				break
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%d\t%d\t%s\t%s\t%s\t%.1f%%\t%d\n",
+				i+1, t.Table, len(t.Queries), t.Executions, u.numberMs(t.AvgDurationMs), u.numberMs(t.TotalDurationMs),
+				u.numberMs(t.WeightedLatencyMs), t.LatencyShare*100, t.RowsReturned)
+		}
+		w.Flush()
+	}
+
+	if len(result.Connections) > 0 {
+		printConnections(result.Connections, topN, u)
 	}
 
-	fmt.Println("\nTop 5 Queries with Errors:")
-	sort.Slice(sortedResults, func(i, j int) bool {
+	if len(result.SchemaSpread) > 0 {
+		fmt.Println("\nLatency Across Schemas:")
+		w = newTable()
+		fmt.Fprintf(w, "  QUERY\tSCHEMAS\tMIN %[1]s\tMEDIAN %[1]s\tP95 %[1]s\tMAX %[1]s\tSLOWEST\n", u.heading())
+		for _, sp := range result.SchemaSpread {
+			slowest := make([]string, len(sp.Slowest))
+			for i, l := range sp.Slowest {
+				slowest[i] = fmt.Sprintf("%s (%s)", l.Schema, u.numberMs(l.AvgMs))
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%s\t%s\t%s\t%s\n", sp.Query, sp.Schemas,
+				u.numberMs(sp.MinMs), u.numberMs(sp.MedianMs), u.numberMs(sp.P95Ms), u.numberMs(sp.MaxMs), strings.Join(slowest, ", "))
+		}
+		w.Flush()
+	}
+
+	fmt.Printf("\nTop %d Queries with Errors:\n", topN)
+	sort.SliceStable(sortedResults, func(i, j int) bool {
 		return sortedResults[i].Errors > sortedResults[j].Errors
 	})
-
 	errorCount := 0
+	w = newTable()
 	for _, q := range sortedResults {
-		if q.Errors == 0 {
-			continue
-		}
-
-		errorCount++
-		if errorCount > 5 {
+		if q.Errors == 0 || errorCount >= topN {
 			break
 		}
+		if errorCount == 0 {
+			fmt.Fprintln(w, "  #\tQUERY\tERRORS\tSUCCESS\tTYPES\tFIRST ERROR")
+		}
+		errorCount++
 
-		fmt.Printf("  %d. %s: %d errors\n", errorCount, q.Name, q.Errors)
+		firstError := ""
 		if len(q.ErrorDetails) > 0 {
-			fmt.Printf("     First error: %s\n", q.ErrorDetails[0])
+			firstError = truncate(q.ErrorDetails[0], 80)
 		}
+		fmt.Fprintf(w, "  %d\t%s\t%d\t%.1f%%\t%s\t%s\n",
+			errorCount, q.Name, q.Errors, q.SuccessRate*100, formatCounts(q.ErrorsByType), firstError)
 	}
-
+	w.Flush()
 	if errorCount == 0 {
 		fmt.Println("  No queries with errors")
 	}
 
+	if len(s.ErrorsByType) > 0 {
+		fmt.Println("\nErrors by Type:")
+		w = newTable()
+		for _, errType := range sortedKeys(s.ErrorsByType) {
+			fmt.Fprintf(w, "  %s:\t%d\n", errType, s.ErrorsByType[errType])
+		}
+		w.Flush()
+	}
+
+	var zeroRows []string
+	for _, q := range result.QueryResults {
+		if q.ZeroRows {
+			zeroRows = append(zeroRows, q.Name)
+		}
+	}
+	if len(zeroRows) > 0 {
+		fmt.Println("\nWarning: these queries returned no rows in any iteration, so their")
+		fmt.Println("timings may not reflect real data (wrong parameters or an empty table?):")
+		for _, name := range zeroRows {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	for _, q := range result.QueryResults {
+		if len(q.Profile) == 0 {
+			continue
+		}
+		stages := make([]model.ProfileStage, len(q.Profile))
+		copy(stages, q.Profile)
+		sort.SliceStable(stages, func(i, j int) bool {
+			return stages[i].Duration > stages[j].Duration
+		})
+
+		fmt.Printf("\nProfile of %s:\n", q.Name)
+		w = newTable()
+		for i, stage := range stages {
+			if i >= topN {
+				break
+			}
+			fmt.Fprintf(w, "  %s:\t%s\t(%.1f%%)\n", stage.Stage, u.format(stage.Duration), stage.Percent)
+		}
+		w.Flush()
+	}
+
+	printQueryNotes("Non-deterministic Row Counts", result.QueryResults, func(q model.QueryResult) []string {
+		if !q.NonDeterministicRowCount {
+			return nil
+		}
+		return []string{fmt.Sprintf("returned between %d and %d rows; concurrent writes or LIMIT without a stable ORDER BY?", q.MinRowCount, q.MaxRowCount)}
+	})
+	printQueryNotes("Timeout-censored Latency", result.QueryResults, func(q model.QueryResult) []string {
+		if !q.TimeoutCensored {
+			return nil
+		}
+		return []string{fmt.Sprintf("%.1f%% of executions timed out; p95 %s counting timeouts (%s from survivors only), p99 %s",
+			q.TimeoutRate*100, u.format(q.CensoredPercentile95), u.format(q.Percentile95), u.format(q.CensoredPercentile99))}
+	})
+	printQueryNotes("Lint Warnings", result.QueryResults, func(q model.QueryResult) []string { return q.LintWarnings })
+	printQueryNotes("Plan Warnings", result.QueryResults, func(q model.QueryResult) []string { return q.PlanWarnings })
+	printCostMisestimates(result.CostLatency, u)
+	printColdWarm(result.QueryResults, topN, u)
+	printQueryNotes("SLA Violations", result.QueryResults, func(q model.QueryResult) []string { return q.SLAViolations })
+	printSLOs(result.QueryResults, result.Summary)
+	printQueryNotes("Row Lock Waits (server-wide while each query ran)", result.QueryResults, func(q model.QueryResult) []string {
+		if q.LockWaits == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("%d waits, %d ms waiting", q.LockWaits, q.LockWaitTimeMs)}
+	})
+	printQueryNotes("Plan Flipped During the Run (latency may be bimodal)", result.QueryResults, func(q model.QueryResult) []string {
+		if !q.PlanFlipped {
+			return nil
+		}
+		return []string{q.PlanFingerprints[0] + " → " + q.PlanFingerprints[1]}
+	})
+
+	if d := result.ServerDelta; d != nil {
+		printServerDelta(*d, s)
+	}
+	if b := result.ConnectionBenchmark; b != nil {
+		PrintConnectionBenchmark(*b)
+	}
+	if len(result.Workers) > 0 {
+		printWorkers(result.Workers)
+	}
+	if soak := result.Soak; soak != nil && len(soak.Trends) > 0 {
+		printSoakTrends(*soak, u)
+	}
+
 	fmt.Println("\nDatabase Information:")
-	fmt.Printf("  Version: %s\n", result.ConnectionInfo.Version)
-	fmt.Printf("  Threads Running: %d\n", result.ConnectionInfo.ThreadsRunning)
-	fmt.Printf("  Threads Connected: %d\n", result.ConnectionInfo.ThreadsConnected)
-	fmt.Printf("  Open Tables: %d\n", result.ConnectionInfo.OpenTables)
-	fmt.Printf("  Slow Queries: %d\n", result.ConnectionInfo.SlowQueries)
-	fmt.Printf("  Questions/sec: %.2f\n", result.ConnectionInfo.QuestionsPerSec)
+	info := result.ConnectionInfo
+	w = newTable()
+	fmt.Fprintf(w, "  Version:\t%s\n", info.Version)
+	fmt.Fprintf(w, "  Threads Running:\t%d\n", info.ThreadsRunning)
+	fmt.Fprintf(w, "  Threads Connected:\t%d\n", info.ThreadsConnected)
+	fmt.Fprintf(w, "  Open Tables:\t%d\n", info.OpenTables)
+	fmt.Fprintf(w, "  Slow Queries:\t%d\n", info.SlowQueries)
+	fmt.Fprintf(w, "  Questions/sec:\t%.2f\n", info.QuestionsPerSec)
+	if info.ConnectionMode != "" {
+		fmt.Fprintf(w, "  Connection Mode:\t%s\n", info.ConnectionMode)
+	}
+	if info.ConnectionMode == "pool" {
+		fmt.Fprintf(w, "  Pool Wait:\t%s\n", u.format(info.PoolWait))
+	}
+	if s.AvgAcquireMs > 0 {
+		fmt.Fprintf(w, "  Connection Acquire:\tavg %s, p95 %s per execution\n", u.numberMs(s.AvgAcquireMs), u.formatMs(s.P95AcquireMs))
+	}
+	if result.EffectiveTimingScheme() == model.TimingIncludesAcquire {
+		fmt.Fprintf(w, "  Timing:\tquery times include waiting for a pooled connection\n")
+	} else {
+		fmt.Fprintf(w, "  Timing:\tquery times exclude connection acquisition\n")
+	}
+	if info.Reconnects > 0 {
+		fmt.Fprintf(w, "  Reconnects:\t%d\n", info.Reconnects)
+	}
+	if info.HealthChecks > 0 {
+		fmt.Fprintf(w, "  Health Checks:\t%d idle connections pinged, %d evicted\n", info.HealthChecks, info.HealthCheckEvictions)
+	}
+	if p := info.PoolLimit; p != nil {
+		if p.Clamped {
+			fmt.Fprintf(w, "  Pool Size:\t%d connections, clamped from %d (max_connections %d)\n", p.MaxOpenConns, p.Requested, p.ServerMaxConnections)
+		} else {
+			fmt.Fprintf(w, "  Pool Size:\t%d connections (max_connections %d)\n", p.MaxOpenConns, p.ServerMaxConnections)
+		}
+	}
+	if wc := info.Workload; wc != nil {
+		fmt.Fprintf(w, "  Workload:\tfull joins: %d, full scans: %d, sort scans: %d, rows read by scans: %d\n",
+			wc.SelectFullJoin, wc.SelectScan, wc.SortScan, wc.HandlerReadRndNext)
+	}
+	w.Flush()
 
 	fmt.Println("\nTest Completed At:", time.Now().Format(time.RFC1123))
 	fmt.Println("======================================")
 }
 
+func anyInsufficientSamples(results []model.QueryResult) bool {
+	for _, q := range results {
+		if q.P95InsufficientSamples || q.P99InsufficientSamples {
+			return true
+		}
+	}
+	return false
+}
+
+// printTotalTime lists the queries the run spent the most time in, summed
+// over their successful executions. A fast query called often can outrank
+// the slowest one.
+func printTotalTime(results []model.QueryResult, topN int, u durationUnit) {
+	var timed []model.QueryResult
+	for _, q := range results {
+		if q.TotalDuration > 0 {
+			timed = append(timed, q)
+		}
+	}
+	if len(timed) == 0 {
+		return
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].PercentOfTotalTime > timed[j].PercentOfTotalTime
+	})
+
+	fmt.Printf("\nTop %d Queries by Total Time:\n", topN)
+	w := newTable()
+	fmt.Fprintf(w, "  #\tQUERY\tTOTAL %[1]s\t%% OF TOTAL\tEXECUTIONS\tAVG %[1]s\n", u.heading())
+	for i, q := range timed[:min(topN, len(timed))] {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%.1f%%\t%d\t%s\n",
+			i+1, q.Name, u.number(q.TotalDuration), q.PercentOfTotalTime, q.SuccessfulExecutions, u.number(q.AvgDuration))
+	}
+	w.Flush()
+}
+
+// printColdWarm lists the queries whose first execution was slowest relative
+// to their steady state.
+func printColdWarm(results []model.QueryResult, topN int, u durationUnit) {
+	var measured []model.QueryResult
+	for _, q := range results {
+		if q.ColdWarmRatio > 0 {
+			measured = append(measured, q)
+		}
+	}
+	if len(measured) == 0 {
+		return
+	}
+	sort.Slice(measured, func(i, j int) bool {
+		return measured[i].ColdWarmRatio > measured[j].ColdWarmRatio
+	})
+
+	fmt.Println("\nLargest Cold/Warm Ratio (first execution vs warm median):")
+	w := newTable()
+	fmt.Fprintf(w, "  QUERY\tCOLD %[1]s\tWARM MEDIAN %[1]s\tWARM P95 %[1]s\tRATIO\n", u.heading())
+	for _, q := range measured[:min(topN, len(measured))] {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%.1fx\n", q.Name, u.number(q.ColdDuration), u.number(q.MedianDuration), u.p95(q), q.ColdWarmRatio)
+	}
+	w.Flush()
+}
+
+// printSLOs lists every query with a latency SLO and how much of its error
+// budget the run used, missed SLOs first.
+func printSLOs(results []model.QueryResult, s model.ResultSummary) {
+	var declared []model.QueryResult
+	for _, q := range results {
+		if q.SLO != nil && q.SLO.Executions > 0 {
+			declared = append(declared, q)
+		}
+	}
+	if len(declared) == 0 {
+		return
+	}
+	sort.SliceStable(declared, func(i, j int) bool {
+		return declared[i].SLO.ErrorBudgetBurn > declared[j].SLO.ErrorBudgetBurn
+	})
+
+	fmt.Printf("\nLatency SLOs (%d of %d missed):\n", s.SLOMissedQueries, s.SLOQueries)
+	w := newTable()
+	fmt.Fprintf(w, "  QUERY\tOBJECTIVE\tCOMPLIANCE\tBUDGET BURNED\tSTATUS\n")
+	for _, q := range declared {
+		status := "met"
+		if !q.SLO.Met {
+			status = "MISSED"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%.2f%%\t%.0f%%\t%s\n",
+			q.Name, q.SLO.Objective(), q.SLO.Compliance*100, q.SLO.ErrorBudgetBurn*100, status)
+	}
+	w.Flush()
+}
+
+// misestimateFactor is how far a query's latency per unit of optimizer cost
+// must be from the suite median, either way, to be called out.
+const misestimateFactor = 10
+
+// printCostMisestimates lists queries much slower or faster than their
+// optimizer cost predicts relative to the rest of the suite.
+func printCostMisestimates(points []model.CostLatency, u durationUnit) {
+	var off []model.CostLatency
+	for _, p := range points {
+		if p.RelativeSpeed > misestimateFactor || (p.RelativeSpeed > 0 && p.RelativeSpeed < 1.0/misestimateFactor) {
+			off = append(off, p)
+		}
+	}
+	if len(off) == 0 {
+		return
+	}
+
+	fmt.Println("\nOptimizer Misestimates (latency per unit of cost vs suite median):")
+	w := newTable()
+	fmt.Fprintf(w, "  QUERY\tCOST\tAVG %s\tRELATIVE\tEST ROWS\tAVG ROWS\n", u.heading())
+	for _, p := range off {
+		estRows := "-"
+		if p.EstimatedRows != nil {
+			estRows = fmt.Sprintf("%d", *p.EstimatedRows)
+		}
+		fmt.Fprintf(w, "  %s\t%.2f\t%s\t%.1fx\t%s\t%.1f\n", p.Query, p.EstimatedCost, u.numberMs(p.AvgMs), p.RelativeSpeed, estRows, p.AvgRows)
+	}
+	w.Flush()
+}
+
+// coldCacheHitRate is the buffer pool hit rate over the run below which the
+// summary warns that results reflect a cold cache.
+const coldCacheHitRate = 0.95
+
+// printServerDelta turns the run's server counter deltas into rates per
+// execution and per returned row.
+func printServerDelta(d database.ServerCounters, s model.ResultSummary) {
+	fmt.Println("\nServer Activity (whole run, all sessions):")
+	w := newTable()
+	fmt.Fprintf(w, "  Questions:\t%d\n", d.Questions)
+	if s.TotalRowsReturned > 0 {
+		fmt.Fprintf(w, "  InnoDB Rows Read:\t%d (%.1f per returned row)\n",
+			d.InnodbRowsRead, float64(d.InnodbRowsRead)/float64(s.TotalRowsReturned))
+	} else {
+		fmt.Fprintf(w, "  InnoDB Rows Read:\t%d\n", d.InnodbRowsRead)
+	}
+	perExec := func(label string, n int64) {
+		if s.TotalExecutions > 0 {
+			fmt.Fprintf(w, "  %s:\t%d (%.2f per execution)\n", label, n, float64(n)/float64(s.TotalExecutions))
+		} else {
+			fmt.Fprintf(w, "  %s:\t%d\n", label, n)
+		}
+	}
+	perExec("Buffer Pool Disk Reads", d.InnodbBufferPoolReads)
+	hitRate, hasReads := d.BufferPoolHitRate()
+	if hasReads {
+		fmt.Fprintf(w, "  Buffer Pool Hit Rate:\t%.2f%%\n", hitRate*100)
+	}
+	perExec("Temp Tables on Disk", d.CreatedTmpDiskTables)
+	perExec("Full Joins", d.SelectFullJoin)
+	perExec("Sort Merge Passes", d.SortMergePasses)
+	perExec("Bytes Sent", d.BytesSent)
+	perExec("Bytes Received", d.BytesReceived)
+	w.Flush()
+	if hasReads && hitRate < coldCacheHitRate {
+		fmt.Printf("  Warning: only %.1f%% of page reads during the run hit the buffer pool; the run was disk-bound\n", hitRate*100)
+		fmt.Println("  and reflects a cold cache. Raise warmupIterations or repeat the run before trusting latencies.")
+	}
+}
+
+// PrintConnectionBenchmark prints one line per batch of fresh connections.
+func PrintConnectionBenchmark(b database.ConnectionBenchmark) {
+	fmt.Printf("\nConnection Establishment (%d connections per batch, concurrency %d):\n", b.Connections, b.Concurrency)
+	w := newTable()
+	fmt.Fprintln(w, "  MODE\tTLS\tMIN\tAVG\tP95\tMAX\tFAILURES")
+	for _, l := range b.Batches {
+		tls := "off"
+		if l.TLS {
+			tls = "on"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%d/%d\n", l.Mode, tls,
+			FormatDuration(l.Min), FormatDuration(l.Avg), FormatDuration(l.P95), FormatDuration(l.Max), l.Failures, l.Attempts)
+	}
+	w.Flush()
+	for _, l := range b.Batches {
+		if l.FirstError != "" {
+			fmt.Printf("  %s (TLS %t) first error: %s\n", l.Mode, l.TLS, l.FirstError)
+		}
+	}
+}
+
+// printAlerts lists every alert that fired, right under the headline numbers:
+// the run's latencies mean less if the server was struggling meanwhile.
+func printAlerts(alerts []model.Alert, intervalSeconds float64) {
+	fmt.Printf("\n!!! %d ALERTS FIRED DURING THE RUN !!!\n", len(alerts))
+	w := newTable()
+	fmt.Fprintln(w, "  FIRED\tRULE\tVALUE\tWORST\tDURATION")
+	for _, alert := range alerts {
+		duration := "until the end of the run"
+		if alert.ResolvedAt != nil {
+			duration = alert.ResolvedAt.Sub(alert.FiredAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%.4g\t%.4g\t%s\n",
+			alert.FiredAt.Format("15:04:05"), alert.Rule, alert.Value, alert.WorstValue, duration)
+	}
+	w.Flush()
+	fmt.Printf("  Metrics sampled every %gs; an alert shorter than that can be missed.\n", intervalSeconds)
+}
+
+// printGuardrails lists every pause the guardrails forced on the run, right
+// under the headline numbers: its throughput is what the server allowed, not
+// what it could sustain.
+func printGuardrails(g model.Guardrails, total time.Duration) {
+	fmt.Printf("\n!!! THROUGHPUT THROTTLED BY GUARDRAILS: dispatch paused %s (%.0f%% of the run) in %d pauses !!!\n",
+		g.PausedTime.Round(time.Millisecond), float64(g.PausedTime)/float64(max(total, 1))*100, len(g.Pauses))
+	w := newTable()
+	fmt.Fprintln(w, "  PAUSED\tRULE\tVALUE\tDURATION")
+	for _, pause := range g.Pauses {
+		duration := pause.Duration.Round(time.Millisecond).String()
+		if pause.ResumedAt == nil {
+			duration += " (until the end of the run)"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%.4g\t%s\n", pause.PausedAt.Format("15:04:05"), pause.Rule, pause.Value, duration)
+	}
+	w.Flush()
+}
+
+// printSoakTrends shows how each query's latency and error rate moved across
+// the snapshots of a soak: first and last snapshot, and the p95 of every
+// snapshot as a sparkline.
+func printSoakTrends(soak model.Soak, u durationUnit) {
+	fmt.Printf("\nSoak Trends (%d snapshots over %s):\n", soak.Snapshots, soak.WindowEnd.Sub(soak.WindowStart).Round(time.Second))
+	w := newTable()
+	fmt.Fprintf(w, "  QUERY\tAVG %[1]s FIRST→LAST\tP95 %[1]s FIRST→LAST\tERRORS FIRST→LAST\tP95 TREND\n", u.heading())
+	for _, trend := range soak.Trends {
+		if len(trend.Points) == 0 {
+			continue
+		}
+		first, last := trend.Points[0], trend.Points[len(trend.Points)-1]
+		p95s := make([]float64, len(trend.Points))
+		for i, p := range trend.Points {
+			p95s[i] = p.P95Ms
+		}
+		fmt.Fprintf(w, "  %s\t%s → %s\t%s → %s\t%.1f%% → %.1f%%\t%s\n", trend.Query,
+			u.numberMs(first.AvgMs), u.numberMs(last.AvgMs),
+			soakP95(first, u), soakP95(last, u),
+			first.ErrorRate*100, last.ErrorRate*100, sparkline(p95s))
+	}
+	w.Flush()
+}
+
+func soakP95(p model.SoakPoint, u durationUnit) string {
+	if p.P95InsufficientSamples {
+		return notAvailable
+	}
+	return u.numberMs(p.P95Ms)
+}
+
+// sparkline draws values as a row of block characters scaled from zero to
+// their maximum.
+func sparkline(values []float64) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	peak := slices.Max(values)
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if peak > 0 {
+			level = min(int(v/peak*float64(len(levels)-1)+0.5), len(levels)-1)
+		}
+		b.WriteRune(levels[level])
+	}
+	return b.String()
+}
+
+// printWorkers lists each worker's share of a distributed run and warns when
+// some of them failed, since the run's counts are then short.
+func printWorkers(workers []model.WorkerRun) {
+	fmt.Printf("\nWorkers (%d):\n", len(workers))
+	w := newTable()
+	fmt.Fprintln(w, "  ADDRESS\tITERATIONS\tCONCURRENCY\tEXECUTIONS\tSTATUS")
+	var failed, missing int
+	for _, worker := range workers {
+		status := "ok"
+		if worker.Error != "" {
+			status = "failed: " + truncate(worker.Error, 60)
+			failed++
+			missing += worker.Iterations
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%s\n", worker.Address, worker.Iterations, worker.Concurrency, worker.Executions, status)
+	}
+	w.Flush()
+	if failed > 0 {
+		fmt.Printf("  Warning: %d of %d workers failed; each query is missing %d of its iterations.\n", failed, len(workers), missing)
+	}
+}
+
+// printConnections lists the slowest connections, or workers in pool mode,
+// by latency relative to their queries' medians.
+func printConnections(conns []model.ConnectionStats, topN int, u durationUnit) {
+	fmt.Println("\nLatency by Connection (slowest first, relative to each query's median):")
+	w := newTable()
+	fmt.Fprintf(w, "  CONNECTION\tEXECUTIONS\tERRORS\tAVG %[1]s\tP95 %[1]s\tRELATIVE\n", u.heading())
+	for i, c := range conns {
+		if i >= topN {
+			break
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%s\t%s\t%.2fx\n",
+			connectionName(c), c.Executions, c.Errors, u.numberMs(c.AvgMs), u.numberMs(c.P95Ms), c.RelativeLatency)
+	}
+	w.Flush()
+	if len(conns) > topN {
+		fmt.Printf("  ... and %d more; fastest %.2fx\n", len(conns)-topN, conns[len(conns)-1].RelativeLatency)
+	}
+}
+
+// connectionName names a connection by its worker, and its server
+// connection id when known.
+func connectionName(c model.ConnectionStats) string {
+	if c.ConnectionID != 0 {
+		return fmt.Sprintf("worker %d (connection %d)", c.Worker, c.ConnectionID)
+	}
+	return fmt.Sprintf("worker %d", c.Worker)
+}
+
+// describeEnvironment summarizes on one line which server a run measured and
+// from where, e.g. "MySQL 8.0.36 at db:3306 from ci-1 (linux/amd64, 8 CPUs)".
+func describeEnvironment(result model.TestResult) string {
+	env, m := result.Environment, result.Metadata
+	desc := "MySQL " + orUnknown(env.ServerVersion) + " at " + orUnknown(env.DSNHost)
+	if m.Hostname != "" {
+		desc += fmt.Sprintf(" from %s (%s/%s, %d CPUs)", m.Hostname, m.OS, m.Arch, m.CPUs)
+	}
+	if settings := serverSettings(env); settings != "" {
+		desc += "; " + settings
+	}
+	return desc
+}
+
+// serverSettings renders the recorded server variables as "name=value, ...".
+func serverSettings(env model.Environment) string {
+	names := make([]string, 0, len(env.ServerVariables))
+	for name := range env.ServerVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + env.ServerVariables[name]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// statementKinds is the display order of the per-statement-type summary.
+var statementKinds = []string{"select", "insert", "update", "delete", "other"}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+// printQueryNotes prints a titled section listing each query's notes, or
+// nothing if no query has any.
+func printQueryNotes(title string, results []model.QueryResult, notes func(model.QueryResult) []string) {
+	printed := false
+	w := newTable()
+	for _, q := range results {
+		for _, note := range notes(q) {
+			if !printed {
+				fmt.Printf("\n%s:\n", title)
+				printed = true
+			}
+			fmt.Fprintf(w, "  %s:\t%s\n", q.Name, note)
+		}
+	}
+	w.Flush()
+}
+
+// formatCounts renders m as "a: 1, b: 2" in key order.
+func formatCounts(m map[string]int) string {
+	parts := make([]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		parts = append(parts, fmt.Sprintf("%s: %d", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truncate(s string, n int) string {
+	r := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-3]) + "..."
+}
+
 func FormatDuration(d time.Duration) string {
 	if d < time.Microsecond {
 		return fmt.Sprintf("%.2f ns", float64(d.Nanoseconds()))
 	} else if d < time.Millisecond {
-		return fmt.Sprintf("%.2f μs", float64(d.Nanoseconds())/1000)
+		return fmt.Sprintf("%.2f µs", float64(d.Nanoseconds())/1000)
 	} else if d < time.Second {
 		return fmt.Sprintf("%.2f ms", float64(d.Nanoseconds())/1000000)
 	} else if d < time.Minute {
@@ -0,0 +1,95 @@
+// internal/report/html.go
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+var htmlReport = template.Must(template.New("report").Funcs(unitFuncs(unitMs)).Funcs(template.FuncMap{
+	"pct":         func(f float64) float64 { return f * 100 },
+	"environment": describeEnvironment,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Performance Test: {{.Label}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.failed { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Performance Test: {{.Label}}</h1>
+<p>
+Run at {{.Timestamp.Format "2006-01-02 15:04:05 MST"}} in {{.TotalDuration}}.
+{{.Summary.TotalQueries}} queries, {{.Summary.TotalExecutions}} executions ({{.Summary.FailedExecutions}} failed).
+Average query time {{durMs .Summary.AvgDurationMs}}, throughput {{printf "%.1f" .Summary.AchievedQPS}} queries/sec.
+{{with .Guardrails}}{{if .Throttled}}<strong>Throttled by guardrails: dispatch paused {{.PausedTime}} in {{len .Pauses}} pauses.</strong>{{end}}{{end}}
+{{if .Environment.GitCommit}}Commit {{.Environment.GitCommit}} ({{.Environment.GitBranch}}).{{end}}
+{{if .Metadata.GitSHA}}Code under test {{.Metadata.GitSHA}}.{{end}}
+{{with .Metadata}}{{if .Version}}Analyzer v{{.Version}} ({{.GoVersion}}).{{end}}{{end}}
+Environment: {{environment .}}.
+</p>
+<table>
+<tr><th>Query</th><th>Avg ({{unit}})</th><th>P95 ({{unit}})</th><th>P99 ({{unit}})</th><th>QPS</th><th>Success</th><th>Rows</th><th>Complexity</th></tr>
+{{range .QueryResults}}<tr{{if or .SLAViolations .Errors}} class="failed"{{end}} title="{{.Description}}">
+<td>{{.Name}}</td>
+<td>{{dur .AvgDuration}}</td>
+<td>{{p95 .}}</td>
+<td>{{p99 .}}</td>
+<td>{{printf "%.1f" .AchievedQPS}}</td>
+<td>{{printf "%.1f" (pct .SuccessRate)}}%</td>
+<td>{{.RowsAffected}}</td>
+<td>{{.QueryComplexity}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// unitFuncs renders the report's durations in u.
+func unitFuncs(u durationUnit) template.FuncMap {
+	return template.FuncMap{
+		"dur":   u.number,
+		"p95":   u.p95,
+		"p99":   u.p99,
+		"durMs": u.formatMs,
+		"unit":  func() string { return u.label },
+	}
+}
+
+// SaveHTML writes a standalone HTML page with the run summary and a table of
+// per-query results.
+func SaveHTML(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance", "html", "html"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating HTML file: %w", err)
+	}
+	defer f.Close()
+
+	tmpl, err := htmlReport.Clone()
+	if err != nil {
+		return fmt.Errorf("error rendering HTML report: %w", err)
+	}
+	tmpl.Funcs(unitFuncs(reportUnit(result)))
+
+	if err := tmpl.Execute(f, result); err != nil {
+		return fmt.Errorf("error rendering HTML report: %w", err)
+	}
+
+	log.Printf("HTML report saved to %s", filename)
+	return nil
+}
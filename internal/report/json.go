@@ -6,28 +6,27 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
 	"time"
 
+	"github.com/0xsj/fn-analyzer/internal/config"
 	"github.com/0xsj/fn-analyzer/internal/model"
 )
 
 func SaveJSON(result model.TestResult, outputDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	label := result.Label
-	if label == "" {
-		label = "test"
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance", "json", "json"))
+	if err != nil {
+		return err
 	}
 
-	filename := filepath.Join(outputDir, fmt.Sprintf("performance-%s-%s.json", label, timestamp))
+	result.QueryResults = limitExecutions(result.QueryResults, result.Config.IncludeExecutions, result.Config.MaxExecutionsInReport)
 
 	data, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error marshaling results: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := writeReportFile(filename, data, result.Config.CompressReports); err != nil {
 		return fmt.Errorf("error writing results file: %w", err)
 	}
 
@@ -35,14 +34,54 @@ func SaveJSON(result model.TestResult, outputDir string) error {
 	return nil
 }
 
-func SaveSummaryJSON(result model.TestResult, outputDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	label := result.Label
-	if label == "" {
-		label = "test"
+// limitExecutions returns a copy of results with the per-execution detail
+// dropped entirely (include false) or cut to max executions of each query.
+// The executions kept are spread evenly across the run, in order, so they
+// aren't biased towards its cold start. Truncated results record how many
+// executions were left out.
+func limitExecutions(results []model.QueryResult, include bool, max int) []model.QueryResult {
+	if include && (max <= 0 || !anyExceeds(results, max)) {
+		return results
+	}
+
+	trimmed := make([]model.QueryResult, len(results))
+	for i, q := range results {
+		switch {
+		case !include:
+			q.Executions = nil
+		case len(q.Executions) > max:
+			q.ExecutionsTruncated = len(q.Executions) - max
+			q.Executions = spreadSample(q.Executions, max)
+		}
+		trimmed[i] = q
 	}
+	return trimmed
+}
 
-	filename := filepath.Join(outputDir, fmt.Sprintf("summary-%s-%s.json", label, timestamp))
+// spreadSample returns n of executions at evenly spaced positions, keeping
+// their order.
+func spreadSample(executions []model.QueryExecution, n int) []model.QueryExecution {
+	sample := make([]model.QueryExecution, n)
+	for i := range sample {
+		sample[i] = executions[i*len(executions)/n]
+	}
+	return sample
+}
+
+func anyExceeds(results []model.QueryResult, max int) bool {
+	for _, q := range results {
+		if len(q.Executions) > max {
+			return true
+		}
+	}
+	return false
+}
+
+func SaveSummaryJSON(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "summary", "json", "json"))
+	if err != nil {
+		return err
+	}
 
 	summary := struct {
 		Timestamp      time.Time           `json:"timestamp"`
@@ -102,7 +141,7 @@ func SaveSummaryJSON(result model.TestResult, outputDir string) error {
 		return fmt.Errorf("error marshaling summary: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := writeReportFile(filename, data, result.Config.CompressReports); err != nil {
 		return fmt.Errorf("error writing summary file: %w", err)
 	}
 
@@ -110,95 +149,73 @@ func SaveSummaryJSON(result model.TestResult, outputDir string) error {
 	return nil
 }
 
-func SaveComparisonJSON(before, after model.TestResult, outputDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	filename := filepath.Join(outputDir, fmt.Sprintf("comparison-%s-vs-%s-%s.json",
-		before.Label, after.Label, timestamp))
-
-	afterMap := make(map[string]model.QueryResult)
-	for _, q := range after.QueryResults {
-		afterMap[q.Name] = q
-	}
-
-	comparisons := make([]model.QueryComparison, 0, len(before.QueryResults))
-
-	for _, beforeQ := range before.QueryResults {
-		afterQ, found := afterMap[beforeQ.Name]
-		if !found {
-			continue
+// LoadResult reads a TestResult previously written by SaveJSON, gzipped or
+// not. Reports from earlier schema versions are upgraded to the current one;
+// reports from a newer version are refused rather than half-read. path may
+// also be a run directory (see CreateRunDir), whose newest results file is
+// read.
+func LoadResult(path string) (model.TestResult, error) {
+	var result model.TestResult
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		results, err := LoadRecentResults(path, 1)
+		if err != nil {
+			return result, fmt.Errorf("error reading results directory: %w", err)
 		}
-
-		beforeAvgMs := float64(beforeQ.AvgDuration.Microseconds()) / 1000
-		afterAvgMs := float64(afterQ.AvgDuration.Microseconds()) / 1000
-
-		var improvementPct float64
-		if beforeAvgMs > 0 {
-			improvementPct = (beforeAvgMs - afterAvgMs) / beforeAvgMs * 100
+		if len(results) == 0 {
+			return result, fmt.Errorf("no results file found in %s", path)
 		}
-
-		comparison := model.QueryComparison{
-			Name:               beforeQ.Name,
-			BeforeAvgMs:        beforeAvgMs,
-			AfterAvgMs:         afterAvgMs,
-			ImprovementPercent: improvementPct,
-			BeforeErrors:       beforeQ.Errors,
-			AfterErrors:        afterQ.Errors,
-			BeforeRows:         beforeQ.RowsAffected,
-			AfterRows:          afterQ.RowsAffected,
-		}
-
-		comparisons = append(comparisons, comparison)
+		return results[0], nil
 	}
 
-	sort.Slice(comparisons, func(i, j int) bool {
-		return comparisons[i].ImprovementPercent > comparisons[j].ImprovementPercent
-	})
-
-	var beforeTotal, afterTotal time.Duration
-	var beforeCount, afterCount int
-
-	for _, q := range before.QueryResults {
-		if q.SuccessfulExecutions > 0 {
-			beforeTotal += q.AvgDuration
-			beforeCount++
-		}
+	data, err := readReportFile(path)
+	if err != nil {
+		return result, fmt.Errorf("error reading results file: %w", err)
 	}
 
-	for _, q := range after.QueryResults {
-		if q.SuccessfulExecutions > 0 {
-			afterTotal += q.AvgDuration
-			afterCount++
-		}
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
 	}
-
-	var avgTimeImprovement float64
-	if beforeCount > 0 && afterCount > 0 {
-		beforeAvg := float64(beforeTotal.Microseconds()) / float64(beforeCount) / 1000
-		afterAvg := float64(afterTotal.Microseconds()) / float64(afterCount) / 1000
-
-		if beforeAvg > 0 {
-			avgTimeImprovement = (beforeAvg - afterAvg) / beforeAvg * 100
-		}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return result, fmt.Errorf("error parsing results file %s: %w", path, err)
 	}
-
-	comparison := model.ComparisonResult{
-		Before: before,
-		After:  after,
-		ImprovementSummary: model.ImprovementStats{
-			AvgTimeImprovement: avgTimeImprovement,
-		},
-		QueryComparisons: comparisons,
+	if header.SchemaVersion > model.SchemaVersion {
+		return result, fmt.Errorf("results file %s uses schema version %d, but this build of fn-analyzer only reads up to version %d; upgrade fn-analyzer to load it",
+			path, header.SchemaVersion, model.SchemaVersion)
 	}
 
-	data, err := json.MarshalIndent(comparison, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling comparison: %w", err)
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("error parsing results file %s: %w", path, err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
-		return fmt.Errorf("error writing comparison file: %w", err)
-	}
+	upgradeResult(&result)
+	return result, nil
+}
 
-	log.Printf("Comparison results saved to %s", filename)
-	return nil
+// upgradeResult fills in what reports written under earlier schema versions
+// don't record, so they compare correctly against current ones.
+func upgradeResult(result *model.TestResult) {
+	if result.SchemaVersion < 2 {
+		// Version 1 always used the default complexity rules, and its
+		// durations included waiting for a pooled connection.
+		result.Config.ComplexityRules = result.Config.ComplexityRules.OrDefault()
+		if result.TimingScheme == "" {
+			result.TimingScheme = model.TimingIncludesAcquire
+		}
+	}
+	if result.SchemaVersion < 3 && result.Config.PercentileMethod == "" {
+		// Percentiles were read at index floor(n·p) until version 3.
+		result.Config.PercentileMethod = config.PercentileNearestRank
+	}
+	if result.SchemaVersion < 4 {
+		// Failed queries weren't split until version 4.
+		for _, q := range result.QueryResults {
+			if q.FullyFailed() {
+				result.Summary.FullyFailedQueries++
+			} else if q.Errors > 0 {
+				result.Summary.PartiallyFailedQueries++
+			}
+		}
+	}
+	result.SchemaVersion = model.SchemaVersion
 }
@@ -0,0 +1,137 @@
+// internal/report/json_test.go
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// The files in testdata are frozen reports as each schema version wrote them.
+// Don't regenerate them: a report written today must keep loading the same
+// way after the model changes.
+
+func loadFixture(t *testing.T, name string) model.TestResult {
+	t.Helper()
+	result, err := LoadResult(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("LoadResult(%s) = %v", name, err)
+	}
+	return result
+}
+
+func TestLoadResultCurrent(t *testing.T) {
+	result := loadFixture(t, "result_v4.json")
+
+	if result.SchemaVersion != model.SchemaVersion || result.Label != "baseline" || len(result.QueryResults) != 2 {
+		t.Fatalf("loaded schema version %d, label %q and %d queries, want %d, baseline and 2",
+			result.SchemaVersion, result.Label, len(result.QueryResults), model.SchemaVersion)
+	}
+	if q := result.QueryResults[0]; q.Name != "orders_by_customer" || q.Percentile95 != 19*time.Millisecond {
+		t.Errorf("first query = %s with p95 %v, want orders_by_customer with 19ms", q.Name, q.Percentile95)
+	}
+	if result.Summary.FullyFailedQueries != 1 || result.Summary.PartiallyFailedQueries != 0 {
+		t.Errorf("fully/partially failed = %d/%d, want 1/0",
+			result.Summary.FullyFailedQueries, result.Summary.PartiallyFailedQueries)
+	}
+	if result.Config.Timeout != 30*time.Second || result.Config.PercentileMethod != config.PercentileLinear {
+		t.Errorf("config timeout %v with %s percentiles, want 30s with linear", result.Config.Timeout, result.Config.PercentileMethod)
+	}
+	if result.TimingScheme != model.TimingExcludesAcquire {
+		t.Errorf("timing scheme = %q, want %q", result.TimingScheme, model.TimingExcludesAcquire)
+	}
+}
+
+// Every property the current fixture holds must still exist in the model;
+// a renamed or removed field would otherwise be dropped silently on load.
+func TestLoadResultFixtureFieldsExist(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "result_v4.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var result model.TestResult
+	if err := dec.Decode(&result); err != nil {
+		t.Errorf("the model no longer reads result_v4.json: %v", err)
+	}
+}
+
+// A report from the previous version loads as the same result as the
+// current one, with the failed queries split as version 4 does.
+func TestLoadResultPrevious(t *testing.T) {
+	previous := loadFixture(t, "result_v3.json")
+	current := loadFixture(t, "result_v4.json")
+
+	if !reflect.DeepEqual(previous, current) {
+		t.Errorf("result_v3.json loads as\n%+v\nwant\n%+v", previous.Summary, current.Summary)
+	}
+}
+
+func TestLoadResultVersion1(t *testing.T) {
+	result := loadFixture(t, "result_v1.json")
+
+	if result.SchemaVersion != model.SchemaVersion {
+		t.Errorf("schema version = %d, want upgraded to %d", result.SchemaVersion, model.SchemaVersion)
+	}
+	if result.Config.PercentileMethod != config.PercentileNearestRank {
+		t.Errorf("percentile method = %q, want %q", result.Config.PercentileMethod, config.PercentileNearestRank)
+	}
+	if result.TimingScheme != model.TimingIncludesAcquire {
+		t.Errorf("timing scheme = %q, want %q", result.TimingScheme, model.TimingIncludesAcquire)
+	}
+	if result.Config.ComplexityRules != config.DefaultComplexityRules() {
+		t.Errorf("complexity rules = %+v, want the defaults", result.Config.ComplexityRules)
+	}
+	// Version 1 wrote the timeout in nanoseconds.
+	if result.Config.Timeout != 30*time.Second {
+		t.Errorf("timeout = %v, want 30s", result.Config.Timeout)
+	}
+	if result.Summary.FullyFailedQueries != 1 {
+		t.Errorf("fully failed queries = %d, want 1", result.Summary.FullyFailedQueries)
+	}
+}
+
+func TestLoadResultNewer(t *testing.T) {
+	_, err := LoadResult(filepath.Join("testdata", "result_v99.json"))
+	if err == nil {
+		t.Fatal("LoadResult() loaded a report from a newer schema version")
+	}
+	for _, want := range []string{"schema version 99", "upgrade fn-analyzer"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadResult() = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadResultGzip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "result_v4.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "result.json.gz")
+	if err := os.WriteFile(path, compressed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := LoadResult(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, loadFixture(t, "result_v4.json")) {
+		t.Error("the gzipped report loads differently from the plain one")
+	}
+}
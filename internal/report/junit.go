@@ -0,0 +1,97 @@
+// internal/report/junit.go
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// SaveJUnit writes one test case per query so CI systems can display the run.
+// A query fails if it breached an SLA, or if any execution failed and it has
+// no minSuccessRate allowing for that.
+func SaveJUnit(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance", "junit", "xml"))
+	if err != nil {
+		return err
+	}
+
+	suite := junitTestSuite{
+		Name:      "fn-analyzer." + result.Label,
+		Tests:     len(result.QueryResults),
+		Time:      result.TotalDuration.Seconds(),
+		Timestamp: result.Timestamp.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, q := range result.QueryResults {
+		tc := junitTestCase{
+			Name:      q.Name,
+			ClassName: "fn-analyzer." + result.Label,
+			Time:      q.AvgDuration.Seconds(),
+			SystemOut: fmt.Sprintf("avg %.2f ms, p95 %s ms, p99 %s ms, %.1f qps, %d/%d successful",
+				durationMs(q.AvgDuration), unitMs.p95(q), unitMs.p99(q),
+				q.AchievedQPS, q.SuccessfulExecutions, q.SuccessfulExecutions+q.Errors),
+		}
+
+		switch {
+		case len(q.SLAViolations) > 0:
+			tc.Failure = &junitFailure{
+				Message: "SLA violated",
+				Text:    strings.Join(q.SLAViolations, "\n"),
+			}
+		case q.Errors > 0 && q.MinSuccessRate == 0:
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d executions failed", q.Errors),
+				Text:    strings.Join(q.ErrorDetails, "\n"),
+			}
+		}
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JUnit report: %w", err)
+	}
+
+	if err := os.WriteFile(filename, append([]byte(xml.Header), data...), 0644); err != nil {
+		return fmt.Errorf("error writing JUnit file: %w", err)
+	}
+
+	log.Printf("JUnit report saved to %s", filename)
+	return nil
+}
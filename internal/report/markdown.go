@@ -0,0 +1,209 @@
+// internal/report/markdown.go
+package report
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// SaveMarkdown writes a Markdown summary suitable for pasting into a pull
+// request or wiki page.
+func SaveMarkdown(result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, resultReportName(result, "performance", "md", "md"))
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filename, []byte(renderMarkdown(result, 0)), 0644); err != nil {
+		return fmt.Errorf("error writing Markdown file: %w", err)
+	}
+
+	log.Printf("Markdown report saved to %s", filename)
+	return nil
+}
+
+// stepSummaryLimit is GitHub's limit on the size of one step's summary.
+const stepSummaryLimit = 1024 * 1024
+
+// AppendStepSummary appends a Markdown summary of result to path, the file
+// GitHub Actions shows on the job page: the Markdown report's statistics and
+// query table, the worst regressions against comparison when there is one,
+// and outcome, the reason the run fails, if any. Tables stop after maxRows
+// rows, pointing to the full report instead.
+func AppendStepSummary(path string, result model.TestResult, comparison *model.ComparisonResult, outcome error, maxRows int) error {
+	var b strings.Builder
+	if outcome != nil {
+		fmt.Fprintf(&b, "> [!CAUTION]\n> **Run failed:** %s\n\n", outcome)
+	}
+	b.WriteString(renderMarkdown(result, maxRows))
+	if comparison != nil {
+		writeRegressions(&b, *comparison, reportUnit(result), maxRows)
+		writeSLOComparison(&b, *comparison, maxRows)
+	}
+
+	summary := b.String()
+	if len(summary) > stepSummaryLimit {
+		note := fmt.Sprintf("\n\n_Summary truncated; see the full report in %s._\n", result.Config.OutputDir)
+		summary = summary[:strings.LastIndexByte(summary[:stepSummaryLimit-len(note)], '\n')] + note
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening step summary: %w", err)
+	}
+	if _, err := f.WriteString(summary + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing step summary: %w", err)
+	}
+	return f.Close()
+}
+
+// writeRegressions lists the queries that got slower in comparison, worst
+// first, after those that failed every execution.
+func writeRegressions(b *strings.Builder, comparison model.ComparisonResult, u durationUnit, maxRows int) {
+	var regressions []model.QueryComparison
+	for _, qc := range comparison.QueryComparisons {
+		if qc.ImprovementPercent < 0 || qc.AfterFailed {
+			regressions = append(regressions, qc)
+		}
+	}
+
+	fmt.Fprintf(b, "\n## Compared with %s\n\n", markdownEscape(comparison.Before.Label))
+	fmt.Fprintf(b, "Average query time improved %.1f%%, p95 %.1f%%.\n",
+		comparison.ImprovementSummary.AvgTimeImprovement, comparison.ImprovementSummary.P95TimeImprovement)
+	if len(regressions) == 0 {
+		b.WriteString("\nNo query got slower.\n")
+		return
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].AfterFailed != regressions[j].AfterFailed {
+			return regressions[i].AfterFailed
+		}
+		return regressions[i].ImprovementPercent < regressions[j].ImprovementPercent
+	})
+
+	fmt.Fprintf(b, "\n| Query | Before (%[1]s) | After (%[1]s) | Change | Significant |\n", u.label)
+	b.WriteString("|-------|---------:|---------:|-------:|:-----------:|\n")
+	for i, qc := range regressions {
+		if i == maxRows {
+			writeTruncated(b, len(regressions)-maxRows, comparison.After.Config.OutputDir)
+			break
+		}
+		if qc.AfterFailed {
+			fmt.Fprintf(b, "| %s | %s | failed | every execution failed | |\n", markdownEscape(qc.Name), u.numberMs(qc.BeforeAvgMs))
+			continue
+		}
+		significant := ""
+		switch {
+		case qc.SignificanceUnavailable:
+			significant = "n/a"
+		case qc.Significant:
+			significant = "yes"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %+.1f%% | %s |\n",
+			markdownEscape(qc.Name), u.numberMs(qc.BeforeAvgMs), u.numberMs(qc.AfterAvgMs), -qc.ImprovementPercent, significant)
+	}
+}
+
+// writeSLOComparison lists the latency SLO compliance of the queries that
+// declare one in both runs.
+func writeSLOComparison(b *strings.Builder, comparison model.ComparisonResult, maxRows int) {
+	var rows []model.QueryComparison
+	for _, qc := range comparison.QueryComparisons {
+		if qc.SLO != nil {
+			rows = append(rows, qc)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	b.WriteString("\n### Latency SLOs\n\n")
+	b.WriteString("| Query | Objective | Before | After |\n")
+	b.WriteString("|-------|-----------|-------:|------:|\n")
+	for i, qc := range rows {
+		if i == maxRows {
+			writeTruncated(b, len(rows)-maxRows, comparison.After.Config.OutputDir)
+			break
+		}
+		fmt.Fprintf(b, "| %s | %s | %.2f%% (%s) | %.2f%% (%s) |\n", markdownEscape(qc.Name), qc.SLO.Objective,
+			qc.SLO.BeforeCompliance*100, sloStatus(qc.SLO.BeforeMet), qc.SLO.AfterCompliance*100, sloStatus(qc.SLO.AfterMet))
+	}
+}
+
+// writeTruncated notes the rows a table left out.
+func writeTruncated(b *strings.Builder, omitted int, outputDir string) {
+	fmt.Fprintf(b, "\n_%d more not shown; see the full report in %s._\n", omitted, outputDir)
+}
+
+// renderMarkdown renders the Markdown report of result, with at most maxRows
+// queries in its table; 0 lists them all.
+func renderMarkdown(result model.TestResult, maxRows int) string {
+	u := reportUnit(result)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Performance Test: %s\n\n", result.Label)
+	fmt.Fprintf(&b, "- Run at: %s\n", result.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&b, "- Total duration: %s\n", result.TotalDuration)
+	fmt.Fprintf(&b, "- Queries: %d (%d partially failed, %d fully failed)\n",
+		result.Summary.TotalQueries, result.Summary.PartiallyFailedQueries, result.Summary.FullyFailedQueries)
+	fmt.Fprintf(&b, "- Executions: %d (%d failed)\n", result.Summary.TotalExecutions, result.Summary.FailedExecutions)
+	fmt.Fprintf(&b, "- Average query time: %s\n", u.formatMs(result.Summary.AvgDurationMs))
+	fmt.Fprintf(&b, "- Throughput: %.1f queries/sec\n", result.Summary.AchievedQPS)
+	if g := result.Guardrails; g.Throttled() {
+		fmt.Fprintf(&b, "- **Throttled by guardrails:** dispatch paused %s in %d pauses\n", g.PausedTime.Round(time.Millisecond), len(g.Pauses))
+	}
+	if result.Environment.GitCommit != "" {
+		fmt.Fprintf(&b, "- Commit: %s (%s)\n", result.Environment.GitCommit, result.Environment.GitBranch)
+	}
+	if result.Metadata.GitSHA != "" {
+		fmt.Fprintf(&b, "- Code under test: %s\n", result.Metadata.GitSHA)
+	}
+	if m := result.Metadata; m.Version != "" {
+		fmt.Fprintf(&b, "- Analyzer: v%s (%s)\n", m.Version, m.GoVersion)
+	}
+	fmt.Fprintf(&b, "- Environment: %s\n", describeEnvironment(result))
+
+	fmt.Fprintf(&b, "\n| Query | Avg (%[1]s) | P95 (%[1]s) | P99 (%[1]s) | QPS | Success | Rows | Complexity |\n", u.label)
+	b.WriteString("|-------|---------:|---------:|---------:|----:|--------:|-----:|------------|\n")
+	for i, q := range result.QueryResults {
+		if i == maxRows && maxRows > 0 {
+			writeTruncated(&b, len(result.QueryResults)-maxRows, result.Config.OutputDir)
+			break
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %.1f | %.1f%% | %d | %s |\n",
+			markdownEscape(q.Name), u.number(q.AvgDuration), u.p95(q), u.p99(q),
+			q.AchievedQPS, q.SuccessRate*100, q.RowsAffected, q.QueryComplexity)
+	}
+
+	var violations []string
+	for _, q := range result.QueryResults {
+		for _, v := range q.SLAViolations {
+			violations = append(violations, fmt.Sprintf("- **%s**: %s", markdownEscape(q.Name), v))
+		}
+	}
+	if len(violations) > 0 {
+		b.WriteString("\n## SLA Violations\n\n")
+		if maxRows > 0 && len(violations) > maxRows {
+			b.WriteString(strings.Join(violations[:maxRows], "\n"))
+			b.WriteString("\n")
+			writeTruncated(&b, len(violations)-maxRows, result.Config.OutputDir)
+		} else {
+			b.WriteString(strings.Join(violations, "\n"))
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,171 @@
+// internal/report/schema.go
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// TestResultSchema is the JSON Schema describing the report written by
+// SaveJSON. It is the documented output contract for downstream tools.
+//
+//go:embed schema/testresult.schema.json
+var TestResultSchema []byte
+
+// jsonSchema is the subset of JSON Schema used by TestResultSchema: type,
+// properties, required, items, additionalProperties and local $ref.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 json.RawMessage        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+// ValidateResult marshals result and checks it against TestResultSchema.
+func ValidateResult(result model.TestResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error marshaling results: %w", err)
+	}
+	return ValidateJSON(data)
+}
+
+// ValidateJSON checks an encoded report against TestResultSchema.
+func ValidateJSON(data []byte) error {
+	var root jsonSchema
+	if err := json.Unmarshal(TestResultSchema, &root); err != nil {
+		return fmt.Errorf("error parsing embedded schema: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("error parsing report: %w", err)
+	}
+
+	var problems []string
+	root.validate(&root, doc, "$", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("report does not match schema:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (s *jsonSchema) validate(root *jsonSchema, value any, path string, problems *[]string) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		def, ok := root.Defs[name]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: unresolved schema reference %s", path, s.Ref))
+			return
+		}
+		def.validate(root, value, path, problems)
+		return
+	}
+
+	if types := s.types(); len(types) > 0 {
+		actual := jsonType(value)
+		matched := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), actual))
+			return
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+
+		var extra *jsonSchema
+		allowExtra := true
+		if len(s.AdditionalProperties) > 0 {
+			if string(s.AdditionalProperties) == "false" {
+				allowExtra = false
+			} else if string(s.AdditionalProperties) != "true" {
+				extra = &jsonSchema{}
+				if err := json.Unmarshal(s.AdditionalProperties, extra); err != nil {
+					extra = nil
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "." + k
+			if prop, ok := s.Properties[k]; ok {
+				prop.validate(root, v[k], childPath, problems)
+			} else if extra != nil {
+				extra.validate(root, v[k], childPath, problems)
+			} else if !allowExtra {
+				*problems = append(*problems, fmt.Sprintf("%s: unexpected property", childPath))
+			}
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func (s *jsonSchema) types() []string {
+	if len(s.Type) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(s.Type, &single); err == nil {
+		return []string{single}
+	}
+	var multiple []string
+	json.Unmarshal(s.Type, &multiple)
+	return multiple
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,212 @@
+// internal/report/schema_test.go
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// filled returns a T with every exported field set to a non-zero value,
+// slices and maps holding one element, so omitempty leaves nothing out.
+func filled[T any]() T {
+	var v T
+	fill(reflect.ValueOf(&v).Elem(), map[reflect.Type]bool{})
+	return v
+}
+
+func fill(v reflect.Value, visiting map[reflect.Type]bool) {
+	switch v.Type() {
+	case reflect.TypeOf(time.Time{}):
+		v.Set(reflect.ValueOf(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)))
+		return
+	case reflect.TypeOf(time.Duration(0)):
+		v.SetInt(int64(1500 * time.Millisecond))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Pointer:
+		if visiting[v.Type().Elem()] {
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), visiting)
+	case reflect.Slice:
+		if visiting[v.Type().Elem()] {
+			return
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), visiting)
+	case reflect.Array:
+		for i := range v.Len() {
+			fill(v.Index(i), visiting)
+		}
+	case reflect.Map:
+		key := reflect.New(v.Type().Key()).Elem()
+		fill(key, visiting)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fill(elem, visiting)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		visiting[v.Type()] = true
+		defer delete(visiting, v.Type())
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), visiting)
+			}
+		}
+	}
+}
+
+// undeclared returns the paths of properties in value that schema doesn't
+// declare, where it declares an object's properties without allowing others.
+func undeclared(root, s *jsonSchema, value any, path string) []string {
+	if s.Ref != "" {
+		return undeclared(root, root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")], value, path)
+	}
+
+	var paths []string
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if prop, ok := s.Properties[k]; ok {
+				paths = append(paths, undeclared(root, prop, child, path+"."+k)...)
+			} else if len(s.Properties) > 0 && len(s.AdditionalProperties) == 0 {
+				paths = append(paths, path+"."+k)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for _, item := range v {
+				paths = append(paths, undeclared(root, s.Items, item, path+"[]")...)
+			}
+		}
+	}
+	return paths
+}
+
+func decodeReport(t *testing.T, data []byte) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// TestSchemaMatchesModel fails when a field is added to the report without
+// documenting it in the schema, or the schema and the model disagree on a
+// type.
+func TestSchemaMatchesModel(t *testing.T) {
+	result := filled[model.TestResult]()
+	if err := ValidateResult(result); err != nil {
+		t.Fatalf("ValidateResult() = %v", err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root jsonSchema
+	if err := json.Unmarshal(TestResultSchema, &root); err != nil {
+		t.Fatal(err)
+	}
+	if paths := undeclared(&root, &root, decodeReport(t, data), "$"); len(paths) > 0 {
+		slices.Sort(paths)
+		t.Errorf("properties missing from the schema:\n  %s", strings.Join(slices.Compact(paths), "\n  "))
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	data, err := json.Marshal(filled[model.TestResult]())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(doc map[string]any)
+		want   string // Substring of the error, or "" for a valid report
+	}{
+		{"valid", func(map[string]any) {}, ""},
+		{"unknown top-level key", func(doc map[string]any) { doc["addedLater"] = true }, ""},
+		{"unknown config key", func(doc map[string]any) { doc["config"].(map[string]any)["addedLater"] = 1 }, ""},
+		{"missing required", func(doc map[string]any) { delete(doc, "summary") }, `$: missing required property "summary"`},
+		{"missing nested required", func(doc map[string]any) {
+			delete(doc["queryResults"].([]any)[0].(map[string]any), "avgDurationNs")
+		}, `$.queryResults[0]: missing required property "avgDurationNs"`},
+		{"string for integer", func(doc map[string]any) { doc["schemaVersion"] = "4" }, "$.schemaVersion: expected integer, got string"},
+		{"float for integer", func(doc map[string]any) { doc["totalDurationNs"] = 1.5 }, "$.totalDurationNs: expected integer, got number"},
+		{"object for array", func(doc map[string]any) { doc["queryResults"] = map[string]any{} }, "$.queryResults: expected array or null, got object"},
+		{"null for object", func(doc map[string]any) { doc["config"] = nil }, "$.config: expected object, got null"},
+		{"wrong map value", func(doc map[string]any) {
+			doc["environment"].(map[string]any)["serverVariables"] = map[string]any{"max_connections": 151}
+		}, "$.environment.serverVariables.max_connections: expected string, got integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decodeReport(t, data).(map[string]any)
+			tt.mutate(doc)
+			mutated, err := json.Marshal(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ValidateJSON(mutated)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("ValidateJSON() = %v, want nil", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("ValidateJSON() = nil, want error containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("ValidateJSON() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJSONMalformed(t *testing.T) {
+	if err := ValidateJSON([]byte(`{"schemaVersion": `)); err == nil || !strings.Contains(err.Error(), "error parsing report") {
+		t.Errorf("ValidateJSON() = %v, want a parse error", err)
+	}
+}
+
+// The report schema leaves objects open, but the validator enforces
+// additionalProperties false where a schema sets it.
+func TestValidateUnexpectedProperty(t *testing.T) {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": { "name": { "type": "string" } },
+		"additionalProperties": false
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var problems []string
+	schema.validate(&schema, map[string]any{"name": "a", "extra": "b"}, "$", &problems)
+	if !slices.Equal(problems, []string{"$.extra: unexpected property"}) {
+		t.Errorf("problems = %q, want only $.extra", problems)
+	}
+}
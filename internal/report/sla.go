@@ -0,0 +1,154 @@
+// internal/report/sla.go
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// LoadSLAs reads an SLA file: a JSON object mapping query names to their
+// maximum p95 in milliseconds.
+func LoadSLAs(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SLA file: %w", err)
+	}
+
+	var slas map[string]float64
+	if err := json.Unmarshal(data, &slas); err != nil {
+		return nil, fmt.Errorf("error parsing SLA file %s: %w", path, err)
+	}
+	if len(slas) == 0 {
+		return nil, fmt.Errorf("SLA file %s names no queries", path)
+	}
+	for name, maxP95 := range slas {
+		if maxP95 <= 0 {
+			return nil, fmt.Errorf("SLA file %s: max p95 of %q must be a positive number of milliseconds, got %g", path, name, maxP95)
+		}
+	}
+	return slas, nil
+}
+
+// BuildSLACheck judges each query named in slas by its p95 in result. A query
+// the run doesn't have, or that never succeeded, breaches its SLA: there is
+// nothing to show it stayed within budget.
+func BuildSLACheck(result model.TestResult, slas map[string]float64, slaFile string) model.SLACheck {
+	check := model.SLACheck{
+		Label:            result.Label,
+		Commit:           describeCommit(result.Environment),
+		SLAFile:          slaFile,
+		PercentileMethod: result.Config.PercentileMethod,
+		Checks:           []model.SLAQueryCheck{},
+	}
+
+	byName := make(map[string]model.QueryResult, len(result.QueryResults))
+	for _, q := range result.QueryResults {
+		byName[q.Name] = q
+		if _, ok := slas[q.Name]; !ok {
+			check.Unchecked = append(check.Unchecked, q.Name)
+		}
+	}
+
+	var insufficient int
+	for name, maxP95 := range slas {
+		qc := model.SLAQueryCheck{Name: name, MaxP95Ms: maxP95}
+		q, found := byName[name]
+		switch {
+		case !found:
+			qc.Reason = "not in the run"
+		case q.SuccessfulExecutions == 0:
+			qc.Reason = "no successful executions"
+		default:
+			qc.P95Ms = float64(q.Percentile95.Microseconds()) / 1000
+			qc.Passed = qc.P95Ms <= maxP95
+			qc.InsufficientSamples = q.P95InsufficientSamples
+		}
+
+		if qc.Passed {
+			check.Passed++
+		} else {
+			check.Breached++
+		}
+		if qc.InsufficientSamples {
+			insufficient++
+		}
+		check.Checks = append(check.Checks, qc)
+	}
+
+	sort.Slice(check.Checks, func(i, j int) bool {
+		if check.Checks[i].Passed != check.Checks[j].Passed {
+			return !check.Checks[i].Passed
+		}
+		return check.Checks[i].Name < check.Checks[j].Name
+	})
+
+	if insufficient > 0 {
+		check.Warnings = append(check.Warnings,
+			fmt.Sprintf("%d queries have too few successful executions for their p95 to mean much; run more iterations before trusting a pass", insufficient))
+	}
+	if result.Config.PercentileMethod == config.PercentileNearestRank {
+		check.Warnings = append(check.Warnings,
+			"the run's percentiles were computed by nearest rank, which reads higher on small samples; replay the report to judge linear percentiles")
+	}
+	if len(check.Unchecked) > 0 {
+		check.Warnings = append(check.Warnings,
+			fmt.Sprintf("%d queries in the run have no SLA", len(check.Unchecked)))
+	}
+
+	return check
+}
+
+// SaveSLACheck writes an SLA check built by BuildSLACheck to outputDir and
+// logs each query's verdict.
+func SaveSLACheck(check model.SLACheck, result model.TestResult, outputDir string) error {
+	filename, err := reportPath(outputDir, result.Config.OutputNameTemplate, reportName{
+		kind:      "sla",
+		label:     result.Label,
+		format:    "json",
+		ext:       "json",
+		timestamp: time.Now(),
+		gitCommit: reportCommit(result),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(check, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling SLA check: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing SLA check file: %w", err)
+	}
+
+	for _, w := range check.Warnings {
+		log.Printf("Warning: %s", w)
+	}
+	if check.Commit != "" {
+		log.Printf("Checked commit: %s", check.Commit)
+	}
+
+	log.Printf("%d of %d queries within their SLA (%d breached)", check.Passed, len(check.Checks), check.Breached)
+	for _, qc := range check.Checks {
+		status := "pass"
+		if !qc.Passed {
+			status = "FAIL"
+		}
+		if qc.Reason != "" {
+			log.Printf("  %s %s: %s (max p95 %.2f ms)", status, qc.Name, qc.Reason, qc.MaxP95Ms)
+			continue
+		}
+		log.Printf("  %s %s: p95 %.2f ms, max %.2f ms", status, qc.Name, qc.P95Ms, qc.MaxP95Ms)
+	}
+
+	log.Printf("SLA check saved to %s", filename)
+	return nil
+}
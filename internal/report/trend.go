@@ -0,0 +1,145 @@
+// internal/report/trend.go
+package report
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+	"github.com/0xsj/fn-analyzer/pkg/utils"
+)
+
+// worseningThreshold is how far, relative to its mean, a query's fitted
+// latency must rise across the series to be flagged as trending worse.
+const worseningThreshold = 0.10
+
+// BuildTrend computes each query's average latency across runs, which must be
+// ordered oldest first. Queries are listed in order of first appearance.
+func BuildTrend(results []model.TestResult) []model.QueryTrend {
+	var trends []model.QueryTrend
+	index := make(map[string]int)
+
+	for run, result := range results {
+		for _, q := range result.QueryResults {
+			i, ok := index[q.Name]
+			if !ok {
+				i = len(trends)
+				index[q.Name] = i
+				trends = append(trends, model.QueryTrend{Name: q.Name, AvgMs: make([]float64, len(results))})
+			}
+			trends[i].AvgMs[run] = durationMs(q.AvgDuration)
+		}
+	}
+
+	for i := range trends {
+		var x, y []float64
+		for run, avg := range trends[i].AvgMs {
+			if avg > 0 {
+				x = append(x, float64(run))
+				y = append(y, avg)
+			}
+		}
+		if len(x) < 2 {
+			continue
+		}
+
+		trends[i].SlopeMs = utils.LinearRegressionSlope(x, y)
+		var mean float64
+		for _, v := range y {
+			mean += v
+		}
+		mean /= float64(len(y))
+		rise := trends[i].SlopeMs * (x[len(x)-1] - x[0])
+		trends[i].Worsening = mean > 0 && rise/mean > worseningThreshold
+	}
+
+	return trends
+}
+
+// SaveTrend writes a CSV with one row per query and one latency column per
+// run, followed by the fitted slope, and logs the queries trending worse.
+// results must be ordered oldest first.
+func SaveTrend(results []model.TestResult, outputDir string) error {
+	if len(results) < 2 {
+		return fmt.Errorf("a trend needs at least 2 runs, got %d", len(results))
+	}
+	latest := results[len(results)-1]
+
+	filename, err := reportPath(outputDir, latest.Config.OutputNameTemplate, reportName{
+		kind:      "trend",
+		label:     fmt.Sprintf("%d-runs", len(results)),
+		format:    "csv",
+		ext:       "csv",
+		timestamp: time.Now(),
+		gitCommit: reportCommit(latest),
+	})
+	if err != nil {
+		return err
+	}
+
+	header := []string{"name"}
+	for _, result := range results {
+		header = append(header, fmt.Sprintf("%s %s avg_ms", result.Label, result.Timestamp.Format("20060102-150405")))
+	}
+	header = append(header, "slope_ms_per_run", "worsening")
+	records := [][]string{header}
+
+	trends := BuildTrend(results)
+	for _, t := range trends {
+		record := []string{t.Name}
+		for _, avg := range t.AvgMs {
+			cell := ""
+			if avg > 0 {
+				cell = fmt.Sprintf("%.3f", avg)
+			}
+			record = append(record, cell)
+		}
+		records = append(records, append(record, fmt.Sprintf("%.4f", t.SlopeMs), strconv.FormatBool(t.Worsening)))
+	}
+
+	// The newest run's config picks the CSV dialect, as it does the file name.
+	data, err := encodeCSV(latest.Config, true, records...)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		return fmt.Errorf("error writing trend file: %w", err)
+	}
+
+	for _, t := range trends {
+		if t.Worsening {
+			log.Printf("Trending worse: %s (+%.3f ms per run)", t.Name, t.SlopeMs)
+		}
+	}
+
+	log.Printf("Trend across %d runs saved to %s", len(results), filename)
+	return nil
+}
+
+// LoadRecentResults loads the n newest full JSON reports (optionally
+// gzipped) under dir, ordered oldest first by run time.
+func LoadRecentResults(dir string, n int) ([]model.TestResult, error) {
+	paths, err := resultFiles(dir, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var results []model.TestResult
+	for _, path := range paths {
+		if len(results) >= n {
+			break
+		}
+		if result, err := LoadResult(path); err == nil && len(result.QueryResults) > 0 {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Timestamp.Before(results[j].Timestamp)
+	})
+	return results, nil
+}
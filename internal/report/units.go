@@ -0,0 +1,127 @@
+// internal/report/units.go
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// DurationUnits lists the accepted durationUnit settings. auto picks the
+// largest unit in which every query's average is at least 1, so fast OLTP
+// queries are shown in microseconds rather than as 0.00 ms.
+var DurationUnits = []string{"ms", "us", "ns", "auto"}
+
+// durationUnit renders durations in one unit across a report.
+type durationUnit struct {
+	name   string // ASCII name used in CSV column headers
+	label  string // Display label
+	scale  time.Duration
+	digits int
+	auto   bool // Single values outside tables pick their own unit
+}
+
+var (
+	unitMs = durationUnit{name: "ms", label: "ms", scale: time.Millisecond, digits: 2}
+	unitUs = durationUnit{name: "us", label: "µs", scale: time.Microsecond, digits: 2}
+	unitNs = durationUnit{name: "ns", label: "ns", scale: time.Nanosecond, digits: 0}
+)
+
+// ValidateDurationUnit returns an error if unit isn't one of DurationUnits.
+// An empty unit means ms.
+func ValidateDurationUnit(unit string) error {
+	if unit != "" && !slices.Contains(DurationUnits, unit) {
+		return fmt.Errorf("unknown duration unit %q (want %s)", unit, strings.Join(DurationUnits, ", "))
+	}
+	return nil
+}
+
+// reportUnit returns the unit durations in result's reports are shown in.
+func reportUnit(result model.TestResult) durationUnit {
+	switch result.Config.DurationUnit {
+	case "us":
+		return unitUs
+	case "ns":
+		return unitNs
+	case "auto":
+		fastest := time.Duration(0)
+		for _, q := range result.QueryResults {
+			if q.AvgDuration > 0 && (fastest == 0 || q.AvgDuration < fastest) {
+				fastest = q.AvgDuration
+			}
+		}
+		u := unitMs
+		switch {
+		case fastest == 0 || fastest >= time.Millisecond:
+		case fastest >= time.Microsecond:
+			u = unitUs
+		default:
+			u = unitNs
+		}
+		u.auto = true
+		return u
+	default:
+		return unitMs
+	}
+}
+
+// heading is the unit as shown in table column headings.
+func (u durationUnit) heading() string {
+	return strings.ToUpper(u.name)
+}
+
+// value returns d in the unit.
+func (u durationUnit) value(d time.Duration) float64 {
+	return float64(d) / float64(u.scale)
+}
+
+// fromMs converts one of the summary's millisecond figures to the unit.
+func (u durationUnit) fromMs(ms float64) float64 {
+	return ms * float64(time.Millisecond) / float64(u.scale)
+}
+
+// number formats d in the unit without a label, for table cells.
+func (u durationUnit) number(d time.Duration) string {
+	return fmt.Sprintf("%.*f", u.digits, u.value(d))
+}
+
+// numberMs formats a summary millisecond figure in the unit without a label.
+func (u durationUnit) numberMs(ms float64) string {
+	return fmt.Sprintf("%.*f", u.digits, u.fromMs(ms))
+}
+
+// notAvailable stands in for a percentile computed from too few executions.
+const notAvailable = "n/a"
+
+// p95 formats a query's p95 like number, or n/a when it has too few samples.
+func (u durationUnit) p95(q model.QueryResult) string {
+	if q.P95InsufficientSamples {
+		return notAvailable
+	}
+	return u.number(q.Percentile95)
+}
+
+// p99 formats a query's p99 like number, or n/a when it has too few samples.
+func (u durationUnit) p99(q model.QueryResult) string {
+	if q.P99InsufficientSamples {
+		return notAvailable
+	}
+	return u.number(q.Percentile99)
+}
+
+// format formats d with its label. With auto, standalone values choose
+// their own unit.
+func (u durationUnit) format(d time.Duration) string {
+	if u.auto {
+		return FormatDuration(d)
+	}
+	return u.number(d) + " " + u.label
+}
+
+// formatMs formats a summary millisecond figure with the unit's label.
+func (u durationUnit) formatMs(ms float64) string {
+	return u.numberMs(ms) + " " + u.label
+}
@@ -0,0 +1,217 @@
+// internal/report/writers.go
+package report
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/database"
+	"github.com/0xsj/fn-analyzer/internal/environment"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// Formats lists the report formats WriteReports understands.
+var Formats = []string{"json", "csv", "html", "md", "junit"}
+
+// DefaultFormats are written when no formats are configured.
+var DefaultFormats = []string{"json", "csv"}
+
+var writers = map[string]func(model.TestResult, string) error{
+	"json":  SaveJSON,
+	"csv":   SaveCSV,
+	"html":  SaveHTML,
+	"md":    SaveMarkdown,
+	"junit": SaveJUnit,
+}
+
+// ValidateFormats returns an error naming the first unknown format.
+func ValidateFormats(formats []string) error {
+	for _, f := range formats {
+		if _, ok := writers[f]; !ok {
+			return fmt.Errorf("unknown report format %q (want %s)", f, strings.Join(Formats, ", "))
+		}
+	}
+	return nil
+}
+
+// WriteReports writes result to outputDir in each of the given formats, or in
+// DefaultFormats if none are given.
+func WriteReports(result model.TestResult, outputDir string, formats []string) error {
+	if len(formats) == 0 {
+		formats = DefaultFormats
+	}
+	if err := ValidateFormats(formats); err != nil {
+		return err
+	}
+
+	for _, f := range formats {
+		if err := writers[f](result, outputDir); err != nil {
+			return fmt.Errorf("error saving %s report: %w", f, err)
+		}
+	}
+	return nil
+}
+
+// writeReportFile writes data to filename, gzipped when compress is set.
+func writeReportFile(filename string, data []byte, compress bool) error {
+	if !compress {
+		return os.WriteFile(filename, data, 0644)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readReportFile reads a report file, decompressing it if its name ends in
+// .gz.
+func readReportFile(path string) ([]byte, error) {
+	if !strings.HasSuffix(path, ".gz") {
+		return os.ReadFile(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
+
+// reportName describes one report file to be named by the output name
+// template.
+type reportName struct {
+	kind      string
+	label     string
+	format    string
+	ext       string
+	timestamp time.Time
+	gitCommit string
+}
+
+// resultReportName names a report of the given kind and format for result.
+func resultReportName(result model.TestResult, kind, format, ext string) reportName {
+	label := result.Label
+	if label == "" {
+		label = "test"
+	}
+	if result.Config.CompressReports && (format == "json" || format == "csv") {
+		ext += ".gz"
+	}
+	return reportName{kind: kind, label: label, format: format, ext: ext, timestamp: result.Timestamp, gitCommit: reportCommit(result)}
+}
+
+// reportCommit is the commit a report's name refers to: the code under test
+// when given, otherwise the analyzer's working directory.
+func reportCommit(result model.TestResult) string {
+	if result.Metadata.GitSHA != "" {
+		return result.Metadata.GitSHA
+	}
+	return result.Environment.GitCommit
+}
+
+// reportPath renders name with the output name template under outputDir,
+// creating any directories the template introduces. If the file already
+// exists a -2, -3, ... counter is appended instead of overwriting it.
+func reportPath(outputDir, nameTemplate string, name reportName) (string, error) {
+	tmpl, err := config.ParseOutputNameTemplate(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid outputNameTemplate: %w", err)
+	}
+
+	timestamp := name.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	gitSHA := environment.GitInfo{Commit: name.gitCommit}.ShortCommit()
+	if gitSHA == "" {
+		gitSHA = "nogit"
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, config.OutputNameFields{
+		Kind:      name.kind,
+		Label:     name.label,
+		Timestamp: timestamp.Format("20060102-150405"),
+		Format:    name.format,
+		Hostname:  hostname,
+		Driver:    database.DriverName,
+		GitSHA:    gitSHA,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering report name: %w", err)
+	}
+
+	base := filepath.Join(outputDir, b.String())
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return "", fmt.Errorf("error creating report directory: %w", err)
+	}
+
+	path := base + "." + name.ext
+	for n := 2; fileExists(path); n++ {
+		path = fmt.Sprintf("%s-%d.%s", base, n, name.ext)
+	}
+	return path, nil
+}
+
+// CreateRunDir creates the directory holding every file of one run,
+// <outputDir>/<label>-<timestamp>, adding a -2, -3, ... suffix if a run
+// started in the same second already has one.
+func CreateRunDir(outputDir, label string, start time.Time) (string, error) {
+	if label == "" {
+		label = "test"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Join(outputDir, label+"-"+start.Format("20060102-150405"))
+	dir := base
+	for n := 2; ; n++ {
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		dir = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
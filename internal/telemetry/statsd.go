@@ -0,0 +1,235 @@
+// internal/telemetry/statsd.go
+package telemetry
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xsj/fn-analyzer/internal/config"
+	"github.com/0xsj/fn-analyzer/internal/model"
+)
+
+// statsdQueueSize is how many metrics can wait for the sender before new ones
+// are dropped.
+const statsdQueueSize = 8192
+
+// StatsD sends execution metrics to a statsd agent. Recording never blocks:
+// metrics are queued for a goroutine that writes them over UDP, and dropped
+// when the queue is full.
+type StatsD struct {
+	cfg     config.StatsD
+	address string
+	conn    net.Conn
+	queue   chan string
+	done    chan struct{}
+	stop    chan struct{}
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+
+	mu     sync.Mutex
+	window map[string]*errorWindow // Executions per query since the last gauges
+}
+
+// errorWindow counts one query's executions over a flush window.
+type errorWindow struct {
+	executions int
+	errors     int
+}
+
+// StartStatsD starts sending to the agent cfg names, or returns nil when
+// cfg isn't enabled. Close it to flush the queue and get the counts for the
+// report.
+func StartStatsD(cfg config.StatsD) (*StatsD, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if len(cfg.Tags) > 0 && !cfg.DatadogTags {
+		log.Printf("Warning: statsd tags are only sent with datadogTags; ignoring %d tags", len(cfg.Tags))
+	}
+
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	// Dialing UDP only resolves the address; nothing is sent until a metric is.
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to statsd agent %s: %w", address, err)
+	}
+
+	s := &StatsD{
+		cfg:     cfg,
+		address: address,
+		conn:    conn,
+		queue:   make(chan string, statsdQueueSize),
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		window:  make(map[string]*errorWindow),
+	}
+	go s.send()
+	go s.flushErrorRates(time.Duration(cfg.FlushSeconds * float64(time.Second)))
+
+	log.Printf("Sending execution metrics to statsd at %s", address)
+	return s, nil
+}
+
+// Execution records one finished execution of query: its duration as a
+// timing, and its outcome towards the query's error rate. It is safe for
+// concurrent use.
+func (s *StatsD) Execution(query, complexity string, execution model.QueryExecution) {
+	status := "ok"
+	if execution.Error != nil {
+		status = "error"
+	}
+
+	ms := strconv.FormatFloat(float64(execution.Duration)/float64(time.Millisecond), 'f', 3, 64)
+	if s.cfg.DatadogTags {
+		s.enqueue(s.metric("execution.duration", ms, "ms",
+			"query:"+tagValue(query), "complexity:"+complexity, "status:"+status))
+	} else {
+		s.enqueue(s.metric(metricSegment(query)+".duration", ms, "ms"))
+	}
+
+	s.mu.Lock()
+	w := s.window[query]
+	if w == nil {
+		w = &errorWindow{}
+		s.window[query] = w
+	}
+	w.executions++
+	if execution.Error != nil {
+		w.errors++
+	}
+	s.mu.Unlock()
+}
+
+// Close sends the last error rates, waits for the queue to drain and
+// returns what became of the run's metrics.
+func (s *StatsD) Close() model.StatsDEmission {
+	close(s.stop)
+	<-s.done
+	if err := s.conn.Close(); err != nil {
+		log.Printf("Warning: error closing statsd connection: %v", err)
+	}
+
+	emission := model.StatsDEmission{
+		Address: s.address,
+		Sent:    s.sent.Load(),
+		Dropped: s.dropped.Load(),
+		Failed:  s.failed.Load(),
+	}
+	if emission.Lost() > 0 {
+		log.Printf("Warning: %d of %d statsd metrics were not sent (%d dropped, %d failed)",
+			emission.Lost(), emission.Sent+emission.Lost(), emission.Dropped, emission.Failed)
+	}
+	return emission
+}
+
+// flushErrorRates sends a gauge of each query's error rate, and the run's
+// overall rate, for every window of interval until Close, then closes the
+// queue so the sender can finish.
+func (s *StatsD) flushErrorRates(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sendErrorRates()
+		case <-s.stop:
+			s.sendErrorRates()
+			close(s.queue)
+			return
+		}
+	}
+}
+
+// sendErrorRates sends the gauges for the window that just ended and starts
+// a new one. Queries that didn't run in the window keep their last value.
+func (s *StatsD) sendErrorRates() {
+	s.mu.Lock()
+	window := s.window
+	s.window = make(map[string]*errorWindow)
+	s.mu.Unlock()
+
+	var total errorWindow
+	for query, w := range window {
+		total.executions += w.executions
+		total.errors += w.errors
+		rate := formatRate(w)
+		if s.cfg.DatadogTags {
+			s.enqueue(s.metric("error_rate", rate, "g", "query:"+tagValue(query)))
+		} else {
+			s.enqueue(s.metric(metricSegment(query)+".error_rate", rate, "g"))
+		}
+	}
+	if total.executions > 0 {
+		s.enqueue(s.metric("run.error_rate", formatRate(&total), "g"))
+	}
+}
+
+// send writes queued metrics to the agent until the queue is closed.
+func (s *StatsD) send() {
+	defer close(s.done)
+	for metric := range s.queue {
+		if _, err := s.conn.Write([]byte(metric)); err != nil {
+			s.failed.Add(1)
+			continue
+		}
+		s.sent.Add(1)
+	}
+}
+
+// enqueue queues metric for the sender, or drops it if the queue is full.
+func (s *StatsD) enqueue(metric string) {
+	select {
+	case s.queue <- metric:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// metric formats one statsd line. tags, and the configured tags, are only
+// added with datadogTags.
+func (s *StatsD) metric(name, value, kind string, tags ...string) string {
+	var b strings.Builder
+	if s.cfg.Prefix != "" {
+		b.WriteString(s.cfg.Prefix)
+		b.WriteByte('.')
+	}
+	fmt.Fprintf(&b, "%s:%s|%s", name, value, kind)
+	if s.cfg.DatadogTags {
+		tags = append(tags, s.cfg.Tags...)
+		if len(tags) > 0 {
+			b.WriteString("|#")
+			b.WriteString(strings.Join(tags, ","))
+		}
+	}
+	return b.String()
+}
+
+func formatRate(w *errorWindow) string {
+	return strconv.FormatFloat(float64(w.errors)/float64(w.executions), 'f', 4, 64)
+}
+
+// metricSegment makes a query name usable as one segment of a metric name.
+func metricSegment(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// tagValue strips the characters that end a DogStatsD tag from a tag value.
+func tagValue(value string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace(value)
+}
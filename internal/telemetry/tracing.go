@@ -0,0 +1,79 @@
+// internal/telemetry/tracing.go
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// shutdownTimeout bounds how long the end of a run waits for the last spans
+// to reach the collector.
+const shutdownTimeout = 5 * time.Second
+
+// TracingEnabled reports whether the standard OpenTelemetry environment
+// variables ask for traces to be exported: an OTLP endpoint is set and the
+// SDK isn't disabled.
+func TracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// StartTracing returns a tracer whose spans are exported over OTLP/HTTP to
+// the collector named by the OTEL_EXPORTER_OTLP_* environment variables, and
+// a function that flushes and stops it. Without an endpoint it returns a nil
+// tracer, and the run creates no spans at all.
+//
+// Spans are queued and exported in batches off the run's goroutines. When the
+// collector is slow or unreachable the queue fills and further spans are
+// dropped; executions never wait for it.
+func StartTracing(ctx context.Context, version string) (trace.Tracer, func(), error) {
+	if !TracingEnabled() {
+		return nil, func() {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "fn-analyzer"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		log.Printf("Warning: incomplete trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("Warning: couldn't flush traces: %v", err)
+		}
+	}
+
+	log.Printf("Exporting execution traces over OTLP")
+	return provider.Tracer("github.com/0xsj/fn-analyzer"), stop, nil
+}
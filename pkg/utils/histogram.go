@@ -0,0 +1,152 @@
+// pkg/utils/histogram.go
+package utils
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// histogramSubBits sets the histogram's resolution: every power of two is
+// split into 2^histogramSubBits buckets, so a value read back from its
+// bucket's midpoint is within 0.4% of the value recorded.
+const histogramSubBits = 7
+
+// Histogram summarizes durations in log-linear buckets, with the count,
+// sum, spread and extremes kept exactly. Histograms merge by adding them up,
+// so samples recorded in several places, such as a distributed run's
+// workers, combine into the statistics of all of them without every
+// duration being sent. The zero value is empty and ready to use.
+type Histogram struct {
+	Buckets map[int]int   `json:"buckets"` // Durations per bucket index
+	Count   int           `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	M2      float64       `json:"m2"` // Sum of squared deviations from the mean, in ns²
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+}
+
+// histogramBucket returns the index of the bucket holding d: values below
+// 2^histogramSubBits ns have a bucket each, larger ones share a bucket with
+// those that agree in their leading histogramSubBits+1 bits.
+func histogramBucket(d time.Duration) int {
+	v := uint64(max(d, 0))
+	if v < 1<<histogramSubBits {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histogramSubBits - 1
+	return shift<<histogramSubBits + int(v>>shift)
+}
+
+// histogramMidpoint returns the middle of bucket i.
+func histogramMidpoint(i int) time.Duration {
+	if i < 1<<histogramSubBits {
+		return time.Duration(i)
+	}
+	shift := i>>histogramSubBits - 1
+	lower := int64(i-shift<<histogramSubBits) << shift
+	return time.Duration(lower + int64(1)<<shift/2)
+}
+
+// Add records d.
+func (h *Histogram) Add(d time.Duration) {
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int)
+	}
+	h.Buckets[histogramBucket(d)]++
+
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if h.Count == 0 || d > h.Max {
+		h.Max = d
+	}
+
+	// Welford's update keeps the spread accurate over long runs, where
+	// summing squares of nanoseconds would lose it.
+	before := h.exactMean()
+	h.Count++
+	h.Sum += d
+	h.M2 += (float64(d) - before) * (float64(d) - h.exactMean())
+}
+
+// Merge adds other's durations to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if h.Count == 0 {
+		h.Min, h.Max = other.Min, other.Max
+	}
+	h.Min = min(h.Min, other.Min)
+	h.Max = max(h.Max, other.Max)
+
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int, len(other.Buckets))
+	}
+	for i, n := range other.Buckets {
+		h.Buckets[i] += n
+	}
+
+	// Chan et al.'s pairwise combination of the two spreads.
+	delta := other.exactMean() - h.exactMean()
+	n, m := float64(h.Count), float64(other.Count)
+	h.M2 += other.M2 + delta*delta*n*m/(n+m)
+	h.Count += other.Count
+	h.Sum += other.Sum
+}
+
+func (h *Histogram) exactMean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Count)
+}
+
+// Stats summarizes the recorded durations like CalculateStatsWith. The
+// count, mean, standard deviation, minimum and maximum are exact; the
+// percentiles are read from bucket midpoints and kept within the extremes.
+func (h *Histogram) Stats(method PercentileMethod) Stats {
+	if h == nil || h.Count == 0 {
+		return Stats{}
+	}
+
+	indexes := make([]int, 0, len(h.Buckets))
+	for i := range h.Buckets {
+		indexes = append(indexes, i)
+	}
+	slices.Sort(indexes)
+
+	at := func(rank int) time.Duration {
+		switch rank {
+		case 0:
+			return h.Min
+		case h.Count - 1:
+			return h.Max
+		}
+		seen := 0
+		for _, i := range indexes {
+			if seen += h.Buckets[i]; rank < seen {
+				return min(max(histogramMidpoint(i), h.Min), h.Max)
+			}
+		}
+		return h.Max
+	}
+
+	var stdDev time.Duration
+	if h.Count > 1 {
+		stdDev = time.Duration(math.Sqrt(h.M2 / float64(h.Count-1)))
+	}
+
+	return Stats{
+		Min:     h.Min,
+		Max:     h.Max,
+		Mean:    h.Sum / time.Duration(h.Count),
+		Median:  percentileOfRanks(h.Count, at, 0.5, method),
+		StdDev:  stdDev,
+		P95:     percentileOfRanks(h.Count, at, 0.95, method),
+		P99:     percentileOfRanks(h.Count, at, 0.99, method),
+		Samples: h.Count,
+	}
+}
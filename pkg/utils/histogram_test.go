@@ -0,0 +1,86 @@
+// pkg/utils/histogram_test.go
+package utils
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogramBucketResolution(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 127, 128, 129, 255, 256, 1000, 999_999, time.Millisecond, 3 * time.Second, time.Hour} {
+		i := histogramBucket(d)
+		if histogramBucket(d+1) < i {
+			t.Errorf("bucket of %v is %d, after the next value's", d, i)
+		}
+		mid := histogramMidpoint(i)
+		if histogramBucket(mid) != i {
+			t.Errorf("midpoint %v of bucket %d for %v lies in bucket %d", mid, i, d, histogramBucket(mid))
+		}
+		if diff := float64(mid-d) / float64(max(d, 1)); diff > 0.004 || diff < -0.004 {
+			t.Errorf("%v reads back as %v, %.2f%% off", d, mid, diff*100)
+		}
+	}
+}
+
+func TestHistogramStats(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	durations := make([]time.Duration, 5000)
+	for i := range durations {
+		// Long-tailed, from tens of microseconds to a few seconds.
+		durations[i] = time.Duration(rng.ExpFloat64() * float64(20*time.Millisecond))
+	}
+
+	var h Histogram
+	for _, d := range durations {
+		h.Add(d)
+	}
+
+	for _, method := range []PercentileMethod{PercentileLinear, PercentileNearestRank} {
+		want := CalculateStatsWith(durations, method)
+		got := h.Stats(method)
+
+		if got.Min != want.Min || got.Max != want.Max || got.Mean != want.Mean || got.Samples != want.Samples {
+			t.Errorf("%s: min/max/mean/samples = %v/%v/%v/%d, want %v/%v/%v/%d exactly", method,
+				got.Min, got.Max, got.Mean, got.Samples, want.Min, want.Max, want.Mean, want.Samples)
+		}
+		if diff := got.StdDev - want.StdDev; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("%s: stddev = %v, want %v", method, got.StdDev, want.StdDev)
+		}
+		for _, p := range []struct {
+			name      string
+			got, want time.Duration
+		}{{"median", got.Median, want.Median}, {"p95", got.P95, want.P95}, {"p99", got.P99, want.P99}} {
+			if diff := float64(p.got-p.want) / float64(p.want); diff > 0.005 || diff < -0.005 {
+				t.Errorf("%s: %s = %v, want %v to within 0.5%%", method, p.name, p.got, p.want)
+			}
+		}
+	}
+}
+
+// Merging the histograms of parts of a sample gives the histogram of the
+// whole, whichever way it was split.
+func TestHistogramMerge(t *testing.T) {
+	durations := millis(3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5, 8, 9, 7, 9)
+
+	var whole, merged Histogram
+	for _, d := range durations {
+		whole.Add(d)
+	}
+	for _, part := range [][]time.Duration{durations[:2], durations[2:9], nil, durations[9:]} {
+		var h Histogram
+		for _, d := range part {
+			h.Add(d)
+		}
+		merged.Merge(&h)
+	}
+	merged.Merge(nil)
+
+	if got, want := merged.Stats(PercentileLinear), whole.Stats(PercentileLinear); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged stats = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(merged.Buckets, whole.Buckets) {
+		t.Errorf("merged buckets differ from the whole sample's")
+	}
+}
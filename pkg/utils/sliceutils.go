@@ -3,40 +3,91 @@ package utils
 
 import (
 	"math"
-	"sort"
+	"slices"
 	"time"
 )
 
+// PercentileMethod selects how a percentile is read off a sample.
+type PercentileMethod string
+
+const (
+	// PercentileLinear interpolates linearly between the two closest ranks
+	// (Hyndman and Fan's R-7, as in R, NumPy and Excel's PERCENTILE.INC), so
+	// the median of an even-length sample is the mean of its middle two and
+	// a p95 of 20 samples lies between the 19th and the 20th.
+	PercentileLinear PercentileMethod = "linear"
+	// PercentileNearestRank takes the value at index floor(n·p), as earlier
+	// versions did. It reads high on small samples: the p95 of 20 samples is
+	// their maximum.
+	PercentileNearestRank PercentileMethod = "nearest-rank"
+)
+
+// CalculatePercentile returns the percentile (0-100) of durations by linear
+// interpolation. durations is left in its original order.
 func CalculatePercentile(durations []time.Duration, percentile float64) time.Duration {
+	return CalculatePercentileWith(durations, percentile, PercentileLinear)
+}
+
+// CalculatePercentileWith is CalculatePercentile with the given method; an
+// empty method is PercentileLinear.
+func CalculatePercentileWith(durations []time.Duration, percentile float64, method PercentileMethod) time.Duration {
+	return CalculatePercentileInPlace(slices.Clone(durations), percentile, method)
+}
+
+// CalculatePercentileInPlace is CalculatePercentileWith without the copy: it
+// sorts durations in place. Use it only on a slice the caller owns and no
+// longer needs in its original order.
+func CalculatePercentileInPlace(durations []time.Duration, percentile float64, method PercentileMethod) time.Duration {
 	if len(durations) == 0 {
 		return 0
 	}
 
-	sort.Slice(durations, func(i, j int) bool {
-		return durations[i] < durations[j]
-	})
+	slices.Sort(durations)
+	return percentileOfSorted(durations, percentile/100, method)
+}
+
+// percentileOfSorted returns the p-quantile (0-1) of sorted, which must not
+// be empty.
+func percentileOfSorted(sorted []time.Duration, p float64, method PercentileMethod) time.Duration {
+	return percentileOfRanks(len(sorted), func(i int) time.Duration { return sorted[i] }, p, method)
+}
 
-	idx := int(math.Floor(float64(len(durations)) * percentile / 100.0))
+// percentileOfRanks returns the p-quantile (0-1) of n > 0 values, where at
+// returns the value of rank i in ascending order.
+func percentileOfRanks(n int, at func(i int) time.Duration, p float64, method PercentileMethod) time.Duration {
+	p = math.Min(math.Max(p, 0), 1)
 
-	if idx >= len(durations) {
-		idx = len(durations) - 1
+	if method == PercentileNearestRank {
+		return at(min(int(float64(n)*p), n-1))
 	}
 
-	return durations[idx]
+	rank := float64(n-1) * p
+	lo := int(math.Floor(rank))
+	if lo >= n-1 {
+		return at(n - 1)
+	}
+	frac := rank - float64(lo)
+	low := at(lo)
+	return low + time.Duration(math.Round(frac*float64(at(lo+1)-low)))
 }
 
+// CalculateStandardDeviation returns the sample standard deviation of
+// durations around mean, dividing by n-1: a run's executions are a sample of
+// the query's latency, not all of it. It is 0 for fewer than two durations.
 func CalculateStandardDeviation(durations []time.Duration, mean time.Duration) time.Duration {
 	if len(durations) <= 1 {
 		return 0
 	}
 
-	var sum int64
+	// Summed as int64 nanoseconds, the squares overflow after a few dozen
+	// executions half a second from the mean.
+	var sum float64
 	for _, d := range durations {
-		diff := d - mean
-		sum += diff.Nanoseconds() * diff.Nanoseconds()
+		diff := float64(d - mean)
+		sum += diff * diff
 	}
 
-	variance := float64(sum) / float64(len(durations)-1)
+	variance := sum / float64(len(durations)-1)
 	return time.Duration(math.Sqrt(variance))
 }
 
@@ -51,14 +102,28 @@ type Stats struct {
 	Samples int
 }
 
+// CalculateStats summarizes durations, with percentiles by linear
+// interpolation and the sample standard deviation of
+// CalculateStandardDeviation. durations is left in its original order.
 func CalculateStats(durations []time.Duration) Stats {
+	return CalculateStatsWith(durations, PercentileLinear)
+}
+
+// CalculateStatsWith is CalculateStats with the given percentile method; an
+// empty method is PercentileLinear.
+func CalculateStatsWith(durations []time.Duration, method PercentileMethod) Stats {
+	return CalculateStatsInPlace(slices.Clone(durations), method)
+}
+
+// CalculateStatsInPlace is CalculateStatsWith without the copy: it sorts
+// durations in place. Use it only on a slice the caller owns and no longer
+// needs in its original order.
+func CalculateStatsInPlace(durations []time.Duration, method PercentileMethod) Stats {
 	if len(durations) == 0 {
 		return Stats{}
 	}
 
-	sort.Slice(durations, func(i, j int) bool {
-		return durations[i] < durations[j]
-	})
+	slices.Sort(durations)
 
 	var total time.Duration
 	for _, d := range durations {
@@ -67,37 +132,14 @@ func CalculateStats(durations []time.Duration) Stats {
 
 	mean := total / time.Duration(len(durations))
 
-	var sumSquares int64
-	for _, d := range durations {
-		diff := d - mean
-		sumSquares += diff.Nanoseconds() * diff.Nanoseconds()
-	}
-
-	variance := float64(sumSquares) / float64(len(durations))
-	stdDev := time.Duration(math.Sqrt(variance))
-
-	p50Idx := int(float64(len(durations)) * 0.5)
-	p95Idx := int(float64(len(durations)) * 0.95)
-	p99Idx := int(float64(len(durations)) * 0.99)
-
-	if p50Idx >= len(durations) {
-		p50Idx = len(durations) - 1
-	}
-	if p95Idx >= len(durations) {
-		p95Idx = len(durations) - 1
-	}
-	if p99Idx >= len(durations) {
-		p99Idx = len(durations) - 1
-	}
-
 	return Stats{
 		Min:     durations[0],
 		Max:     durations[len(durations)-1],
 		Mean:    mean,
-		Median:  durations[p50Idx],
-		StdDev:  stdDev,
-		P95:     durations[p95Idx],
-		P99:     durations[p99Idx],
+		Median:  percentileOfSorted(durations, 0.5, method),
+		StdDev:  CalculateStandardDeviation(durations, mean),
+		P95:     percentileOfSorted(durations, 0.95, method),
+		P99:     percentileOfSorted(durations, 0.99, method),
 		Samples: len(durations),
 	}
 }
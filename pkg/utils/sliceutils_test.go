@@ -0,0 +1,129 @@
+// pkg/utils/sliceutils_test.go
+package utils
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCalculateStandardDeviation(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		durations := make([]time.Duration, len(values))
+		for i, v := range values {
+			durations[i] = time.Duration(v) * time.Millisecond
+		}
+		return durations
+	}
+
+	// Long executions far from the mean overflowed an int64 sum of squares.
+	var long []time.Duration
+	for i := range 50 {
+		if i%2 == 0 {
+			long = append(long, 500*time.Millisecond)
+		} else {
+			long = append(long, 1500*time.Millisecond)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		mean      time.Duration
+		want      time.Duration
+	}{
+		{"empty", nil, 0, 0},
+		{"one", ms(5), 5 * time.Millisecond, 0},
+		{"textbook sample", ms(2, 4, 4, 4, 5, 5, 7, 9), 5 * time.Millisecond, time.Duration(math.Sqrt(32.0/7) * float64(time.Millisecond))},
+		{"long executions", long, time.Second, time.Duration(math.Sqrt(50*0.25/49) * float64(time.Second))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateStandardDeviation(tt.durations, tt.mean)
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("CalculateStandardDeviation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The NIST/SEMATECH e-Handbook's percentile example (section 7.2.6.2): 12
+// resistivity measurements, read here as milliseconds.
+var nistSample = []time.Duration{
+	95_177_200, 95_156_700, 95_193_700, 95_195_900, 95_144_200, 95_061_000,
+	95_159_100, 95_119_500, 95_106_500, 95_092_500, 95_199_000, 95_168_200,
+}
+
+func TestCalculatePercentileWith(t *testing.T) {
+	one := []time.Duration{42 * time.Millisecond}
+
+	tests := []struct {
+		name       string
+		durations  []time.Duration
+		percentile float64
+		method     PercentileMethod
+		want       time.Duration
+	}{
+		// Linear is R-7, Excel's PERCENTILE.INC: the handbook quotes 95.1957
+		// for its p90, against 95.1981 by its own (R-6) definition.
+		{"nist p90 linear", nistSample, 90, PercentileLinear, 95_195_680},
+		{"nist p50 linear", nistSample, 50, PercentileLinear, 95_157_900},
+		{"nist p25 linear", nistSample, 25, PercentileLinear, 95_116_250},
+		{"nist p0 linear", nistSample, 0, PercentileLinear, 95_061_000},
+		{"nist p100 linear", nistSample, 100, PercentileLinear, 95_199_000},
+		{"empty method is linear", nistSample, 90, "", 95_195_680},
+		{"nist p90 nearest-rank", nistSample, 90, PercentileNearestRank, 95_195_900},
+		{"nist p50 nearest-rank", nistSample, 50, PercentileNearestRank, 95_159_100},
+		{"nist p25 nearest-rank", nistSample, 25, PercentileNearestRank, 95_119_500},
+		{"nist p0 nearest-rank", nistSample, 0, PercentileNearestRank, 95_061_000},
+		{"nist p100 nearest-rank", nistSample, 100, PercentileNearestRank, 95_199_000},
+		{"n=1 p0 linear", one, 0, PercentileLinear, one[0]},
+		{"n=1 p50 linear", one, 50, PercentileLinear, one[0]},
+		{"n=1 p100 linear", one, 100, PercentileLinear, one[0]},
+		{"n=1 p0 nearest-rank", one, 0, PercentileNearestRank, one[0]},
+		{"n=1 p100 nearest-rank", one, 100, PercentileNearestRank, one[0]},
+		{"below 0 clamps", nistSample, -5, PercentileLinear, 95_061_000},
+		{"above 100 clamps", nistSample, 150, PercentileNearestRank, 95_199_000},
+		{"empty", nil, 50, PercentileLinear, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculatePercentileWith(tt.durations, tt.percentile, tt.method); got != tt.want {
+				t.Errorf("CalculatePercentileWith(%v, %q) = %v, want %v", tt.percentile, tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculatePreservesInputOrder(t *testing.T) {
+	input := []time.Duration{5, 1, 4, 2, 3}
+	durations := slices.Clone(input)
+
+	if got := CalculatePercentile(durations, 50); got != 3 {
+		t.Errorf("CalculatePercentile() = %v, want 3ns", got)
+	}
+	if got := CalculatePercentileWith(durations, 100, PercentileNearestRank); got != 5 {
+		t.Errorf("CalculatePercentileWith() = %v, want 5ns", got)
+	}
+	if got := CalculateStats(durations); got.Min != 1 || got.Max != 5 || got.Median != 3 {
+		t.Errorf("CalculateStats() = %+v, want min 1ns, max 5ns, median 3ns", got)
+	}
+	CalculateStatsWith(durations, PercentileNearestRank)
+
+	if !slices.Equal(durations, input) {
+		t.Errorf("durations reordered to %v, want %v", durations, input)
+	}
+}
+
+func TestCalculateInPlaceSorts(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3}
+	if got := CalculateStatsInPlace(durations, PercentileLinear); got.Median != 3 || got.Samples != 5 {
+		t.Errorf("CalculateStatsInPlace() = %+v, want median 3ns over 5 samples", got)
+	}
+	if !slices.IsSorted(durations) {
+		t.Errorf("durations = %v, want sorted in place", durations)
+	}
+}
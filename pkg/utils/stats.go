@@ -0,0 +1,176 @@
+// pkg/utils/stats.go
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MannWhitneyU compares two latency samples without assuming a distribution.
+// It returns the U statistic (the smaller of U1 and U2) and the two-sided
+// p-value from the normal approximation with tie and continuity corrections.
+// The approximation is reasonable once each sample has roughly 8 or more
+// values; with an empty sample it returns (0, 1).
+func MannWhitneyU(before, after []time.Duration) (uStatistic float64, pValue float64) {
+	n1, n2 := len(before), len(after)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type sample struct {
+		value time.Duration
+		first bool
+	}
+
+	combined := make([]sample, 0, n1+n2)
+	for _, d := range before {
+		combined = append(combined, sample{d, true})
+	}
+	for _, d := range after {
+		combined = append(combined, sample{d, false})
+	}
+	sort.Slice(combined, func(i, j int) bool {
+		return combined[i].value < combined[j].value
+	})
+
+	var rankSum1, tieTerm float64
+	for i := 0; i < len(combined); {
+		j := i
+		for j < len(combined) && combined[j].value == combined[i].value {
+			j++
+		}
+
+		// Tied values share the average of the ranks they span (1-based)
+		avgRank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if combined[k].first {
+				rankSum1 += avgRank
+			}
+		}
+
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+
+	u1 := rankSum1 - fn1*(fn1+1)/2
+	u2 := fn1*fn2 - u1
+	uStatistic = math.Min(u1, u2)
+
+	mean := fn1 * fn2 / 2
+	variance := fn1 * fn2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return uStatistic, 1
+	}
+
+	diff := math.Abs(u1-mean) - 0.5
+	if diff < 0 {
+		diff = 0
+	}
+	z := diff / math.Sqrt(variance)
+	pValue = math.Erfc(z / math.Sqrt2)
+
+	return uStatistic, pValue
+}
+
+// PearsonCorrelation returns the linear correlation coefficient of x and y,
+// between -1 and 1. It returns 0 when the slices differ in length, hold fewer
+// than two points, or either has no variance.
+func PearsonCorrelation(x, y []float64) float64 {
+	n := len(x)
+	if n != len(y) || n < 2 {
+		return 0
+	}
+
+	var meanX, meanY float64
+	for i := range n {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX, varY float64
+	for i := range n {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+
+	return cov / math.Sqrt(varX*varY)
+}
+
+// SpearmanCorrelation returns the rank correlation coefficient of x and y:
+// the Pearson correlation of their ranks, with tied values sharing the
+// average of the ranks they span. It measures whether y rises with x at all,
+// not whether it does so linearly, and isn't dominated by a single outlier.
+// It returns 0 in the same cases as PearsonCorrelation.
+func SpearmanCorrelation(x, y []float64) float64 {
+	if len(x) != len(y) {
+		return 0
+	}
+	return PearsonCorrelation(ranks(x), ranks(y))
+}
+
+// ranks returns the 1-based rank of each value, averaged over ties.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return values[order[a]] < values[order[b]]
+	})
+
+	ranked := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+		avgRank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			ranked[order[k]] = avgRank
+		}
+		i = j
+	}
+	return ranked
+}
+
+// LinearRegressionSlope returns the slope of the least-squares line through
+// the points (x[i], y[i]). It returns 0 when the slices differ in length, hold
+// fewer than two points, or x has no variance.
+func LinearRegressionSlope(x, y []float64) float64 {
+	n := len(x)
+	if n != len(y) || n < 2 {
+		return 0
+	}
+
+	var meanX, meanY float64
+	for i := range n {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX float64
+	for i := range n {
+		dx := x[i] - meanX
+		cov += dx * (y[i] - meanY)
+		varX += dx * dx
+	}
+	if varX == 0 {
+		return 0
+	}
+
+	return cov / varX
+}
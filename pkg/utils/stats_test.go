@@ -0,0 +1,87 @@
+// pkg/utils/stats_test.go
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func millis(values ...float64) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, v := range values {
+		durations[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return durations
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []time.Duration
+		wantU         float64
+		wantP         float64
+	}{
+		// R's ?wilcox.test example (Hollander & Wolfe, depression scale):
+		// W = 35. R reports p = 0.1103 one-sided without continuity
+		// correction; with it, two-sided, z = 9.5/sqrt(200/3).
+		{
+			name:   "R wilcox.test example",
+			before: millis(0.80, 0.83, 1.89, 1.04, 1.45, 1.38, 1.91, 1.64, 0.73, 1.46),
+			after:  millis(1.15, 0.88, 0.90, 0.74, 1.21),
+			wantU:  15,
+			wantP:  0.244624,
+		},
+		// Tied values share average ranks, and the ties (2, 4, 3 and 2
+		// values) shrink the variance by sum(t^3 - t) = 96, as in
+		// wilcox.test(x, y, exact = FALSE): W = 3.5, p = 0.0130.
+		{
+			name:   "ties",
+			before: millis(1, 2, 2, 3, 3, 3, 4),
+			after:  millis(3, 4, 4, 5, 5, 6),
+			wantU:  3.5,
+			wantP:  0.012999,
+		},
+		{
+			name:   "fully separated",
+			before: millis(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20),
+			after:  millis(21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37, 38, 39, 40),
+			wantU:  0,
+			wantP:  6.7956e-8,
+		},
+		{
+			name:   "identical samples",
+			before: millis(1, 2, 3, 4, 5),
+			after:  millis(1, 2, 3, 4, 5),
+			wantU:  12.5,
+			wantP:  1,
+		},
+		{
+			name:   "every value tied",
+			before: millis(2, 2, 2),
+			after:  millis(2, 2),
+			wantU:  3,
+			wantP:  1,
+		},
+		{"empty before", nil, millis(1, 2), 0, 1},
+		{"empty after", millis(1, 2), nil, 0, 1},
+		{"both empty", nil, nil, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, p := MannWhitneyU(tt.before, tt.after)
+			if u != tt.wantU {
+				t.Errorf("U = %v, want %v", u, tt.wantU)
+			}
+			if math.Abs(p-tt.wantP) > 1e-4*tt.wantP {
+				t.Errorf("p = %v, want %v", p, tt.wantP)
+			}
+
+			// The test is two-sided, so the order of the samples doesn't matter.
+			if u2, p2 := MannWhitneyU(tt.after, tt.before); u2 != u || math.Abs(p2-p) > 1e-12 {
+				t.Errorf("swapped samples give (%v, %v), want (%v, %v)", u2, p2, u, p)
+			}
+		})
+	}
+}